@@ -4,8 +4,6 @@
 package stun
 
 import (
-	"fmt"
-	"io"
 	"net"
 	"strconv"
 )
@@ -45,6 +43,24 @@ type OtherAddress struct {
 	Port int
 }
 
+// SourceAddress represents SOURCE-ADDRESS attribute of RFC 3489 servers,
+// the address that response was sent from.
+//
+// RFC 3489 Section 11.2.5.
+type SourceAddress struct {
+	IP   net.IP
+	Port int
+}
+
+// ChangedAddress represents CHANGED-ADDRESS attribute of RFC 3489 servers,
+// the address that response would be sent from with CHANGE-REQUEST.
+//
+// RFC 3489 Section 11.2.3.
+type ChangedAddress struct {
+	IP   net.IP
+	Port int
+}
+
 // AddTo adds ALTERNATE-SERVER attribute to message.
 func (s *AlternateServer) AddTo(m *Message) error {
 	a := (*MappedAddress)(s)
@@ -69,38 +85,23 @@ func (a *MappedAddress) GetFromAs(m *Message, t AttrType) error {
 	if err != nil {
 		return err
 	}
-	if len(value) <= 4 {
-		return io.ErrUnexpectedEOF
-	}
-	family := bin.Uint16(value[0:2])
-	if family != familyIPv6 && family != familyIPv4 {
-		return newDecodeErr("xor-mapped address", "family",
-			fmt.Sprintf("bad value %d", family),
-		)
-	}
-	ipLen := net.IPv4len
-	if family == familyIPv6 {
-		ipLen = net.IPv6len
-	}
-	// Ensuring len(a.IP) == ipLen and reusing a.IP.
-	if len(a.IP) < ipLen {
-		a.IP = a.IP[:cap(a.IP)]
-		for len(a.IP) < ipLen {
-			a.IP = append(a.IP, 0)
-		}
-	}
-	a.IP = a.IP[:ipLen]
-	for i := range a.IP {
-		a.IP[i] = 0
+	ipLen, err := addressLength(t, value)
+	if err != nil {
+		return err
 	}
+	a.IP = resizeIP(a.IP, ipLen)
 	a.Port = int(bin.Uint16(value[2:4]))
 	copy(a.IP, value[4:])
+	a.IP = normalizeIP(a.IP)
 
 	return nil
 }
 
 // AddToAs adds MAPPED-ADDRESS value to m as t attribute.
 func (a *MappedAddress) AddToAs(msg *Message, attrType AttrType) error {
+	if err := msg.checkMutable(); err != nil {
+		return err
+	}
 	var (
 		family = familyIPv4
 		ip     = a.IP
@@ -169,3 +170,124 @@ func (o *ResponseOrigin) GetFrom(m *Message) error {
 func (o ResponseOrigin) String() string {
 	return net.JoinHostPort(o.IP.String(), strconv.Itoa(o.Port))
 }
+
+// AddTo adds SOURCE-ADDRESS attribute to message.
+func (s *SourceAddress) AddTo(m *Message) error {
+	a := (*MappedAddress)(s)
+
+	return a.AddToAs(m, AttrSourceAddress)
+}
+
+// GetFrom decodes SOURCE-ADDRESS from message.
+func (s *SourceAddress) GetFrom(m *Message) error {
+	a := (*MappedAddress)(s)
+
+	return a.GetFromAs(m, AttrSourceAddress)
+}
+
+func (s SourceAddress) String() string {
+	return net.JoinHostPort(s.IP.String(), strconv.Itoa(s.Port))
+}
+
+// AddTo adds CHANGED-ADDRESS attribute to message.
+func (c *ChangedAddress) AddTo(m *Message) error {
+	a := (*MappedAddress)(c)
+
+	return a.AddToAs(m, AttrChangedAddress)
+}
+
+// GetFrom decodes CHANGED-ADDRESS from message.
+func (c *ChangedAddress) GetFrom(m *Message) error {
+	a := (*MappedAddress)(c)
+
+	return a.GetFromAs(m, AttrChangedAddress)
+}
+
+func (c ChangedAddress) String() string {
+	return net.JoinHostPort(c.IP.String(), strconv.Itoa(c.Port))
+}
+
+// getMappedAddress decodes XOR-MAPPED-ADDRESS of m into a, falling back
+// to MAPPED-ADDRESS, see Message.MappedAddress.
+func getMappedAddress(m *Message, a *XORMappedAddress) error {
+	addr, err := m.MappedAddress()
+	if err != nil {
+		return err
+	}
+	*a = NewXORMappedAddress(addr)
+
+	return nil
+}
+
+// ResponsePort represents RESPONSE-PORT attribute, the port that server
+// should send response to.
+//
+// RFC 5780 Section 7.5.
+type ResponsePort uint16
+
+const responsePortSize = 4 // port and padding
+
+// AddTo adds RESPONSE-PORT attribute to message.
+func (p ResponsePort) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	v := make([]byte, responsePortSize)
+	bin.PutUint16(v[0:2], uint16(p))
+	m.Add(AttrResponsePort, v)
+
+	return nil
+}
+
+// GetFrom decodes RESPONSE-PORT from message.
+func (p *ResponsePort) GetFrom(m *Message) error {
+	v, err := m.Get(AttrResponsePort)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrResponsePort, len(v), responsePortSize); err != nil {
+		return err
+	}
+	*p = ResponsePort(bin.Uint16(v[0:2]))
+
+	return nil
+}
+
+// maxMessageLength is the largest length of message attributes that is
+// multiple of 4 and fits into 16 bits of header.
+const maxMessageLength = 0xFFFF - 0xFFFF%padding
+
+// Padding represents PADDING attribute of n zero bytes, which is used to
+// make message large, e.g. for path MTU probing, see ProbeMTU.
+//
+// RFC 5780 Section 7.6.
+type Padding int
+
+// AddTo adds PADDING attribute to message. Returns error if size is
+// negative or message would be larger than allowed by length in header.
+func (p Padding) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	if p < 0 {
+		return ErrAttributeSizeInvalid
+	}
+	size := int(m.Length) + attributeHeaderSize + nearestPaddedValueLength(int(p))
+	if err := CheckOverflow(AttrPadding, size, maxMessageLength); err != nil {
+		return err
+	}
+	m.Add(AttrPadding, make([]byte, p))
+
+	return nil
+}
+
+// GetFrom decodes size of PADDING from message.
+func (p *Padding) GetFrom(m *Message) error {
+	v, err := m.Get(AttrPadding)
+	if err != nil {
+		return err
+	}
+	*p = Padding(len(v))
+
+	return nil
+}
@@ -147,6 +147,105 @@ func TestOtherAddress(t *testing.T) { //nolint:dupl
 	})
 }
 
+func TestRFC3489Addresses(t *testing.T) {
+	var (
+		source  = &SourceAddress{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 3478}
+		changed = &ChangedAddress{IP: net.IPv4(192, 0, 2, 2).To4(), Port: 3479}
+		m       = MustBuild(TransactionID, BindingSuccess, source, changed)
+	)
+	var gotSource SourceAddress
+	if err := gotSource.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if gotSource.String() != "192.0.2.1:3478" {
+		t.Errorf("unexpected SOURCE-ADDRESS: %s", gotSource)
+	}
+	var gotChanged ChangedAddress
+	if err := gotChanged.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if gotChanged.String() != "192.0.2.2:3479" {
+		t.Errorf("unexpected CHANGED-ADDRESS: %s", gotChanged)
+	}
+	if err := gotChanged.GetFrom(new(Message)); !errors.Is(err, ErrAttributeNotFound) {
+		t.Error("should be not found: ", err)
+	}
+}
+
+func TestGetMappedAddress(t *testing.T) {
+	for name, setter := range map[string]Setter{
+		"XOR":    &XORMappedAddress{IP: net.IPv4(192, 0, 2, 1), Port: 3478},
+		"Legacy": &MappedAddress{IP: net.IPv4(192, 0, 2, 1), Port: 3478},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var addr XORMappedAddress
+			if err := getMappedAddress(MustBuild(TransactionID, BindingSuccess, setter), &addr); err != nil {
+				t.Fatal(err)
+			}
+			if addr.String() != "192.0.2.1:3478" {
+				t.Errorf("unexpected address: %s", addr)
+			}
+		})
+	}
+	var addr XORMappedAddress
+	if err := getMappedAddress(MustBuild(TransactionID, BindingSuccess), &addr); !errors.Is(err, ErrAttributeNotFound) {
+		t.Error("should be not found: ", err)
+	}
+}
+
+func TestResponsePort(t *testing.T) {
+	m := MustBuild(BindingRequest, ResponsePort(5412))
+	var port ResponsePort
+	if err := port.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if port != 5412 {
+		t.Errorf("unexpected port: %d", port)
+	}
+	if err := port.GetFrom(new(Message)); !errors.Is(err, ErrAttributeNotFound) {
+		t.Error("should be not found: ", err)
+	}
+	m = MustBuild(BindingRequest, RawAttribute{Type: AttrResponsePort, Value: []byte{1, 2}})
+	if err := port.GetFrom(m); !IsAttrSizeInvalid(err) {
+		t.Error("should be invalid size: ", err)
+	}
+}
+
+func TestPadding_AddTo(t *testing.T) {
+	m := MustBuild(BindingRequest, Padding(5), Fingerprint)
+	if len(m.Raw) != messageHeaderSize+attributeHeaderSize+8+attributeHeaderSize+fingerprintSize {
+		t.Errorf("unexpected message size: %d", len(m.Raw))
+	}
+	decoded := new(Message)
+	if err := Decode(m.Raw, decoded); err != nil {
+		t.Fatal(err)
+	}
+	var p Padding
+	if err := p.GetFrom(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if p != 5 {
+		t.Errorf("unexpected padding: %d", p)
+	}
+	if err := p.GetFrom(new(Message)); !errors.Is(err, ErrAttributeNotFound) {
+		t.Error("should be not found: ", err)
+	}
+	if err := Padding(-1).AddTo(New()); !IsAttrSizeInvalid(err) {
+		t.Error("should be invalid size: ", err)
+	}
+	largest := maxMessageLength - attributeHeaderSize
+	if m, err := Build(BindingRequest, Padding(largest)); err != nil || m.Length != maxMessageLength {
+		t.Errorf("largest padding should fit: %v", err)
+	}
+	if err := Padding(largest + 1).AddTo(New()); !IsAttrSizeOverflow(err) {
+		t.Error("should overflow: ", err)
+	}
+	m = MustBuild(BindingRequest, NewSoftware("software"))
+	if err := Padding(largest).AddTo(m); !IsAttrSizeOverflow(err) {
+		t.Error("should overflow with other attributes: ", err)
+	}
+}
+
 func BenchmarkMappedAddress_AddTo(b *testing.B) {
 	m := new(Message)
 	b.ReportAllocs()
@@ -176,3 +275,46 @@ func BenchmarkAlternateServer_AddTo(b *testing.B) {
 		m.Reset()
 	}
 }
+
+func TestMappedAddress_GetFrom_Family(t *testing.T) {
+	value := func(family uint16, ip net.IP) []byte {
+		v := make([]byte, 4+len(ip))
+		bin.PutUint16(v[0:2], family)
+		bin.PutUint16(v[2:4], 1234)
+		copy(v[4:], ip)
+
+		return v
+	}
+	for _, tc := range []struct {
+		name  string
+		value []byte
+		check func(error) bool
+	}{
+		{"BadFamily", value(0, net.IPv4(1, 2, 3, 4).To4()), func(err error) bool {
+			return errors.Is(err, ErrBadAddressFamily)
+		}},
+		{"IPv6Short", value(familyIPv6, net.IPv4(1, 2, 3, 4).To4()), IsAttrSizeInvalid},
+		{"IPv4Long", value(familyIPv4, net.IPv6loopback), IsAttrSizeOverflow},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(Message)
+			m.Add(AttrOtherAddress, tc.value)
+			var addr OtherAddress
+			if err := addr.GetFrom(m); !tc.check(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+	t.Run("IPv4Mapped", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrMappedAddress, value(familyIPv6, net.IPv4(192, 0, 2, 1)))
+		var addr MappedAddress
+		if err := addr.GetFrom(m); err != nil {
+			t.Fatal(err)
+		}
+		if len(addr.IP) != net.IPv4len || !addr.IP.Equal(net.IPv4(192, 0, 2, 1)) {
+			t.Errorf("unexpected address: %s (%d bytes)", addr, len(addr.IP))
+		}
+	})
+}
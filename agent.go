@@ -4,9 +4,16 @@
 package stun
 
 import (
+	"context"
 	"errors"
+	"io"
+	"net"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/pion/logging"
 )
 
 // NoopHandler just discards any event.
@@ -14,33 +21,168 @@ func NoopHandler() Handler {
 	return func(Event) {}
 }
 
+// AgentOption sets Agent option.
+type AgentOption func(a *Agent)
+
+// WithAgentLogger sets agent logger, that logs timed out transactions.
+// Defaults to pion/logging logger with "stun" scope, so logging can be
+// enabled via PION_LOG_* environment variables.
+func WithAgentLogger(log logging.LeveledLogger) AgentOption {
+	return func(a *Agent) {
+		a.log = log
+	}
+}
+
+// WithAgentClock sets Clock of agent that is used by CollectNow, system
+// clock by default.
+func WithAgentClock(clock Clock) AgentOption {
+	return func(a *Agent) {
+		a.clock = clock
+	}
+}
+
+// WithAgentTransactionIDSource sets source of transaction IDs returned by
+// Agent.NewTransactionID, crypto/rand by default. Source must be safe for
+// concurrent use if agent is shared by goroutines.
+func WithAgentTransactionIDSource(r io.Reader) AgentOption {
+	return func(a *Agent) {
+		a.ids = r
+	}
+}
+
+// WithAgentCollector sets collector that is started by
+// Agent.StartCollector, ticker with agent clock by default.
+func WithAgentCollector(c Collector) AgentOption {
+	return func(a *Agent) {
+		a.collector = c
+	}
+}
+
 // NewAgent initializes and returns new Agent with provided handler.
 // If h is nil, the NoopHandler will be used.
-func NewAgent(h Handler) *Agent {
+func NewAgent(h Handler, options ...AgentOption) *Agent {
 	if h == nil {
 		h = NoopHandler()
 	}
-	a := &Agent{
-		transactions: make(map[transactionID]agentTransaction),
-		handler:      h,
+	a := new(Agent)
+	for i := range a.shards {
+		a.shards[i].transactions = make(map[transactionID]agentTransaction)
+		a.shards[i].handler = h
+	}
+	for _, o := range options {
+		o(a)
+	}
+	if a.log == nil {
+		a.log = defaultLogger()
+	}
+	if a.clock == nil {
+		a.clock = systemClock()
 	}
 
 	return a
 }
 
+// defaultLogger returns logger that is used if logger is not set
+// explicitly.
+func defaultLogger() logging.LeveledLogger {
+	return logging.NewDefaultLoggerFactory().NewLogger("stun")
+}
+
 // Agent is low-level abstraction over transaction list that
 // handles concurrency (all calls are goroutine-safe) and
 // time outs (via Collect call).
+//
+// Transactions are sharded by id, so calls for different transactions
+// from many goroutines do not serialize on single lock.
 type Agent struct {
+	shards      [agentShards]agentShard
+	closed      int32  // all calls are invalid if non-zero, see Close
+	collections uint64 // Collect calls on open agent
+	log         logging.LeveledLogger
+	clock       Clock
+	ids         io.Reader // source of transaction IDs, nil for crypto/rand
+
+	collectorMux     sync.Mutex
+	collector        Collector // see WithAgentCollector
+	collectorStarted bool      // set by StartCollector
+}
+
+// agentShards is count of Agent shards, must be power of two.
+const agentShards = 16
+
+// agentShard is part of Agent transactions.
+type agentShard struct {
 	// transactions is map of transactions that are currently
 	// in progress. Event handling is done in such way when
 	// transaction is unregistered before agentTransaction access,
 	// minimizing mux lock and protecting agentTransaction from
 	// data races via unexpected concurrent access.
 	transactions map[transactionID]agentTransaction
-	closed       bool       // all calls are invalid if true
-	mux          sync.Mutex // protects transactions and closed
-	handler      Handler    // handles transactions
+	deadlines    deadlineHeap // deadlines of transactions, see Collect
+	closed       bool         // set by Agent.Close
+	mux          sync.Mutex   // protects shard fields
+	handler      Handler      // handles transactions, copy of agent handler
+	stats        AgentStats   // InFlight and Collections are not maintained
+}
+
+// shard returns shard of transaction with id.
+func (a *Agent) shard(id transactionID) *agentShard {
+	return &a.shards[id[TransactionIDSize-1]&(agentShards-1)]
+}
+
+func (a *Agent) isClosed() bool {
+	return atomic.LoadInt32(&a.closed) != 0
+}
+
+// AgentStats are Agent counters.
+type AgentStats struct {
+	InFlight    int    // transactions in progress
+	Started     uint64 // transactions registered by Start
+	Completed   uint64 // transactions completed by Process
+	Stopped     uint64 // transactions stopped by Stop or StopWithError
+	TimedOut    uint64 // transactions terminated by Collect
+	Collections uint64 // Collect calls on open agent
+}
+
+// Stats returns current agent counters.
+func (a *Agent) Stats() AgentStats {
+	stats := AgentStats{Collections: atomic.LoadUint64(&a.collections)}
+	for i := range a.shards {
+		s := &a.shards[i]
+		s.mux.Lock()
+		stats.InFlight += len(s.transactions)
+		stats.Started += s.stats.Started
+		stats.Completed += s.stats.Completed
+		stats.Stopped += s.stats.Stopped
+		stats.TimedOut += s.stats.TimedOut
+		s.mux.Unlock()
+	}
+
+	return stats
+}
+
+// PendingTransaction describes transaction in progress.
+type PendingTransaction struct {
+	ID       [TransactionIDSize]byte
+	Deadline time.Time
+}
+
+// Transactions returns transactions in progress, ordered by deadline.
+func (a *Agent) Transactions() []PendingTransaction {
+	var pending []PendingTransaction
+	for i := range a.shards {
+		s := &a.shards[i]
+		s.mux.Lock()
+		for _, t := range s.transactions {
+			pending = append(pending, PendingTransaction{ID: t.id, Deadline: t.deadline})
+		}
+		s.mux.Unlock()
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Deadline.Before(pending[j].Deadline)
+	})
+
+	return pending
 }
 
 // Handler handles state changes of transaction.
@@ -51,11 +193,24 @@ type Agent struct {
 type Handler func(e Event)
 
 // Event is passed to Handler describing the transaction event.
-// Do not reuse outside Handler.
+// Do not reuse outside Handler, use Message.Clone to retain message.
 type Event struct {
 	TransactionID [TransactionIDSize]byte
 	Message       *Message
 	Error         error
+	// UserData is value passed to Agent.StartWithContext, nil for
+	// transactions started with Agent.Start and for messages that do not
+	// match any transaction.
+	UserData any
+	// Remote and Local are source and destination addresses of packet
+	// with Message, and Timestamp is its receive time. They are set only
+	// if message is passed to Agent.ProcessFrom, e.g. by Client.
+	Remote    net.Addr
+	Local     net.Addr
+	Timestamp time.Time
+	// RTT is round-trip time of Client transaction, set only if it is
+	// completed without retransmissions, see ClientStats.
+	RTT time.Duration
 }
 
 // agentTransaction represents transaction in progress.
@@ -63,6 +218,30 @@ type Event struct {
 type agentTransaction struct {
 	id       transactionID
 	deadline time.Time
+	userData any
+	handler  Handler      // overrides agent handler if set
+	await    chan<- Event // set by Agent.Await
+}
+
+// handlerOr returns transaction handler or h if it is not set.
+func (t agentTransaction) handlerOr(h Handler) Handler {
+	if t.handler != nil {
+		return t.handler
+	}
+
+	return h
+}
+
+// notify passes e to Agent.Await of transaction, if any. Message is
+// cloned, because it is valid only during handler call.
+func (t agentTransaction) notify(e Event) {
+	if t.await == nil {
+		return
+	}
+	if e.Message != nil {
+		e.Message = e.Message.Clone()
+	}
+	t.await <- e
 }
 
 var (
@@ -73,28 +252,38 @@ var (
 	// ErrTransactionExists indicates that transaction with same id is already
 	// registered.
 	ErrTransactionExists = errors.New("transaction exists with same id")
+	// ErrTransactionAwaited indicates that transaction is already awaited
+	// by another Agent.Await call.
+	ErrTransactionAwaited = errors.New("transaction is already awaited")
 )
 
 // StopWithError removes transaction from list and calls handler with
 // provided error. Can return ErrTransactionNotExists and ErrAgentClosed.
 func (a *Agent) StopWithError(id [TransactionIDSize]byte, err error) error {
-	a.mux.Lock()
-	if a.closed {
-		a.mux.Unlock()
+	s := a.shard(id)
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
 
 		return ErrAgentClosed
 	}
-	t, exists := a.transactions[id]
-	delete(a.transactions, id)
-	h := a.handler
-	a.mux.Unlock()
+	t, exists := s.transactions[id]
+	delete(s.transactions, id)
+	if exists {
+		s.stats.Stopped++
+	}
+	h := t.handlerOr(s.handler)
+	s.mux.Unlock()
 	if !exists {
 		return ErrTransactionNotExists
 	}
-	h(Event{
+	e := Event{
 		TransactionID: t.id,
 		Error:         err,
-	})
+		UserData:      t.userData,
+	}
+	h(e)
+	t.notify(e)
 
 	return nil
 }
@@ -114,18 +303,40 @@ var ErrAgentClosed = errors.New("agent is closed")
 //
 // Agent handler is guaranteed to be eventually called.
 func (a *Agent) Start(id [TransactionIDSize]byte, deadline time.Time) error {
-	a.mux.Lock()
-	defer a.mux.Unlock()
-	if a.closed {
+	return a.StartWithContext(id, deadline, nil)
+}
+
+// StartWithContext is like Start, but userData is passed back to handler
+// in Event.UserData, so callers do not need to keep their own map of
+// transaction state.
+func (a *Agent) StartWithContext(id [TransactionIDSize]byte, deadline time.Time, userData any) error {
+	return a.start(agentTransaction{id: id, deadline: deadline, userData: userData})
+}
+
+// StartWithHandler is like Start, but events of transaction are passed
+// to h instead of agent handler.
+func (a *Agent) StartWithHandler(id [TransactionIDSize]byte, deadline time.Time, h Handler) error {
+	return a.start(agentTransaction{id: id, deadline: deadline, handler: h})
+}
+
+func (a *Agent) start(t agentTransaction) error {
+	s := a.shard(t.id)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
 		return ErrAgentClosed
 	}
-	_, exists := a.transactions[id]
+	_, exists := s.transactions[t.id]
 	if exists {
 		return ErrTransactionExists
 	}
-	a.transactions[id] = agentTransaction{
-		id:       id,
-		deadline: deadline,
+	s.transactions[t.id] = t
+	s.stats.Started++
+	if len(s.deadlines) >= 2*len(s.transactions)+agentCollectCap {
+		// Dropping entries of completed transactions.
+		s.deadlines.rebuild(s.transactions)
+	} else {
+		s.deadlines.push(deadlineEntry{deadline: t.deadline, id: t.id})
 	}
 
 	return nil
@@ -140,80 +351,216 @@ var ErrTransactionTimeOut = errors.New("transaction is timed out")
 
 // Collect terminates all transactions that have deadline before provided
 // time, blocking until all handlers will process ErrTransactionTimeOut.
+// Cost of call is proportional to count of timed out transactions, not
+// of all transactions in progress.
 // Will return ErrAgentClosed if agent is already closed.
 //
 // It is safe to call Collect concurrently but makes no sense.
 func (a *Agent) Collect(gcTime time.Time) error {
-	toRemove := make([]transactionID, 0, agentCollectCap)
-	a.mux.Lock()
-	if a.closed {
+	if a.isClosed() {
 		// Doing nothing if agent is closed.
 		// All transactions should be already closed
 		// during Close() call.
-		a.mux.Unlock()
-
 		return ErrAgentClosed
 	}
-	// Adding all transactions with deadline before gcTime
-	// to toCall and toRemove slices.
-	// No allocs if there are less than agentCollectCap
-	// timed out transactions.
-	for id, t := range a.transactions {
-		if t.deadline.Before(gcTime) {
-			toRemove = append(toRemove, id)
+	atomic.AddUint64(&a.collections, 1)
+	toRemove := make([]agentTransaction, 0, agentCollectCap)
+	for i := range a.shards {
+		s := &a.shards[i]
+		s.mux.Lock()
+		if s.closed {
+			s.mux.Unlock()
+
+			continue
+		}
+		// Adding all transactions with deadline before gcTime
+		// to toRemove slice.
+		// No allocs if there are less than agentCollectCap
+		// timed out transactions.
+		start := len(toRemove)
+		for s.deadlines.expired(gcTime) {
+			e := s.deadlines.pop()
+			t, exists := s.transactions[e.id]
+			if !exists || !t.deadline.Equal(e.deadline) {
+				// Transaction is completed before deadline.
+				continue
+			}
+			toRemove = append(toRemove, t)
+		}
+		// Un-registering timed out transactions and resolving
+		// their handlers, so handler does not require locked mutex.
+		for j := start; j < len(toRemove); j++ {
+			delete(s.transactions, toRemove[j].id)
+			toRemove[j].handler = toRemove[j].handlerOr(s.handler)
 		}
+		s.stats.TimedOut += uint64(len(toRemove) - start)
+		s.mux.Unlock()
 	}
-	// Un-registering timed out transactions.
-	for _, id := range toRemove {
-		delete(a.transactions, id)
+	if len(toRemove) > 0 {
+		a.log.Debugf("agent: %d transaction(s) timed out", len(toRemove))
 	}
-	// Calling handler does not require locked mutex,
-	// reducing lock time.
-	h := a.handler
-	a.mux.Unlock()
 	// Sending ErrTransactionTimeOut to handler for all transactions,
 	// blocking until last one.
 	event := Event{
 		Error: ErrTransactionTimeOut,
 	}
-	for _, id := range toRemove {
-		event.TransactionID = id
-		h(event)
+	for _, t := range toRemove {
+		event.TransactionID = t.id
+		event.UserData = t.userData
+		t.handler(event)
+		t.notify(event)
+	}
+
+	return nil
+}
+
+// ErrCollectorStarted indicates that Agent.StartCollector is already
+// called.
+var ErrCollectorStarted = errors.New("collector is already started")
+
+// StartCollector starts collector that calls CollectNow with provided
+// interval, so transactions time out without explicit Collect calls.
+// Collector is stopped by Close. Could return ErrAgentClosed and
+// ErrCollectorStarted.
+//
+// Do not call Close from handler of timed out transaction, because
+// Close waits for collector to stop.
+func (a *Agent) StartCollector(interval time.Duration) error {
+	a.collectorMux.Lock()
+	defer a.collectorMux.Unlock()
+	if a.isClosed() {
+		return ErrAgentClosed
+	}
+	if a.collectorStarted {
+		return ErrCollectorStarted
+	}
+	if a.collector == nil {
+		a.collector = NewTickerCollector(a.clock)
 	}
+	if err := a.collector.Start(interval, func(t time.Time) {
+		closedOrPanic(a.Collect(t))
+	}); err != nil {
+		return err
+	}
+	a.collectorStarted = true
 
 	return nil
 }
 
+// NewTransactionID returns new transaction ID read from source of agent,
+// see WithAgentTransactionIDSource.
+func (a *Agent) NewTransactionID() ([TransactionIDSize]byte, error) {
+	if a.ids == nil {
+		return NewTransactionID(), nil
+	}
+
+	return NewTransactionIDFrom(a.ids)
+}
+
+// CollectNow is Collect with current time of agent clock, see
+// WithAgentClock.
+func (a *Agent) CollectNow() error {
+	return a.Collect(a.clock.Now())
+}
+
 // Process incoming message, synchronously passing it to handler.
 func (a *Agent) Process(m *Message) error {
+	return a.ProcessFrom(m, nil, nil, time.Time{})
+}
+
+// ProcessFrom is like Process, but also passes addresses and receive
+// time of packet with message to handler.
+func (a *Agent) ProcessFrom(m *Message, remote, local net.Addr, received time.Time) error {
 	event := Event{
 		TransactionID: m.TransactionID,
 		Message:       m,
+		Remote:        remote,
+		Local:         local,
+		Timestamp:     received,
 	}
-	a.mux.Lock()
-	if a.closed {
-		a.mux.Unlock()
+	s := a.shard(m.TransactionID)
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
 
 		return ErrAgentClosed
 	}
-	h := a.handler
-	delete(a.transactions, m.TransactionID)
-	a.mux.Unlock()
+	h := s.handler
+	t, exists := s.transactions[m.TransactionID]
+	if exists {
+		delete(s.transactions, m.TransactionID)
+		s.stats.Completed++
+		event.UserData = t.userData
+		h = t.handlerOr(h)
+	}
+	s.mux.Unlock()
 	h(event)
+	t.notify(event)
 
 	return nil
 }
 
+// Await blocks until transaction with id completes or ctx is done,
+// returning event of transaction and its error. Transaction is stopped
+// with ctx error if ctx is done first. Event handler is still called.
+//
+// Transaction must be started and not completed yet, otherwise
+// ErrTransactionNotExists is returned, so message that can complete
+// transaction should be processed after Await is called. Message of
+// returned event is a copy and can be retained.
+func (a *Agent) Await(ctx context.Context, id [TransactionIDSize]byte) (Event, error) {
+	s := a.shard(id)
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+
+		return Event{}, ErrAgentClosed
+	}
+	t, exists := s.transactions[id]
+	if !exists {
+		s.mux.Unlock()
+
+		return Event{}, ErrTransactionNotExists
+	}
+	if t.await != nil {
+		s.mux.Unlock()
+
+		return Event{}, ErrTransactionAwaited
+	}
+	done := make(chan Event, 1)
+	t.await = done
+	s.transactions[id] = t
+	s.mux.Unlock()
+
+	select {
+	case e := <-done:
+		return e, e.Error
+	case <-ctx.Done():
+		// Transaction can be completed concurrently, so event is
+		// delivered anyway, with ctx error only if stop succeeds.
+		_ = a.StopWithError(id, ctx.Err())
+		e := <-done
+
+		return e, e.Error
+	}
+}
+
 // SetHandler sets agent handler to h.
 func (a *Agent) SetHandler(h Handler) error {
-	a.mux.Lock()
-	if a.closed {
-		a.mux.Unlock()
-
+	if a.isClosed() {
 		return ErrAgentClosed
 	}
-	a.handler = h
-	a.mux.Unlock()
+	for i := range a.shards {
+		s := &a.shards[i]
+		s.mux.Lock()
+		if s.closed {
+			s.mux.Unlock()
+
+			return ErrAgentClosed
+		}
+		s.handler = h
+		s.mux.Unlock()
+	}
 
 	return nil
 }
@@ -221,23 +568,40 @@ func (a *Agent) SetHandler(h Handler) error {
 // Close terminates all transactions with ErrAgentClosed and renders Agent to
 // closed state.
 func (a *Agent) Close() error {
+	if !atomic.CompareAndSwapInt32(&a.closed, 0, 1) {
+		return ErrAgentClosed
+	}
+	a.collectorMux.Lock()
+	started := a.collectorStarted
+	a.collectorMux.Unlock()
+	if started {
+		if err := a.collector.Close(); err != nil {
+			a.log.Warnf("agent: failed to close collector: %v", err)
+		}
+	}
 	e := Event{
 		Error: ErrAgentClosed,
 	}
-	a.mux.Lock()
-	if a.closed {
-		a.mux.Unlock()
-
-		return ErrAgentClosed
+	pending := 0
+	for i := range a.shards {
+		s := &a.shards[i]
+		s.mux.Lock()
+		pending += len(s.transactions)
+		for _, t := range s.transactions {
+			e.TransactionID = t.id
+			e.UserData = t.userData
+			t.handlerOr(s.handler)(e)
+			t.notify(e)
+		}
+		s.transactions = nil
+		s.deadlines = nil
+		s.closed = true
+		s.handler = nil
+		s.mux.Unlock()
 	}
-	for _, t := range a.transactions {
-		e.TransactionID = t.id
-		a.handler(e)
+	if pending > 0 {
+		a.log.Debugf("agent: closed with %d transaction(s) in progress", pending)
 	}
-	a.transactions = nil
-	a.closed = true
-	a.handler = nil
-	a.mux.Unlock()
 
 	return nil
 }
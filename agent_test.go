@@ -4,9 +4,17 @@
 package stun
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/pion/logging"
 )
 
 func TestAgent_ProcessInTransaction(t *testing.T) {
@@ -214,3 +222,564 @@ func BenchmarkAgent_Process(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkAgent_ProcessParallel(b *testing.B) {
+	agent := NewAgent(nil)
+	defer func() {
+		if err := agent.Close(); err != nil {
+			b.Error(err)
+		}
+	}()
+	deadline := time.Now().AddDate(0, 0, 1)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		m := new(Message)
+		for pb.Next() {
+			m.TransactionID = NewTransactionID()
+			if err := agent.Start(m.TransactionID, deadline); err != nil {
+				b.Fatal(err)
+			}
+			if err := agent.Process(m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestAgent_Concurrent(t *testing.T) {
+	var completed int32
+	agent := NewAgent(func(e Event) {
+		if e.Error == nil {
+			atomic.AddInt32(&completed, 1)
+		}
+	})
+	const (
+		workers      = 8
+		transactions = 100
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := new(Message)
+			for j := 0; j < transactions; j++ {
+				m.TransactionID = NewTransactionID()
+				if err := agent.Start(m.TransactionID, time.Time{}); err != nil {
+					t.Error(err)
+				}
+				if err := agent.Process(m); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if stats := agent.Stats(); stats.Completed != workers*transactions || stats.InFlight != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if completed != workers*transactions {
+		t.Errorf("unexpected completed count: %d", completed)
+	}
+	if err := agent.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAgent_Stats(t *testing.T) {
+	agent := NewAgent(nil)
+	now := time.Now()
+	ids := make([][TransactionIDSize]byte, 4)
+	for i := range ids {
+		ids[i] = NewTransactionID()
+		if err := agent.Start(ids[i], now.Add(time.Duration(len(ids)-i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pending := agent.Transactions()
+	if len(pending) != len(ids) {
+		t.Fatalf("unexpected transactions: %v", pending)
+	}
+	for i, p := range pending {
+		if p.ID != ids[len(ids)-1-i] {
+			t.Errorf("transactions should be ordered by deadline: %v", pending)
+		}
+	}
+	if err := agent.Process(&Message{TransactionID: ids[0]}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Process(&Message{TransactionID: NewTransactionID()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Stop(ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Collect(now.Add(time.Second * 3)); err != nil {
+		t.Fatal(err)
+	}
+	expected := AgentStats{
+		InFlight:    0,
+		Started:     4,
+		Completed:   1,
+		Stopped:     1,
+		TimedOut:    2,
+		Collections: 1,
+	}
+	if stats := agent.Stats(); stats != expected {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if err := agent.Start(ids[0], now); err != nil {
+		t.Fatal(err)
+	}
+	if stats := agent.Stats(); stats.InFlight != 1 || stats.Started != 5 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if err := agent.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if pending = agent.Transactions(); len(pending) != 0 {
+		t.Errorf("unexpected transactions: %v", pending)
+	}
+}
+
+func TestAgent_StartWithContext(t *testing.T) {
+	events := make(map[transactionID]Event)
+	agent := NewAgent(func(e Event) {
+		events[e.TransactionID] = e
+	})
+	now := time.Now()
+	processed, stopped, collected, closed := NewTransactionID(), NewTransactionID(), NewTransactionID(), NewTransactionID()
+	for i, id := range [][TransactionIDSize]byte{processed, stopped, collected, closed} {
+		if err := agent.StartWithContext(id, now.Add(time.Duration(i)*time.Second), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := agent.StartWithContext(processed, now, nil); !errors.Is(err, ErrTransactionExists) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := agent.Process(&Message{TransactionID: processed}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Stop(stopped); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Collect(now.Add(time.Second * 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range [][TransactionIDSize]byte{processed, stopped, collected, closed} {
+		if e, ok := events[id]; !ok || e.UserData != i {
+			t.Errorf("unexpected event for transaction %d: %+v", i, e)
+		}
+	}
+}
+
+func TestAgent_StartWithHandler(t *testing.T) {
+	var global, own []Event
+	agent := NewAgent(func(e Event) {
+		global = append(global, e)
+	})
+	ownHandler := func(e Event) {
+		own = append(own, e)
+	}
+	now := time.Now()
+	ids := [][TransactionIDSize]byte{NewTransactionID(), NewTransactionID(), NewTransactionID(), NewTransactionID()}
+	for i, id := range ids {
+		if err := agent.StartWithHandler(id, now.Add(time.Duration(i)*time.Second), ownHandler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shared := NewTransactionID()
+	if err := agent.Start(shared, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Process(&Message{TransactionID: ids[0]}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Stop(ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Collect(now.Add(time.Second * 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Process(&Message{TransactionID: NewTransactionID()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(own) != len(ids) {
+		t.Fatalf("unexpected events: %+v", own)
+	}
+	for i, e := range own {
+		if e.TransactionID != ids[i] {
+			t.Errorf("unexpected event %d: %+v", i, e)
+		}
+	}
+	if len(global) != 2 || global[1].TransactionID != shared {
+		t.Errorf("unexpected agent handler events: %+v", global)
+	}
+}
+
+func TestAgent_ProcessFrom(t *testing.T) {
+	var event Event
+	agent := NewAgent(func(e Event) {
+		event = e
+	})
+	var (
+		remote   = &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 3478}
+		local    = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}
+		received = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+		m        = &Message{TransactionID: NewTransactionID()}
+	)
+	if err := agent.ProcessFrom(m, remote, local, received); err != nil {
+		t.Fatal(err)
+	}
+	if event.Message != m || event.Remote != remote || event.Local != local || !event.Timestamp.Equal(received) {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if err := agent.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.ProcessFrom(m, remote, local, received); !errors.Is(err, ErrAgentClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAgent_Logger(t *testing.T) {
+	var out bytes.Buffer
+	agent := NewAgent(nil, WithAgentLogger(logging.NewDefaultLeveledLoggerForScope("test", logging.LogLevelTrace, &out)))
+	if err := agent.Start(NewTransactionID(), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Collect(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "agent: 1 transaction(s) timed out") {
+		t.Errorf("timeout not found in log:\n%s", out.String())
+	}
+}
+
+func TestAgent_CollectNow(t *testing.T) {
+	now := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var timedOut []transactionID
+	agent := NewAgent(func(e Event) {
+		if errors.Is(e.Error, ErrTransactionTimeOut) {
+			timedOut = append(timedOut, e.TransactionID)
+		}
+	}, WithAgentClock(ClockFunc(func() time.Time { return now })))
+	id := NewTransactionID()
+	if err := agent.Start(id, now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.CollectNow(); err != nil {
+		t.Fatal(err)
+	}
+	if len(timedOut) != 0 {
+		t.Fatal("transaction should not time out")
+	}
+	now = now.Add(time.Second * 2)
+	if err := agent.CollectNow(); err != nil {
+		t.Fatal(err)
+	}
+	if len(timedOut) != 1 || timedOut[0] != id {
+		t.Errorf("unexpected timed out transactions: %x", timedOut)
+	}
+	if err := agent.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.CollectNow(); !errors.Is(err, ErrAgentClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAgent_NewTransactionID(t *testing.T) {
+	source := bytes.Repeat([]byte{2}, TransactionIDSize)
+	agent := NewAgent(nil, WithAgentTransactionIDSource(bytes.NewReader(source)))
+	id, err := agent.NewTransactionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(id[:], source) {
+		t.Errorf("unexpected id: %x", id)
+	}
+	if _, err = agent.NewTransactionID(); err == nil {
+		t.Error("exhausted source should fail")
+	}
+	if _, err = NewAgent(nil).NewTransactionID(); err != nil {
+		t.Error(err)
+	}
+}
+
+// waitAwaited blocks until transaction with id is awaited.
+func waitAwaited(a *Agent, id transactionID) {
+	for {
+		s := a.shard(id)
+		s.mux.Lock()
+		awaited := s.transactions[id].await != nil
+		s.mux.Unlock()
+		if awaited {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAgent_Await(t *testing.T) {
+	t.Run("Process", func(t *testing.T) {
+		agent := NewAgent(nil)
+		msg := MustBuild(TransactionID, BindingSuccess)
+		if err := agent.Start(msg.TransactionID, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			waitAwaited(agent, msg.TransactionID)
+			if err := agent.Process(msg); err != nil {
+				t.Error(err)
+			}
+		}()
+		e, err := agent.Await(context.Background(), msg.TransactionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Message == msg || !bytes.Equal(e.Message.Raw, msg.Raw) {
+			t.Errorf("message should be equal copy: %s", e.Message)
+		}
+		if _, err := agent.Await(context.Background(), msg.TransactionID); !errors.Is(err, ErrTransactionNotExists) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		var handled error
+		agent := NewAgent(func(e Event) { handled = e.Error })
+		id := NewTransactionID()
+		if err := agent.Start(id, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		if _, err := agent.Await(ctx, id); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !errors.Is(handled, context.DeadlineExceeded) {
+			t.Errorf("unexpected handler error: %v", handled)
+		}
+		if agent.Stats().InFlight != 0 {
+			t.Error("transaction should be stopped")
+		}
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		agent := NewAgent(nil)
+		id := NewTransactionID()
+		deadline := time.Now()
+		if err := agent.Start(id, deadline); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			waitAwaited(agent, id)
+			if err := agent.Collect(deadline.Add(time.Second)); err != nil {
+				t.Error(err)
+			}
+		}()
+		if _, err := agent.Await(context.Background(), id); !errors.Is(err, ErrTransactionTimeOut) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Closed", func(t *testing.T) {
+		agent := NewAgent(nil)
+		id := NewTransactionID()
+		if err := agent.Start(id, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			waitAwaited(agent, id)
+			if err := agent.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+		if _, err := agent.Await(context.Background(), id); !errors.Is(err, ErrAgentClosed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := agent.Await(context.Background(), id); !errors.Is(err, ErrAgentClosed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestAgent_StartCollector(t *testing.T) {
+	now := time.Now()
+	timedOut := make(chan struct{})
+	agent := NewAgent(func(e Event) {
+		if errors.Is(e.Error, ErrTransactionTimeOut) {
+			close(timedOut)
+		}
+	}, WithAgentClock(ClockFunc(func() time.Time {
+		return now.Add(time.Hour)
+	})))
+	if err := agent.Start(NewTransactionID(), now); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.StartCollector(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.StartCollector(time.Millisecond); !errors.Is(err, ErrCollectorStarted) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	select {
+	case <-timedOut:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transaction is not timed out")
+	}
+	if err := agent.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.StartCollector(time.Millisecond); !errors.Is(err, ErrAgentClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+type agentTestCollector struct {
+	started, closed bool
+	startErr        error
+}
+
+func (c *agentTestCollector) Start(time.Duration, func(now time.Time)) error {
+	c.started = true
+
+	return c.startErr
+}
+
+func (c *agentTestCollector) Close() error {
+	c.closed = true
+
+	return nil
+}
+
+func TestAgent_WithAgentCollector(t *testing.T) {
+	collector := new(agentTestCollector)
+	agent := NewAgent(nil, WithAgentCollector(collector))
+	if err := agent.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if collector.closed {
+		t.Error("collector that is not started should not be closed")
+	}
+	agent = NewAgent(nil, WithAgentCollector(collector))
+	if err := agent.StartCollector(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !collector.started || !collector.closed {
+		t.Error("collector should be started and closed")
+	}
+	startErr := errors.New("start failed")
+	agent = NewAgent(nil, WithAgentCollector(&agentTestCollector{startErr: startErr}))
+	if err := agent.StartCollector(time.Second); !errors.Is(err, startErr) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAgent_CollectOrder(t *testing.T) {
+	var timedOut []transactionID
+	agent := NewAgent(func(e Event) {
+		if errors.Is(e.Error, ErrTransactionTimeOut) {
+			timedOut = append(timedOut, e.TransactionID)
+		}
+	})
+	defer agent.Close() //nolint:errcheck
+	base := time.Unix(1700000000, 0)
+	// Transactions are in the same shard, so they time out in order of
+	// deadlines.
+	id := func(i int) (tid transactionID) {
+		tid[0] = byte(i)
+
+		return tid
+	}
+	// Deadlines are in reverse order of ids.
+	for i := 0; i < 10; i++ {
+		if err := agent.Start(id(i), base.Add(time.Duration(10-i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Completed transaction should not time out, and restarted one should
+	// time out only at new deadline.
+	if err := agent.Stop(id(9)); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Stop(id(8)); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Start(id(8), base.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Collect(base.Add(5500 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	expected := []transactionID{id(7), id(6), id(5)}
+	if len(timedOut) != len(expected) {
+		t.Fatalf("unexpected timed out transactions: %x", timedOut)
+	}
+	for i := range expected {
+		if timedOut[i] != expected[i] {
+			t.Errorf("%d: %x timed out instead of %x", i, timedOut[i], expected[i])
+		}
+	}
+	if stats := agent.Stats(); stats.InFlight != 6 {
+		t.Errorf("unexpected in flight count: %d", stats.InFlight)
+	}
+	timedOut = nil
+	if err := agent.Collect(base.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(timedOut) != 6 || timedOut[5] != id(8) {
+		t.Errorf("unexpected timed out transactions: %x", timedOut)
+	}
+}
+
+func TestAgent_CollectCompleted(t *testing.T) {
+	agent := NewAgent(nil)
+	defer agent.Close() //nolint:errcheck
+	deadline := time.Now().Add(time.Hour)
+	m := new(Message)
+	for i := 0; i < 10*agentCollectCap; i++ {
+		m.TransactionID = NewTransactionID()
+		if err := agent.Start(m.TransactionID, deadline); err != nil {
+			t.Fatal(err)
+		}
+		if err := agent.Process(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Entries of completed transactions should not accumulate.
+	for i := range agent.shards {
+		if n := len(agent.shards[i].deadlines); n > 2*agentCollectCap {
+			t.Errorf("shard %d: %d deadlines", i, n)
+		}
+	}
+}
+
+func BenchmarkAgent_CollectInFlight(b *testing.B) {
+	agent := NewAgent(nil)
+	deadline := time.Now().AddDate(0, 0, 1)
+	for i := 0; i < 10000; i++ {
+		if err := agent.Start(NewTransactionID(), deadline); err != nil {
+			b.Fatal(err)
+		}
+	}
+	defer func() {
+		if err := agent.Close(); err != nil {
+			b.Error(err)
+		}
+	}()
+	b.ReportAllocs()
+	gcDeadline := deadline.Add(-time.Second)
+	for i := 0; i < b.N; i++ {
+		if err := agent.Collect(gcDeadline); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Annotation keys used by Annotate.
+const (
+	AnnotationFingerprint     = "fingerprint"
+	AnnotationIntegrity       = "integrity"
+	AnnotationIntegritySHA256 = "integrity-sha256"
+	AnnotationUnknownAttrs    = "unknown-attrs"
+)
+
+// Annotation values used by Annotate.
+const (
+	AnnotationValid      = "valid"
+	AnnotationInvalid    = "invalid"
+	AnnotationAbsent     = "absent"
+	AnnotationUnverified = "unverified"
+)
+
+// Annotations are results of message validation, keyed by the checked
+// property, e.g. "fingerprint: valid" or "unknown-attrs: [0x8030]".
+//
+// Annotations are produced by decoding tools and serialized with the
+// decoded message, so downstream analysis does not need to re-derive
+// validation results.
+type Annotations map[string]string
+
+// Annotate validates m and returns its annotations.
+//
+// MESSAGE-INTEGRITY attributes are reported as unverified because
+// Annotate has no access to credentials, callers that checked integrity
+// can overwrite the AnnotationIntegrity value.
+func Annotate(m *Message) Annotations {
+	a := Annotations{
+		AnnotationFingerprint: AnnotationAbsent,
+		AnnotationIntegrity:   AnnotationAbsent,
+	}
+	if m.Contains(AttrFingerprint) {
+		a[AnnotationFingerprint] = AnnotationValid
+		if Fingerprint.Check(m) != nil {
+			a[AnnotationFingerprint] = AnnotationInvalid
+		}
+	}
+	if m.Contains(AttrMessageIntegrity) {
+		a[AnnotationIntegrity] = AnnotationUnverified
+	}
+	if m.Contains(AttrMessageIntegritySHA256) {
+		a[AnnotationIntegritySHA256] = AnnotationUnverified
+	}
+	var (
+		unknown []string
+		names   = attrNames()
+	)
+	for _, attr := range m.Attributes {
+		if _, ok := attrName(names, attr.Type); !ok {
+			unknown = append(unknown, fmt.Sprintf("0x%04x", attr.Type.Value()))
+		}
+	}
+	if len(unknown) > 0 {
+		a[AnnotationUnknownAttrs] = "[" + strings.Join(unknown, " ") + "]"
+	}
+
+	return a
+}
+
+// Keys returns sorted annotation keys.
+func (a Annotations) Keys() []string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Dump writes annotations to w in the Message.Dump format, one per line
+// in the key order.
+func (a Annotations) Dump(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	if len(a) > 0 {
+		fmt.Fprintf(buf, "    Annotations\n") //nolint:errcheck
+	}
+	for _, k := range a.Keys() {
+		fmt.Fprintf(buf, "        %s: %s\n", k, a[k]) //nolint:errcheck
+	}
+
+	return buf.Flush()
+}
+
+func (a Annotations) String() string {
+	parts := make([]string, 0, len(a))
+	for _, k := range a.Keys() {
+		parts = append(parts, k+": "+a[k])
+	}
+
+	return strings.Join(parts, ", ")
+}
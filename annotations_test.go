@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		a := Annotate(MustBuild(TransactionID, BindingRequest))
+		if a[AnnotationFingerprint] != AnnotationAbsent {
+			t.Errorf("unexpected fingerprint annotation: %s", a[AnnotationFingerprint])
+		}
+		if a[AnnotationIntegrity] != AnnotationAbsent {
+			t.Errorf("unexpected integrity annotation: %s", a[AnnotationIntegrity])
+		}
+		if _, ok := a[AnnotationUnknownAttrs]; ok {
+			t.Error("unexpected unknown-attrs annotation")
+		}
+	})
+	t.Run("Full", func(t *testing.T) {
+		m := MustBuild(TransactionID, BindingRequest,
+			RawAttribute{Type: 0x8030, Value: []byte{1}},
+			NewShortTermIntegrity("pwd"),
+			Fingerprint,
+		)
+		a := Annotate(m)
+		if a[AnnotationFingerprint] != AnnotationValid {
+			t.Errorf("unexpected fingerprint annotation: %s", a[AnnotationFingerprint])
+		}
+		if a[AnnotationIntegrity] != AnnotationUnverified {
+			t.Errorf("unexpected integrity annotation: %s", a[AnnotationIntegrity])
+		}
+		if a[AnnotationUnknownAttrs] != "[0x8030]" {
+			t.Errorf("unexpected unknown-attrs annotation: %s", a[AnnotationUnknownAttrs])
+		}
+		m.Raw[len(m.Raw)-1]++
+		if got := Annotate(m)[AnnotationFingerprint]; got != AnnotationInvalid {
+			t.Errorf("unexpected fingerprint annotation: %s", got)
+		}
+	})
+	t.Run("Registered", func(t *testing.T) {
+		RegisterAttrName(0x8030, "VENDOR-ATTR")
+		defer RegisterAttrName(0x8030, "")
+		m := MustBuild(TransactionID, BindingRequest,
+			RawAttribute{Type: 0x8030, Value: []byte{1}},
+			RawAttribute{Type: 0x8031, Value: []byte{1}},
+		)
+		if got := Annotate(m)[AnnotationUnknownAttrs]; got != "[0x8031]" {
+			t.Errorf("unexpected unknown-attrs annotation: %s", got)
+		}
+	})
+}
+
+func TestAnnotations_Serialization(t *testing.T) {
+	a := Annotations{
+		AnnotationIntegrity:   AnnotationUnverified,
+		AnnotationFingerprint: AnnotationValid,
+	}
+	if s := a.String(); s != "fingerprint: valid, integrity: unverified" {
+		t.Errorf("unexpected string: %s", s)
+	}
+	buf := new(bytes.Buffer)
+	if err := a.Dump(buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := "    Annotations\n        fingerprint: valid\n        integrity: unverified\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected dump: %q", buf)
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"fingerprint":"valid","integrity":"unverified"}` {
+		t.Errorf("unexpected JSON: %s", data)
+	}
+}
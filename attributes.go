@@ -161,8 +161,18 @@ func attrNames() map[AttrType]string {
 	}
 }
 
+// attrName returns name of t from names of attributes that are
+// implemented by package or from names registered by RegisterAttrName.
+func attrName(names map[AttrType]string, t AttrType) (string, bool) {
+	if s, ok := names[t]; ok {
+		return s, true
+	}
+
+	return registeredAttrName(t)
+}
+
 func (t AttrType) String() string {
-	s, ok := attrNames()[t]
+	s, ok := attrName(attrNames(), t)
 	if !ok {
 		// Just return hex representation of unknown attribute type.
 		return fmt.Sprintf("0x%x", uint16(t))
@@ -187,6 +197,9 @@ type RawAttribute struct {
 // AddTo implements Setter, adding attribute as a.Type with a.Value and ignoring
 // the Length field.
 func (a RawAttribute) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
 	m.Add(a.Type, a.Value)
 
 	return nil
@@ -224,12 +237,12 @@ var ErrAttributeNotFound = errors.New("attribute not found")
 // if there is no attribute with such type,
 // ErrAttributeNotFound is returned.
 func (m *Message) Get(t AttrType) ([]byte, error) {
-	v, ok := m.Attributes.Get(t)
-	if !ok {
+	i := m.lookup(t)
+	if i < 0 {
 		return nil, ErrAttributeNotFound
 	}
 
-	return v.Value, nil
+	return m.Attributes[i].Value, nil
 }
 
 // STUN aligns attributes on 32-bit boundaries, attributes whose content
@@ -21,6 +21,11 @@ func (e AttrOverflowErr) Error() string {
 	)
 }
 
+// Unwrap returns ErrAttributeSizeOverflow, so e matches it with errors.Is.
+func (e AttrOverflowErr) Unwrap() error {
+	return ErrAttributeSizeOverflow
+}
+
 // AttrLengthErr means that length for attribute is invalid.
 type AttrLengthErr struct {
 	Attr     AttrType
@@ -35,3 +40,8 @@ func (e AttrLengthErr) Error() string {
 		e.Expected,
 	)
 }
+
+// Unwrap returns ErrAttributeSizeInvalid, so e matches it with errors.Is.
+func (e AttrLengthErr) Unwrap() error {
+	return ErrAttributeSizeInvalid
+}
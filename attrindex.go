@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+// attrIndex maps attribute types of message to position of first
+// attribute of that type in Message.Attributes, see Message.BuildIndex.
+type attrIndex struct {
+	enabled bool
+	valid   bool // cleared by methods that modify attributes
+	n       int  // len(Attributes) when index was built
+	first   map[AttrType]int
+	owner   *Message // message that built index, see Message.buildIndex
+}
+
+// BuildIndex enables index of attributes, so Get and Contains do not scan
+// all attributes of m, which is faster for messages with many attributes.
+// Index is built on first lookup and rebuilt on next lookup after
+// attributes are modified by methods of m, e.g. Add, Delete or Decode,
+// so it is kept when m is reused for many messages.
+//
+// Index allocates, so it is disabled by default to keep decoding of small
+// messages allocation-free. Because lookup can build index, Get and
+// Contains of message with index are not safe for concurrent use.
+// Changes of m.Attributes that are made without methods of m are not
+// tracked, call BuildIndex again after them.
+func (m *Message) BuildIndex() {
+	m.mustBeMutable()
+	m.index.enabled = true
+	m.index.valid = false
+}
+
+// invalidateIndex marks index of m as outdated.
+func (m *Message) invalidateIndex() {
+	m.index.valid = false
+}
+
+// lookup returns position of first attribute of type t in m.Attributes,
+// or -1 if there is no such attribute.
+func (m *Message) lookup(t AttrType) int {
+	if !m.index.enabled {
+		for i, a := range m.Attributes {
+			if a.Type == t {
+				return i
+			}
+		}
+
+		return -1
+	}
+	if !m.indexValid() {
+		m.buildIndex()
+	}
+	if i, ok := m.index.first[t]; ok {
+		return i
+	}
+
+	return -1
+}
+
+// indexValid reports whether index of m is built for current attributes
+// by m itself.
+func (m *Message) indexValid() bool {
+	return m.index.valid && m.index.n == len(m.Attributes) && m.index.owner == m
+}
+
+// buildIndex builds index of m. Value copy of message shares map of index
+// with the original, so copy gets its own map instead of overwriting map
+// that the original still uses.
+func (m *Message) buildIndex() {
+	if m.index.owner != m {
+		m.index.first, m.index.owner = nil, m
+	}
+	m.index.build(m.Attributes)
+}
+
+func (x *attrIndex) build(attributes Attributes) {
+	if x.first == nil {
+		x.first = make(map[AttrType]int, len(attributes))
+	}
+	for t := range x.first {
+		delete(x.first, t)
+	}
+	for i, a := range attributes {
+		if _, ok := x.first[a.Type]; !ok {
+			x.first[a.Type] = i
+		}
+	}
+	x.n = len(attributes)
+	x.valid = true
+}
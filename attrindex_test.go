@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+func manyAttributesMessage(count int) *Message {
+	m := New()
+	m.SetType(BindingRequest)
+	for i := 0; i < count; i++ {
+		m.Add(AttrType(0x8100+i), []byte{byte(i)})
+	}
+	m.WriteHeader()
+
+	return m
+}
+
+func TestMessage_BuildIndex(t *testing.T) {
+	m := manyAttributesMessage(20)
+	m.Add(AttrType(0x8100), []byte{0xFF}) // duplicate
+	m.BuildIndex()
+	get := func(at AttrType) byte {
+		t.Helper()
+		v, err := m.Get(at)
+		if err != nil {
+			t.Fatalf("%s: %v", at, err)
+		}
+
+		return v[0]
+	}
+	if v := get(0x8100); v != 0 {
+		t.Errorf("first attribute should be returned, got %d", v)
+	}
+	if v := get(0x8113); v != 19 {
+		t.Errorf("unexpected value %d", v)
+	}
+	if _, err := m.Get(AttrSoftware); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	t.Run("Add", func(t *testing.T) {
+		m.Add(AttrSoftware, []byte("software"))
+		if !m.Contains(AttrSoftware) {
+			t.Error("added attribute should be found")
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		if err := m.Delete(AttrType(0x8101)); err != nil {
+			t.Fatal(err)
+		}
+		if m.Contains(AttrType(0x8101)) {
+			t.Error("deleted attribute should not be found")
+		}
+		if v := get(0x8113); v != 19 {
+			t.Errorf("unexpected value %d", v)
+		}
+	})
+	t.Run("Replace", func(t *testing.T) {
+		if err := m.Replace(AttrType(0x8113), []byte{42, 0}); err != nil {
+			t.Fatal(err)
+		}
+		if v := get(0x8113); v != 42 {
+			t.Errorf("unexpected value %d", v)
+		}
+	})
+	t.Run("ForEach", func(t *testing.T) {
+		var values []byte
+		if err := m.ForEach(AttrType(0x8100), func(m *Message) error {
+			values = append(values, get(0x8100))
+
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if len(values) != 2 || values[0] != 0 || values[1] != 0xFF {
+			t.Errorf("unexpected values: %v", values)
+		}
+		if v := get(0x8113); v != 42 {
+			t.Errorf("unexpected value %d", v)
+		}
+	})
+	t.Run("Decode", func(t *testing.T) {
+		other := manyAttributesMessage(3)
+		m.Raw = append(m.Raw[:0], other.Raw...)
+		if err := m.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		if m.Contains(AttrType(0x8113)) || m.Contains(AttrSoftware) {
+			t.Error("attributes of previous message should not be found")
+		}
+		if v := get(0x8102); v != 2 {
+			t.Errorf("unexpected value %d", v)
+		}
+	})
+	t.Run("Reset", func(t *testing.T) {
+		m.Reset()
+		if m.Contains(AttrType(0x8100)) {
+			t.Error("reset message should be empty")
+		}
+	})
+}
+
+func TestMessage_BuildIndexCopy(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest, NewRealm("realm"), NewNonce("nonce"))
+	m.BuildIndex()
+	var nonce Nonce
+	if err := nonce.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	// Copy rebuilds index after Delete, which should not change index of m.
+	view := *m
+	view.Raw = append([]byte(nil), m.Raw...)
+	view.Attributes = append(Attributes(nil), m.Attributes...)
+	if err := view.Delete(AttrRealm); err != nil {
+		t.Fatal(err)
+	}
+	if err := nonce.GetFrom(&view); err != nil || nonce.String() != "nonce" {
+		t.Errorf("unexpected nonce %q: %v", nonce, err)
+	}
+	if err := nonce.GetFrom(m); err != nil || nonce.String() != "nonce" {
+		t.Errorf("unexpected nonce %q: %v", nonce, err)
+	}
+}
+
+func TestMessage_BuildIndexAllocs(t *testing.T) {
+	m := manyAttributesMessage(30)
+	m.BuildIndex()
+	testutil.ShouldNotAllocate(t, func() {
+		if _, err := m.Get(AttrType(0x811D)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func BenchmarkMessage_GetManyAttributes(b *testing.B) {
+	for _, indexed := range []bool{false, true} {
+		name := "Scan"
+		if indexed {
+			name = "Index"
+		}
+		b.Run(name, func(b *testing.B) {
+			m := manyAttributesMessage(64)
+			if indexed {
+				m.BuildIndex()
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := m.Get(AttrType(0x813F)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PasswordAlgorithm represents PASSWORD-ALGORITHM attribute, the
+// algorithm of long-term credential key derivation.
+//
+// RFC 8489 Section 14.12.
+type PasswordAlgorithm uint16
+
+// Password algorithms from RFC 8489 Section 18.5.
+const (
+	PasswordAlgorithmMD5    PasswordAlgorithm = 0x0001
+	PasswordAlgorithmSHA256 PasswordAlgorithm = 0x0002
+)
+
+func (a PasswordAlgorithm) String() string {
+	switch a {
+	case PasswordAlgorithmMD5:
+		return "MD5"
+	case PasswordAlgorithmSHA256:
+		return "SHA-256"
+	default:
+		return fmt.Sprintf("0x%04x", uint16(a))
+	}
+}
+
+const passwordAlgorithmSize = 4 // algorithm and parameters length
+
+// AddTo adds PASSWORD-ALGORITHM attribute without parameters to message.
+func (a PasswordAlgorithm) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	v := make([]byte, passwordAlgorithmSize)
+	bin.PutUint16(v[0:2], uint16(a))
+	m.Add(AttrPasswordAlgorithm, v)
+
+	return nil
+}
+
+// GetFrom decodes PASSWORD-ALGORITHM from message, ignoring parameters.
+func (a *PasswordAlgorithm) GetFrom(m *Message) error {
+	v, err := m.Get(AttrPasswordAlgorithm)
+	if err != nil {
+		return err
+	}
+	if len(v) < passwordAlgorithmSize {
+		return io.ErrUnexpectedEOF
+	}
+	*a = PasswordAlgorithm(bin.Uint16(v[0:2]))
+
+	return nil
+}
+
+// PasswordAlgorithms represents PASSWORD-ALGORITHMS attribute, the list
+// of password algorithms supported by server in order of preference.
+// Parameters of algorithms are ignored.
+//
+// RFC 8489 Section 14.11.
+type PasswordAlgorithms []PasswordAlgorithm
+
+// AddTo adds PASSWORD-ALGORITHMS attribute to message.
+func (a PasswordAlgorithms) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	v := make([]byte, passwordAlgorithmSize*len(a))
+	for i, alg := range a {
+		bin.PutUint16(v[i*passwordAlgorithmSize:], uint16(alg))
+	}
+	m.Add(AttrPasswordAlgorithms, v)
+
+	return nil
+}
+
+// GetFrom decodes PASSWORD-ALGORITHMS from message.
+func (a *PasswordAlgorithms) GetFrom(m *Message) error {
+	v, err := m.Get(AttrPasswordAlgorithms)
+	if err != nil {
+		return err
+	}
+	algorithms := (*a)[:0]
+	for len(v) > 0 {
+		if len(v) < passwordAlgorithmSize {
+			return io.ErrUnexpectedEOF
+		}
+		params := passwordAlgorithmSize + nearestPaddedValueLength(int(bin.Uint16(v[2:4])))
+		if len(v) < params {
+			return io.ErrUnexpectedEOF
+		}
+		algorithms = append(algorithms, PasswordAlgorithm(bin.Uint16(v[0:2])))
+		v = v[params:]
+	}
+	*a = algorithms
+
+	return nil
+}
+
+// ErrUnsupportedPasswordAlgorithm means that long-term key can't be
+// derived using requested password algorithm.
+var ErrUnsupportedPasswordAlgorithm = errors.New("unsupported password algorithm")
+
+// NewLongTermIntegrityAlgorithm returns new MessageIntegrity with key for
+// long-term credentials, derived using alg as described in RFC 8489
+// Section 9.2.2. Username, realm and password are prepared as in
+// NewLongTermIntegrity. MD5 is unsupported if LegacyHashesEnabled is false.
+// Key is also used by MessageIntegritySHA256.
+func NewLongTermIntegrityAlgorithm(
+	username, realm, password string, alg PasswordAlgorithm,
+) (MessageIntegrity, error) {
+	switch {
+	case alg == PasswordAlgorithmMD5 && LegacyHashesEnabled():
+		return NewLongTermIntegrity(username, realm, password), nil
+	case alg == PasswordAlgorithmSHA256:
+		k := sha256.Sum256([]byte(longTermKey(username, realm, password)))
+
+		return MessageIntegrity(k[:]), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedPasswordAlgorithm, alg)
+	}
+}
+
+// NegotiatePasswordAlgorithm selects password algorithm for request that
+// retries authentication after 401 (Unauthorized) error response res,
+// as described in RFC 8489 Section 9.2.4. Supported algorithms default to
+// MD5 and SHA-256, or only SHA-256 if LegacyHashesEnabled is false.
+//
+// If nonce cookie of res advertises FeaturePasswordAlgorithms, the first
+// algorithm of PASSWORD-ALGORITHMS that is supported is returned, and
+// request must also include PASSWORD-ALGORITHMS of res. Otherwise server
+// follows RFC 5389 and zero algorithm is returned, see LongTermAuth.
+func NegotiatePasswordAlgorithm(res *Message, supported ...PasswordAlgorithm) (PasswordAlgorithm, error) {
+	var nonce NonceCookie
+	if err := nonce.GetFrom(res); err != nil {
+		return 0, err
+	}
+	if nonce.Features()&FeaturePasswordAlgorithms == 0 {
+		return 0, nil
+	}
+	var algorithms PasswordAlgorithms
+	if err := algorithms.GetFrom(res); errors.Is(err, ErrAttributeNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(supported) == 0 {
+		supported = supportedPasswordAlgorithms()
+	}
+	for _, alg := range algorithms {
+		for _, s := range supported {
+			if alg == s {
+				return alg, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("%w: none of %v", ErrUnsupportedPasswordAlgorithm, algorithms)
+}
+
+// Credentials are username and password of long-term credential
+// mechanism. Keys are derived from values prepared with
+// PrepareOpaqueString.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// authSetter applies setters in order, the last one being
+// MESSAGE-INTEGRITY or MESSAGE-INTEGRITY-SHA256, returning err if it is set.
+type authSetter struct {
+	setters []Setter
+	err     error
+}
+
+func (s authSetter) AddTo(m *Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, setter := range s.setters {
+		if err := setter.AddTo(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ShortTermAuth returns Setter that adds USERNAME and MESSAGE-INTEGRITY
+// of short-term credential mechanism (RFC 5389 Section 10.1).
+//
+// Integrity covers all preceding attributes, so pass it to Build after
+// all other setters except Fingerprint.
+func ShortTermAuth(username, password string) Setter {
+	return authSetter{setters: []Setter{
+		NewUsername(username),
+		NewShortTermIntegrity(password),
+	}}
+}
+
+// LongTermAuth returns Setter that adds USERNAME, REALM, NONCE,
+// PASSWORD-ALGORITHM and MESSAGE-INTEGRITY-SHA256 of long-term credential
+// mechanism (RFC 8489 Section 9.2). Zero alg means RFC 5389 behavior:
+// key is derived with MD5, PASSWORD-ALGORITHM is omitted and message is
+// protected with MESSAGE-INTEGRITY instead.
+//
+// Integrity covers all preceding attributes, so pass it to Build after
+// all other setters except Fingerprint.
+func LongTermAuth(creds Credentials, realm, nonce string, alg PasswordAlgorithm) Setter {
+	integrity, err := NewLongTermIntegrityAlgorithm(creds.Username, realm, creds.Password, keyAlgorithm(alg))
+	if err != nil {
+		return authSetter{err: err}
+	}
+
+	return longTermAuth(creds.Username, realm, nonce, alg, integrity)
+}
+
+func longTermAuth(username, realm, nonce string, alg PasswordAlgorithm, key []byte) Setter {
+	setters := []Setter{NewUsername(username), NewRealm(realm), NewNonce(nonce)}
+	if alg == 0 {
+		return authSetter{setters: append(setters, MessageIntegrity(key))}
+	}
+
+	return authSetter{setters: append(setters, alg, MessageIntegritySHA256(key))}
+}
+
+// supportedPasswordAlgorithms returns algorithms of key derivation that
+// are allowed, see LegacyHashesEnabled.
+func supportedPasswordAlgorithms() []PasswordAlgorithm {
+	if !LegacyHashesEnabled() {
+		return []PasswordAlgorithm{PasswordAlgorithmSHA256}
+	}
+
+	return []PasswordAlgorithm{PasswordAlgorithmMD5, PasswordAlgorithmSHA256}
+}
+
+// keyAlgorithm returns algorithm of key derivation for alg, where zero
+// alg means RFC 5389 MD5 derivation.
+func keyAlgorithm(alg PasswordAlgorithm) PasswordAlgorithm {
+	if alg == 0 {
+		return PasswordAlgorithmMD5
+	}
+
+	return alg
+}
+
+// LongTermCredentials are long-term credentials with keys derived once
+// for all supported password algorithms, so integrity of many messages
+// can be added or checked without deriving key for each of them, e.g.
+// by server that authenticates requests of the same user.
+//
+// LongTermCredentials are immutable and safe for concurrent use.
+type LongTermCredentials struct {
+	username string
+	realm    string
+	md5      MessageIntegrity // nil if MD5 is not available
+	sha256   MessageIntegrity
+}
+
+// NewLongTermCredentials derives and returns keys of username, realm and
+// password. Username and realm are prepared with PrepareOpaqueString, so
+// USERNAME and REALM attributes match the keys. MD5 key is not derived in
+// builds with fips tag.
+func NewLongTermCredentials(username, realm, password string) *LongTermCredentials {
+	sha256Key, _ := NewLongTermIntegrityAlgorithm(username, realm, password, PasswordAlgorithmSHA256)
+	md5, _ := md5Key(longTermKey(username, realm, password))
+
+	return &LongTermCredentials{
+		username: opaqueString(username),
+		realm:    opaqueString(realm),
+		md5:      md5,
+		sha256:   sha256Key,
+	}
+}
+
+// Username returns username of credentials.
+func (c *LongTermCredentials) Username() string { return c.username }
+
+// Realm returns realm of credentials.
+func (c *LongTermCredentials) Realm() string { return c.realm }
+
+// Key returns key for alg without allocation, zero alg means MD5. Key
+// must not be modified.
+func (c *LongTermCredentials) Key(alg PasswordAlgorithm) (MessageIntegrity, error) {
+	switch keyAlgorithm(alg) {
+	case PasswordAlgorithmMD5:
+		if !LegacyHashesEnabled() {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedPasswordAlgorithm, PasswordAlgorithmMD5)
+		}
+
+		return c.md5, nil
+	case PasswordAlgorithmSHA256:
+		return c.sha256, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedPasswordAlgorithm, alg)
+	}
+}
+
+// Check checks MESSAGE-INTEGRITY-SHA256 or MESSAGE-INTEGRITY of m with key
+// for alg, see Key and IntegrityFor.
+func (c *LongTermCredentials) Check(m *Message, alg PasswordAlgorithm) error {
+	key, err := c.Key(alg)
+	if err != nil {
+		return err
+	}
+
+	return IntegrityFor(m, key).Check(m)
+}
+
+// Auth is like LongTermAuth, but uses derived key.
+func (c *LongTermCredentials) Auth(nonce string, alg PasswordAlgorithm) Setter {
+	key, err := c.Key(alg)
+	if err != nil {
+		return authSetter{err: err}
+	}
+
+	return longTermAuth(c.username, c.realm, nonce, alg, key)
+}
+
+// Unauthenticated returns Setter that adds no attributes, for symmetry
+// with ShortTermAuth and LongTermAuth where auth mode is selected at
+// run time.
+func Unauthenticated() Setter {
+	return authSetter{}
+}
@@ -0,0 +1,236 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+func TestPasswordAlgorithm(t *testing.T) {
+	m := MustBuild(BindingRequest, PasswordAlgorithmSHA256)
+	var alg PasswordAlgorithm
+	if err := alg.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if alg != PasswordAlgorithmSHA256 {
+		t.Errorf("unexpected algorithm: %s", alg)
+	}
+	m = MustBuild(BindingRequest, RawAttribute{Type: AttrPasswordAlgorithm, Value: []byte{0, 1}})
+	if err := alg.GetFrom(m); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := alg.GetFrom(MustBuild(BindingRequest)); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for alg, s := range map[PasswordAlgorithm]string{
+		PasswordAlgorithmMD5:    "MD5",
+		PasswordAlgorithmSHA256: "SHA-256",
+		0x0042:                  "0x0042",
+	} {
+		if alg.String() != s {
+			t.Errorf("%q != %q", alg, s)
+		}
+	}
+}
+
+func TestPasswordAlgorithms(t *testing.T) {
+	algorithms := PasswordAlgorithms{PasswordAlgorithmSHA256, PasswordAlgorithmMD5}
+	m := MustBuild(BindingRequest, algorithms)
+	var got PasswordAlgorithms
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != algorithms[0] || got[1] != algorithms[1] {
+		t.Errorf("unexpected algorithms: %v", got)
+	}
+	// Parameters are skipped with padding.
+	m = MustBuild(BindingRequest, RawAttribute{Type: AttrPasswordAlgorithms, Value: []byte{
+		0, 0x42, 0, 1, 0xff, 0, 0, 0,
+		0, 2, 0, 0,
+	}})
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != 0x42 || got[1] != PasswordAlgorithmSHA256 {
+		t.Errorf("unexpected algorithms: %v", got)
+	}
+	for _, v := range [][]byte{{0, 1}, {0, 1, 0, 4, 0}} {
+		m = MustBuild(BindingRequest, RawAttribute{Type: AttrPasswordAlgorithms, Value: v})
+		if err := got.GetFrom(m); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%v: unexpected error: %v", v, err)
+		}
+	}
+}
+
+func TestNegotiatePasswordAlgorithm(t *testing.T) {
+	algorithms := PasswordAlgorithms{PasswordAlgorithmSHA256, PasswordAlgorithmMD5}
+	for _, tc := range []struct {
+		name      string
+		setters   []Setter
+		supported []PasswordAlgorithm
+		expected  PasswordAlgorithm
+		err       error
+	}{
+		{"RFC5389", []Setter{NewNonce("nonce"), algorithms}, nil, 0, nil},
+		{"NoAlgorithms", []Setter{NewNonceCookie(FeaturePasswordAlgorithms, "nonce")}, nil, 0, nil},
+		{"Preferred", []Setter{NewNonceCookie(FeaturePasswordAlgorithms, "nonce"), algorithms}, nil, PasswordAlgorithmSHA256, nil},
+		{"Supported", []Setter{
+			NewNonceCookie(FeaturePasswordAlgorithms, "nonce"), algorithms,
+		}, []PasswordAlgorithm{PasswordAlgorithmMD5}, PasswordAlgorithmMD5, nil},
+		{"Unsupported", []Setter{
+			NewNonceCookie(FeaturePasswordAlgorithms, "nonce"), PasswordAlgorithms{0x42},
+		}, nil, 0, ErrUnsupportedPasswordAlgorithm},
+		{"NoNonce", nil, nil, 0, ErrAttributeNotFound},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			res := MustBuild(append([]Setter{TransactionID, BindingError, CodeUnauthorized}, tc.setters...)...)
+			alg, err := NegotiatePasswordAlgorithm(res, tc.supported...)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if alg != tc.expected {
+				t.Errorf("unexpected algorithm: %s", alg)
+			}
+		})
+	}
+}
+
+func TestNewLongTermIntegrityAlgorithm(t *testing.T) {
+	// RFC 8489 Section 9.2.2: key = SHA-256(username ":" realm ":" password).
+	i, err := NewLongTermIntegrityAlgorithm("user", "realm", "pass", PasswordAlgorithmSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(i) != 32 {
+		t.Errorf("unexpected key length: %d", len(i))
+	}
+	if _, err = NewLongTermIntegrityAlgorithm("user", "realm", "pass", 0x0042); !errors.Is(err, ErrUnsupportedPasswordAlgorithm) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestShortTermAuth(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest, ShortTermAuth("user", "pass"), Fingerprint)
+	var username Username
+	if err := username.GetFrom(m); err != nil || username.String() != "user" {
+		t.Errorf("unexpected username %q: %v", username, err)
+	}
+	if err := NewShortTermIntegrity("pass").Check(m); err != nil {
+		t.Error(err)
+	}
+	if err := Fingerprint.Check(m); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLongTermAuth(t *testing.T) {
+	creds := Credentials{Username: "user", Password: "pass"}
+	t.Run("Legacy", func(t *testing.T) {
+		testutil.SkipWithoutMD5(t)
+		m := MustBuild(TransactionID, BindingRequest, LongTermAuth(creds, "realm", "nonce", 0))
+		if m.Contains(AttrPasswordAlgorithm) {
+			t.Error("PASSWORD-ALGORITHM should be omitted")
+		}
+		if err := NewLongTermIntegrity("user", "realm", "pass").Check(m); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("SHA256", func(t *testing.T) {
+		m := MustBuild(TransactionID, BindingRequest, LongTermAuth(creds, "realm", "nonce", PasswordAlgorithmSHA256))
+		var (
+			alg   PasswordAlgorithm
+			realm Realm
+			nonce Nonce
+		)
+		if err := m.Parse(&alg, &realm, &nonce); err != nil {
+			t.Fatal(err)
+		}
+		if alg != PasswordAlgorithmSHA256 || realm.String() != "realm" || nonce.String() != "nonce" {
+			t.Errorf("unexpected attributes: %s", m)
+		}
+		key, _ := NewLongTermIntegrityAlgorithm("user", "realm", "pass", PasswordAlgorithmSHA256)
+		if err := MessageIntegritySHA256(key).Check(m); err != nil {
+			t.Error(err)
+		}
+		if m.Contains(AttrMessageIntegrity) {
+			t.Error("MESSAGE-INTEGRITY should be omitted")
+		}
+		if last := m.Attributes[len(m.Attributes)-1]; last.Type != AttrMessageIntegritySHA256 {
+			t.Errorf("integrity should be last, got %s", last.Type)
+		}
+	})
+	t.Run("UnsupportedAlgorithm", func(t *testing.T) {
+		if _, err := Build(BindingRequest, LongTermAuth(creds, "realm", "nonce", 0x0042)); !errors.Is(err, ErrUnsupportedPasswordAlgorithm) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestLongTermCredentials(t *testing.T) {
+	creds := NewLongTermCredentials("user", "realm", "pass")
+	if creds.Username() != "user" || creds.Realm() != "realm" {
+		t.Errorf("unexpected credentials: %s %s", creds.Username(), creds.Realm())
+	}
+	for _, alg := range []PasswordAlgorithm{0, PasswordAlgorithmMD5, PasswordAlgorithmSHA256} {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			if keyAlgorithm(alg) == PasswordAlgorithmMD5 {
+				testutil.SkipWithoutMD5(t)
+			}
+			expected, err := NewLongTermIntegrityAlgorithm("user", "realm", "pass", keyAlgorithm(alg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := creds.Key(alg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(key, expected) {
+				t.Errorf("unexpected key: %s", key)
+			}
+			m := MustBuild(TransactionID, BindingRequest, creds.Auth("nonce", alg))
+			if err = creds.Check(m, alg); err != nil {
+				t.Error(err)
+			}
+			testutil.ShouldNotAllocate(t, func() {
+				if _, keyErr := creds.Key(alg); keyErr != nil {
+					t.Error(keyErr)
+				}
+			})
+		})
+	}
+	if _, err := creds.Key(0x0042); !errors.Is(err, ErrUnsupportedPasswordAlgorithm) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := creds.Check(new(Message), 0x0042); !errors.Is(err, ErrUnsupportedPasswordAlgorithm) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Build(BindingRequest, creds.Auth("nonce", 0x0042)); !errors.Is(err, ErrUnsupportedPasswordAlgorithm) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func BenchmarkLongTermCredentials_Check(b *testing.B) {
+	creds := NewLongTermCredentials("user", "realm", "pass")
+	m := MustBuild(TransactionID, BindingRequest, creds.Auth("nonce", 0))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := creds.Check(m, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestUnauthenticated(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest, Unauthenticated())
+	if len(m.Attributes) != 0 {
+		t.Errorf("unexpected attributes: %s", m)
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Schedule is exponential or linear backoff with optional jitter.
+//
+// Client computes retransmission timeouts with Schedule, which is
+// LinearSchedule of RTO set by WithRTO by default or the one set by
+// WithSchedule. Other packages (e.g. TURN clients or ICE agents) can
+// reuse Schedule to get exactly the same timing behavior.
+type Schedule struct {
+	// Initial is the delay before the first retry.
+	Initial time.Duration
+	// Max limits single delay, zero means no limit.
+	Max time.Duration
+	// Multiplier is the growth factor between consecutive delays.
+	// Values less than 1 are treated as 1 (constant delay).
+	Multiplier float64
+	// Increment is added to delay after each retry, so delay grows
+	// linearly if Multiplier is 1.
+	Increment time.Duration
+	// Jitter is the fraction of delay that is randomized, from 0 (no
+	// jitter) to 1 (delay is uniformly distributed in [0, 2*delay)).
+	Jitter float64
+}
+
+// Default retransmission schedule parameters from RFC 5389 Section 7.2.1.
+const (
+	defaultScheduleInitial    = 500 * time.Millisecond
+	defaultScheduleMultiplier = 2
+)
+
+// DefaultSchedule returns the retransmission schedule recommended by
+// RFC 5389 Section 7.2.1: starting with 500ms and doubling after each
+// retransmission. Note that Client uses LinearSchedule unless schedule
+// is set by WithSchedule.
+func DefaultSchedule() Schedule {
+	return Schedule{
+		Initial:    defaultScheduleInitial,
+		Multiplier: defaultScheduleMultiplier,
+	}
+}
+
+// LinearSchedule returns schedule that starts with rto and grows by rto
+// after each retransmission, which is the default schedule of Client.
+func LinearSchedule(rto time.Duration) Schedule {
+	return Schedule{
+		Initial:   rto,
+		Increment: rto,
+	}
+}
+
+// Delay returns delay for zero-based attempt without jitter.
+func (s Schedule) Delay(attempt int) time.Duration {
+	if attempt < 0 {
+		attempt = 0
+	}
+	multiplier := s.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(s.Initial)*math.Pow(multiplier, float64(attempt)) + float64(s.Increment)*float64(attempt)
+	if s.Max > 0 && d > float64(s.Max) {
+		return s.Max
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(d)
+}
+
+// Next returns delay for zero-based attempt with jitter applied.
+func (s Schedule) Next(attempt int) time.Duration {
+	d := s.Delay(attempt)
+	jitter := s.Jitter
+	if jitter <= 0 || d <= 0 {
+		return d
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+	// Shifting delay by random value in [-jitter*d, jitter*d).
+	delta := jitter * float64(d)
+	d += time.Duration(delta * (2*rand.Float64() - 1)) //nolint:gosec
+	if s.Max > 0 && d > s.Max {
+		return s.Max
+	}
+
+	return d
+}
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_Delay(t *testing.T) {
+	s := DefaultSchedule()
+	for i, expected := range []time.Duration{
+		500 * time.Millisecond,
+		1000 * time.Millisecond,
+		2000 * time.Millisecond,
+		4000 * time.Millisecond,
+	} {
+		if got := s.Delay(i); got != expected {
+			t.Errorf("Delay(%d) = %s, expected %s", i, got, expected)
+		}
+	}
+	if got := s.Delay(-1); got != s.Initial {
+		t.Errorf("Delay(-1) = %s, expected %s", got, s.Initial)
+	}
+	s.Max = 3 * time.Second
+	if got := s.Delay(10); got != s.Max {
+		t.Errorf("Delay(10) = %s, expected %s", got, s.Max)
+	}
+	constant := Schedule{Initial: time.Second}
+	if got := constant.Delay(5); got != time.Second {
+		t.Errorf("Delay(5) = %s, expected %s", got, time.Second)
+	}
+	huge := Schedule{Initial: time.Hour, Multiplier: 10}
+	if got := huge.Delay(100); got <= 0 {
+		t.Errorf("Delay(100) overflowed: %s", got)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	s := Schedule{
+		Initial:    100 * time.Millisecond,
+		Multiplier: 2,
+		Jitter:     0.5,
+	}
+	for i := 0; i < 100; i++ {
+		d := s.Next(1)
+		if d < 100*time.Millisecond || d > 300*time.Millisecond {
+			t.Fatalf("Next(1) = %s is out of jitter range", d)
+		}
+	}
+	s.Jitter = 0
+	if got := s.Next(1); got != 200*time.Millisecond {
+		t.Errorf("Next(1) without jitter = %s", got)
+	}
+	s.Jitter = 5
+	s.Max = 150 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		if d := s.Next(3); d > s.Max {
+			t.Fatalf("Next(3) = %s exceeds max", d)
+		}
+	}
+}
+
+func TestLinearSchedule(t *testing.T) {
+	const rto = 300 * time.Millisecond
+	s := LinearSchedule(rto)
+	for attempt := 0; attempt < 7; attempt++ {
+		// Client timeout before Schedule: (attempt+1)*rto.
+		expected := time.Duration(attempt+1) * rto
+		if got := s.Next(attempt); got != expected {
+			t.Errorf("Next(%d) = %s, expected %s", attempt, got, expected)
+		}
+	}
+}
+
+func TestClientTransaction_nextTimeout(t *testing.T) {
+	now := time.Now()
+	tr := &clientTransaction{schedule: LinearSchedule(time.Second), attempt: 1}
+	if got := tr.nextTimeout(now); !got.Equal(now.Add(2 * time.Second)) {
+		t.Errorf("unexpected linear timeout: %s", got.Sub(now))
+	}
+	tr.schedule = Schedule{Initial: time.Second, Multiplier: 3}
+	if got := tr.nextTimeout(now); !got.Equal(now.Add(3 * time.Second)) {
+		t.Errorf("unexpected scheduled timeout: %s", got.Sub(now))
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import "errors"
+
+// Priority of Setter in BuildWithBudget. Setters with negative priority
+// are optional and can be dropped to fit the budget, lowest first.
+type Priority int
+
+// Predefined priorities.
+const (
+	PriorityPadding   Priority = -20
+	PrioritySoftware  Priority = -10
+	PriorityMandatory Priority = 0
+)
+
+// Prioritized is implemented by setters that have priority other than
+// PriorityMandatory by default, e.g. Software and Padding.
+type Prioritized interface {
+	Priority() Priority
+}
+
+// Priority returns PrioritySoftware.
+func (Software) Priority() Priority { return PrioritySoftware }
+
+// Priority returns PriorityPadding.
+func (Padding) Priority() Priority { return PriorityPadding }
+
+type prioritizedSetter struct {
+	Setter
+	priority Priority
+}
+
+func (s prioritizedSetter) Priority() Priority { return s.priority }
+
+// WithPriority wraps s, overriding its priority in BuildWithBudget.
+func WithPriority(s Setter, p Priority) Setter {
+	return prioritizedSetter{Setter: s, priority: p}
+}
+
+// priorityOf returns priority of s, defaulting to PriorityMandatory.
+func priorityOf(s Setter) Priority {
+	if p, ok := s.(Prioritized); ok {
+		return p.Priority()
+	}
+
+	return PriorityMandatory
+}
+
+// ErrBudgetExceeded means that message does not fit the budget even
+// without optional attributes.
+var ErrBudgetExceeded = errors.New("message exceeds size budget")
+
+// BuildWithBudget is like Build, but ensures that encoded message size
+// is not bigger than budget bytes by dropping optional setters, the ones
+// with negative priority, from lowest priority to highest, and from last
+// to first within same priority. Returns dropped setters, or
+// ErrBudgetExceeded if message does not fit with mandatory setters only.
+//
+// Message is rebuilt after each drop, so setters can be applied several
+// times.
+//
+// Useful for MTU-constrained probes that still want maximal metadata.
+func (m *Message) BuildWithBudget(budget int, setters ...Setter) ([]Setter, error) {
+	active := append([]Setter(nil), setters...)
+	var dropped []Setter
+	for {
+		if err := m.Build(active...); err != nil {
+			return dropped, err
+		}
+		if len(m.Raw) <= budget {
+			return dropped, nil
+		}
+		drop := -1
+		for i, s := range active {
+			p := priorityOf(s)
+			if p < PriorityMandatory && (drop < 0 || p <= priorityOf(active[drop])) {
+				drop = i
+			}
+		}
+		if drop < 0 {
+			return dropped, ErrBudgetExceeded
+		}
+		dropped = append(dropped, active[drop])
+		active = append(active[:drop], active[drop+1:]...)
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMessage_BuildWithBudget(t *testing.T) {
+	var (
+		software = NewSoftware("software that has quite a long name")
+		padding  = Padding(64)
+		username = NewUsername("user")
+		full     = MustBuild(TransactionID, BindingRequest, username, padding, software, Fingerprint)
+		noPad    = MustBuild(TransactionID, BindingRequest, username, software, Fingerprint)
+		minimal  = MustBuild(TransactionID, BindingRequest, username, Fingerprint)
+	)
+	for _, tc := range []struct {
+		name    string
+		budget  int
+		dropped int
+		err     error
+	}{
+		{"Fits", len(full.Raw), 0, nil},
+		{"DropPadding", len(noPad.Raw), 1, nil},
+		{"DropBoth", len(minimal.Raw), 2, nil},
+		{"Exceeded", len(minimal.Raw) - 1, 2, ErrBudgetExceeded},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(Message)
+			dropped, err := m.BuildWithBudget(tc.budget,
+				TransactionID, BindingRequest, username, padding, software, Fingerprint,
+			)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(dropped) != tc.dropped {
+				t.Fatalf("unexpected dropped count: %d", len(dropped))
+			}
+			if tc.dropped > 0 && priorityOf(dropped[0]) != PriorityPadding {
+				t.Error("padding should be dropped first")
+			}
+			if err == nil && len(m.Raw) > tc.budget {
+				t.Errorf("message size %d exceeds budget %d", len(m.Raw), tc.budget)
+			}
+			if err == nil && !m.Contains(AttrUsername) {
+				t.Error("mandatory attribute dropped")
+			}
+		})
+	}
+}
+
+func TestWithPriority(t *testing.T) {
+	if priorityOf(NewUsername("u")) != PriorityMandatory {
+		t.Error("setters should be mandatory by default")
+	}
+	if priorityOf(NewSoftware("s")) != PrioritySoftware {
+		t.Error("unexpected software priority")
+	}
+	if priorityOf(Padding(4)) != PriorityPadding {
+		t.Error("unexpected padding priority")
+	}
+	if priorityOf(WithPriority(NewSoftware("s"), PriorityMandatory)) != PriorityMandatory {
+		t.Error("priority should be overridden")
+	}
+}
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+)
+
+// ErrNoMessageType means that MessageBuilder has no message type set.
+var ErrNoMessageType = errors.New("message type is not set")
+
+// MessageBuilder builds message step by step, checking that integrity
+// and fingerprint are added last:
+//
+//	m, err := NewBuilder().
+//		BindingRequest().
+//		Username("user").
+//		Integrity(NewShortTermIntegrity("pass")).
+//		Fingerprint().
+//		Build()
+//
+// Transaction ID is random unless set by TransactionID. Errors are
+// reported by Build. Zero value is not usable, use NewBuilder.
+type MessageBuilder struct {
+	msgType *MessageType
+	id      Setter
+	setters []Setter
+	rank    int // attrRank of last added integrity or fingerprint
+	err     error
+}
+
+// NewBuilder returns new MessageBuilder.
+func NewBuilder() *MessageBuilder {
+	return &MessageBuilder{id: TransactionID}
+}
+
+// Type sets message type.
+func (b *MessageBuilder) Type(t MessageType) *MessageBuilder {
+	b.msgType = &t
+
+	return b
+}
+
+// BindingRequest sets Binding request message type.
+func (b *MessageBuilder) BindingRequest() *MessageBuilder {
+	return b.Type(BindingRequest)
+}
+
+// BindingSuccess sets Binding success response message type.
+func (b *MessageBuilder) BindingSuccess() *MessageBuilder {
+	return b.Type(BindingSuccess)
+}
+
+// BindingIndication sets Binding indication message type.
+func (b *MessageBuilder) BindingIndication() *MessageBuilder {
+	return b.Type(NewType(MethodBinding, ClassIndication))
+}
+
+// TransactionID sets transaction ID of message.
+func (b *MessageBuilder) TransactionID(id [TransactionIDSize]byte) *MessageBuilder {
+	b.id = NewTransactionIDSetter(id)
+
+	return b
+}
+
+// Add adds attribute of s, which must be added before integrity and
+// fingerprint.
+func (b *MessageBuilder) Add(s Setter) *MessageBuilder {
+	return b.add(0, s)
+}
+
+// Username adds USERNAME attribute.
+func (b *MessageBuilder) Username(username string) *MessageBuilder {
+	return b.Add(NewUsername(username))
+}
+
+// Realm adds REALM attribute.
+func (b *MessageBuilder) Realm(realm string) *MessageBuilder {
+	return b.Add(NewRealm(realm))
+}
+
+// Nonce adds NONCE attribute.
+func (b *MessageBuilder) Nonce(nonce string) *MessageBuilder {
+	return b.Add(NewNonce(nonce))
+}
+
+// Software adds SOFTWARE attribute.
+func (b *MessageBuilder) Software(software string) *MessageBuilder {
+	return b.Add(NewSoftware(software))
+}
+
+// Integrity adds MESSAGE-INTEGRITY attribute with key, which must be
+// added after all attributes except fingerprint.
+func (b *MessageBuilder) Integrity(key MessageIntegrity) *MessageBuilder {
+	return b.add(attrRank(AttrMessageIntegrity), key)
+}
+
+// Fingerprint adds FINGERPRINT attribute, which must be the last one.
+func (b *MessageBuilder) Fingerprint() *MessageBuilder {
+	return b.add(attrRank(AttrFingerprint), Fingerprint)
+}
+
+func (b *MessageBuilder) add(rank int, s Setter) *MessageBuilder {
+	if b.err == nil && b.rank > 0 && rank <= b.rank {
+		b.err = ErrAttributeOrder
+	}
+	if rank > 0 {
+		b.rank = rank
+	}
+	b.setters = append(b.setters, s)
+
+	return b
+}
+
+// Build returns new message, or first error of builder. Builder can be
+// used again to build another message with the same attributes and new
+// transaction ID.
+func (b *MessageBuilder) Build() (*Message, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.msgType == nil {
+		return nil, ErrNoMessageType
+	}
+	setters := make([]Setter, 0, len(b.setters)+2)
+	setters = append(setters, b.id, *b.msgType)
+	setters = append(setters, b.setters...)
+	m, err := Build(setters...)
+	if err != nil {
+		return nil, err
+	}
+	if err = CheckAttributeOrder(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
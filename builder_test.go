@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	var (
+		id        = [TransactionIDSize]byte{1, 2, 3}
+		integrity = NewShortTermIntegrity("pass")
+	)
+	m, err := NewBuilder().
+		BindingRequest().
+		TransactionID(id).
+		Username("user").
+		Realm("realm").
+		Nonce("nonce").
+		Software("software").
+		Integrity(integrity).
+		Fingerprint().
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := MustBuild(NewTransactionIDSetter(id), BindingRequest,
+		NewUsername("user"), NewRealm("realm"), NewNonce("nonce"), NewSoftware("software"),
+		integrity, Fingerprint,
+	)
+	if !bytes.Equal(m.Raw, expected.Raw) {
+		t.Errorf("unexpected message: %s", m)
+	}
+	t.Run("TransactionID", func(t *testing.T) {
+		b := NewBuilder().BindingIndication()
+		first, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.TransactionID == second.TransactionID {
+			t.Error("transaction ID should be random")
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		for name, b := range map[string]*MessageBuilder{
+			"AfterIntegrity":   NewBuilder().BindingSuccess().Integrity(integrity).Username("user"),
+			"AfterFingerprint": NewBuilder().BindingSuccess().Fingerprint().Integrity(integrity),
+			"Duplicate":        NewBuilder().BindingSuccess().Fingerprint().Fingerprint(),
+		} {
+			if _, err := b.Build(); !errors.Is(err, ErrAttributeOrder) {
+				t.Errorf("%s: unexpected error: %v", name, err)
+			}
+		}
+		if _, err := NewBuilder().Username("user").Build(); !errors.Is(err, ErrNoMessageType) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := NewBuilder().BindingRequest().Add(Fingerprint).Username("user").Build(); !errors.Is(err, ErrAttributeOrder) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
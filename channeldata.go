@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ChannelNumber represents CHANNEL-NUMBER attribute and channel of TURN
+// ChannelData message.
+//
+// RFC 8656 Section 18.1.
+type ChannelNumber uint16
+
+// Range of channel numbers that can be bound by TURN client.
+const (
+	MinChannelNumber ChannelNumber = 0x4000
+	MaxChannelNumber ChannelNumber = 0x4FFF
+)
+
+const channelNumberSize = 4 // number and RFFU
+
+// Valid reports whether n is in range that can be bound by client.
+func (n ChannelNumber) Valid() bool {
+	return n >= MinChannelNumber && n <= MaxChannelNumber
+}
+
+func (n ChannelNumber) String() string {
+	return fmt.Sprintf("0x%04x", uint16(n))
+}
+
+// AddTo adds CHANNEL-NUMBER attribute to message.
+func (n ChannelNumber) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	v := make([]byte, channelNumberSize)
+	bin.PutUint16(v[0:2], uint16(n))
+	m.Add(AttrChannelNumber, v)
+
+	return nil
+}
+
+// GetFrom decodes CHANNEL-NUMBER from message.
+func (n *ChannelNumber) GetFrom(m *Message) error {
+	v, err := m.Get(AttrChannelNumber)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrChannelNumber, len(v), channelNumberSize); err != nil {
+		return err
+	}
+	*n = ChannelNumber(bin.Uint16(v[0:2]))
+
+	return nil
+}
+
+// channelDataHeaderSize is size of channel number and length.
+const channelDataHeaderSize = 4
+
+var (
+	// ErrInvalidChannelNumber means that channel number of ChannelData is
+	// not in range of MinChannelNumber and MaxChannelNumber.
+	ErrInvalidChannelNumber = errors.New("channel number is not in valid range")
+	// ErrBadChannelDataLength means that length of ChannelData is not
+	// consistent with its buffer.
+	ErrBadChannelDataLength = errors.New("channel data length does not match buffer")
+)
+
+// ChannelData is TURN ChannelData message, which carries application
+// data of channel with 4-byte header instead of STUN message.
+//
+// RFC 8656 Section 12.4.
+type ChannelData struct {
+	Number ChannelNumber
+	Data   []byte // sub-slice of Raw after Decode
+	Raw    []byte
+}
+
+// Encode encodes c into c.Raw for datagram transport, reusing its buffer.
+func (c *ChannelData) Encode() {
+	c.Raw = c.AppendTo(c.Raw[:0], false)
+}
+
+// EncodePadded encodes c into c.Raw for stream transport like TCP or TLS,
+// where ChannelData is padded to multiple of 4 bytes. Padding is not
+// included in length.
+func (c *ChannelData) EncodePadded() {
+	c.Raw = c.AppendTo(c.Raw[:0], true)
+}
+
+// AppendTo appends encoded c to buf, padded if pad is true, and returns
+// the extended buffer. Data of c should not alias buf.
+func (c *ChannelData) AppendTo(buf []byte, pad bool) []byte {
+	buf = append(buf, 0, 0, 0, 0)
+	header := buf[len(buf)-channelDataHeaderSize:]
+	bin.PutUint16(header[0:2], uint16(c.Number))
+	bin.PutUint16(header[2:4], uint16(len(c.Data))) //nolint:gosec // G115
+	buf = append(buf, c.Data...)
+	if pad {
+		for i := len(c.Data); i < nearestPaddedValueLength(len(c.Data)); i++ {
+			buf = append(buf, 0)
+		}
+	}
+
+	return buf
+}
+
+// Decode decodes c.Raw into c, accepting padding of stream transports.
+// Data is sub-slice of c.Raw.
+func (c *ChannelData) Decode() error {
+	if len(c.Raw) < channelDataHeaderSize {
+		return ErrUnexpectedHeaderEOF
+	}
+	number := ChannelNumber(bin.Uint16(c.Raw[0:2]))
+	if !number.Valid() {
+		return ErrInvalidChannelNumber
+	}
+	size := int(bin.Uint16(c.Raw[2:4]))
+	end := channelDataHeaderSize + size
+	if len(c.Raw) < end || len(c.Raw) > channelDataHeaderSize+nearestPaddedValueLength(size) {
+		return ErrBadChannelDataLength
+	}
+	c.Number = number
+	c.Data = c.Raw[channelDataHeaderSize:end]
+
+	return nil
+}
+
+func (c *ChannelData) String() string {
+	return fmt.Sprintf("ChannelData %s l=%d", c.Number, len(c.Data))
+}
+
+// IsChannelData reports whether b is ChannelData message: channel number
+// is in valid range and length is consistent with size of b, allowing
+// padding. Use Demux to classify datagrams of shared socket.
+func IsChannelData(b []byte) bool {
+	c := ChannelData{Raw: b}
+
+	return c.Decode() == nil
+}
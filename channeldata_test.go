@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestChannelNumber(t *testing.T) {
+	m := MustBuild(TransactionID, NewType(MethodChannelBind, ClassRequest), ChannelNumber(0x4001))
+	var n ChannelNumber
+	if err := n.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0x4001 || !n.Valid() || n.String() != "0x4001" {
+		t.Errorf("unexpected number %s", n)
+	}
+	if ChannelNumber(0x3FFF).Valid() || ChannelNumber(0x5000).Valid() {
+		t.Error("numbers out of range should be invalid")
+	}
+	m = MustBuild(TransactionID, BindingRequest, RawAttribute{Type: AttrChannelNumber, Value: []byte{0x40}})
+	if err := n.GetFrom(m); !IsAttrSizeInvalid(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := n.GetFrom(MustBuild(TransactionID, BindingRequest)); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestChannelData(t *testing.T) {
+	c := &ChannelData{Number: 0x4000, Data: []byte{1, 2, 3, 4, 5}}
+	c.Encode()
+	if !bytes.Equal(c.Raw, []byte{0x40, 0, 0, 5, 1, 2, 3, 4, 5}) {
+		t.Errorf("unexpected encoding: %x", c.Raw)
+	}
+	c.EncodePadded()
+	padded := []byte{0x40, 0, 0, 5, 1, 2, 3, 4, 5, 0, 0, 0}
+	if !bytes.Equal(c.Raw, padded) {
+		t.Errorf("unexpected padded encoding: %x", c.Raw)
+	}
+	for _, raw := range [][]byte{padded, padded[:9]} {
+		decoded := &ChannelData{Raw: raw}
+		if err := decoded.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Number != c.Number || !bytes.Equal(decoded.Data, c.Data) {
+			t.Errorf("unexpected %s", decoded)
+		}
+		if !IsChannelData(raw) {
+			t.Errorf("%x should be ChannelData", raw)
+		}
+	}
+	for _, tc := range []struct {
+		name string
+		raw  []byte
+		err  error
+	}{
+		{"Short", []byte{0x40, 0}, ErrUnexpectedHeaderEOF},
+		{"Number", []byte{0x50, 0, 0, 0}, ErrInvalidChannelNumber},
+		{"Truncated", padded[:8], ErrBadChannelDataLength},
+		{"Trailing", append(padded, 0), ErrBadChannelDataLength},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded := &ChannelData{Raw: tc.raw}
+			if err := decoded.Decode(); !errors.Is(err, tc.err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if IsChannelData(tc.raw) {
+				t.Error("should not be ChannelData")
+			}
+		})
+	}
+	if Demux(padded[:8]) != PacketUnknown {
+		t.Error("truncated ChannelData should not be classified")
+	}
+}
+
+func BenchmarkChannelData_Decode(b *testing.B) {
+	c := &ChannelData{Number: 0x4000, Data: make([]byte, 100)}
+	c.Encode()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := c.Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -7,12 +7,14 @@
 package stun
 
 import (
+	"crypto/subtle"
 	"errors"
-
-	"github.com/pion/stun/v3/internal/hmac"
 )
 
-// CheckSize returns ErrAttrSizeInvalid if got is not equal to expected.
+// CheckSize returns ErrAttributeSizeInvalid if got is not equal to
+// expected. Attributes that are implemented outside of package can use it
+// in GetFrom, so callers check their errors with IsAttrSizeInvalid like
+// errors of built-in attributes.
 func CheckSize(_ AttrType, got, expected int) error {
 	if got == expected {
 		return nil
@@ -21,8 +23,10 @@ func CheckSize(_ AttrType, got, expected int) error {
 	return ErrAttributeSizeInvalid
 }
 
+// checkHMAC compares HMACs in constant time, so comparison does not leak
+// how many bytes of expected value are guessed.
 func checkHMAC(got, expected []byte) error {
-	if hmac.Equal(got, expected) {
+	if subtle.ConstantTimeCompare(got, expected) == 1 {
 		return nil
 	}
 
@@ -38,11 +42,14 @@ func checkFingerprint(got, expected uint32) error {
 }
 
 // IsAttrSizeInvalid returns true if error means that attribute size is invalid.
+// Wrapped errors are matched too.
 func IsAttrSizeInvalid(err error) bool {
 	return errors.Is(err, ErrAttributeSizeInvalid)
 }
 
-// CheckOverflow returns ErrAttributeSizeOverflow if got is bigger that max.
+// CheckOverflow returns ErrAttributeSizeOverflow if got is bigger that
+// maxVal. Like CheckSize, it is intended for AddTo of attributes that are
+// implemented outside of package, see IsAttrSizeOverflow.
 func CheckOverflow(_ AttrType, got, maxVal int) error {
 	if got <= maxVal {
 		return nil
@@ -52,6 +59,7 @@ func CheckOverflow(_ AttrType, got, maxVal int) error {
 }
 
 // IsAttrSizeOverflow returns true if error means that attribute size is too big.
+// Wrapped errors are matched too.
 func IsAttrSizeOverflow(err error) bool {
 	return errors.Is(err, ErrAttributeSizeOverflow)
 }
@@ -6,9 +6,15 @@
 
 package stun
 
-import "github.com/pion/stun/v3/internal/hmac"
+import (
+	"crypto/subtle"
+	"errors"
+)
 
-// CheckSize returns *AttrLengthError if got is not equal to expected.
+// CheckSize returns *AttrLengthErr if got is not equal to expected.
+// Attributes that are implemented outside of package can use it in
+// GetFrom, so callers check their errors with IsAttrSizeInvalid like
+// errors of built-in attributes.
 func CheckSize(a AttrType, got, expected int) error {
 	if got == expected {
 		return nil
@@ -20,12 +26,14 @@ func CheckSize(a AttrType, got, expected int) error {
 	}
 }
 
+// checkHMAC compares HMACs in constant time, so comparison does not leak
+// how many bytes of expected value are guessed.
 func checkHMAC(got, expected []byte) error {
-	if hmac.Equal(got, expected) {
+	if subtle.ConstantTimeCompare(got, expected) == 1 {
 		return nil
 	}
 	return &IntegrityErr{
-		Expected: expected,
+		Expected: append([]byte(nil), expected...), // expected is pooled
 		Actual:   got,
 	}
 }
@@ -41,12 +49,14 @@ func checkFingerprint(got, expected uint32) error {
 }
 
 // IsAttrSizeInvalid returns true if error means that attribute size is invalid.
+// Wrapped errors are matched too.
 func IsAttrSizeInvalid(err error) bool {
-	_, ok := err.(*AttrLengthErr)
-	return ok
+	return errors.Is(err, ErrAttributeSizeInvalid)
 }
 
 // CheckOverflow returns *AttrOverflowErr if got is bigger that max.
+// Like CheckSize, it is intended for AddTo of attributes that are
+// implemented outside of package, see IsAttrSizeOverflow.
 func CheckOverflow(t AttrType, got, max int) error {
 	if got <= max {
 		return nil
@@ -59,7 +69,7 @@ func CheckOverflow(t AttrType, got, max int) error {
 }
 
 // IsAttrSizeOverflow returns true if error means that attribute size is too big.
+// Wrapped errors are matched too.
 func IsAttrSizeOverflow(err error) bool {
-	_, ok := err.(*AttrOverflowErr)
-	return ok
+	return errors.Is(err, ErrAttributeSizeOverflow)
 }
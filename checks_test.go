@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCheckSize(t *testing.T) {
+	const vendor AttrType = 0xC001
+	if err := CheckSize(vendor, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckSize(vendor, 3, 4)
+	if !IsAttrSizeInvalid(err) || IsAttrSizeOverflow(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if wrapped := fmt.Errorf("vendor attribute: %w", err); !IsAttrSizeInvalid(wrapped) {
+		t.Errorf("wrapped error should match: %v", wrapped)
+	}
+}
+
+func TestCheckOverflow(t *testing.T) {
+	const vendor AttrType = 0xC001
+	if err := CheckOverflow(vendor, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckOverflow(vendor, 5, 4)
+	if !IsAttrSizeOverflow(err) || IsAttrSizeInvalid(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if wrapped := fmt.Errorf("vendor attribute: %w", err); !IsAttrSizeOverflow(wrapped) {
+		t.Errorf("wrapped error should match: %v", wrapped)
+	}
+}
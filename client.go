@@ -4,6 +4,8 @@
 package stun
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -17,6 +19,7 @@ import (
 	"time"
 
 	"github.com/pion/dtls/v3"
+	"github.com/pion/logging"
 	"github.com/pion/transport/v3"
 	"github.com/pion/transport/v3/stdnet"
 )
@@ -26,8 +29,16 @@ var ErrUnsupportedURI = fmt.Errorf("invalid schema or transport")
 
 // Dial connects to the address on the named network and then
 // initializes Client on that connection, returning error if any.
+//
+// Host names are resolved via the package DNS cache.
 func Dial(network, address string) (*Client, error) {
-	conn, err := net.Dial(network, address)
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialCached(defaultDNSCache, network, host, port, func(addr string) (net.Conn, error) {
+		return net.Dial(network, addr)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -41,27 +52,48 @@ type DialConfig struct {
 	TLSConfig  tls.Config
 
 	Net transport.Net
+
+	// DNSCache is used to resolve URI host. Defaults to the package
+	// cache if Net is not set, otherwise Net resolves host itself.
+	DNSCache DNSCache
 }
 
 // DialURI connect to the STUN/TURN URI and then
 // initializes Client on that connection, returning error if any.
-func DialURI(uri *URI, cfg *DialConfig) (*Client, error) { //nolint:cyclop
+func DialURI(uri *URI, cfg *DialConfig) (*Client, error) {
+	conn, err := dialURI(uri, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(conn)
+}
+
+// dialURI connects to the STUN/TURN URI.
+func dialURI(uri *URI, cfg *DialConfig) (Connection, error) { //nolint:cyclop
 	var conn Connection
 	var err error
 
 	nw := cfg.Net
+	cache := cfg.DNSCache
 	if nw == nil {
 		nw, err = stdnet.NewNet()
 		if err != nil {
 			return nil, fmt.Errorf("failed to create net: %w", err)
 		}
+		if cache == nil {
+			cache = defaultDNSCache
+		}
 	}
 
 	addr := net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port))
+	port := strconv.Itoa(uri.Port)
 
 	switch {
 	case uri.Scheme == SchemeTypeSTUN:
-		if conn, err = nw.Dial("udp", addr); err != nil {
+		if conn, err = dialCached(cache, "udp", uri.Host, port, func(a string) (net.Conn, error) {
+			return nw.Dial("udp", a)
+		}); err != nil {
 			return nil, fmt.Errorf("failed to listen: %w", err)
 		}
 
@@ -71,7 +103,9 @@ func DialURI(uri *URI, cfg *DialConfig) (*Client, error) { //nolint:cyclop
 			network = "tcp" //nolint:goconst
 		}
 
-		if conn, err = nw.Dial(network, addr); err != nil {
+		if conn, err = dialCached(cache, network, uri.Host, port, func(a string) (net.Conn, error) {
+			return nw.Dial(network, a)
+		}); err != nil {
 			return nil, fmt.Errorf("failed to dial: %w", err)
 		}
 
@@ -79,13 +113,16 @@ func DialURI(uri *URI, cfg *DialConfig) (*Client, error) { //nolint:cyclop
 		dtlsCfg := cfg.DTLSConfig // Copy
 		dtlsCfg.ServerName = uri.Host
 
-		udpAddr, err := net.ResolveUDPAddr("udp", addr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to resolve UDPAddr: %w", err)
-		}
+		var udpConn transport.UDPConn
+		if _, err = dialCached(cache, "udp", uri.Host, port, func(a string) (net.Conn, error) {
+			udpAddr, resolveErr := net.ResolveUDPAddr("udp", a)
+			if resolveErr != nil {
+				return nil, fmt.Errorf("failed to resolve UDPAddr: %w", resolveErr)
+			}
+			udpConn, resolveErr = nw.DialUDP("udp", nil, udpAddr)
 
-		udpConn, err := nw.DialUDP("udp", nil, udpAddr)
-		if err != nil {
+			return udpConn, resolveErr
+		}); err != nil {
 			return nil, fmt.Errorf("failed to dial: %w", err)
 		}
 
@@ -94,21 +131,23 @@ func DialURI(uri *URI, cfg *DialConfig) (*Client, error) { //nolint:cyclop
 		}
 
 	case (uri.Scheme == SchemeTypeTURNS || uri.Scheme == SchemeTypeSTUNS) && uri.Proto == ProtoTypeTCP:
-		tlsCfg := cfg.TLSConfig //nolint:govet
+		tlsCfg := cfg.TLSConfig.Clone()
 		tlsCfg.ServerName = uri.Host
 
-		tcpConn, err := nw.Dial("tcp", addr)
+		tcpConn, err := dialCached(cache, "tcp", uri.Host, port, func(a string) (net.Conn, error) {
+			return nw.Dial("tcp", a)
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to dial: %w", err)
 		}
 
-		conn = tls.Client(tcpConn, &tlsCfg)
+		conn = tls.Client(tcpConn, tlsCfg)
 
 	default:
 		return nil, ErrUnsupportedURI
 	}
 
-	return NewClient(conn)
+	return conn, nil
 }
 
 // ErrNoConnection means that ClientOptions.Connection is nil.
@@ -134,13 +173,35 @@ func WithRTO(rto time.Duration) ClientOption {
 }
 
 // WithClock sets Clock of client, the source of current time.
-// Also clock is passed to default collector if set.
+// Also clock is passed to default collector and agent if set, so
+// transactions time out when clock passes their deadline, checked with
+// rate of WithTimeoutRate.
 func WithClock(clock Clock) ClientOption {
 	return func(c *Client) {
 		c.clock = clock
 	}
 }
 
+// WithTransactionIDSource sets source of transaction IDs of messages built
+// by client, e.g. keepalive requests, crypto/rand by default. Source is
+// also passed to default agent. Source must be safe for concurrent use
+// if client is used by multiple goroutines.
+//
+// Use TransactionIDFrom setter to build requests with the same source.
+func WithTransactionIDSource(r io.Reader) ClientOption {
+	return func(c *Client) {
+		c.ids = r
+	}
+}
+
+// WithSchedule sets retransmission schedule, overriding the default
+// LinearSchedule of RTO. Timeout of each attempt is computed by s.Next.
+func WithSchedule(s Schedule) ClientOption {
+	return func(c *Client) {
+		c.schedule = &s
+	}
+}
+
 // WithTimeoutRate sets RTO timer minimum resolution.
 func WithTimeoutRate(d time.Duration) ClientOption {
 	return func(c *Client) {
@@ -166,6 +227,232 @@ func WithCollector(coll Collector) ClientOption {
 	}
 }
 
+// RequestDecorator modifies outgoing message just before it is sent.
+type RequestDecorator func(m *Message) error
+
+// WithRequestDecorator adds decorator that is applied to each message
+// sent by client, including indications and retransmissions, e.g. to
+// inject dynamic attributes like transmit counters or rotating tokens.
+// Decorators are applied in order they are added, and message is not
+// sent if decorator returns error.
+//
+// Decorator is applied to a copy of the original message, so changes
+// do not accumulate over retransmissions. FINGERPRINT is removed before
+// decorators are called and added again after them. Messages protected
+// by MESSAGE-INTEGRITY can not be decorated and are not sent, returning
+// ErrMessageProtected.
+//
+// Decorator can be called concurrently.
+func WithRequestDecorator(d RequestDecorator) ClientOption {
+	return func(c *Client) {
+		c.requestDecorators = append(c.requestDecorators, d)
+	}
+}
+
+// RequestInterceptor is RequestDecorator, the name of request hook that
+// is paired with ResponseInterceptor.
+type RequestInterceptor = RequestDecorator
+
+// WithRequestInterceptor is WithRequestDecorator.
+func WithRequestInterceptor(i RequestInterceptor) ClientOption {
+	return WithRequestDecorator(i)
+}
+
+// ResponseInterceptor is called with each message received by client
+// before it is matched with transaction. It can modify message, and
+// message is dropped if it returns error. Message must not be retained.
+type ResponseInterceptor func(m *Message) error
+
+// WithResponseInterceptor adds interceptor that is applied to each
+// message received by client, after interceptors that are added before
+// it. Interceptors are called from read goroutine.
+func WithResponseInterceptor(i ResponseInterceptor) ClientOption {
+	return func(c *Client) {
+		c.responseInterceptors = append(c.responseInterceptors, i)
+	}
+}
+
+// WithSoftware makes client set SOFTWARE attribute of each sent message
+// to software, or remove it if software is empty, see StampSoftware.
+// Messages with MESSAGE-INTEGRITY are sent as is, so SOFTWARE should be
+// added before integrity when building them, e.g. with Ordered.
+func WithSoftware(software string) ClientOption {
+	return func(c *Client) {
+		s := NewSoftware(software)
+		c.software = &s
+	}
+}
+
+// WithTransactionHistory enables recording of last size completed
+// transactions, see Client.TransactionHistory. History is logged with
+// debug level when connection is closed, see WithLogger.
+//
+// Useful for debugging stream connections that are dropped by server
+// after specific request patterns.
+func WithTransactionHistory(size int) ClientOption {
+	return func(c *Client) {
+		if size > 0 {
+			c.history = newTransactionHistory(size)
+		}
+	}
+}
+
+// WithLogger sets client logger, that traces sent and received messages
+// and logs retransmissions, timeouts and write errors with debug level.
+// Logger is also passed to default agent. Defaults to pion/logging logger
+// with "stun" scope, so logging can be enabled via PION_LOG_* environment
+// variables.
+func WithLogger(log logging.LeveledLogger) ClientOption {
+	return func(c *Client) {
+		c.log = log
+	}
+}
+
+// MetricsCollector receives client traffic events, see WithMetrics.
+// Methods can be called concurrently.
+type MetricsCollector interface {
+	IncSent()                     // message is written to connection
+	IncReceived()                 // message is read from connection
+	ObserveRTT(rtt time.Duration) // transaction RTT is sampled, see ClientStats
+	IncTimeout()                  // transaction is timed out
+	IncRetransmit()               // request is retransmitted
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) IncSent()                 {}
+func (noopMetrics) IncReceived()             {}
+func (noopMetrics) ObserveRTT(time.Duration) {}
+func (noopMetrics) IncTimeout()              {}
+func (noopMetrics) IncRetransmit()           {}
+
+// WithMetrics sets collector of client metrics. See stunmetrics package
+// for implementation that exposes them to Prometheus and expvar.
+func WithMetrics(m MetricsCollector) ClientOption {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// TransactionTracer observes client transactions, see WithTracer.
+// Methods can be called concurrently.
+type TransactionTracer interface {
+	// StartTransaction is called when transaction of request m to server
+	// is started, server is nil if connection does not provide remote
+	// address. Returned function is called once with final event of
+	// transaction.
+	StartTransaction(m *Message, server net.Addr) func(e Event)
+}
+
+// WithTracer sets tracer of client transactions. See stuntrace package
+// for implementation that records transactions as tracing spans.
+func WithTracer(t TransactionTracer) ClientOption {
+	return func(c *Client) {
+		c.tracer = t
+	}
+}
+
+// WithTransactionErrors makes client report transactions completed with
+// error response as *TransactionError in Event.Error, so its code can be
+// checked with errors.As instead of decoding ERROR-CODE of Event.Message.
+func WithTransactionErrors() ClientOption {
+	return func(c *Client) {
+		c.txErrors = true
+	}
+}
+
+// WithMaxInflight bounds count of transactions in progress to n, so
+// client does not accumulate transactions when remote drops traffic.
+// Start and Do beyond the limit return ErrMaxInflight, or wait for
+// transaction to complete if WithInflightQueue is set. Zero means no limit.
+func WithMaxInflight(n int) ClientOption {
+	return func(c *Client) {
+		c.inflight = nil
+		if n > 0 {
+			c.inflight = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithInflightQueue makes Start and Do wait until transaction count is
+// below limit of WithMaxInflight instead of returning ErrMaxInflight.
+func WithInflightQueue() ClientOption {
+	return func(c *Client) {
+		c.inflightQueue = true
+	}
+}
+
+// WithRFC3489 makes client decode responses with Message.DecodeRFC3489,
+// accepting responses of RFC 3489 servers without the magic cookie.
+func WithRFC3489() ClientOption {
+	return WithDecodeMode(DecodeLegacy)
+}
+
+// WithDecodeMode sets compatibility mode of decoding responses, see
+// Message.DecodeAs. WithRFC3489 is WithDecodeMode(DecodeLegacy).
+func WithDecodeMode(mode DecodeMode) ClientOption {
+	return func(c *Client) {
+		c.decodeMode = mode
+	}
+}
+
+// WithStreamDecoding makes client read responses with StreamDecoder, so
+// messages that are split or coalesced by reads of stream connection are
+// decoded. It is enabled by default for connections with TCP local
+// address, e.g. of Dial with "tcp" network, and should be set for other
+// stream connections. Ignored with WithRFC3489.
+func WithStreamDecoding() ClientOption {
+	return func(c *Client) {
+		c.stream = true
+	}
+}
+
+// WithDSCP sets DSCP of packets sent by client, e.g.
+// DSCPExpeditedForwarding to prioritize connectivity checks, see SetDSCP.
+// Connection should be net.Conn backed by IP socket, otherwise NewClient
+// returns error. Connections of WithReconnect get DSCP too.
+func WithDSCP(dscp int) ClientOption {
+	return func(c *Client) {
+		c.dscp = &dscp
+	}
+}
+
+// setDSCP sets DSCP of WithDSCP option on conn, if any.
+func (c *Client) setDSCP(conn Connection) error {
+	if c.dscp == nil {
+		return nil
+	}
+	netConn, ok := conn.(net.Conn)
+	if !ok {
+		return ErrDSCPNotSupported
+	}
+
+	return SetDSCP(netConn, *c.dscp)
+}
+
+// WithFlowLabel sets IPv6 flow label of packets sent by client, see
+// SetFlowLabel. Connection should be *net.UDPConn connected to IPv6
+// address, otherwise NewClient returns error. Connections of
+// WithReconnect get flow label too.
+func WithFlowLabel(label uint32) ClientOption {
+	return func(c *Client) {
+		c.flowLabel = &label
+	}
+}
+
+// setFlowLabel sets flow label of WithFlowLabel option on conn, if any.
+func (c *Client) setFlowLabel(conn Connection) error {
+	if c.flowLabel == nil {
+		return nil
+	}
+	netConn, ok := conn.(net.Conn)
+	if !ok {
+		return ErrFlowLabelNotSupported
+	}
+
+	return SetFlowLabel(netConn, *c.flowLabel)
+}
+
 // WithNoConnClose prevents client from closing underlying connection when
 // the Close() method is called.
 func WithNoConnClose() ClientOption {
@@ -206,6 +493,7 @@ const (
 func NewClient(conn Connection, options ...ClientOption) (*Client, error) {
 	client := &Client{
 		close:       make(chan struct{}),
+		errc:        make(chan error, 1),
 		c:           conn,
 		clock:       systemClock(),
 		rto:         int64(defaultRTO),
@@ -220,25 +508,48 @@ func NewClient(conn Connection, options ...ClientOption) (*Client, error) {
 	if client.c == nil {
 		return nil, ErrNoConnection
 	}
+	if err := client.setDSCP(client.c); err != nil {
+		return nil, err
+	}
+	if err := client.setFlowLabel(client.c); err != nil {
+		return nil, err
+	}
+	if client.log == nil {
+		client.log = defaultLogger()
+	}
 	if client.a == nil {
-		client.a = NewAgent(nil)
+		client.a = NewAgent(nil,
+			WithAgentLogger(client.log),
+			WithAgentClock(client.clock),
+			WithAgentTransactionIDSource(client.ids),
+		)
+	}
+	if client.metrics == nil {
+		client.metrics = noopMetrics{}
 	}
 	if err := client.a.SetHandler(client.handleAgentCallback); err != nil {
 		return nil, err
 	}
 	if client.collector == nil {
-		client.collector = &tickerCollector{
-			close: make(chan struct{}),
-			clock: client.clock,
-		}
+		client.collector = NewTickerCollector(client.clock)
 	}
 	if err := client.collector.Start(client.rtoRate, func(t time.Time) {
 		closedOrPanic(client.a.Collect(t))
 	}); err != nil {
 		return nil, err
 	}
+	if !client.stream {
+		client.stream = isStreamConn(conn)
+	}
 	client.wg.Add(1)
 	go client.readUntilClosed()
+	if client.keepAlive.interval > 0 {
+		if client.keepAlive.maxFailures <= 0 {
+			client.keepAlive.maxFailures = defaultKeepAliveFailures
+		}
+		client.wg.Add(1)
+		go client.keepAliveUntilClosed()
+	}
 	runtime.SetFinalizer(client, clientFinalizer)
 
 	return client, nil
@@ -284,17 +595,48 @@ type Client struct {
 	a           ClientAgent
 	c           Connection
 	close       chan struct{}
+	errc        chan error // see Err
+	err         error      // fatal error of connection
 	rtoRate     time.Duration
 	maxAttempts int32
 	closed      bool
-	closeConn   bool // should call c.Close() while closing
+	draining    bool          // set by CloseCtx
+	pending     int           // count of transactions in progress
+	drained     chan struct{} // closed when pending is zero while draining
+	closeConn   bool          // should call c.Close() while closing
+	connMux     sync.RWMutex  // guards c and closeConn
+	reconnect   reconnect
 	wg          sync.WaitGroup
 	clock       Clock
 	handler     Handler
 	collector   Collector
+	schedule    *Schedule
+	history     *transactionHistory
+	rtt         rttStats
+	metrics     MetricsCollector
+	tracer      TransactionTracer
+	keepAlive   keepAlive
+	auth        clientAuth
+	ids         io.Reader  // source of transaction IDs, nil for crypto/rand
+	decodeMode  DecodeMode // see WithDecodeMode
+	stream      bool       // decode responses with StreamDecoder
+	dscp        *int       // see WithDSCP
+	flowLabel   *uint32    // see WithFlowLabel
+	txErrors    bool       // report error responses as TransactionError
+	log         logging.LeveledLogger
 	t           map[transactionID]*clientTransaction
+	inbound     func(m *Message, from net.Addr)
+	sourceCheck SourceCheck
+	other       map[string]net.Addr // OTHER-ADDRESS of destinations
 
-	// mux guards closed and t
+	inflight      chan struct{} // slots of WithMaxInflight, nil if unlimited
+	inflightQueue bool          // wait for slot instead of ErrMaxInflight
+
+	requestDecorators    []RequestDecorator
+	responseInterceptors []ResponseInterceptor
+	software             *Software // see WithSoftware
+
+	// mux guards closed, draining, pending, drained, err, t, inbound and other
 	mux sync.RWMutex
 }
 
@@ -303,13 +645,17 @@ type Client struct {
 // provided by event.
 // Concurrent access is invalid.
 type clientTransaction struct {
-	id      transactionID
-	attempt int32
-	calls   int32
-	h       Handler
-	start   time.Time
-	rto     time.Duration
-	raw     []byte
+	id       transactionID
+	method   Method
+	attempt  int32
+	calls    int32
+	h        Handler
+	start    time.Time
+	schedule Schedule
+	raw      []byte
+	to       net.Addr      // destination of unconnected client
+	source   net.Addr      // expected source of response, nil if any
+	end      func(e Event) // set by TransactionTracer
 }
 
 func (t *clientTransaction) handle(e Event) {
@@ -334,12 +680,32 @@ func putClientTransaction(t *clientTransaction) {
 	t.raw = t.raw[:0]
 	t.start = time.Time{}
 	t.attempt = 0
+	t.schedule = Schedule{}
+	t.end = nil
 	t.id = transactionID{}
 	clientTransactionPool.Put(t)
 }
 
+// discard ends trace of transaction that failed to start with err and
+// returns it to pool.
+func (t *clientTransaction) discard(err error) {
+	if t.end != nil {
+		t.end(Event{TransactionID: t.id, Error: err})
+	}
+	putClientTransaction(t)
+}
+
 func (t *clientTransaction) nextTimeout(now time.Time) time.Time {
-	return now.Add(time.Duration(t.attempt+1) * t.rto)
+	return now.Add(t.schedule.Next(int(t.attempt)))
+}
+
+// retransmitSchedule returns schedule of new transaction.
+func (c *Client) retransmitSchedule() Schedule {
+	if c.schedule != nil {
+		return *c.schedule
+	}
+
+	return LinearSchedule(time.Duration(atomic.LoadInt64(&c.rto)))
 }
 
 // start registers transaction.
@@ -356,15 +722,113 @@ func (c *Client) start(t *clientTransaction) error {
 		return ErrTransactionExists
 	}
 	c.t[t.id] = t
+	if t.attempt == 0 {
+		// Retransmissions restart the same transaction.
+		c.pending++
+	}
 
 	return nil
 }
 
-// Clock abstracts the source of current time.
+// maxTransactionIDRetries is count of attempts to regenerate transaction
+// ID that collides with transaction in progress, see resolveCollision.
+const maxTransactionIDRetries = 3
+
+// resolveCollision regenerates transaction ID of msg while it is used by
+// transaction of other message in progress. Starting the same message
+// twice is not collision and is rejected by start with
+// ErrTransactionExists.
+func (c *Client) resolveCollision(msg *Message) error {
+	for retry := 0; ; retry++ {
+		c.mux.RLock()
+		t, exists := c.t[msg.TransactionID]
+		collision := exists && !bytes.Equal(t.raw, msg.Raw)
+		c.mux.RUnlock()
+		if !collision {
+			return nil
+		}
+		if retry == maxTransactionIDRetries || msg.Contains(AttrMessageIntegrity) ||
+			msg.Contains(AttrMessageIntegritySHA256) {
+			// Integrity covers transaction ID, so it can not be changed.
+			return ErrTransactionExists
+		}
+		c.log.Debugf("client: transaction ID %x collides, regenerating", msg.TransactionID)
+		fingerprint := msg.Contains(AttrFingerprint)
+		if fingerprint {
+			_ = msg.Delete(AttrFingerprint)
+		}
+		if err := TransactionIDFrom(c.ids).AddTo(msg); err != nil {
+			return err
+		}
+		if fingerprint {
+			if err := Fingerprint.AddTo(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ErrMaxInflight indicates that transaction is not started, because
+// limit of WithMaxInflight is reached.
+var ErrMaxInflight = errors.New("too many transactions in progress")
+
+// acquireInflight takes slot of WithMaxInflight, waiting for it if
+// WithInflightQueue is set.
+func (c *Client) acquireInflight() error {
+	if c.inflight == nil {
+		return nil
+	}
+	select {
+	case c.inflight <- struct{}{}:
+		return nil
+	default:
+	}
+	if !c.inflightQueue {
+		return ErrMaxInflight
+	}
+	select {
+	case c.inflight <- struct{}{}:
+		return nil
+	case <-c.close:
+		return ErrClientClosed
+	}
+}
+
+// releaseInflight returns slot taken by acquireInflight.
+func (c *Client) releaseInflight() {
+	if c.inflight != nil {
+		<-c.inflight
+	}
+}
+
+// transactionDone decrements count of transactions in progress,
+// notifying CloseCtx when client is drained.
+func (c *Client) transactionDone() {
+	c.releaseInflight()
+	c.mux.Lock()
+	c.pending--
+	if c.pending == 0 && c.drained != nil {
+		close(c.drained)
+		c.drained = nil
+	}
+	c.mux.Unlock()
+}
+
+// Clock abstracts the source of current time, so tests and simulations
+// can drive timeouts of Client and Agent with fake time, see WithClock
+// and WithAgentClock.
 type Clock interface {
 	Now() time.Time
 }
 
+// ClockFunc is adapter to use ordinary function as Clock.
+type ClockFunc func() time.Time
+
+// Now returns f().
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
 type systemClockService struct{}
 
 func (systemClockService) Now() time.Time { return time.Now() }
@@ -411,25 +875,193 @@ func (c CloseErr) Error() string {
 	return fmt.Sprintf("failed to close: %s (connection), %s (agent)", sprintErr(c.ConnectionErr), sprintErr(c.AgentErr))
 }
 
+// packetProcessor is implemented by agents that accept packet addresses
+// and receive time, like Agent.
+type packetProcessor interface {
+	ProcessFrom(m *Message, remote, local net.Addr, received time.Time) error
+}
+
+// connAddrs returns addresses of conn if it provides them, e.g. is
+// net.Conn.
+func connAddrs(conn Connection) (remote, local net.Addr) {
+	if c, ok := conn.(interface{ RemoteAddr() net.Addr }); ok {
+		remote = c.RemoteAddr()
+	}
+	if c, ok := conn.(interface{ LocalAddr() net.Addr }); ok {
+		local = c.LocalAddr()
+	}
+
+	return remote, local
+}
+
 func (c *Client) readUntilClosed() {
 	defer c.wg.Done()
+	defer close(c.errc)
 	m := new(Message)
 	m.Raw = make([]byte, 1024)
+	conn := c.conn()
+	dec := c.streamDecoder(conn)
+	remote, local := connAddrs(conn)
+	processor, withAddrs := c.a.(packetProcessor)
 	for {
 		select {
 		case <-c.close:
 			return
 		default:
 		}
-		_, err := m.ReadFrom(c.c)
+		from, err := c.readMessage(conn, dec, m)
+		if err != nil && c.reconnect.dial != nil && !c.isClosed() {
+			if conn = c.redial(err); conn == nil {
+				return
+			}
+			dec = c.streamDecoder(conn)
+			remote, local = connAddrs(conn)
+
+			continue
+		}
+		if err != nil && isFatalReadErr(err) && !c.isClosed() {
+			if errors.Is(err, io.EOF) {
+				c.logHistory("connection closed by peer")
+			}
+			c.fail(err)
+
+			return
+		}
+		if err == nil && from != nil && !c.expectedFrom(m.TransactionID, from) {
+			c.log.Debugf("client: dropped %s from unexpected %s", m, from)
+
+			continue
+		}
 		if err == nil {
-			if pErr := c.a.Process(m); errors.Is(pErr, ErrAgentClosed) {
+			if iErr := c.interceptResponse(m); iErr != nil {
+				c.log.Debugf("client: dropped %s: %v", m, iErr)
+
+				continue
+			}
+			c.metrics.IncReceived()
+			c.log.Tracef("client: received %s", m)
+			var pErr error
+			if withAddrs {
+				if from != nil {
+					remote = from
+				}
+				pErr = processor.ProcessFrom(m, remote, local, c.clock.Now())
+			} else {
+				pErr = c.a.Process(m)
+			}
+			if errors.Is(pErr, ErrAgentClosed) {
 				return
 			}
 		}
 	}
 }
 
+// isFatalReadErr reports whether err of reading from connection means
+// that connection is unusable.
+func isFatalReadErr(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.ErrClosedPipe) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, ErrInvalidStream) || errors.Is(err, ErrMessageTooLarge)
+}
+
+// streamDecoder returns StreamDecoder of conn if responses are decoded
+// from stream, or nil.
+func (c *Client) streamDecoder(conn Connection) *StreamDecoder {
+	if !c.stream || c.decodeMode == DecodeLegacy {
+		return nil
+	}
+
+	return NewStreamDecoder(conn, 0)
+}
+
+// isStreamConn reports whether conn has TCP local address.
+func isStreamConn(conn Connection) bool {
+	nc, ok := conn.(interface{ LocalAddr() net.Addr })
+	if !ok || nc.LocalAddr() == nil {
+		return false
+	}
+	switch nc.LocalAddr().Network() {
+	case "tcp", "tcp4", "tcp6":
+		return true
+	default:
+		return false
+	}
+}
+
+// Err returns channel that receives fatal error of reading from
+// connection, e.g. io.EOF when stream connection is closed by peer.
+// After that, transactions in progress and new ones fail with this error
+// instead of timing out, and client should be closed. Errors that are
+// handled by WithReconnect are not fatal.
+//
+// Channel is closed when client stops reading from connection, after
+// fatal error or Close.
+func (c *Client) Err() <-chan error {
+	return c.errc
+}
+
+// fail stops transactions in progress with fatal error err of connection
+// and reports it to Err.
+func (c *Client) fail(err error) {
+	c.log.Debugf("client: failed to read: %v", err)
+	c.mux.Lock()
+	c.err = err
+	transactions := make([]*clientTransaction, 0, len(c.t))
+	for id, t := range c.t {
+		delete(c.t, id)
+		transactions = append(transactions, t)
+	}
+	c.mux.Unlock()
+	c.setConnectionState(ConnectionStateDisconnected)
+	c.errc <- err
+	for _, t := range transactions {
+		// Handler of agent ignores stopped transaction that is deleted.
+		_ = c.a.Stop(t.id)
+		c.finish(t, Event{TransactionID: t.id, Error: err})
+	}
+}
+
+// interceptResponse applies response interceptors to m.
+func (c *Client) interceptResponse(m *Message) error {
+	for _, i := range c.responseInterceptors {
+		if err := i(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readMessage reads and decodes single message from conn into m,
+// returning source address if conn is unconnected.
+func (c *Client) readMessage(conn Connection, dec *StreamDecoder, m *Message) (net.Addr, error) {
+	if dec != nil {
+		return nil, dec.DecodeAs(m, c.decodeMode)
+	}
+	pc, unconnected := conn.(*packetConnection)
+	if c.decodeMode == DecodeDefault && !unconnected {
+		_, err := m.ReadFrom(conn)
+
+		return nil, err
+	}
+	var (
+		buf  = m.Raw[:cap(m.Raw)]
+		n    int
+		from net.Addr
+		err  error
+	)
+	if unconnected {
+		n, from, err = pc.ReadFrom(buf)
+	} else {
+		n, err = conn.Read(buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Raw = buf[:n]
+	return from, m.DecodeAs(c.decodeMode)
+}
+
 func closedOrPanic(err error) {
 	if err == nil || errors.Is(err, ErrAgentClosed) {
 		return
@@ -443,6 +1075,20 @@ type tickerCollector struct {
 	clock Clock
 }
 
+// NewTickerCollector returns Collector that calls function on each tick
+// of time.Ticker, passing current time of clock. If clock is nil, system
+// clock is used. It is the default collector of Client and Agent.
+func NewTickerCollector(clock Clock) Collector {
+	if clock == nil {
+		clock = systemClock()
+	}
+
+	return &tickerCollector{
+		close: make(chan struct{}),
+		clock: clock,
+	}
+}
+
 // Collector calls function f with constant rate.
 //
 // The simple Collector is ticker which calls function on each tick.
@@ -481,6 +1127,13 @@ func (a *tickerCollector) Close() error {
 // ErrClientClosed indicates that client is closed.
 var ErrClientClosed = errors.New("client is closed")
 
+func (c *Client) isClosed() bool {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return c.closed
+}
+
 // Close stops internal connection and agent, returning CloseErr on error.
 func (c *Client) Close() error {
 	if err := c.checkInit(); err != nil {
@@ -494,16 +1147,20 @@ func (c *Client) Close() error {
 	}
 	c.closed = true
 	c.mux.Unlock()
+	c.logHistory("closing connection")
 	if closeErr := c.collector.Close(); closeErr != nil {
 		return closeErr
 	}
 	var connErr error
 	agentErr := c.a.Close()
+	c.connMux.Lock()
 	if c.closeConn {
 		connErr = c.c.Close()
 	}
+	c.connMux.Unlock()
 	close(c.close)
 	c.wg.Wait()
+	c.setConnectionState(ConnectionStateClosed)
 	if agentErr == nil && connErr == nil {
 		return nil
 	}
@@ -514,9 +1171,70 @@ func (c *Client) Close() error {
 	}
 }
 
-// Indicate sends indication m to server. Shorthand to Start call
-// with zero deadline and callback.
+// CloseCtx gracefully closes client: new transactions are rejected with
+// ErrClientClosed, while ones in progress are completed or time out,
+// then Close is called. If ctx is done before that, client is closed
+// immediately and ctx error is returned.
+func (c *Client) CloseCtx(ctx context.Context) error {
+	if err := c.checkInit(); err != nil {
+		return err
+	}
+	c.mux.Lock()
+	if c.closed {
+		c.mux.Unlock()
+
+		return ErrClientClosed
+	}
+	c.draining = true
+	if c.pending > 0 && c.drained == nil {
+		c.drained = make(chan struct{})
+	}
+	drained := c.drained
+	c.mux.Unlock()
+	var ctxErr error
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			ctxErr = ctx.Err()
+		}
+	}
+	if err := c.Close(); err != nil {
+		return err
+	}
+
+	return ctxErr
+}
+
+// SetInboundHandler sets h to be called with messages that do not belong
+// to client transactions, i.e. indications like TURN Data Indication,
+// requests of server and responses with unknown transaction ID, that are
+// dropped otherwise. The from address is nil if connection does not
+// provide it.
+//
+// The h is called from read goroutine and must not retain m, use
+// Message.Clone to keep it. Nil h removes handler.
+func (c *Client) SetInboundHandler(h func(m *Message, from net.Addr)) {
+	c.mux.Lock()
+	c.inbound = h
+	c.mux.Unlock()
+}
+
+// ErrNotIndication means that message passed to Client.Indicate is not
+// an indication.
+var ErrNotIndication = errors.New("message is not an indication")
+
+// Indicate sends indication m to server, e.g. Binding Indication or TURN
+// Send Indication, without starting transaction. Returns ErrNotIndication
+// if class of m is not ClassIndication.
 func (c *Client) Indicate(m *Message) error {
+	if err := c.checkInit(); err != nil {
+		return err
+	}
+	if m.Type.Class != ClassIndication {
+		return fmt.Errorf("%w: %s", ErrNotIndication, m.Type)
+	}
+
 	return c.Start(m, nil)
 }
 
@@ -573,7 +1291,7 @@ var callbackWaitHandlerPool = sync.Pool{ //nolint:gochecknoglobals
 var ErrClientNotInitialized = errors.New("client not initialized")
 
 func (c *Client) checkInit() error {
-	if c == nil || c.c == nil || c.a == nil || c.close == nil {
+	if c == nil || c.conn() == nil || c.a == nil || c.close == nil {
 		return ErrClientNotInitialized
 	}
 
@@ -581,23 +1299,28 @@ func (c *Client) checkInit() error {
 }
 
 // Do is Start wrapper that waits until callback is called. If no callback
-// provided, Indicate is called instead.
+// provided, m is sent without starting transaction.
 //
 // Do has cpu overhead due to blocking, see BenchmarkClient_Do.
 // Use Start method for less overhead.
 func (c *Client) Do(m *Message, f func(Event)) error {
+	return c.DoTo(m, nil, f)
+}
+
+// DoTo is like Do, but writes message to destination to, see StartTo.
+func (c *Client) DoTo(m *Message, to net.Addr, f func(Event)) error {
 	if err := c.checkInit(); err != nil {
 		return err
 	}
 	if f == nil {
-		return c.Indicate(m)
+		return c.StartTo(m, to, nil)
 	}
 	h := callbackWaitHandlerPool.Get().(*callbackWaitHandler) //nolint:forcetypeassert
 	h.setCallback(f)
 	defer func() {
 		callbackWaitHandlerPool.Put(h)
 	}()
-	if err := c.Start(m, h.handler); err != nil {
+	if err := c.StartTo(m, to, h.handler); err != nil {
 		return err
 	}
 	h.wait()
@@ -605,12 +1328,16 @@ func (c *Client) Do(m *Message, f func(Event)) error {
 	return nil
 }
 
-func (c *Client) delete(id transactionID) {
+// delete removes transaction id, reporting whether it was in progress.
+func (c *Client) delete(id transactionID) bool {
 	c.mux.Lock()
-	if c.t != nil {
+	defer c.mux.Unlock()
+	_, found := c.t[id]
+	if found {
 		delete(c.t, id)
 	}
-	c.mux.Unlock()
+
+	return found
 }
 
 type buffer struct {
@@ -623,6 +1350,62 @@ var bufferPool = &sync.Pool{ //nolint:gochecknoglobals
 	},
 }
 
+// finish records RTT sample and transaction t to history and calls its
+// handler, releasing t.
+func (c *Client) finish(t *clientTransaction, event Event) {
+	if event.Error == nil && t.attempt == 0 {
+		received := event.Timestamp
+		if received.IsZero() {
+			received = c.clock.Now()
+		}
+		event.RTT = received.Sub(t.start)
+		c.rtt.add(event.RTT)
+		c.metrics.ObserveRTT(event.RTT)
+	}
+	if c.txErrors && event.Error == nil && event.Message != nil &&
+		event.Message.Type.Class == ClassErrorResponse {
+		event.Error = newTransactionError(event.Message)
+	}
+	if errors.Is(event.Error, ErrTransactionTimeOut) {
+		c.metrics.IncTimeout()
+		c.log.Debugf("client: %s %x timed out after %d attempt(s)", t.method, t.id, t.attempt+1)
+	}
+	if c.history != nil {
+		c.history.add(TransactionRecord{
+			ID:       t.id,
+			Method:   t.method,
+			Start:    t.start,
+			Duration: c.clock.Now().Sub(t.start),
+			Attempts: int(t.attempt) + 1,
+			Err:      event.Error,
+		})
+	}
+	if t.end != nil {
+		t.end(event)
+	}
+	t.handle(event)
+	putClientTransaction(t)
+	c.transactionDone()
+}
+
+// TransactionHistory returns completed transactions from oldest to
+// newest, or nil if history is not enabled by WithTransactionHistory.
+func (c *Client) TransactionHistory() []TransactionRecord {
+	if c.history == nil {
+		return nil
+	}
+
+	return c.history.list()
+}
+
+// logHistory logs transaction history on connection close.
+func (c *Client) logHistory(reason string) {
+	if c.history == nil {
+		return
+	}
+	c.log.Debugf("client: %s, transaction history:%s", reason, c.history)
+}
+
 func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 	c.mux.Lock()
 	if c.closed {
@@ -634,8 +1417,12 @@ func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 	if found {
 		delete(c.t, transaction.id)
 	}
+	inbound := c.inbound
 	c.mux.Unlock()
 	if !found {
+		if inbound != nil && event.Error == nil && event.Message != nil {
+			inbound(event.Message, event.Remote)
+		}
 		if c.handler != nil && !errors.Is(event.Error, ErrTransactionStopped) {
 			c.handler(event)
 		}
@@ -644,13 +1431,17 @@ func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 	}
 	if atomic.LoadInt32(&c.maxAttempts) <= transaction.attempt || event.Error == nil {
 		// Transaction completed.
-		transaction.handle(event)
-		putClientTransaction(transaction)
+		if event.Error == nil && transaction.to != nil && c.sourceCheck == SourceCheckOtherAddress {
+			c.learnOtherAddress(transaction.to, event.Message)
+		}
+		c.finish(transaction, event)
 
 		return
 	}
 	// Doing re-transmission.
 	transaction.attempt++
+	c.metrics.IncRetransmit()
+	c.log.Debugf("client: retransmitting %s %x, attempt %d", transaction.method, transaction.id, transaction.attempt+1)
 	buff := bufferPool.Get().(*buffer) //nolint:forcetypeassert
 	buff.buf = buff.buf[:copy(buff.buf[:cap(buff.buf)], transaction.raw)]
 	defer bufferPool.Put(buff)
@@ -663,8 +1454,7 @@ func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 	if startErr := c.start(transaction); startErr != nil {
 		c.delete(id)
 		event.Error = startErr
-		transaction.handle(event)
-		putClientTransaction(transaction)
+		c.finish(transaction, event)
 
 		return
 	}
@@ -672,13 +1462,12 @@ func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 	if startErr := c.a.Start(id, timeOut); startErr != nil {
 		c.delete(id)
 		event.Error = startErr
-		transaction.handle(event)
-		putClientTransaction(transaction)
+		c.finish(transaction, event)
 
 		return
 	}
 	// Writing message to connection again.
-	_, writeErr := c.c.Write(buff.buf)
+	_, writeErr := c.write(buff.buf, transaction.to)
 	if writeErr != nil {
 		c.delete(id)
 		event.Error = writeErr
@@ -692,46 +1481,182 @@ func (c *Client) handleAgentCallback(event Event) { //nolint:cyclop
 				Cause: writeErr,
 			}
 		}
-		transaction.handle(event)
-		putClientTransaction(transaction)
+		c.finish(transaction, event)
 
 		return
 	}
 }
 
+// decorate applies request decorators to m, keeping FINGERPRINT as the
+// last attribute.
+func (c *Client) decorate(m *Message) error {
+	if len(c.requestDecorators) == 0 {
+		return nil
+	}
+	if m.Contains(AttrMessageIntegrity) || m.Contains(AttrMessageIntegritySHA256) {
+		return ErrMessageProtected
+	}
+	fingerprint := m.Contains(AttrFingerprint)
+	if fingerprint {
+		_ = m.Delete(AttrFingerprint)
+	}
+	for _, d := range c.requestDecorators {
+		if err := d(m); err != nil {
+			return err
+		}
+	}
+	if fingerprint {
+		return Fingerprint.AddTo(m)
+	}
+
+	return nil
+}
+
+// write writes raw message to connection, applying request decorators,
+// SOFTWARE and long-term credentials if set. Destination
+// to is required for unconnected connection and ignored otherwise.
+func (c *Client) write(raw []byte, to net.Addr) (int, error) {
+	if len(c.requestDecorators) > 0 || c.software != nil || c.auth.enabled {
+		m := &Message{Raw: append([]byte(nil), raw...)}
+		if err := m.Decode(); err != nil {
+			return 0, err
+		}
+		if err := c.decorate(m); err != nil {
+			return 0, err
+		}
+		if c.software != nil {
+			if err := StampSoftware(m, *c.software); err != nil && !errors.Is(err, ErrMessageProtected) {
+				return 0, err
+			}
+		}
+		if c.auth.enabled {
+			if err := c.auth.addTo(m); err != nil {
+				return 0, err
+			}
+		}
+		raw = m.Raw
+	}
+	conn := c.conn()
+	var (
+		n   int
+		err error
+	)
+	if pc, ok := conn.(*packetConnection); ok && to != nil {
+		n, err = pc.WriteTo(raw, to)
+	} else {
+		n, err = conn.Write(raw)
+	}
+	if err == nil {
+		c.metrics.IncSent()
+	} else if c.reconnect.dial != nil && !c.isClosed() && isRedialWriteErr(err) {
+		// Error of socket, e.g. ICMP port unreachable, can be reported
+		// to writer instead of reader, so closing conn to make reader
+		// redial. Message is handled as lost and is retransmitted over
+		// new connection.
+		c.log.Debugf("client: failed to write: %v, reconnecting", err)
+		_ = conn.Close()
+
+		return 0, nil
+	}
+
+	return n, err
+}
+
 // Start starts transaction (if h set) and writes message to server, handler
 // is called asynchronously.
+//
+// If transaction ID of msg collides with transaction of other message in
+// progress, new ID is set to msg, updating FINGERPRINT. Message with
+// MESSAGE-INTEGRITY is rejected with ErrTransactionExists instead, as
+// well as msg that is already started.
 func (c *Client) Start(msg *Message, handler Handler) error {
+	return c.StartTo(msg, nil, handler)
+}
+
+// StartTo is like Start, but writes message to destination to, which is
+// required for client of unconnected connection, see NewPacketClient.
+// Responses are matched by transaction ID and source address.
+func (c *Client) StartTo(msg *Message, to net.Addr, handler Handler) error {
+	if c.auth.enabled && handler != nil && msg.Type.Class == ClassRequest &&
+		!msg.Contains(AttrMessageIntegrity) && !msg.Contains(AttrMessageIntegritySHA256) {
+		handler = c.authHandler(msg, to, handler)
+	}
+
+	return c.startTo(msg, to, handler)
+}
+
+func (c *Client) startTo(msg *Message, to net.Addr, handler Handler) error { //nolint:cyclop
 	if err := c.checkInit(); err != nil {
 		return err
 	}
 	c.mux.RLock()
-	closed := c.closed
+	closed := c.closed || c.draining
+	fatal := c.err
 	c.mux.RUnlock()
 	if closed {
 		return ErrClientClosed
 	}
+	if fatal != nil {
+		return fatal
+	}
+	var t *clientTransaction
 	if handler != nil {
 		// Starting transaction only if h is set. Useful for indications.
-		t := acquireClientTransaction()
+		if err := c.resolveCollision(msg); err != nil {
+			return err
+		}
+		if err := c.acquireInflight(); err != nil {
+			return err
+		}
+		t = acquireClientTransaction()
 		t.id = msg.TransactionID
+		t.method = msg.Type.Method
 		t.start = c.clock.Now()
 		t.h = handler
-		t.rto = time.Duration(atomic.LoadInt64(&c.rto))
+		t.schedule = c.retransmitSchedule()
 		t.attempt = 0
 		t.raw = append(t.raw[:0], msg.Raw...)
 		t.calls = 0
+		t.to = to
+		t.source = c.expectedSource(msg, to)
+		if c.tracer != nil {
+			remote := to
+			if remote == nil {
+				remote, _ = connAddrs(c.conn())
+			}
+			t.end = c.tracer.StartTransaction(msg, remote)
+		}
 		d := t.nextTimeout(t.start)
 		if err := c.start(t); err != nil {
+			c.releaseInflight()
+			t.discard(err)
+
 			return err
 		}
 		if err := c.a.Start(msg.TransactionID, d); err != nil {
+			if c.delete(msg.TransactionID) {
+				c.transactionDone()
+				t.discard(err)
+			}
+
 			return err
 		}
 	}
-	_, err := msg.WriteTo(c.c)
+	_, err := c.write(msg.Raw, to)
+	if err != nil {
+		c.log.Debugf("client: failed to send %s: %v", msg, err)
+	} else {
+		c.log.Tracef("client: sent %s", msg)
+	}
 	if err != nil && handler != nil {
-		c.delete(msg.TransactionID)
+		if !c.delete(msg.TransactionID) {
+			// Transaction is already finished, e.g. by fatal read error.
+			return err
+		}
+		c.transactionDone()
+		if t.end != nil {
+			t.end(Event{TransactionID: t.id, Error: err})
+		}
 		// Stopping transaction instead of waiting until deadline.
 		if stopErr := c.a.Stop(msg.TransactionID); stopErr != nil {
 			return StopErr{
@@ -9,15 +9,20 @@ package stun
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/pion/logging"
 )
 
 var (
@@ -569,7 +574,11 @@ func (a *gcWaitAgent) Close() error {
 }
 
 func (a *gcWaitAgent) Collect(time.Time) error {
-	a.gc <- struct{}{}
+	// Not blocking collector, as it is closed before agent.
+	select {
+	case a.gc <- struct{}{}:
+	default:
+	}
 
 	return nil
 }
@@ -613,6 +622,271 @@ func TestClientGC(t *testing.T) {
 	}
 }
 
+func TestClient_FakeClock(t *testing.T) {
+	closed := make(chan struct{})
+	conn := &testConnection{
+		write: func(bytes []byte) (int, error) {
+			return len(bytes), nil
+		},
+		read: func([]byte) (int, error) {
+			<-closed
+
+			return 0, io.EOF
+		},
+		close: func() error {
+			close(closed)
+
+			return nil
+		},
+	}
+	clock := &manualClock{current: time.Now()}
+	c, err := NewClient(conn,
+		WithClock(clock),
+		WithRTO(time.Hour),
+		WithNoRetransmit,
+		WithTimeoutRate(time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	done := make(chan error, 1)
+	if err = c.Start(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		done <- e.Error
+	}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Hour * 8)
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrTransactionTimeOut) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Error("transaction should time out with clock")
+	}
+}
+
+func TestClient_RFC3489(t *testing.T) {
+	var (
+		requests = make(chan []byte, 1)
+		closed   = make(chan struct{})
+	)
+	conn := &testConnection{
+		write: func(b []byte) (int, error) {
+			requests <- append([]byte(nil), b...)
+
+			return len(b), nil
+		},
+		read: func(b []byte) (int, error) {
+			select {
+			case raw := <-requests:
+				req := new(Message)
+				if err := Decode(raw, req); err != nil {
+					return 0, err
+				}
+				res := MustBuild(req, BindingSuccess, &MappedAddress{
+					IP: net.IPv4(192, 0, 2, 1), Port: 3478,
+				})
+				// Legacy server does not know magic cookie and sends
+				// back whole 128 bit transaction ID, so it is replaced.
+				copy(res.Raw[4:8], []byte{1, 2, 3, 4})
+
+				return copy(b, res.Raw), nil
+			case <-closed:
+				return 0, io.EOF
+			}
+		},
+		close: func() error {
+			close(closed)
+
+			return nil
+		},
+	}
+	c, err := NewClient(conn, WithRFC3489())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	var res Event
+	if err = c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		res = e
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	var addr XORMappedAddress
+	if err = getMappedAddress(res.Message, &addr); err != nil {
+		t.Fatal(err)
+	}
+	if addr.Port != 3478 {
+		t.Errorf("unexpected address: %s", addr)
+	}
+}
+
+func TestClient_Indicate(t *testing.T) {
+	var (
+		written = make(chan []byte, 1)
+		closed  = make(chan struct{})
+	)
+	c, err := NewClient(&testConnection{
+		write: func(b []byte) (int, error) {
+			written <- append([]byte(nil), b...)
+
+			return len(b), nil
+		},
+		read: func([]byte) (int, error) {
+			<-closed
+
+			return 0, io.EOF
+		},
+		close: func() error {
+			close(closed)
+
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	if err = c.Indicate(MustBuild(TransactionID, BindingRequest)); !errors.Is(err, ErrNotIndication) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	m := MustBuild(TransactionID, NewType(MethodBinding, ClassIndication))
+	if err = c.Indicate(m); err != nil {
+		t.Fatal(err)
+	}
+	if raw := <-written; !bytes.Equal(raw, m.Raw) {
+		t.Error("unexpected message written")
+	}
+	c.mux.RLock()
+	pending := len(c.t)
+	c.mux.RUnlock()
+	if pending != 0 {
+		t.Error("indication should not start transaction")
+	}
+}
+
+func TestClient_SetInboundHandler(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	type inbound struct {
+		m    *Message
+		from net.Addr
+	}
+	received := make(chan inbound, 2)
+	c.SetInboundHandler(func(m *Message, from net.Addr) {
+		received <- inbound{m: m.Clone(), from: from}
+	})
+	for _, m := range []*Message{
+		MustBuild(TransactionID, NewType(MethodData, ClassIndication), NewSoftware("data")),
+		MustBuild(TransactionID, BindingSuccess),
+	} {
+		if _, err = server.WriteTo(m.Raw, conn.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-received:
+			if got.m.Type != m.Type || got.m.TransactionID != m.TransactionID {
+				t.Errorf("unexpected message: %s", got.m)
+			}
+			if got.from.String() != server.LocalAddr().String() {
+				t.Errorf("unexpected address: %s", got.from)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("%s is not handled", m)
+		}
+	}
+	c.SetInboundHandler(nil)
+	if _, err = server.WriteTo(MustBuild(TransactionID, BindingSuccess).Raw, conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	// Binding transaction to make sure that previous message is processed.
+	go func() {
+		buf := make([]byte, 1500)
+		n, addr, readErr := server.ReadFrom(buf)
+		if readErr != nil {
+			return
+		}
+		m := new(Message)
+		if Decode(buf[:n], m) == nil {
+			_, _ = server.WriteTo(MustBuild(m, BindingSuccess).Raw, addr)
+		}
+	}()
+	if err = c.Do(MustBuild(TransactionID, BindingRequest), func(Event) {}); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 0 {
+		t.Error("removed handler should not be called")
+	}
+}
+
+func TestClient_TransactionErrors(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go serveBinding(server, func(req *Message, _ net.Addr) *Message {
+		return MustBuild(req, BindingError, CodeUnauthorized)
+	})
+	for _, withErrors := range []bool{false, true} {
+		conn, err := net.Dial("udp4", server.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var options []ClientOption
+		if withErrors {
+			options = append(options, WithTransactionErrors())
+		}
+		c, err := NewClient(conn, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res Event
+		if err = c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+			res = e
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+		var tErr *TransactionError
+		if errors.As(res.Error, &tErr) != withErrors {
+			t.Fatalf("unexpected error: %v", res.Error)
+		}
+		if withErrors && !tErr.Code.IsUnauthorized() {
+			t.Errorf("unexpected code: %d", tErr.Code)
+		}
+	}
+}
+
 func TestClientCheckInit(t *testing.T) {
 	if err := (&Client{}).Indicate(nil); !errors.Is(err, ErrClientNotInitialized) {
 		t.Error("unexpected error")
@@ -911,8 +1185,8 @@ func testClientDoConcurrent(t *testing.T, concurrency int) { //nolint:cyclop
 		}()
 	}
 	wg.Wait()
-	if connErr := connR.Close(); connErr != nil {
-		t.Error(connErr)
+	if closeErr := client.Close(); closeErr != nil {
+		t.Error(closeErr)
 	}
 	conns.Wait()
 }
@@ -1534,3 +1808,864 @@ func TestClientImmediateTimeout(t *testing.T) {
 	})
 	<-gotReads
 }
+
+func TestClient_retransmitSchedule(t *testing.T) {
+	c, err := NewClient(noopConnection{}, WithRTO(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	if s := c.retransmitSchedule(); s != LinearSchedule(time.Second) {
+		t.Errorf("unexpected default schedule: %+v", s)
+	}
+	c.SetRTO(time.Millisecond * 200)
+	if s := c.retransmitSchedule(); s != LinearSchedule(time.Millisecond*200) {
+		t.Errorf("unexpected schedule after SetRTO: %+v", s)
+	}
+	scheduled, err := NewClient(noopConnection{}, WithSchedule(DefaultSchedule()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scheduled.Close() //nolint:errcheck
+	if s := scheduled.retransmitSchedule(); s != DefaultSchedule() {
+		t.Errorf("unexpected schedule: %+v", s)
+	}
+}
+
+func TestClient_RequestDecorator(t *testing.T) {
+	const attrCounter AttrType = 0x8050
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	counters := make(chan byte, 2)
+	go func() {
+		buf := make([]byte, 1500)
+		for i := 0; ; i++ {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m := new(Message)
+			if Decode(buf[:n], m) != nil {
+				continue
+			}
+			v, err := m.Get(attrCounter)
+			if err != nil || len(v) != 1 {
+				t.Error("decorated attribute not found")
+
+				continue
+			}
+			counters <- v[0]
+			if i == 0 {
+				// Dropping first request to trigger retransmission.
+				continue
+			}
+			_, _ = server.WriteTo(MustBuild(m, BindingSuccess).Raw, addr)
+		}
+	}()
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counter int32
+	c, err := NewClient(conn,
+		WithRTO(time.Millisecond*50),
+		WithRequestDecorator(func(m *Message) error {
+			m.Add(attrCounter, []byte{byte(atomic.AddInt32(&counter, 1))})
+
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if first, second := <-counters, <-counters; first != 1 || second != 2 {
+		t.Errorf("unexpected counters: %d, %d", first, second)
+	}
+	if err = c.Close(); err != nil {
+		t.Error(err)
+	}
+	t.Run("Error", func(t *testing.T) {
+		c, err := NewClient(noopConnection{}, WithRequestDecorator(func(*Message) error {
+			return errClientStart
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = c.Indicate(MustBuild(TransactionID, NewType(MethodBinding, ClassIndication))); !errors.Is(err, errClientStart) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	indication := NewType(MethodBinding, ClassIndication)
+	decorate := WithRequestDecorator(func(m *Message) error {
+		m.Add(attrCounter, []byte{1})
+
+		return nil
+	})
+	t.Run("Fingerprint", func(t *testing.T) {
+		written := make(chan []byte, 1)
+		closed := make(chan struct{})
+		conn := &testConnection{
+			write: func(b []byte) (int, error) {
+				written <- append([]byte(nil), b...)
+
+				return len(b), nil
+			},
+			read: func([]byte) (int, error) {
+				<-closed
+
+				return 0, io.EOF
+			},
+			close: func() error {
+				close(closed)
+
+				return nil
+			},
+		}
+		c, err := NewClient(conn, decorate, WithRequestInterceptor(NewSoftware("software").AddTo))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = c.Indicate(MustBuild(TransactionID, indication, Fingerprint)); err != nil {
+			t.Fatal(err)
+		}
+		m := new(Message)
+		if err = Decode(<-written, m); err != nil {
+			t.Fatal(err)
+		}
+		if !m.Contains(attrCounter) || !m.Contains(AttrSoftware) {
+			t.Error("decorated attribute not found")
+		}
+		if err = Fingerprint.Check(m); err != nil {
+			t.Error(err)
+		}
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("Protected", func(t *testing.T) {
+		c, err := NewClient(noopConnection{}, decorate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := MustBuild(TransactionID, indication, NewShortTermIntegritySHA256("password"))
+		if err = c.Indicate(m); !errors.Is(err, ErrMessageProtected) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestClient_EventAddresses(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m := new(Message)
+			if Decode(buf[:n], m) == nil {
+				_, _ = server.WriteTo(MustBuild(m, BindingSuccess).Raw, addr)
+			}
+		}
+	}()
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	start := time.Now()
+	if err = c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+
+			return
+		}
+		if e.Remote.String() != server.LocalAddr().String() || e.Local.String() != conn.LocalAddr().String() {
+			t.Errorf("unexpected addresses: %s -> %s", e.Remote, e.Local)
+		}
+		if e.Timestamp.Before(start) || e.Timestamp.After(time.Now()) {
+			t.Errorf("unexpected timestamp: %s", e.Timestamp)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testMetrics struct {
+	sent, received, rtts, timeouts, retransmits int32
+}
+
+func (m *testMetrics) IncSent()                 { atomic.AddInt32(&m.sent, 1) }
+func (m *testMetrics) IncReceived()             { atomic.AddInt32(&m.received, 1) }
+func (m *testMetrics) ObserveRTT(time.Duration) { atomic.AddInt32(&m.rtts, 1) }
+func (m *testMetrics) IncTimeout()              { atomic.AddInt32(&m.timeouts, 1) }
+func (m *testMetrics) IncRetransmit()           { atomic.AddInt32(&m.retransmits, 1) }
+
+func TestClient_Metrics(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m := new(Message)
+			if Decode(buf[:n], m) != nil || m.Contains(AttrSoftware) {
+				// Not answering to requests with SOFTWARE.
+				continue
+			}
+			_, _ = server.WriteTo(MustBuild(m, BindingSuccess).Raw, addr)
+		}
+	}()
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := new(testMetrics)
+	c, err := NewClient(conn,
+		WithMetrics(metrics),
+		WithRTO(time.Millisecond*20),
+		WithTimeoutRate(time.Millisecond*5),
+		WithSchedule(Schedule{Initial: time.Millisecond * 20}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	if err = c.Do(MustBuild(TransactionID, BindingRequest, NewSoftware("x")), func(e Event) {
+		if !errors.Is(e.Error, ErrTransactionTimeOut) {
+			t.Errorf("unexpected error: %v", e.Error)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&metrics.timeouts) != 1 ||
+		atomic.LoadInt32(&metrics.retransmits) != defaultMaxAttempts ||
+		atomic.LoadInt32(&metrics.sent) != defaultMaxAttempts+1 {
+		t.Errorf("unexpected metrics: %+v", metrics)
+	}
+	t.Run("Received", func(t *testing.T) {
+		conn, err := net.Dial("udp4", server.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		metrics := new(testMetrics)
+		c, err := NewClient(conn, WithMetrics(metrics))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close() //nolint:errcheck
+		if err = c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+			if e.Error != nil {
+				t.Error(e.Error)
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if atomic.LoadInt32(&metrics.received) == 0 || atomic.LoadInt32(&metrics.sent) == 0 {
+			t.Errorf("unexpected metrics: %+v", metrics)
+		}
+	})
+}
+
+func TestClient_Logger(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m := new(Message)
+			if Decode(buf[:n], m) == nil {
+				_, _ = server.WriteTo(MustBuild(m, BindingSuccess).Raw, addr)
+			}
+		}
+	}()
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	c, err := NewClient(conn, WithLogger(logging.NewDefaultLeveledLoggerForScope("test", logging.LogLevelTrace, &out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"client: sent Binding request", "client: received Binding success response"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("%q not found in log:\n%s", s, out.String())
+		}
+	}
+}
+
+type testTracer struct {
+	started []*Message
+	ended   []Event
+}
+
+func (t *testTracer) StartTransaction(m *Message, _ net.Addr) func(e Event) {
+	t.started = append(t.started, m)
+
+	return func(e Event) {
+		t.ended = append(t.ended, e)
+	}
+}
+
+func TestClient_Tracer(t *testing.T) {
+	closed := make(chan struct{})
+	conn := &testConnection{
+		write: func([]byte) (int, error) {
+			return 0, errClientWriteTimedOut
+		},
+		read: func([]byte) (int, error) {
+			<-closed
+
+			return 0, io.EOF
+		},
+		close: func() error {
+			close(closed)
+
+			return nil
+		},
+	}
+	tracer := new(testTracer)
+	c, err := NewClient(conn, WithTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := MustBuild(TransactionID, BindingRequest)
+	if err = c.Start(m, func(Event) {}); !errors.Is(err, errClientWriteTimedOut) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = c.Indicate(MustBuild(TransactionID, NewType(MethodBinding, ClassIndication))); !errors.Is(err, errClientWriteTimedOut) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracer.started) != 1 || tracer.started[0] != m {
+		t.Fatalf("unexpected started transactions: %v", tracer.started)
+	}
+	if len(tracer.ended) != 1 || !errors.Is(tracer.ended[0].Error, errClientWriteTimedOut) {
+		t.Fatalf("unexpected ended transactions: %v", tracer.ended)
+	}
+}
+
+func TestClient_TracerStartError(t *testing.T) {
+	conn := &testConnection{
+		write: func(b []byte) (int, error) {
+			return len(b), nil
+		},
+	}
+	tracer := new(testTracer)
+	c, err := NewClient(conn,
+		WithAgent(errorAgent{startErr: io.ErrUnexpectedEOF}),
+		WithTracer(tracer),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	if err = c.Start(MustBuild(TransactionID, BindingRequest), func(Event) {}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tracer.ended) != 1 || !errors.Is(tracer.ended[0].Error, io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected ended transactions: %v", tracer.ended)
+	}
+}
+
+func TestClient_CloseCtx(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	var (
+		received = make(chan struct{}, 1)
+		release  = make(chan struct{})
+	)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m := new(Message)
+			if Decode(buf[:n], m) != nil {
+				continue
+			}
+			received <- struct{}{}
+			<-release
+			_, _ = server.WriteTo(MustBuild(m, BindingSuccess).Raw, addr)
+		}
+	}()
+	newClient := func() *Client {
+		conn, err := net.Dial("udp4", server.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewClient(conn, WithRTO(time.Second*10))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c
+	}
+	isDraining := func(c *Client) bool {
+		c.mux.RLock()
+		defer c.mux.RUnlock()
+
+		return c.draining
+	}
+
+	t.Run("Drain", func(t *testing.T) {
+		c := newClient()
+		events := make(chan Event, 1)
+		if err := c.Start(MustBuild(TransactionID, BindingRequest), func(e Event) {
+			events <- e
+		}); err != nil {
+			t.Fatal(err)
+		}
+		<-received
+		closed := make(chan error, 1)
+		go func() {
+			closed <- c.CloseCtx(context.Background())
+		}()
+		for !isDraining(c) {
+			time.Sleep(time.Millisecond)
+		}
+		if err := c.Start(MustBuild(TransactionID, BindingRequest), func(Event) {}); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		release <- struct{}{}
+		if e := <-events; e.Error != nil {
+			t.Errorf("unexpected error: %v", e.Error)
+		}
+		if err := <-closed; err != nil {
+			t.Error(err)
+		}
+		if err := c.CloseCtx(context.Background()); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Deadline", func(t *testing.T) {
+		c := newClient()
+		if err := c.Start(MustBuild(TransactionID, BindingRequest), func(Event) {}); err != nil {
+			t.Fatal(err)
+		}
+		<-received
+		defer close(release)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		if err := c.CloseCtx(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err := c.Close(); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("client should be closed: %v", err)
+		}
+	})
+	t.Run("Idle", func(t *testing.T) {
+		c := newClient()
+		if err := c.CloseCtx(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestClient_MaxInflight(t *testing.T) {
+	newClient := func(t *testing.T, options ...ClientOption) (*Client, chan<- []byte) {
+		t.Helper()
+		var (
+			responses = make(chan []byte)
+			closed    = make(chan struct{})
+		)
+		c, err := NewClient(&testConnection{
+			write: func(b []byte) (int, error) {
+				return len(b), nil
+			},
+			read: func(b []byte) (int, error) {
+				select {
+				case raw := <-responses:
+					return copy(b, raw), nil
+				case <-closed:
+					return 0, io.EOF
+				}
+			},
+			close: func() error {
+				close(closed)
+
+				return nil
+			},
+		}, append([]ClientOption{WithRTO(time.Minute)}, options...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c, responses
+	}
+	respond := func(req *Message) []byte {
+		return MustBuild(NewTransactionIDSetter(req.TransactionID), BindingSuccess).Raw
+	}
+	t.Run("Reject", func(t *testing.T) {
+		c, responses := newClient(t, WithMaxInflight(2))
+		defer c.Close() //nolint:errcheck
+		done := make(chan struct{}, 3)
+		h := func(Event) { done <- struct{}{} }
+		first := MustBuild(TransactionID, BindingRequest)
+		for _, m := range []*Message{first, MustBuild(TransactionID, BindingRequest)} {
+			if err := c.Start(m, h); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.Start(MustBuild(TransactionID, BindingRequest), h); !errors.Is(err, ErrMaxInflight) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Indications are not limited.
+		if err := c.Start(MustBuild(TransactionID, NewType(MethodBinding, ClassIndication)), nil); err != nil {
+			t.Fatal(err)
+		}
+		responses <- respond(first)
+		<-done
+		if err := c.Start(MustBuild(TransactionID, BindingRequest), h); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("Queue", func(t *testing.T) {
+		c, responses := newClient(t, WithMaxInflight(1), WithInflightQueue())
+		first := MustBuild(TransactionID, BindingRequest)
+		if err := c.Start(first, func(Event) {}); err != nil {
+			t.Fatal(err)
+		}
+		started := make(chan error, 2)
+		go func() {
+			started <- c.Start(MustBuild(TransactionID, BindingRequest), func(Event) {})
+		}()
+		select {
+		case err := <-started:
+			t.Fatalf("should wait for slot, got %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+		responses <- respond(first)
+		if err := <-started; err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			started <- c.Start(MustBuild(TransactionID, BindingRequest), func(Event) {})
+		}()
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-started; !errors.Is(err, ErrClientClosed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestClient_Interceptors(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go serveBinding(server, func(req *Message, _ net.Addr) *Message {
+		var software Software
+		if err := software.GetFrom(req); err != nil {
+			return MustBuild(req, BindingError, CodeBadRequest)
+		}
+
+		return MustBuild(req, BindingSuccess, software)
+	})
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	errDenied := errors.New("denied")
+	var received []Method
+	c, err := NewClient(conn,
+		WithRequestInterceptor(NewSoftware("interceptor").AddTo),
+		WithRequestInterceptor(func(m *Message) error {
+			if m.Contains(AttrUsername) {
+				return errDenied
+			}
+
+			return nil
+		}),
+		WithResponseInterceptor(func(m *Message) error {
+			received = append(received, m.Type.Method)
+
+			return nil
+		}),
+		WithResponseInterceptor(func(m *Message) error {
+			if m.Type.Class == ClassErrorResponse {
+				return errDenied
+			}
+
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	req := MustBuild(TransactionID, BindingRequest)
+	if err = c.Do(req, func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+
+			return
+		}
+		var software Software
+		if getErr := software.GetFrom(e.Message); getErr != nil || software.String() != "interceptor" {
+			t.Errorf("unexpected software %q: %v", software, getErr)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if req.Contains(AttrSoftware) {
+		t.Error("original message should not be modified")
+	}
+	if len(received) != 1 || received[0] != MethodBinding {
+		t.Errorf("unexpected received %v", received)
+	}
+	if err = c.Do(MustBuild(TransactionID, BindingRequest, NewUsername("user")), func(Event) {
+		t.Error("handler should not be called")
+	}); !errors.Is(err, errDenied) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_WithSoftware(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go serveBinding(server, func(req *Message, _ net.Addr) *Message {
+		var software Software
+		if err := software.GetFrom(req); err != nil {
+			return MustBuild(req, BindingError, CodeBadRequest)
+		}
+
+		return MustBuild(req, BindingSuccess, software)
+	})
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(conn, WithSoftware("myapp/1.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	for _, req := range []*Message{
+		MustBuild(TransactionID, BindingRequest, Fingerprint),
+		MustBuild(TransactionID, BindingRequest, NewSoftware("other")),
+	} {
+		if err = c.Do(req, func(e Event) {
+			var software Software
+			if e.Error != nil {
+				t.Error(e.Error)
+			} else if getErr := software.GetFrom(e.Message); getErr != nil || software.String() != "myapp/1.2" {
+				t.Errorf("unexpected software %q: %v", software, getErr)
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClient_Err(t *testing.T) {
+	t.Run("ClosedByPeer", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go func() {
+			m := new(Message)
+			m.Raw = make([]byte, 1024)
+			_, _ = m.ReadFrom(serverConn)
+			// Dropping connection instead of responding.
+			_ = serverConn.Close()
+		}()
+		c, err := NewClient(clientConn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close() //nolint:errcheck
+		start := time.Now()
+		if err = c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+			if !errors.Is(e.Error, io.EOF) {
+				t.Errorf("unexpected error: %v", e.Error)
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if time.Since(start) > defaultRTO {
+			t.Error("transaction should fail without waiting for timeout")
+		}
+		if err = <-c.Err(); !errors.Is(err, io.EOF) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, ok := <-c.Err(); ok {
+			t.Error("channel should be closed")
+		}
+		if err = c.Start(MustBuild(TransactionID, BindingRequest), func(Event) {}); !errors.Is(err, io.EOF) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if state := c.ConnectionState(); state != ConnectionStateDisconnected {
+			t.Errorf("unexpected state: %s", state)
+		}
+	})
+	t.Run("Close", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		defer serverConn.Close() //nolint:errcheck
+		c, err := NewClient(clientConn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err, ok := <-c.Err(); ok {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestClient_StreamDecoding(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close() //nolint:errcheck
+	go func() {
+		conn, acceptErr := listener.Accept()
+		if acceptErr != nil {
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+		d := NewStreamDecoder(conn, 0)
+		var responses []byte
+		for i := 0; i < 2; i++ {
+			req := new(Message)
+			if decodeErr := d.Decode(req); decodeErr != nil {
+				t.Error(decodeErr)
+
+				return
+			}
+			responses = append(responses, MustBuild(req, BindingSuccess, NewSoftware("response")).Raw...)
+		}
+		// Both responses are coalesced and split in the middle of header.
+		for _, part := range [][]byte{responses[:7], responses[7:]} {
+			if _, writeErr := conn.Write(part); writeErr != nil {
+				t.Error(writeErr)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		_, _ = conn.Read(make([]byte, 1)) // waiting for client to close
+	}()
+	c, err := Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		if err = c.Start(MustBuild(TransactionID, BindingRequest), func(e Event) {
+			defer wg.Done()
+			if e.Error != nil {
+				t.Error(e.Error)
+			} else if e.Message.Type != BindingSuccess {
+				t.Errorf("unexpected response %s", e.Message)
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestClient_TransactionIDCollision(t *testing.T) {
+	var (
+		id     = [TransactionIDSize]byte{1, 2, 3}
+		fresh  = [TransactionIDSize]byte{4, 5, 6}
+		closed = make(chan struct{})
+		source = bytes.NewReader(fresh[:])
+	)
+	c, err := NewClient(&testConnection{
+		write: func(b []byte) (int, error) {
+			return len(b), nil
+		},
+		read: func([]byte) (int, error) {
+			<-closed
+
+			return 0, io.EOF
+		},
+		close: func() error {
+			close(closed)
+
+			return nil
+		},
+	}, WithRTO(time.Minute), WithTransactionIDSource(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	start := func(setters ...Setter) (*Message, error) {
+		m := MustBuild(append([]Setter{NewTransactionIDSetter(id), BindingRequest}, setters...)...)
+
+		return m, c.Start(m, func(Event) {})
+	}
+	first, err := start(NewSoftware("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Start(first, func(Event) {}); !errors.Is(err, ErrTransactionExists) {
+		t.Errorf("starting the same message should fail, got %v", err)
+	}
+	second, err := start(NewSoftware("second"), Fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.TransactionID != fresh {
+		t.Errorf("unexpected transaction ID %x", second.TransactionID)
+	}
+	decoded := new(Message)
+	if err = Decode(second.Raw, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TransactionID != fresh || Fingerprint.Check(decoded) != nil {
+		t.Error("transaction ID or FINGERPRINT is not updated in raw message")
+	}
+	if _, err = start(NewShortTermIntegrity("password")); !errors.Is(err, ErrTransactionExists) {
+		t.Errorf("protected message should not be updated, got %v", err)
+	}
+	// Source returns colliding ID for all attempts.
+	source.Reset(bytes.Repeat(id[:], maxTransactionIDRetries))
+	if _, err = start(NewSoftware("third")); !errors.Is(err, ErrTransactionExists) {
+		t.Errorf("unexpected error after retries: %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"net"
+	"sync"
+)
+
+// NonceHandler is called when client learns new REALM or NONCE from
+// 401 (Unauthorized) or 438 (Stale Nonce) error response.
+type NonceHandler func(realm, nonce string)
+
+// WithCredentials enables long-term credential mechanism (RFC 8489
+// Section 9.2) for requests sent by client. First request is sent
+// without credentials, and when server challenges it with 401
+// (Unauthorized) or rotates nonce with 438 (Stale Nonce), client
+// remembers REALM and NONCE, and transparently retries request once with
+// USERNAME, REALM, NONCE and MESSAGE-INTEGRITY. Later requests are
+// protected with last known REALM and NONCE.
+//
+// If server advertises PASSWORD-ALGORITHMS in challenge, key derivation
+// algorithm is selected with NegotiatePasswordAlgorithm, and requests are
+// protected with MESSAGE-INTEGRITY-SHA256 instead, see LongTermAuth.
+//
+// Requests that already have MESSAGE-INTEGRITY are sent as is.
+func WithCredentials(creds Credentials) ClientOption {
+	return func(c *Client) {
+		c.auth.creds = creds
+		c.auth.enabled = true
+	}
+}
+
+// WithNonceHandler sets handler that observes REALM and NONCE rotation
+// of long-term credential mechanism, see WithCredentials.
+func WithNonceHandler(h NonceHandler) ClientOption {
+	return func(c *Client) {
+		c.auth.handler = h
+	}
+}
+
+// clientAuth is state of long-term credential mechanism of client.
+type clientAuth struct {
+	enabled bool
+	creds   Credentials
+	handler NonceHandler
+
+	mux        sync.Mutex // guards realm, nonce and algorithms
+	realm      string
+	nonce      string
+	alg        PasswordAlgorithm
+	algorithms PasswordAlgorithms // of server, echoed if alg is set
+}
+
+func (a *clientAuth) current() (realm, nonce string) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	return a.realm, a.nonce
+}
+
+func (a *clientAuth) algorithm() (PasswordAlgorithm, PasswordAlgorithms) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	return a.alg, a.algorithms
+}
+
+// learn updates REALM and NONCE from m, reporting whether m is challenge
+// after which request should be retried.
+func (a *clientAuth) learn(m *Message) bool {
+	if m == nil || m.Type.Class != ClassErrorResponse {
+		return false
+	}
+	var code ErrorCodeAttribute
+	if code.GetFrom(m) != nil {
+		return false
+	}
+	if !code.Code.IsUnauthorized() && !code.Code.IsStaleNonce() {
+		return false
+	}
+	var nonce Nonce
+	if nonce.GetFrom(m) != nil {
+		return false
+	}
+	var realm Realm
+	if realm.GetFrom(m) != nil {
+		if code.Code.IsUnauthorized() {
+			return false
+		}
+		// 438 response can omit REALM, keeping previous one.
+		r, _ := a.current()
+		realm = Realm(r)
+	}
+	if len(realm) == 0 {
+		return false
+	}
+	alg, err := NegotiatePasswordAlgorithm(m)
+	if err != nil {
+		return false
+	}
+	var algorithms PasswordAlgorithms
+	if alg != 0 {
+		_ = algorithms.GetFrom(m)
+	}
+	a.mux.Lock()
+	changed := a.realm != realm.String() || a.nonce != nonce.String()
+	a.realm, a.nonce = realm.String(), nonce.String()
+	if code.Code.IsUnauthorized() || alg != 0 {
+		a.alg, a.algorithms = alg, algorithms
+	}
+	a.mux.Unlock()
+	if changed && a.handler != nil {
+		a.handler(realm.String(), nonce.String())
+	}
+
+	return true
+}
+
+// addTo adds long-term credentials to request m if REALM and NONCE are
+// known and m is not protected yet.
+func (a *clientAuth) addTo(m *Message) error {
+	if m.Type.Class != ClassRequest ||
+		m.Contains(AttrMessageIntegrity) || m.Contains(AttrMessageIntegritySHA256) {
+		return nil
+	}
+	realm, nonce := a.current()
+	if nonce == "" {
+		return nil
+	}
+	fingerprint := m.Contains(AttrFingerprint)
+	if fingerprint {
+		_ = m.Delete(AttrFingerprint)
+	}
+	alg, algorithms := a.algorithm()
+	if alg != 0 {
+		if err := algorithms.AddTo(m); err != nil {
+			return err
+		}
+	}
+	if err := LongTermAuth(a.creds, realm, nonce, alg).AddTo(m); err != nil {
+		return err
+	}
+	if fingerprint {
+		return Fingerprint.AddTo(m)
+	}
+
+	return nil
+}
+
+// authHandler wraps handler of request msg so that request is retried
+// once with new credentials on 401 or 438 error response.
+func (c *Client) authHandler(msg *Message, to net.Addr, handler Handler) Handler {
+	raw := append([]byte(nil), msg.Raw...)
+
+	return func(e Event) {
+		if !c.auth.learn(e.Message) {
+			handler(e)
+
+			return
+		}
+		retry := &Message{Raw: raw}
+		if err := retry.Decode(); err != nil {
+			handler(Event{TransactionID: e.TransactionID, Error: err})
+
+			return
+		}
+		if err := TransactionIDFrom(c.ids).AddTo(retry); err != nil {
+			handler(Event{TransactionID: e.TransactionID, Error: err})
+
+			return
+		}
+		// Starting from goroutine, because slot of WithMaxInflight is
+		// released only after handler returns.
+		go func() {
+			if err := c.startTo(retry, to, handler); err != nil {
+				handler(Event{TransactionID: retry.TransactionID, Error: err})
+			}
+		}()
+	}
+}
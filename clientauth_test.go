@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+// authServer is long-term credential mechanism server that rotates
+// nonce on demand.
+type authServer struct {
+	mux      sync.Mutex
+	nonce    string
+	requests int
+	stale    bool // always answer with 438
+	alg      PasswordAlgorithm
+	algs     PasswordAlgorithms // advertised with nonce cookie if set
+}
+
+func (s *authServer) rotate(nonce string) {
+	s.mux.Lock()
+	s.nonce = nonce
+	s.mux.Unlock()
+}
+
+func (s *authServer) count() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.requests
+}
+
+func (s *authServer) respond(req *Message, _ net.Addr) *Message {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.requests++
+	challenge := func(code ErrorCode) *Message {
+		if len(s.algs) > 0 {
+			return MustBuild(req, BindingError, code, NewRealm("example.org"),
+				NewNonceCookie(FeaturePasswordAlgorithms, s.nonce), s.algs,
+			)
+		}
+
+		return MustBuild(req, BindingError, code, NewRealm("example.org"), NewNonce(s.nonce))
+	}
+	if !req.Contains(AttrMessageIntegrity) && !req.Contains(AttrMessageIntegritySHA256) {
+		return challenge(CodeUnauthorized)
+	}
+	var nonce Nonce
+	if len(s.algs) > 0 {
+		nonce = Nonce(NewNonceCookie(FeaturePasswordAlgorithms, s.nonce))
+	} else {
+		nonce = NewNonce(s.nonce)
+	}
+	var got Nonce
+	if err := got.GetFrom(req); err != nil || s.stale || got.String() != nonce.String() {
+		return challenge(CodeStaleNonce)
+	}
+	alg := PasswordAlgorithmMD5
+	if len(s.algs) > 0 {
+		var echo PasswordAlgorithms
+		if err := req.Parse(&alg, &echo); err != nil || len(echo) != len(s.algs) {
+			return MustBuild(req, BindingError, CodeBadRequest)
+		}
+		s.alg = alg
+	}
+	key, err := NewLongTermIntegrityAlgorithm("user", "example.org", "secret", alg)
+	if err != nil {
+		return MustBuild(req, BindingError, CodeBadRequest)
+	}
+	if err := IntegrityFor(req, key).Check(req); err != nil {
+		return challenge(CodeUnauthorized)
+	}
+
+	return MustBuild(req, BindingSuccess)
+}
+
+func TestClient_WithCredentials(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	s := &authServer{nonce: "n1"}
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go serveBinding(server, s.respond)
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nonces []string
+	c, err := NewClient(conn,
+		WithCredentials(Credentials{Username: "user", Password: "secret"}),
+		WithNonceHandler(func(realm, nonce string) {
+			if realm != "example.org" {
+				t.Errorf("unexpected realm %q", realm)
+			}
+			nonces = append(nonces, nonce)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	do := func(requests int) {
+		t.Helper()
+		before := s.count()
+		if err := c.Do(MustBuild(TransactionID, BindingRequest, Fingerprint), func(e Event) {
+			if e.Error != nil {
+				t.Error(e.Error)
+			} else if e.Message.Type != BindingSuccess {
+				t.Errorf("unexpected response %s", e.Message)
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.count() - before; got != requests {
+			t.Errorf("%d requests sent, expected %d", got, requests)
+		}
+	}
+	t.Run("Challenge", func(t *testing.T) { do(2) })
+	t.Run("Authenticated", func(t *testing.T) { do(1) })
+	s.rotate("n2")
+	t.Run("StaleNonce", func(t *testing.T) { do(2) })
+	if len(nonces) != 2 || nonces[0] != "n1" || nonces[1] != "n2" {
+		t.Errorf("unexpected nonce rotation: %v", nonces)
+	}
+	t.Run("RetryOnce", func(t *testing.T) {
+		s.mux.Lock()
+		s.stale = true
+		s.mux.Unlock()
+		var code ErrorCodeAttribute
+		if err := c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+			if e.Error != nil {
+				t.Error(e.Error)
+			} else if err := code.GetFrom(e.Message); err != nil {
+				t.Error(err)
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if code.Code != CodeStaleNonce {
+			t.Errorf("unexpected code %d", code.Code)
+		}
+	})
+}
+
+func TestClient_WithCredentials_PasswordAlgorithm(t *testing.T) {
+	s := &authServer{nonce: "n1", algs: PasswordAlgorithms{PasswordAlgorithmSHA256, PasswordAlgorithmMD5}}
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go serveBinding(server, s.respond)
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(conn, WithCredentials(Credentials{Username: "user", Password: "secret"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	if err = c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+		} else if e.Message.Type != BindingSuccess {
+			t.Errorf("unexpected response %s", e.Message)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.requests != 2 || s.alg != PasswordAlgorithmSHA256 {
+		t.Errorf("unexpected negotiation: %d requests, %s", s.requests, s.alg)
+	}
+}
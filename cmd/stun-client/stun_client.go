@@ -5,18 +5,67 @@
 package main
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"strconv"
 
 	"github.com/pion/stun/v3"
 )
 
-func main() {
+//nolint:gochecknoglobals
+var (
+	proto    = flag.String("proto", "udp", "transport protocol: udp, tcp or tls")
+	software = flag.String("software", "", "value of SOFTWARE attribute in request")
+	asJSON   = flag.Bool("json", false, "print result as JSON")
+	verbose  = flag.Bool("v", false, "dump request and response messages to stderr")
+)
+
+// result is JSON representation of binding result.
+type result struct {
+	Server   string `json:"server"`
+	Proto    string `json:"proto"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Software string `json:"software,omitempty"`
+}
+
+func dial(uri *stun.URI) (*stun.Client, error) {
+	addr := net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port))
+	switch *proto {
+	case "udp":
+		return stun.DialURI(uri, &stun.DialConfig{})
+	case "tcp":
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return stun.NewClient(conn, stun.WithNoRetransmit)
+	case "tls":
+		conn, err := tls.Dial("tcp", addr, &tls.Config{
+			ServerName: uri.Host,
+			MinVersion: tls.VersionTLS12,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return stun.NewClient(conn, stun.WithNoRetransmit)
+	default:
+		return nil, fmt.Errorf("%w: %s", stun.ErrProtoType, *proto)
+	}
+}
+
+func main() { //nolint:cyclop
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
-		fmt.Fprintln(os.Stderr, os.Args[0], "stun:stun.l.google.com:19302")
+		fmt.Fprintln(os.Stderr, os.Args[0], "[flags] stun:stun.l.google.com:19302")
+		flag.PrintDefaults()
 	}
 	flag.Parse()
 
@@ -30,22 +79,51 @@ func main() {
 		log.Fatalf("Invalid URI '%s': %s", uriStr, err)
 	}
 
-	// we only try the first address, so restrict ourselves to IPv4
-	client, err := stun.DialURI(uri, &stun.DialConfig{})
+	client, err := dial(uri)
 	if err != nil {
 		log.Fatalf("Failed to dial: %s", err)
 	}
-	if err = client.Do(stun.MustBuild(stun.TransactionID, stun.BindingRequest), func(res stun.Event) {
+
+	setters := []stun.Setter{stun.TransactionID, stun.BindingRequest}
+	if *software != "" {
+		setters = append(setters, stun.NewSoftware(*software))
+	}
+	setters = append(setters, stun.Fingerprint)
+	request := stun.MustBuild(setters...)
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "%+v\n", request)
+	}
+
+	if err = client.Do(request, func(res stun.Event) {
 		if res.Error != nil {
 			log.Fatalf("Failed STUN transaction: %s", res.Error)
 		}
+		if *verbose {
+			fmt.Fprintf(os.Stderr, "%+v\n", res.Message)
+		}
 
-		var xorAddr stun.XORMappedAddress
-		if getErr := xorAddr.GetFrom(res.Message); getErr != nil {
-			log.Fatalf("Failed to get XOR-MAPPED-ADDRESS: %s", getErr)
+		addr, getErr := res.Message.MappedAddress()
+		if getErr != nil {
+			log.Fatalf("Failed to get mapped address: %s", getErr)
 		}
+		if !*asJSON {
+			log.Print(addr)
 
-		log.Print(xorAddr)
+			return
+		}
+		out := result{
+			Server: uriStr,
+			Proto:  *proto,
+			IP:     addr.Addr().String(),
+			Port:   int(addr.Port()),
+		}
+		var serverSoftware stun.Software
+		if serverSoftware.GetFrom(res.Message) == nil {
+			out.Software = serverSoftware.String()
+		}
+		if encodeErr := json.NewEncoder(os.Stdout).Encode(out); encodeErr != nil {
+			log.Fatalf("Failed to encode result: %s", encodeErr)
+		}
 	}); err != nil {
 		log.Fatal("Do:", err)
 	}
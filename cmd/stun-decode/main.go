@@ -6,6 +6,8 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -14,6 +16,28 @@ import (
 	"github.com/pion/stun/v3"
 )
 
+//nolint:gochecknoglobals
+var (
+	asJSON   = flag.Bool("json", false, "print decoded message as JSON")
+	dump     = flag.Bool("dump", false, "print multi-line dump of decoded message")
+	annotate = flag.Bool("annotate", false, "print annotations of decoded message")
+)
+
+type jsonAttribute struct {
+	Type   string `json:"type"`
+	Code   uint16 `json:"code"`
+	Length uint16 `json:"length"`
+	Value  string `json:"value"`
+}
+
+type jsonMessage struct {
+	Type          string           `json:"type"`
+	Length        uint32           `json:"length"`
+	TransactionID string           `json:"transaction_id"`
+	Attributes    []jsonAttribute  `json:"attributes"`
+	Annotations   stun.Annotations `json:"annotations,omitempty"`
+}
+
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", "stun-decode")
@@ -31,5 +55,44 @@ func main() {
 	if err = m.Decode(); err != nil {
 		log.Fatalln("Unable to decode message:", err)
 	}
-	fmt.Println(m)
+	var annotations stun.Annotations
+	if *annotate {
+		annotations = stun.Annotate(m)
+	}
+	switch {
+	case *asJSON:
+		out := jsonMessage{
+			Type:          m.Type.String(),
+			Length:        m.Length,
+			TransactionID: hex.EncodeToString(m.TransactionID[:]),
+			Attributes:    make([]jsonAttribute, 0, len(m.Attributes)),
+			Annotations:   annotations,
+		}
+		for _, a := range m.Attributes {
+			out.Attributes = append(out.Attributes, jsonAttribute{
+				Type:   a.Type.String(),
+				Code:   a.Type.Value(),
+				Length: a.Length,
+				Value:  hex.EncodeToString(a.Value),
+			})
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(out); err != nil {
+			log.Fatalln("Unable to encode message:", err)
+		}
+	case *dump:
+		if err = m.Dump(os.Stdout); err != nil {
+			log.Fatalln("Unable to dump message:", err)
+		}
+		if *annotate {
+			if err = annotations.Dump(os.Stdout); err != nil {
+				log.Fatalln("Unable to dump annotations:", err)
+			}
+		}
+	case *annotate:
+		fmt.Println(m, annotations)
+	default:
+		fmt.Println(m)
+	}
 }
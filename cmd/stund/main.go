@@ -0,0 +1,187 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package main implements a STUN server built on the stunserver package
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/pion/stun/v3/stunmetrics"
+	"github.com/pion/stun/v3/stunserver"
+)
+
+//nolint:gochecknoglobals
+var (
+	udpAddr     = flag.String("udp", ":3478", "UDP listen address, empty to disable")
+	tcpAddr     = flag.String("tcp", ":3478", "TCP listen address, empty to disable")
+	tlsAddr     = flag.String("tls", "", "TLS listen address, empty to disable")
+	certFile    = flag.String("cert", "", "TLS certificate file")
+	keyFile     = flag.String("key", "", "TLS key file")
+	alternate   = flag.String("alternate", "", "alternate IP:port for RFC 5780 mode, requires explicit -udp IP:port")
+	metricsAddr = flag.String("metrics", "", "HTTP listen address of Prometheus metrics endpoint, empty to disable")
+	realm       = flag.String("realm", "pion.ly", "realm for long-term credentials")
+	credentials = flag.String("credentials", "", "file with username:password lines, enables authentication")
+	restSecret  = flag.String("rest-secret", "", "shared secret of TURN REST API credentials, enables authentication")
+	software    = flag.String("software", "pion/stund", "SOFTWARE attribute value, empty to disable")
+	rateLimit   = flag.Float64("rate", 0, "requests per second allowed from each IP address, 0 to disable")
+	rateBurst   = flag.Int("burst", 20, "burst of requests allowed from each IP address")
+	rateSources = flag.Int("rate-sources", 65536, "maximum count of IP addresses tracked by rate limiter")
+)
+
+// listenBehaviorDiscovery opens four sockets for RFC 5780 mode.
+func listenBehaviorDiscovery(primary, alternate string) ([4]net.PacketConn, error) {
+	var conns [4]net.PacketConn
+	primaryIP, primaryPort, err := net.SplitHostPort(primary)
+	if err != nil {
+		return conns, err
+	}
+	alternateIP, alternatePort, err := net.SplitHostPort(alternate)
+	if err != nil {
+		return conns, err
+	}
+	for i, addr := range []string{
+		net.JoinHostPort(primaryIP, primaryPort),
+		net.JoinHostPort(primaryIP, alternatePort),
+		net.JoinHostPort(alternateIP, primaryPort),
+		net.JoinHostPort(alternateIP, alternatePort),
+	} {
+		if conns[i], err = net.ListenPacket("udp", addr); err != nil {
+			return conns, err
+		}
+	}
+
+	return conns, nil
+}
+
+// metricsHandler writes server stats and counters of serverMetrics by
+// method, class and error code in Prometheus text exposition format.
+func metricsHandler(srv *stunserver.Server, serverMetrics *stunmetrics.ServerMetrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		stats := srv.Stats()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		for _, m := range []struct {
+			name, help string
+			value      uint64
+		}{
+			{"stun_messages_received_total", "Messages received.", stats.Received},
+			{"stun_messages_malformed_total", "Packets that failed to decode as STUN.", stats.Malformed},
+			{"stun_responses_success_total", "Success responses sent.", stats.Success},
+			{"stun_responses_error_total", "Error responses sent.", stats.Errors},
+			{"stun_messages_dropped_total", "Messages ignored by server.", stats.Dropped},
+			{"stun_requests_limited_total", "Requests dropped by rate limiter.", stats.Limited},
+		} {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", //nolint:errcheck
+				m.name, m.help, m.name, m.name, m.value,
+			)
+		}
+		_, _ = serverMetrics.WriteTo(w)
+	})
+}
+
+func main() { //nolint:cyclop
+	flag.Parse()
+	var options []stunserver.Option
+	if *software != "" {
+		options = append(options, stunserver.WithSoftware(*software))
+	}
+	if *credentials != "" {
+		store, err := stunserver.LoadCredentials(*credentials, *realm)
+		if err != nil {
+			log.Fatalf("Failed to load credentials: %s", err)
+		}
+		options = append(options, stunserver.WithCredentialStore(*realm, store))
+	} else if *restSecret != "" {
+		store := stunserver.NewTURNRESTCredentials([]byte(*restSecret))
+		options = append(options, stunserver.WithCredentialStore(*realm, store))
+	}
+	if *rateLimit > 0 {
+		options = append(options, stunserver.WithRateLimiter(
+			stunserver.NewRateLimiter(*rateLimit, *rateBurst, *rateSources),
+		))
+	}
+	var serverMetrics *stunmetrics.ServerMetrics
+	if *metricsAddr != "" {
+		serverMetrics = stunmetrics.NewServer()
+		options = append(options, stunserver.WithMetrics(serverMetrics))
+	}
+	srv := stunserver.New(options...)
+	errs := make(chan error, 4)
+
+	switch {
+	case *udpAddr != "" && *alternate != "":
+		conns, err := listenBehaviorDiscovery(*udpAddr, *alternate)
+		if err != nil {
+			log.Fatalf("Failed to listen UDP: %s", err)
+		}
+		go func() { errs <- srv.ServeBehaviorDiscovery(conns[0], conns[1], conns[2], conns[3]) }()
+		log.Printf("Serving RFC 5780 UDP on %s and %s", *udpAddr, *alternate)
+	case *udpAddr != "":
+		conn, err := net.ListenPacket("udp", *udpAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen UDP: %s", err)
+		}
+		go func() { errs <- srv.ServePacket(conn) }()
+		log.Printf("Serving UDP on %s", conn.LocalAddr())
+	}
+	if *tcpAddr != "" {
+		l, err := net.Listen("tcp", *tcpAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen TCP: %s", err)
+		}
+		go func() { errs <- srv.Serve(l) }()
+		log.Printf("Serving TCP on %s", l.Addr())
+	}
+	if *tlsAddr != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificate: %s", err)
+		}
+		l, err := tls.Listen("tcp", *tlsAddr, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})
+		if err != nil {
+			log.Fatalf("Failed to listen TLS: %s", err)
+		}
+		go func() { errs <- srv.Serve(l) }()
+		log.Printf("Serving TLS on %s", l.Addr())
+	}
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metricsHandler(srv, serverMetrics))
+		metricsServer := &http.Server{
+			Addr:              *metricsAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: time.Second * 5,
+		}
+		go func() { errs <- metricsServer.ListenAndServe() }()
+		log.Printf("Serving metrics on http://%s/metrics", *metricsAddr)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	select {
+	case <-signals:
+		log.Print("Shutting down")
+	case err := <-errs:
+		if !errors.Is(err, stunserver.ErrServerClosed) {
+			log.Printf("Serve failed: %s", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, stunserver.ErrServerClosed) {
+		log.Printf("Failed to shut down server: %s", err)
+	}
+}
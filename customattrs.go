@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+// Adapters for attributes that are not implemented by package, e.g.
+// experimental ones from 0xC000-0xFFFF range:
+//
+//	m := MustBuild(BindingRequest, Uint32Attr{Type: 0xC001, Value: 42})
+//	attr := Uint32Attr{Type: 0xC001}
+//	err := attr.GetFrom(m)
+//
+// Type must be set before GetFrom call.
+
+const (
+	uint32AttrSize = 4
+	uint64AttrSize = 8
+)
+
+// RawAttr is attribute with opaque value.
+type RawAttr struct {
+	Type  AttrType
+	Value []byte
+}
+
+// AddTo adds attribute to m.
+func (a RawAttr) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	m.Add(a.Type, a.Value)
+
+	return nil
+}
+
+// GetFrom decodes attribute from m. Value is valid until m.Raw is valid.
+func (a *RawAttr) GetFrom(m *Message) error {
+	v, err := m.Get(a.Type)
+	if err != nil {
+		return err
+	}
+	a.Value = v
+
+	return nil
+}
+
+// Uint32Attr is attribute with 32-bit unsigned integer value, like
+// LIFETIME.
+type Uint32Attr struct {
+	Type  AttrType
+	Value uint32
+}
+
+// AddTo adds attribute to m.
+func (a Uint32Attr) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	var v [uint32AttrSize]byte
+	bin.PutUint32(v[:], a.Value)
+	m.Add(a.Type, v[:])
+
+	return nil
+}
+
+// GetFrom decodes attribute from m.
+func (a *Uint32Attr) GetFrom(m *Message) error {
+	v, err := m.Get(a.Type)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(a.Type, len(v), uint32AttrSize); err != nil {
+		return err
+	}
+	a.Value = bin.Uint32(v)
+
+	return nil
+}
+
+// Uint64Attr is attribute with 64-bit unsigned integer value, like
+// ICE-CONTROLLING.
+type Uint64Attr struct {
+	Type  AttrType
+	Value uint64
+}
+
+// AddTo adds attribute to m.
+func (a Uint64Attr) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	var v [uint64AttrSize]byte
+	bin.PutUint64(v[:], a.Value)
+	m.Add(a.Type, v[:])
+
+	return nil
+}
+
+// GetFrom decodes attribute from m.
+func (a *Uint64Attr) GetFrom(m *Message) error {
+	v, err := m.Get(a.Type)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(a.Type, len(v), uint64AttrSize); err != nil {
+		return err
+	}
+	a.Value = bin.Uint64(v)
+
+	return nil
+}
+
+// StringAttr is attribute with text value, like SOFTWARE.
+type StringAttr struct {
+	Type  AttrType
+	Value string
+}
+
+// AddTo adds attribute to m.
+func (a StringAttr) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	m.Add(a.Type, []byte(a.Value))
+
+	return nil
+}
+
+// GetFrom decodes attribute from m.
+func (a *StringAttr) GetFrom(m *Message) error {
+	v, err := m.Get(a.Type)
+	if err != nil {
+		return err
+	}
+	a.Value = string(v)
+
+	return nil
+}
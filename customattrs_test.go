@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+func TestCustomAttrs(t *testing.T) {
+	const (
+		rawType    AttrType = 0xC001
+		uint32Type AttrType = 0xC002
+		uint64Type AttrType = 0xC003
+		stringType AttrType = 0xC004
+	)
+	m := MustBuild(TransactionID, BindingRequest,
+		RawAttr{Type: rawType, Value: []byte{1, 2, 3}},
+		Uint32Attr{Type: uint32Type, Value: 42},
+		Uint64Attr{Type: uint64Type, Value: 1 << 40},
+		StringAttr{Type: stringType, Value: "value"},
+	)
+	var (
+		raw  = RawAttr{Type: rawType}
+		u32  = Uint32Attr{Type: uint32Type}
+		u64  = Uint64Attr{Type: uint64Type}
+		text = StringAttr{Type: stringType}
+	)
+	if err := m.Parse(&raw, &u32, &u64, &text); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw.Value, []byte{1, 2, 3}) || u32.Value != 42 || u64.Value != 1<<40 || text.Value != "value" {
+		t.Errorf("unexpected values: %v, %d, %d, %q", raw.Value, u32.Value, u64.Value, text.Value)
+	}
+	t.Run("Size", func(t *testing.T) {
+		for _, g := range []Getter{
+			&Uint32Attr{Type: rawType}, &Uint64Attr{Type: uint32Type},
+		} {
+			if err := g.GetFrom(m); !errors.Is(err, ErrAttributeSizeInvalid) {
+				t.Errorf("%T: unexpected error: %v", g, err)
+			}
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		for _, g := range []Getter{
+			&RawAttr{Type: AttrNonce}, &Uint32Attr{Type: AttrNonce},
+			&Uint64Attr{Type: AttrNonce}, &StringAttr{Type: AttrNonce},
+		} {
+			if err := g.GetFrom(m); !errors.Is(err, ErrAttributeNotFound) {
+				t.Errorf("%T: unexpected error: %v", g, err)
+			}
+		}
+	})
+	t.Run("Allocations", func(t *testing.T) {
+		attr := Uint64Attr{Type: uint64Type, Value: 1}
+		testutil.ShouldNotAllocate(t, func() {
+			m.Reset()
+			m.WriteHeader()
+			if err := attr.AddTo(m); err != nil {
+				t.Error(err)
+			}
+			if err := u64.GetFrom(m); err != nil {
+				t.Error(err)
+			}
+		})
+	})
+}
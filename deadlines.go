@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import "time"
+
+// deadlineEntry is deadline of transaction in deadlineHeap.
+type deadlineEntry struct {
+	deadline time.Time
+	id       transactionID
+}
+
+// deadlineHeap is min-heap of transaction deadlines, so Agent.Collect
+// finds timed out transactions without scanning all of them. Entries
+// of transactions that are completed before deadline are not removed
+// from heap, and are skipped when popped or dropped by rebuild.
+//
+// It is not container/heap to avoid allocation on each push.
+type deadlineHeap []deadlineEntry
+
+func (h deadlineHeap) less(i, j int) bool {
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+// expired reports whether earliest deadline is before t.
+func (h deadlineHeap) expired(t time.Time) bool {
+	return len(h) > 0 && h[0].deadline.Before(t)
+}
+
+func (h *deadlineHeap) push(e deadlineEntry) {
+	*h = append(*h, e)
+	h.up(len(*h) - 1)
+}
+
+// pop removes and returns entry with earliest deadline, heap must not
+// be empty.
+func (h *deadlineHeap) pop() deadlineEntry {
+	old := *h
+	e := old[0]
+	last := len(old) - 1
+	old[0] = old[last]
+	*h = old[:last]
+	h.down(0)
+
+	return e
+}
+
+// rebuild replaces entries with deadlines of transactions.
+func (h *deadlineHeap) rebuild(transactions map[transactionID]agentTransaction) {
+	*h = (*h)[:0]
+	for id, t := range transactions {
+		*h = append(*h, deadlineEntry{deadline: t.deadline, id: id})
+	}
+	for i := len(*h)/2 - 1; i >= 0; i-- {
+		h.down(i)
+	}
+}
+
+func (h deadlineHeap) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.less(i, parent) {
+			return
+		}
+		h[i], h[parent] = h[parent], h[i]
+		i = parent
+	}
+}
+
+func (h deadlineHeap) down(i int) {
+	for {
+		least := i
+		if l := 2*i + 1; l < len(h) && h.less(l, least) {
+			least = l
+		}
+		if r := 2*i + 2; r < len(h) && h.less(r, least) {
+			least = r
+		}
+		if least == i {
+			return
+		}
+		h[i], h[least] = h[least], h[i]
+		i = least
+	}
+}
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestDeadlineHeap(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+	var h deadlineHeap
+	transactions := make(map[transactionID]agentTransaction)
+	for i := 0; i < 100; i++ {
+		e := deadlineEntry{deadline: base.Add(time.Duration(rnd.Intn(1000)) * time.Millisecond)}
+		e.id[0], e.id[1] = byte(i), 1
+		h.push(e)
+		if i%2 == 0 {
+			transactions[e.id] = agentTransaction{id: e.id, deadline: e.deadline}
+		}
+	}
+	check := func(count int) {
+		t.Helper()
+		var prev time.Time
+		for i := 0; i < count; i++ {
+			if !h.expired(base.Add(time.Second)) {
+				t.Fatalf("%d: should be expired", i)
+			}
+			e := h.pop()
+			if e.deadline.Before(prev) {
+				t.Fatalf("%d: %s is before %s", i, e.deadline, prev)
+			}
+			prev = e.deadline
+		}
+		if len(h) != 0 || h.expired(base.Add(time.Second)) {
+			t.Errorf("unexpected entries: %d", len(h))
+		}
+	}
+	check(100)
+	h.rebuild(transactions)
+	if len(h) != len(transactions) {
+		t.Fatalf("unexpected length after rebuild: %d", len(h))
+	}
+	check(len(transactions))
+}
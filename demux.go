@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+// PacketKind is protocol of datagram received on socket that is shared
+// by several protocols, as classified by RFC 7983.
+type PacketKind byte
+
+// Possible values for PacketKind.
+const (
+	PacketUnknown PacketKind = iota
+	PacketSTUN
+	PacketZRTP
+	PacketDTLS
+	PacketTURNChannel
+	PacketRTP // RTP or RTCP
+)
+
+func (k PacketKind) String() string {
+	switch k {
+	case PacketSTUN:
+		return "STUN"
+	case PacketZRTP:
+		return "ZRTP"
+	case PacketDTLS:
+		return "DTLS"
+	case PacketTURNChannel:
+		return "TURN channel"
+	case PacketRTP:
+		return "RTP"
+	default:
+		return "unknown"
+	}
+}
+
+// IsSTUNPacket reports whether b is STUN message according to RFC 7983
+// Section 7: first byte is in [0..3], magic cookie is present and message
+// length is consistent with datagram size.
+//
+// Unlike IsMessage, IsSTUNPacket does not produce false positives on
+// DTLS or RTP packets that happen to contain magic cookie.
+func IsSTUNPacket(b []byte) bool {
+	if !IsMessage(b) || b[0] > 3 {
+		return false
+	}
+	size := int(bin.Uint16(b[2:4]))
+
+	return size%4 == 0 && messageHeaderSize+size <= len(b)
+}
+
+// Demux classifies datagram b by its first byte as described in
+// RFC 7983 Section 7. STUN packets additionally require magic cookie,
+// see IsSTUNPacket, and TURN channel packets require valid length, see
+// IsChannelData.
+func Demux(b []byte) PacketKind {
+	if len(b) == 0 {
+		return PacketUnknown
+	}
+	switch first := b[0]; {
+	case first <= 3:
+		if IsSTUNPacket(b) {
+			return PacketSTUN
+		}
+
+		return PacketUnknown
+	case first >= 16 && first <= 19:
+		return PacketZRTP
+	case first >= 20 && first <= 63:
+		return PacketDTLS
+	case first >= 64 && first <= 79:
+		if IsChannelData(b) {
+			return PacketTURNChannel
+		}
+
+		return PacketUnknown
+	case first >= 128 && first <= 191:
+		return PacketRTP
+	default:
+		return PacketUnknown
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import "testing"
+
+func TestDemux(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest, NewSoftware("demux"))
+	withCookie := func(first byte) []byte {
+		b := append([]byte(nil), m.Raw...)
+		b[0] = first
+
+		return b
+	}
+	for _, tc := range []struct {
+		name string
+		b    []byte
+		kind PacketKind
+	}{
+		{"Empty", nil, PacketUnknown},
+		{"STUN", m.Raw, PacketSTUN},
+		{"STUNTruncated", m.Raw[:len(m.Raw)-4], PacketUnknown},
+		{"NoCookie", append([]byte{0, 1, 0, 0}, make([]byte, 16)...), PacketUnknown},
+		{"ZRTP", []byte{16, 0}, PacketZRTP},
+		{"DTLS", withCookie(22), PacketDTLS},
+		{"TURNChannel", []byte{0x40, 0x00, 0x00, 0x00}, PacketTURNChannel},
+		{"RTP", withCookie(0x80), PacketRTP},
+		{"Reserved", []byte{100}, PacketUnknown},
+	} {
+		if kind := Demux(tc.b); kind != tc.kind {
+			t.Errorf("%s: %s, expected %s", tc.name, kind, tc.kind)
+		}
+	}
+}
+
+func TestIsSTUNPacket(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest)
+	if !IsSTUNPacket(m.Raw) {
+		t.Error("should be STUN")
+	}
+	b := append([]byte(nil), m.Raw...)
+	b[3] = 2 // length not multiple of 4
+	if IsSTUNPacket(b) {
+		t.Error("should not be STUN")
+	}
+}
+
+func TestPacketKind_String(t *testing.T) {
+	for kind, s := range map[PacketKind]string{
+		PacketUnknown:     "unknown",
+		PacketSTUN:        "STUN",
+		PacketZRTP:        "ZRTP",
+		PacketDTLS:        "DTLS",
+		PacketTURNChannel: "TURN channel",
+		PacketRTP:         "RTP",
+		PacketKind(100):   "unknown",
+	} {
+		if kind.String() != s {
+			t.Errorf("%d: %q != %q", kind, kind, s)
+		}
+	}
+}
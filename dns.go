@@ -0,0 +1,211 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNSCache resolves host names, possibly returning cached results.
+//
+// Implement it to plug an external cache into DialConfig.
+type DNSCache interface {
+	LookupIP(ctx context.Context, host string) ([]net.IP, error)
+}
+
+// DNSResolver resolves host names, returning addresses and TTL of
+// the result.
+type DNSResolver interface {
+	LookupIPTTL(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+}
+
+// Default TTL limits of MemoryDNSCache.
+const (
+	DefaultDNSMinTTL = time.Second * 5
+	DefaultDNSMaxTTL = time.Minute * 10
+)
+
+// DefaultDNSTTL is TTL that system resolver results are cached for,
+// because net.Resolver does not expose record TTL.
+const DefaultDNSTTL = time.Second * 30
+
+// systemResolver is DNSResolver that uses net.Resolver and reports
+// DefaultDNSTTL for all results.
+type systemResolver struct {
+	r *net.Resolver
+}
+
+func (s systemResolver) LookupIPTTL(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	addrs, err := s.r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, a := range addrs {
+		ips[i] = a.IP
+	}
+
+	return ips, DefaultDNSTTL, nil
+}
+
+// DNSCacheOption sets MemoryDNSCache option.
+type DNSCacheOption func(c *MemoryDNSCache)
+
+// WithDNSResolver sets resolver that is used on cache misses. Use it
+// to plug resolver that reports actual record TTLs.
+func WithDNSResolver(r DNSResolver) DNSCacheOption {
+	return func(c *MemoryDNSCache) {
+		c.resolver = r
+	}
+}
+
+// WithDNSTTLLimits sets minimum and maximum duration results are cached
+// for, regardless of their TTL.
+func WithDNSTTLLimits(minTTL, maxTTL time.Duration) DNSCacheOption {
+	return func(c *MemoryDNSCache) {
+		c.minTTL = minTTL
+		c.maxTTL = maxTTL
+	}
+}
+
+// WithDNSClock sets Clock of cache, the source of current time.
+func WithDNSClock(clock Clock) DNSCacheOption {
+	return func(c *MemoryDNSCache) {
+		c.clock = clock
+	}
+}
+
+type dnsCacheEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// MemoryDNSCache is in-memory DNSCache that honors result TTLs, clamped
+// to configured limits. Failed lookups are not cached.
+//
+// Safe for concurrent use.
+type MemoryDNSCache struct {
+	resolver DNSResolver
+	minTTL   time.Duration
+	maxTTL   time.Duration
+	clock    Clock
+	mux      sync.Mutex
+	entries  map[string]dnsCacheEntry
+}
+
+// NewMemoryDNSCache initializes new MemoryDNSCache that uses system
+// resolver by default.
+func NewMemoryDNSCache(options ...DNSCacheOption) *MemoryDNSCache {
+	c := &MemoryDNSCache{
+		resolver: systemResolver{r: net.DefaultResolver},
+		minTTL:   DefaultDNSMinTTL,
+		maxTTL:   DefaultDNSMaxTTL,
+		clock:    systemClock(),
+		entries:  make(map[string]dnsCacheEntry),
+	}
+	for _, o := range options {
+		o(c)
+	}
+
+	return c
+}
+
+// LookupIP returns addresses of host, resolving it only if there is
+// no unexpired cache entry.
+func (c *MemoryDNSCache) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	host = strings.ToLower(host)
+	now := c.clock.Now()
+	c.mux.Lock()
+	entry, ok := c.entries[host]
+	if ok && now.After(entry.expires) {
+		delete(c.entries, host)
+		ok = false
+	}
+	c.mux.Unlock()
+	if ok {
+		return entry.ips, nil
+	}
+	ips, ttl, err := c.resolver.LookupIPTTL(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if ttl < c.minTTL {
+		ttl = c.minTTL
+	}
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	c.mux.Lock()
+	c.entries[host] = dnsCacheEntry{ips: ips, expires: now.Add(ttl)}
+	c.mux.Unlock()
+
+	return ips, nil
+}
+
+// Flush removes all cache entries.
+func (c *MemoryDNSCache) Flush() {
+	c.mux.Lock()
+	c.entries = make(map[string]dnsCacheEntry)
+	c.mux.Unlock()
+}
+
+// defaultDNSCache is used by Dial and DialURI if no other cache is
+// configured.
+var defaultDNSCache = NewMemoryDNSCache() //nolint:gochecknoglobals
+
+// ErrNoAddress means that host has no addresses of requested family.
+var ErrNoAddress = errors.New("no suitable address found")
+
+// resolveAddrs resolves host via cache, returning host:port addresses
+// that match IP family of network.
+func resolveAddrs(ctx context.Context, cache DNSCache, network, host, port string) ([]string, error) {
+	ips, err := cache.LookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		isV4 := ip.To4() != nil
+		if (strings.HasSuffix(network, "4") && !isV4) || (strings.HasSuffix(network, "6") && isV4) {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+	}
+	if len(addrs) == 0 {
+		return nil, ErrNoAddress
+	}
+
+	return addrs, nil
+}
+
+// dialCached resolves host via cache and calls dial on each address in
+// order, returning first successful connection. If cache is nil, dial is
+// called with unresolved address.
+func dialCached(
+	cache DNSCache, network, host, port string, dial func(address string) (net.Conn, error),
+) (net.Conn, error) {
+	if cache == nil {
+		return dial(net.JoinHostPort(host, port))
+	}
+	addrs, err := resolveAddrs(context.Background(), cache, network, host, port)
+	if err != nil {
+		return nil, err
+	}
+	var conn net.Conn
+	for _, addr := range addrs {
+		if conn, err = dial(addr); err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var errLookup = errors.New("lookup failed")
+
+type testResolver struct {
+	calls int
+	ips   []net.IP
+	ttl   time.Duration
+	err   error
+}
+
+func (r *testResolver) LookupIPTTL(context.Context, string) ([]net.IP, time.Duration, error) {
+	r.calls++
+
+	return r.ips, r.ttl, r.err
+}
+
+func TestMemoryDNSCache(t *testing.T) {
+	clock := &manualClock{current: time.Now()}
+	resolver := &testResolver{
+		ips: []net.IP{net.IPv4(192, 0, 2, 1)},
+		ttl: time.Second * 30,
+	}
+	cache := NewMemoryDNSCache(
+		WithDNSResolver(resolver),
+		WithDNSClock(clock),
+		WithDNSTTLLimits(time.Second*10, time.Minute),
+	)
+	lookup := func(host string) []net.IP {
+		t.Helper()
+		ips, err := cache.LookupIP(context.Background(), host)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ips
+	}
+	if ips := lookup("example.com"); !reflect.DeepEqual(ips, resolver.ips) {
+		t.Errorf("unexpected result: %v", ips)
+	}
+	lookup("EXAMPLE.com")
+	if resolver.calls != 1 {
+		t.Errorf("unexpected resolver calls: %d", resolver.calls)
+	}
+	clock.Add(time.Second * 31)
+	lookup("example.com")
+	if resolver.calls != 2 {
+		t.Errorf("expired entry should be resolved again, calls: %d", resolver.calls)
+	}
+	if lookup("127.0.0.1"); resolver.calls != 2 {
+		t.Error("IP literal should not be resolved")
+	}
+
+	t.Run("Clamp", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			ttl     time.Duration
+			advance time.Duration
+			calls   int
+		}{
+			{"Min", time.Second, time.Second * 5, 1},
+			{"Max", time.Hour, time.Minute + time.Second, 2},
+		} {
+			resolver.calls, resolver.ttl = 0, tc.ttl
+			cache.Flush()
+			lookup("example.com")
+			clock.Add(tc.advance)
+			lookup("example.com")
+			if resolver.calls != tc.calls {
+				t.Errorf("%s: unexpected resolver calls: %d", tc.name, resolver.calls)
+			}
+		}
+	})
+	t.Run("Error", func(t *testing.T) {
+		resolver.err = errLookup
+		resolver.calls = 0
+		cache.Flush()
+		for i := 0; i < 2; i++ {
+			if _, err := cache.LookupIP(context.Background(), "example.com"); !errors.Is(err, errLookup) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+		if resolver.calls != 2 {
+			t.Error("failures should not be cached")
+		}
+	})
+}
+
+func TestResolveAddrs(t *testing.T) {
+	cache := NewMemoryDNSCache(WithDNSResolver(&testResolver{
+		ips: []net.IP{net.ParseIP("2001:db8::1"), net.IPv4(192, 0, 2, 1)},
+	}))
+	for _, tc := range []struct {
+		network string
+		addrs   []string
+	}{
+		{"udp", []string{"[2001:db8::1]:3478", "192.0.2.1:3478"}},
+		{"udp4", []string{"192.0.2.1:3478"}},
+		{"tcp6", []string{"[2001:db8::1]:3478"}},
+	} {
+		addrs, err := resolveAddrs(context.Background(), cache, tc.network, "example.com", "3478")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(addrs, tc.addrs) {
+			t.Errorf("%s: unexpected addrs: %v", tc.network, addrs)
+		}
+	}
+	if _, err := resolveAddrs(context.Background(), cache, "udp4", "::1", "3478"); !errors.Is(err, ErrNoAddress) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDialCached(t *testing.T) {
+	cache := NewMemoryDNSCache(WithDNSResolver(&testResolver{
+		ips: []net.IP{net.IPv4(192, 0, 2, 1), net.IPv4(192, 0, 2, 2)},
+	}))
+	var dialed []string
+	_, err := dialCached(cache, "udp", "example.com", "3478", func(addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+
+		return nil, errLookup
+	})
+	if !errors.Is(err, errLookup) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(dialed, []string{"192.0.2.1:3478", "192.0.2.2:3478"}) {
+		t.Errorf("unexpected dial order: %v", dialed)
+	}
+	dialed = nil
+	_, _ = dialCached(nil, "udp", "example.com", "3478", func(addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+
+		return nil, errLookup
+	})
+	if !reflect.DeepEqual(dialed, []string{"example.com:3478"}) {
+		t.Errorf("unexpected address without cache: %v", dialed)
+	}
+}
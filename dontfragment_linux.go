@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+// +build linux
+
+package stun
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// SetDontFragment sets Don't Fragment bit of IP packets sent by conn,
+// which should be *net.UDPConn, so datagrams that exceed path MTU are
+// dropped instead of being fragmented, see ProbeMTU. Path MTU cached by
+// kernel is ignored, so larger datagrams can still be probed.
+//
+// Supported only on Linux, returns ErrDontFragmentNotSupported on other
+// platforms.
+func SetDontFragment(conn net.Conn) error {
+	ip, ok := localIP(conn.LocalAddr())
+	sc, isSyscallConn := conn.(syscall.Conn)
+	if !ok || !isSyscallConn {
+		return ErrDontFragmentNotSupported
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	level, opt, value := unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE
+	if ip.To4() == nil {
+		level, opt, value = unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE
+	}
+	if ctrlErr := rc.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), level, opt, value)
+	}); ctrlErr != nil {
+		return ctrlErr
+	}
+
+	return err
+}
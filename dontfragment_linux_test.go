@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+// +build linux
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSetDontFragment(t *testing.T) {
+	conn, err := net.Dial("udp4", "127.0.0.1:3478")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close() //nolint:errcheck
+	if err = SetDontFragment(conn); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := conn.(*net.UDPConn).SyscallConn() //nolint:forcetypeassert
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value int
+	if err = rc.Control(func(fd uintptr) {
+		value, err = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if value != unix.IP_PMTUDISC_PROBE {
+		t.Errorf("unexpected IP_MTU_DISCOVER: %d", value)
+	}
+	a, b := net.Pipe()
+	defer a.Close() //nolint:errcheck
+	defer b.Close() //nolint:errcheck
+	if err = SetDontFragment(a); !errors.Is(err, ErrDontFragmentNotSupported) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestProbeMTU(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go serveBinding(server, func(req *Message, _ net.Addr) *Message {
+		return MustBuild(req, BindingSuccess)
+	})
+	// Loopback MTU is larger than maximum probe.
+	mtu, err := ProbeMTU(context.Background(), "stun:"+server.LocalAddr().String(), MTUProbeConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mtu != DefaultMTUProbeMax {
+		t.Errorf("unexpected MTU: %d", mtu)
+	}
+	if _, err = ProbeMTU(context.Background(), "turn:example.com", MTUProbeConfig{}); !errors.Is(err, ErrUnsupportedURI) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+// +build !linux
+
+package stun
+
+import "net"
+
+// SetDontFragment sets Don't Fragment bit of IP packets sent by conn.
+//
+// Supported only on Linux, returns ErrDontFragmentNotSupported on other
+// platforms.
+func SetDontFragment(net.Conn) error {
+	return ErrDontFragmentNotSupported
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// DSCP values that are recommended for connectivity checks of real-time
+// media, RFC 8837 Section 5.
+const (
+	DSCPExpeditedForwarding = 46 // EF, audio
+	DSCPAF41                = 34 // AF41, interactive video
+)
+
+// maxDSCP is maximum value of 6-bit DSCP field.
+const maxDSCP = 63
+
+var (
+	// ErrInvalidDSCP means that DSCP value is not in 0-63 range.
+	ErrInvalidDSCP = errors.New("DSCP is not in range of 0-63")
+	// ErrDSCPNotSupported means that DSCP can not be set on connection,
+	// because it is not IP socket.
+	ErrDSCPNotSupported = errors.New("DSCP is not supported by connection")
+)
+
+// SetDSCP sets Differentiated Services Code Point of packets sent by
+// conn, which should be *net.UDPConn or *net.TCPConn, by setting IP_TOS
+// of IPv4 socket or IPV6_TCLASS of IPv6 one. Dual-stack IPv6 sockets get
+// both, so IPv4 packets are marked too. See SetFlowLabel for IPv6 flow
+// labels.
+func SetDSCP(conn net.Conn, dscp int) error {
+	if dscp < 0 || dscp > maxDSCP {
+		return ErrInvalidDSCP
+	}
+	ip, ok := localIP(conn.LocalAddr())
+	if !ok {
+		return ErrDSCPNotSupported
+	}
+	tos := dscp << 2 // two least significant bits are ECN
+	if ip.To4() != nil {
+		return ipv4.NewConn(conn).SetTOS(tos)
+	}
+	if err := ipv6.NewConn(conn).SetTrafficClass(tos); err != nil {
+		return err
+	}
+	_ = ipv4.NewConn(conn).SetTOS(tos) // fails for IPv6-only sockets
+
+	return nil
+}
+
+// SetPacketDSCP is SetDSCP for packet-oriented conn, like *net.UDPConn
+// that is not connected.
+func SetPacketDSCP(conn net.PacketConn, dscp int) error {
+	if dscp < 0 || dscp > maxDSCP {
+		return ErrInvalidDSCP
+	}
+	ip, ok := localIP(conn.LocalAddr())
+	if !ok {
+		return ErrDSCPNotSupported
+	}
+	tos := dscp << 2
+	if ip.To4() != nil {
+		return ipv4.NewPacketConn(conn).SetTOS(tos)
+	}
+	if err := ipv6.NewPacketConn(conn).SetTrafficClass(tos); err != nil {
+		return err
+	}
+	_ = ipv4.NewPacketConn(conn).SetTOS(tos) // fails for IPv6-only sockets
+
+	return nil
+}
+
+func localIP(addr net.Addr) (net.IP, bool) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP, true
+	case *net.TCPAddr:
+		return a.IP, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestSetDSCP(t *testing.T) {
+	t.Run("IPv4", func(t *testing.T) {
+		conn, err := net.Dial("udp4", "127.0.0.1:3478")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close() //nolint:errcheck
+		if err = SetDSCP(conn, DSCPExpeditedForwarding); err != nil {
+			t.Fatal(err)
+		}
+		if tos, err := ipv4.NewConn(conn).TOS(); err != nil || tos != DSCPExpeditedForwarding<<2 {
+			t.Errorf("unexpected TOS: %d, %v", tos, err)
+		}
+	})
+	t.Run("IPv6", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp6", "[::1]:0")
+		if err != nil {
+			t.Skip("IPv6 is not available:", err)
+		}
+		defer conn.Close() //nolint:errcheck
+		if err = SetPacketDSCP(conn, DSCPAF41); err != nil {
+			t.Fatal(err)
+		}
+		if class, err := ipv6.NewPacketConn(conn).TrafficClass(); err != nil || class != DSCPAF41<<2 {
+			t.Errorf("unexpected traffic class: %d, %v", class, err)
+		}
+	})
+	t.Run("Packet", func(t *testing.T) {
+		conn := listenLocalUDP(t)
+		defer conn.Close() //nolint:errcheck
+		if err := SetPacketDSCP(conn, DSCPAF41); err != nil {
+			t.Fatal(err)
+		}
+		if tos, err := ipv4.NewPacketConn(conn).TOS(); err != nil || tos != DSCPAF41<<2 {
+			t.Errorf("unexpected TOS: %d, %v", tos, err)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		conn := listenLocalUDP(t)
+		defer conn.Close() //nolint:errcheck
+		for _, dscp := range []int{-1, 64} {
+			if err := SetPacketDSCP(conn, dscp); !errors.Is(err, ErrInvalidDSCP) {
+				t.Errorf("%d: unexpected error: %v", dscp, err)
+			}
+		}
+		a, b := net.Pipe()
+		defer a.Close() //nolint:errcheck
+		defer b.Close() //nolint:errcheck
+		if err := SetDSCP(a, DSCPAF41); !errors.Is(err, ErrDSCPNotSupported) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestClient_DSCP(t *testing.T) {
+	conn, err := net.Dial("udp4", "127.0.0.1:3478")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(conn, WithDSCP(DSCPExpeditedForwarding))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	if tos, err := ipv4.NewConn(conn).TOS(); err != nil || tos != DSCPExpeditedForwarding<<2 {
+		t.Errorf("unexpected TOS: %d, %v", tos, err)
+	}
+	pipe, other := net.Pipe()
+	defer other.Close() //nolint:errcheck
+	defer pipe.Close()  //nolint:errcheck
+	if _, err = NewClient(pipe, WithDSCP(DSCPAF41)); !errors.Is(err, ErrDSCPNotSupported) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// dumpHexLineSize is count of bytes printed on single line of hex dump.
+const dumpHexLineSize = 16
+
+// Dump writes multi-line human-readable representation of m to w,
+// similar to the one produced by packet dissectors like Wireshark:
+// header fields and every attribute with its name, length, hex
+// value and decoded interpretation.
+//
+// Use %+v verb to get the same output via fmt.
+func (m *Message) Dump(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "Session Traversal Utilities for NAT\n")                   //nolint:errcheck
+	fmt.Fprintf(buf, "    Message Type: 0x%04x (%s)\n", m.Type.Value(), m.Type) //nolint:errcheck
+	fmt.Fprintf(buf, "    Message Length: %d\n", m.Length)                      //nolint:errcheck
+	if len(m.Raw) >= messageHeaderSize {
+		fmt.Fprintf(buf, "    Message Cookie: 0x%08x\n", bin.Uint32(m.Raw[4:8])) //nolint:errcheck
+	}
+	fmt.Fprintf(buf, "    Message Transaction ID: 0x%x\n", m.TransactionID[:]) //nolint:errcheck
+	if len(m.Attributes) > 0 {
+		fmt.Fprintf(buf, "    Attributes\n") //nolint:errcheck
+	}
+	for _, a := range m.Attributes {
+		fmt.Fprintf(buf, "        %s (0x%04x), length: %d\n", a.Type, a.Type.Value(), a.Length) //nolint:errcheck
+		dumpHex(buf, a.Value, "            ")
+		if s := m.interpretAttribute(a); s != "" {
+			fmt.Fprintf(buf, "            %s\n", s) //nolint:errcheck
+		}
+	}
+
+	return buf.Flush()
+}
+
+// Format implements fmt.Formatter. The %+v verb prints the Dump output,
+// other verbs are applied to the String representation.
+func (m *Message) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('+') {
+		m.Dump(f) //nolint:errcheck,gosec
+
+		return
+	}
+	fmt.Fprintf(f, fmt.FormatString(f, verb), m.String()) //nolint:errcheck
+}
+
+func dumpHex(w io.Writer, b []byte, indent string) {
+	for first := 0; first < len(b); first += dumpHexLineSize {
+		last := first + dumpHexLineSize
+		if last > len(b) {
+			last = len(b)
+		}
+		fmt.Fprintf(w, "%s%04x  % x\n", indent, first, b[first:last]) //nolint:errcheck
+	}
+}
+
+// interpretAttribute returns decoded value of attribute a from m or empty
+// string if the attribute type is not known.
+func (m *Message) interpretAttribute(attr RawAttribute) string { //nolint:cyclop
+	// Getters are using first attribute of provided type, so
+	// decoding from message that contains only attr.
+	single := &Message{
+		Type:          m.Type,
+		TransactionID: m.TransactionID,
+		Attributes:    Attributes{attr},
+	}
+	switch attr.Type {
+	case AttrXORMappedAddress, AttrXORPeerAddress, AttrXORRelayedAddress, AttrMappedAddress,
+		AttrAlternateServer, AttrResponseOrigin, AttrOtherAddress, AttrSourceAddress, AttrChangedAddress:
+		return interpretAddress(single, attr)
+	case AttrUsername, AttrRealm, AttrNonce, AttrSoftware, AttrOrigin:
+		return fmt.Sprintf("Value: %q", attr.Value)
+	case AttrErrorCode:
+		var code ErrorCodeAttribute
+		if err := code.GetFrom(single); err != nil {
+			return "Malformed: " + err.Error()
+		}
+
+		return fmt.Sprintf("Error Code: %d, Reason: %q", code.Code, code.Reason)
+	case AttrUnknownAttributes:
+		var unknown UnknownAttributes
+		if err := unknown.GetFrom(single); err != nil {
+			return "Malformed: " + err.Error()
+		}
+
+		return "Attributes: " + unknown.String()
+	case AttrFingerprint:
+		if err := Fingerprint.Check(m); err != nil {
+			return "CRC-32: invalid"
+		}
+
+		return "CRC-32: valid"
+	case AttrPriority:
+		if len(attr.Value) != 4 {
+			return ""
+		}
+
+		return fmt.Sprintf("Priority: %d", bin.Uint32(attr.Value))
+	case AttrChannelNumber:
+		if len(attr.Value) != 4 {
+			return ""
+		}
+
+		return fmt.Sprintf("Channel: 0x%04x", bin.Uint16(attr.Value))
+	case AttrRequestedTransport:
+		if len(attr.Value) != 4 {
+			return ""
+		}
+
+		return fmt.Sprintf("Protocol: %d", attr.Value[0])
+	case AttrLifetime:
+		if len(attr.Value) != 4 {
+			return ""
+		}
+
+		return fmt.Sprintf("Lifetime: %s", time.Duration(bin.Uint32(attr.Value))*time.Second)
+	case AttrICEControlled, AttrICEControlling:
+		if len(attr.Value) != 8 {
+			return ""
+		}
+
+		return fmt.Sprintf("Tie breaker: 0x%016x", bin.Uint64(attr.Value))
+	default:
+		return ""
+	}
+}
+
+func interpretAddress(m *Message, attr RawAttribute) string {
+	if len(attr.Value) <= 4 {
+		return "Malformed: " + io.ErrUnexpectedEOF.Error()
+	}
+	var (
+		s   string
+		err error
+	)
+	switch attr.Type {
+	case AttrXORMappedAddress, AttrXORPeerAddress, AttrXORRelayedAddress:
+		var addr XORMappedAddress
+		err = addr.GetFromAs(m, attr.Type)
+		s = addr.String()
+	default:
+		var addr MappedAddress
+		err = addr.GetFromAs(m, attr.Type)
+		s = addr.String()
+	}
+	if err != nil {
+		return "Malformed: " + err.Error()
+	}
+
+	return "Address: " + s
+}
@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestMessage_Dump(t *testing.T) {
+	msg := MustBuild(TransactionID, BindingError,
+		NewSoftware("pion test"),
+		&XORMappedAddress{IP: net.IPv4(213, 1, 223, 5), Port: 21254},
+		CodeUnauthorized,
+		UnknownAttributes{AttrData},
+		RawAttribute{Type: AttrPriority, Value: []byte{0, 0, 0, 10}},
+		RawAttribute{Type: AttrLifetime, Value: []byte{0, 0, 0, 60}},
+		RawAttribute{Type: AttrICEControlling, Value: []byte{0, 0, 0, 0, 0, 0, 0, 1}},
+		RawAttribute{Type: AttrChannelNumber, Value: []byte{0x40, 0, 0, 0}},
+		RawAttribute{Type: AttrRequestedTransport, Value: []byte{17, 0, 0, 0}},
+		RawAttribute{Type: AttrMappedAddress, Value: []byte{0, 1}},
+		RawAttribute{Type: 0x7001, Value: []byte{1, 2, 3}},
+		Fingerprint,
+	)
+	buf := new(bytes.Buffer)
+	if err := msg.Dump(buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		"Message Type: 0x0111 (Binding error response)",
+		"Message Cookie: 0x2112a442",
+		"SOFTWARE (0x8022), length: 9",
+		`Value: "pion test"`,
+		"Address: 213.1.223.5:21254",
+		`Error Code: 401, Reason: "Unauthorized"`,
+		"Attributes: DATA",
+		"Priority: 10",
+		"Lifetime: 1m0s",
+		"Tie breaker: 0x0000000000000001",
+		"Channel: 0x4000",
+		"Protocol: 17",
+		"Malformed: unexpected EOF",
+		"0x7001 (0x7001), length: 3",
+		"0000  01 02 03",
+		"CRC-32: valid",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("dump should contain %q:\n%s", s, out)
+		}
+	}
+	if formatted := fmt.Sprintf("%+v", msg); formatted != out {
+		t.Errorf("%%+v should be equal to dump:\n%s", formatted)
+	}
+	if formatted := fmt.Sprintf("%v", msg); formatted != msg.String() {
+		t.Errorf("%%v should be equal to String(): %s", formatted)
+	}
+	if formatted := fmt.Sprintf("%s", msg); formatted != msg.String() { //nolint:gosimple
+		t.Errorf("%%s should be equal to String(): %s", formatted)
+	}
+	msg.Raw[len(msg.Raw)-1]++
+	buf.Reset()
+	if err := msg.Dump(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "CRC-32: invalid") {
+		t.Errorf("dump should report invalid fingerprint:\n%s", buf)
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors of ValidateEphemeralCredentials.
+var (
+	// ErrEphemeralUsername means that username does not start with
+	// expiration timestamp.
+	ErrEphemeralUsername = errors.New("invalid ephemeral username")
+	// ErrEphemeralExpired means that ephemeral credentials are expired.
+	ErrEphemeralExpired = errors.New("ephemeral credentials expired")
+	// ErrEphemeralPassword means that password does not match username.
+	ErrEphemeralPassword = errors.New("invalid ephemeral password")
+)
+
+// ephemeralSep separates expiration timestamp and user of ephemeral
+// username.
+const ephemeralSep = ":"
+
+// EphemeralPassword returns password of ephemeral username, that is
+// base64-encoded HMAC-SHA1 of username with secret.
+func EphemeralPassword(secret []byte, username string) string {
+	mac := hmac.New(sha1.New, secret)
+	mac.Write([]byte(username)) //nolint:errcheck,gosec
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateEphemeralCredentials returns credentials of TURN REST API
+// (draft-uberti-behave-turn-rest), as implemented by coturn with
+// use-auth-secret: username is expiration Unix time, followed by ":" and
+// user if it is not empty, and password is EphemeralPassword of username.
+// Server must share the secret, see ValidateEphemeralCredentials.
+func GenerateEphemeralCredentials(secret []byte, ttl time.Duration, user string) Credentials {
+	return NewEphemeralCredentials(secret, time.Now().Add(ttl), user)
+}
+
+// NewEphemeralCredentials is like GenerateEphemeralCredentials, but with
+// explicit expiration time.
+func NewEphemeralCredentials(secret []byte, expires time.Time, user string) Credentials {
+	username := strconv.FormatInt(expires.Unix(), 10)
+	if user != "" {
+		username += ephemeralSep + user
+	}
+
+	return Credentials{Username: username, Password: EphemeralPassword(secret, username)}
+}
+
+// EphemeralExpiration returns expiration time of ephemeral username, or
+// ErrEphemeralUsername.
+func EphemeralExpiration(username string) (time.Time, error) {
+	timestamp, _, _ := strings.Cut(username, ephemeralSep)
+	expires, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrEphemeralUsername, username)
+	}
+
+	return time.Unix(expires, 0), nil
+}
+
+// ValidateEphemeralCredentials checks that credentials generated by
+// GenerateEphemeralCredentials with secret are not expired at now, and
+// password matches username. Password is compared in constant time.
+func ValidateEphemeralCredentials(secret []byte, c Credentials, now time.Time) error {
+	expires, err := EphemeralExpiration(c.Username)
+	if err != nil {
+		return err
+	}
+	if now.After(expires) {
+		return fmt.Errorf("%w at %s", ErrEphemeralExpired, expires.UTC().Format(time.RFC3339))
+	}
+	if !hmac.Equal([]byte(c.Password), []byte(EphemeralPassword(secret, c.Username))) {
+		return ErrEphemeralPassword
+	}
+
+	return nil
+}
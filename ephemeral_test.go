@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEphemeralCredentials(t *testing.T) {
+	secret := []byte("shared secret")
+	expires := time.Unix(1700003600, 0)
+	c := NewEphemeralCredentials(secret, expires, "alice")
+	// Password of coturn for the same secret and username.
+	if c.Username != "1700003600:alice" || c.Password != "+7mDo33NUOm4N9aqBNvHVWSJGXY=" {
+		t.Errorf("unexpected credentials: %+v", c)
+	}
+	if anonymous := NewEphemeralCredentials(secret, expires, ""); anonymous.Username != "1700003600" {
+		t.Errorf("unexpected username %q", anonymous.Username)
+	}
+	for _, tc := range []struct {
+		name  string
+		creds Credentials
+		now   time.Time
+		err   error
+	}{
+		{"Valid", c, expires.Add(-time.Hour), nil},
+		{"Expiration", c, expires, nil},
+		{"Expired", c, expires.Add(time.Second), ErrEphemeralExpired},
+		{"Password", Credentials{Username: c.Username, Password: "bad"}, expires, ErrEphemeralPassword},
+		{"OtherUser", Credentials{Username: "1700003600:bob", Password: c.Password}, expires, ErrEphemeralPassword},
+		{"Username", Credentials{Username: "alice:1700003600", Password: c.Password}, expires, ErrEphemeralUsername},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateEphemeralCredentials(secret, tc.creds, tc.now); !errors.Is(err, tc.err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+	generated := GenerateEphemeralCredentials(secret, time.Hour, "alice")
+	if !strings.HasSuffix(generated.Username, ":alice") {
+		t.Errorf("unexpected username %q", generated.Username)
+	}
+	if err := ValidateEphemeralCredentials(secret, generated, time.Now()); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateEphemeralCredentials(secret, generated, time.Now().Add(2*time.Hour)); !errors.Is(err, ErrEphemeralExpired) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -32,6 +32,9 @@ const (
 
 // AddTo adds ERROR-CODE to m.
 func (c ErrorCodeAttribute) AddTo(msg *Message) error {
+	if err := msg.checkMutable(); err != nil {
+		return err
+	}
 	value := make([]byte, 0, errorCodeReasonStart+errorCodeReasonMaxB)
 	if err := CheckOverflow(AttrErrorCode,
 		len(c.Reason)+errorCodeReasonStart,
@@ -137,6 +140,13 @@ const (
 	CodePeerAddrFamilyMismatch ErrorCode = 443 // Peer Address Family Mismatch
 )
 
+// Error codes from RFC 8016.
+//
+// RFC 8016 Section 3.4.
+const (
+	CodeMobilityForbidden ErrorCode = 405 // Mobility Forbidden
+)
+
 //nolint:gochecknoglobals
 var errorReasons = map[ErrorCode][]byte{
 	CodeTryAlternate:     []byte("Try Alternate"),
@@ -162,4 +172,52 @@ var errorReasons = map[ErrorCode][]byte{
 	// RFC 6156.
 	CodeAddrFamilyNotSupported: []byte("Address Family not Supported"),
 	CodePeerAddrFamilyMismatch: []byte("Peer Address Family Mismatch"),
+
+	// RFC 8016.
+	CodeMobilityForbidden: []byte("Mobility Forbidden"),
+}
+
+// Reason returns default reason phrase of c, or empty string if c is not
+// registered.
+func (c ErrorCode) Reason() string {
+	return string(errorReasons[c])
+}
+
+// IsTryAlternate reports whether c is 300 (Try Alternate), so request
+// should be retried with ALTERNATE-SERVER.
+func (c ErrorCode) IsTryAlternate() bool {
+	return c == CodeTryAlternate
+}
+
+// IsUnauthorized reports whether c is 401 (Unauthorized), so request
+// should be retried with credentials.
+func (c ErrorCode) IsUnauthorized() bool {
+	return c == CodeUnauthorized
+}
+
+// IsStaleNonce reports whether c is 438 (Stale Nonce), so request should
+// be retried with new NONCE.
+func (c ErrorCode) IsStaleNonce() bool {
+	return c == CodeStaleNonce
+}
+
+// IsServerError reports whether c is in 500-599 range, i.e. server failed
+// due to temporary error and request can be retried.
+func (c ErrorCode) IsServerError() bool {
+	return c >= 500 && c < 600
+}
+
+// CodeFromResponse returns code of ERROR-CODE attribute of error
+// response m. Returns ErrAttributeNotFound if m is not error response or
+// has no ERROR-CODE.
+func CodeFromResponse(m *Message) (ErrorCode, error) {
+	if m.Type.Class != ClassErrorResponse {
+		return 0, ErrAttributeNotFound
+	}
+	var attr ErrorCodeAttribute
+	if err := attr.GetFrom(m); err != nil {
+		return 0, err
+	}
+
+	return attr.Code, nil
 }
@@ -112,3 +112,44 @@ func TestErrorCode(t *testing.T) {
 		t.Error("should error")
 	}
 }
+
+func TestErrorCode_Predicates(t *testing.T) {
+	if CodeMobilityForbidden.Reason() != "Mobility Forbidden" || ErrorCode(666).Reason() != "" {
+		t.Error("unexpected reason")
+	}
+	for _, tc := range []struct {
+		name string
+		ok   func(ErrorCode) bool
+		code ErrorCode
+	}{
+		{"TryAlternate", ErrorCode.IsTryAlternate, CodeTryAlternate},
+		{"Unauthorized", ErrorCode.IsUnauthorized, CodeUnauthorized},
+		{"StaleNonce", ErrorCode.IsStaleNonce, CodeStaleNonce},
+		{"ServerError", ErrorCode.IsServerError, CodeInsufficientCapacity},
+	} {
+		if !tc.ok(tc.code) {
+			t.Errorf("%s: %d should match", tc.name, tc.code)
+		}
+		if tc.ok(CodeBadRequest) {
+			t.Errorf("%s: %d should not match", tc.name, CodeBadRequest)
+		}
+	}
+}
+
+func TestCodeFromResponse(t *testing.T) {
+	code, err := CodeFromResponse(MustBuild(TransactionID, BindingError, CodeStaleNonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != CodeStaleNonce {
+		t.Errorf("unexpected code: %d", code)
+	}
+	for _, m := range []*Message{
+		MustBuild(TransactionID, BindingSuccess, CodeStaleNonce),
+		MustBuild(TransactionID, BindingError),
+	} {
+		if _, err = CodeFromResponse(m); !errors.Is(err, ErrAttributeNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
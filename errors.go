@@ -3,7 +3,10 @@
 
 package stun
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // DecodeErr records an error and place when it is occurred.
 //
@@ -64,3 +67,36 @@ var ErrAttributeSizeInvalid = errors.New("attribute size is invalid")
 
 // ErrAttributeSizeOverflow means that decoded attribute size is too big.
 var ErrAttributeSizeOverflow = errors.New("attribute size overflow")
+
+// ErrErrorResponse means that server responded with error response.
+var ErrErrorResponse = errors.New("error response")
+
+// TransactionError is error of transaction that is completed with error
+// response, see WithTransactionErrors. It matches ErrErrorResponse with
+// errors.Is.
+type TransactionError struct {
+	Code    ErrorCode // zero if response has no valid ERROR-CODE
+	Reason  string
+	Message *Message // copy of response
+}
+
+// newTransactionError returns TransactionError of error response m,
+// cloning m as it can be reused after handler returns.
+func newTransactionError(m *Message) *TransactionError {
+	e := &TransactionError{Message: m.Clone()}
+	var attr ErrorCodeAttribute
+	if attr.GetFrom(e.Message) == nil {
+		e.Code, e.Reason = attr.Code, string(attr.Reason)
+	}
+
+	return e
+}
+
+func (e *TransactionError) Error() string {
+	return fmt.Sprintf("%s: %s %d %s", ErrErrorResponse, e.Message.Type, e.Code, e.Reason)
+}
+
+// Unwrap returns ErrErrorResponse.
+func (e *TransactionError) Unwrap() error {
+	return ErrErrorResponse
+}
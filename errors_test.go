@@ -36,3 +36,25 @@ func TestDecodeErr_IsInvalidCookie(t *testing.T) {
 		t.Error("bad parent")
 	}
 }
+
+func TestTransactionError(t *testing.T) {
+	m := MustBuild(TransactionID, BindingError, CodeStaleNonce)
+	var err error = newTransactionError(m)
+	m.Reset()
+	if !errors.Is(err, ErrErrorResponse) {
+		t.Error("should match ErrErrorResponse")
+	}
+	var tErr *TransactionError
+	if !errors.As(err, &tErr) {
+		t.Fatal("not transaction error")
+	}
+	if tErr.Code != CodeStaleNonce || tErr.Reason != "Stale Nonce" || tErr.Message.Type != BindingError {
+		t.Errorf("unexpected error: %+v", tErr)
+	}
+	if expected := "error response: Binding error response 438 Stale Nonce"; err.Error() != expected {
+		t.Errorf("%q != %q", err, expected)
+	}
+	if err = newTransactionError(MustBuild(TransactionID, BindingError)); err.(*TransactionError).Code != 0 { //nolint:errorlint,forcetypeassert
+		t.Errorf("unexpected error: %v", err)
+	}
+}
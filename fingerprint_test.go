@@ -7,8 +7,12 @@
 package stun
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
 )
 
 func BenchmarkFingerprint_AddTo(b *testing.B) {
@@ -77,3 +81,131 @@ func BenchmarkFingerprint_Check(b *testing.B) {
 		}
 	}
 }
+
+func TestFingerprint_CheckFast(t *testing.T) {
+	m := new(Message)
+	addAttr(t, m, NewSoftware("software"))
+	m.WriteHeader()
+	if err := Fingerprint.CheckFast(m); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	Fingerprint.AddTo(m) //nolint:errcheck,gosec
+	m.WriteHeader()
+	// Checking raw message that is not decoded.
+	raw := &Message{Raw: append([]byte(nil), m.Raw...)}
+	if err := Fingerprint.CheckFast(raw); err != nil {
+		t.Error(err)
+	}
+	raw.Raw[3]++
+	if err := Fingerprint.CheckFast(raw); err == nil {
+		t.Error("should error")
+	}
+	if err := Fingerprint.CheckFast(&Message{Raw: m.Raw[:messageHeaderSize]}); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	bad := new(Message)
+	bad.WriteHeader()
+	bad.Add(AttrFingerprint, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	bad.Raw = bad.Raw[:len(bad.Raw)-4] // length field is kept
+	if err := Fingerprint.CheckFast(bad); err == nil {
+		t.Error("should error")
+	}
+}
+
+func BenchmarkFingerprint_CheckFast(b *testing.B) {
+	b.ReportAllocs()
+	m := new(Message)
+	addAttr(b, m, &XORMappedAddress{IP: net.IPv4(213, 1, 223, 5)})
+	addAttr(b, m, NewSoftware("software"))
+	m.WriteHeader()
+	Fingerprint.AddTo(m) //nolint:errcheck,gosec
+	m.WriteHeader()
+	b.SetBytes(int64(len(m.Raw)))
+	for i := 0; i < b.N; i++ {
+		if err := Fingerprint.CheckFast(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFingerprintValue(b *testing.B) {
+	for _, size := range []int{64, 512, 1500} {
+		buf := make([]byte, size)
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				FingerprintValue(buf)
+			}
+		})
+	}
+}
+
+func TestFingerprintHash(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest, NewSoftware("software"))
+	var h FingerprintHash
+	for _, part := range [][]byte{m.Raw[:messageHeaderSize], m.Raw[messageHeaderSize:]} {
+		if n, err := h.Write(part); err != nil || n != len(part) {
+			t.Fatalf("unexpected write: %d, %v", n, err)
+		}
+	}
+	if h.Sum32() != FingerprintValue(m.Raw) {
+		t.Errorf("unexpected value: 0x%08x", h.Sum32())
+	}
+	h.Reset()
+	if h.Sum32() != FingerprintValue(nil) {
+		t.Errorf("unexpected value after reset: 0x%08x", h.Sum32())
+	}
+}
+
+func TestFingerprint_Allocs(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest, NewSoftware("software"))
+	m.Raw = append(make([]byte, 0, 128), m.Raw...)
+	length := m.Length
+	if err := Fingerprint.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var h FingerprintHash
+	for name, f := range map[string]func(){
+		"AddTo": func() {
+			m.Attributes = m.Attributes[:1]
+			m.Raw = m.Raw[:messageHeaderSize+int(length)]
+			m.Length = length
+			if err := Fingerprint.AddTo(m); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"Check": func() {
+			if err := Fingerprint.Check(m); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"CheckFast": func() {
+			if err := Fingerprint.CheckFast(m); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"Hash": func() {
+			h.Reset()
+			h.Write(m.Raw) //nolint:errcheck,gosec
+			h.Sum32()
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			testutil.ShouldNotAllocate(t, f)
+		})
+	}
+}
+
+func BenchmarkFingerprintHash(b *testing.B) {
+	m := MustBuild(TransactionID, BindingRequest, NewSoftware("software"))
+	var h FingerprintHash
+	b.ReportAllocs()
+	b.SetBytes(int64(len(m.Raw)))
+	for i := 0; i < b.N; i++ {
+		h.Reset()
+		h.Write(m.Raw[:messageHeaderSize]) //nolint:errcheck,gosec
+		h.Write(m.Raw[messageHeaderSize:]) //nolint:errcheck,gosec
+		h.Sum32()
+	}
+}
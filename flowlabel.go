@@ -0,0 +1,18 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import "errors"
+
+// maxFlowLabel is maximum value of 20-bit IPv6 flow label.
+const maxFlowLabel = 1<<20 - 1
+
+var (
+	// ErrInvalidFlowLabel means that flow label does not fit in 20 bits.
+	ErrInvalidFlowLabel = errors.New("flow label is not in range of 0-1048575")
+	// ErrFlowLabelNotSupported means that flow label can not be set on
+	// connection, because it is not IPv6 UDP socket or platform is not
+	// Linux.
+	ErrFlowLabelNotSupported = errors.New("flow label is not supported by connection")
+)
@@ -0,0 +1,161 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+// +build linux
+
+package stun
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ipv6FlowInfoSend is IPV6_FLOWINFO_SEND socket option of Linux, that
+// makes kernel take flow label from sin6_flowinfo of destination address.
+const ipv6FlowInfoSend = 33
+
+// SetFlowLabel sets IPv6 flow label of packets sent by conn, which should
+// be *net.UDPConn connected to IPv6 address, e.g. to keep packets of
+// connectivity checks on the same path as media. Label zero lets kernel
+// choose the label.
+//
+// Kernel checks that label is leased with IPV6_FLOWLABEL_MGR only if
+// exclusive labels are used in network namespace, SetFlowLabel returns
+// error in that case.
+//
+// Supported only on Linux, returns ErrFlowLabelNotSupported on other
+// platforms.
+func SetFlowLabel(conn net.Conn, label uint32) error {
+	if label > maxFlowLabel {
+		return ErrInvalidFlowLabel
+	}
+	remote, isUDP := conn.RemoteAddr().(*net.UDPAddr)
+	sc, isSyscallConn := conn.(syscall.Conn)
+	if !isUDP || !isSyscallConn || remote.IP.To4() != nil {
+		return ErrFlowLabelNotSupported
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	sa := rawSockaddrInet6(remote, label)
+	if ctrlErr := rc.Control(func(fd uintptr) {
+		if err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, ipv6FlowInfoSend, 1); err != nil {
+			return
+		}
+		// Flow label of connected socket is taken from address of connect.
+		_, _, errno := unix.Syscall(unix.SYS_CONNECT, fd, uintptr(unsafe.Pointer(&sa)), unsafe.Sizeof(sa))
+		if errno != 0 {
+			err = os.NewSyscallError("connect", errno)
+		}
+	}); ctrlErr != nil {
+		return ctrlErr
+	}
+
+	return err
+}
+
+// NewFlowLabelPacketConn returns conn that sends packets to IPv6
+// addresses with flow label, see SetFlowLabel. The conn should be IPv6
+// *net.UDPConn, packets to IPv4 addresses of dual-stack socket are sent
+// as is.
+//
+// Supported only on Linux, returns ErrFlowLabelNotSupported on other
+// platforms.
+func NewFlowLabelPacketConn(conn net.PacketConn, label uint32) (net.PacketConn, error) {
+	if label > maxFlowLabel {
+		return nil, ErrInvalidFlowLabel
+	}
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		return nil, ErrFlowLabelNotSupported
+	}
+	if addr, isUDP := udpConn.LocalAddr().(*net.UDPAddr); !isUDP || addr.IP.To4() != nil {
+		return nil, ErrFlowLabelNotSupported
+	}
+	rc, err := udpConn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	if ctrlErr := rc.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, ipv6FlowInfoSend, 1)
+	}); ctrlErr != nil {
+		return nil, ctrlErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &flowLabelPacketConn{UDPConn: udpConn, rc: rc, label: label}, nil
+}
+
+// flowLabelPacketConn sends packets to IPv6 addresses with sendto, as
+// net.UDPConn does not set sin6_flowinfo.
+type flowLabelPacketConn struct {
+	*net.UDPConn
+	rc    syscall.RawConn
+	label uint32
+}
+
+func (c *flowLabelPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok || udpAddr.IP.To4() != nil {
+		return c.UDPConn.WriteTo(b, addr)
+	}
+	sa := rawSockaddrInet6(udpAddr, c.label)
+	var (
+		n     int
+		errno syscall.Errno
+	)
+	if err := c.rc.Write(func(fd uintptr) bool {
+		var p unsafe.Pointer
+		if len(b) > 0 {
+			p = unsafe.Pointer(&b[0])
+		}
+		var r uintptr
+		r, _, errno = unix.Syscall6(unix.SYS_SENDTO, fd, uintptr(p), uintptr(len(b)), 0,
+			uintptr(unsafe.Pointer(&sa)), unsafe.Sizeof(sa),
+		)
+		if errno == unix.EAGAIN || errno == unix.EINTR {
+			return false // waiting until socket is writable
+		}
+		n = int(r)
+
+		return true
+	}); err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, &net.OpError{
+			Op: "write", Net: "udp6", Source: c.LocalAddr(), Addr: addr,
+			Err: os.NewSyscallError("sendto", errno),
+		}
+	}
+
+	return n, nil
+}
+
+// rawSockaddrInet6 returns sockaddr_in6 of addr with flow label.
+func rawSockaddrInet6(addr *net.UDPAddr, label uint32) unix.RawSockaddrInet6 {
+	sa := unix.RawSockaddrInet6{Family: unix.AF_INET6}
+	// Port and flow info are in network byte order.
+	binary.BigEndian.PutUint16((*[2]byte)(unsafe.Pointer(&sa.Port))[:], uint16(addr.Port)) //nolint:gosec // G115
+	binary.BigEndian.PutUint32((*[4]byte)(unsafe.Pointer(&sa.Flowinfo))[:], label)
+	copy(sa.Addr[:], addr.IP.To16())
+	if addr.Zone != "" {
+		if ifi, err := net.InterfaceByName(addr.Zone); err == nil {
+			sa.Scope_id = uint32(ifi.Index) //nolint:gosec // G115
+		} else if index, err := strconv.ParseUint(addr.Zone, 10, 32); err == nil {
+			sa.Scope_id = uint32(index)
+		}
+	}
+
+	return sa
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+// +build linux
+
+package stun
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ipv6FlowInfo is IPV6_FLOWINFO socket option of Linux, that enables
+// receiving flow info of packets.
+const ipv6FlowInfo = 11
+
+// listenFlowInfo returns IPv6 socket that receives flow info of packets.
+func listenFlowInfo(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skip("IPv6 is not available:", err)
+	}
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctrlErr := rc.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, ipv6FlowInfo, 1)
+	}); ctrlErr != nil {
+		t.Fatal(ctrlErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+// readFlowLabel reads packet from conn of listenFlowInfo and returns its
+// flow label.
+func readFlowLabel(t *testing.T, conn *net.UDPConn) uint32 {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+		t.Fatal(err)
+	}
+	buf, oob := make([]byte, 1500), make([]byte, 128)
+	_, oobn, _, _, err := conn.ReadMsgUDP(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range messages {
+		if m.Header.Level == unix.IPPROTO_IPV6 && m.Header.Type == ipv6FlowInfo && len(m.Data) >= 4 {
+			return binary.BigEndian.Uint32(m.Data) & maxFlowLabel
+		}
+	}
+	t.Fatal("flow info not received")
+
+	return 0
+}
+
+func TestSetFlowLabel(t *testing.T) {
+	const label = 0x12345
+	server := listenFlowInfo(t)
+	defer server.Close() //nolint:errcheck
+	conn, err := net.Dial("udp6", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close() //nolint:errcheck
+	if err = SetFlowLabel(conn, label); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFlowLabel(t, server); got != label {
+		t.Errorf("unexpected flow label: %#x", got)
+	}
+	if err = SetFlowLabel(conn, maxFlowLabel+1); !errors.Is(err, ErrInvalidFlowLabel) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	ipv4, err := net.Dial("udp4", "127.0.0.1:3478")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ipv4.Close() //nolint:errcheck
+	if err = SetFlowLabel(ipv4, label); !errors.Is(err, ErrFlowLabelNotSupported) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewFlowLabelPacketConn(t *testing.T) {
+	const label = 0xABCDE
+	server := listenFlowInfo(t)
+	defer server.Close() //nolint:errcheck
+	udpConn, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := NewFlowLabelPacketConn(udpConn, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close() //nolint:errcheck
+	if n, err := conn.WriteTo([]byte("ping"), server.LocalAddr()); err != nil || n != 4 {
+		t.Fatalf("unexpected write: %d, %v", n, err)
+	}
+	if got := readFlowLabel(t, server); got != label {
+		t.Errorf("unexpected flow label: %#x", got)
+	}
+	if err = conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.WriteTo([]byte("ping"), server.LocalAddr()); err == nil {
+		t.Error("write to closed conn should fail")
+	}
+	ipv4 := listenLocalUDP(t)
+	defer ipv4.Close() //nolint:errcheck
+	if _, err = NewFlowLabelPacketConn(ipv4, label); !errors.Is(err, ErrFlowLabelNotSupported) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_WithFlowLabel(t *testing.T) {
+	const label = 0x54321
+	server := listenFlowInfo(t)
+	defer server.Close() //nolint:errcheck
+	conn, err := net.Dial("udp6", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(conn, WithFlowLabel(label))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	if err = c.Indicate(MustBuild(TransactionID, NewType(MethodBinding, ClassIndication))); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFlowLabel(t, server); got != label {
+		t.Errorf("unexpected flow label: %#x", got)
+	}
+	if _, err = NewClient(noopConnection{}, WithFlowLabel(label)); !errors.Is(err, ErrFlowLabelNotSupported) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+// +build !linux
+
+package stun
+
+import "net"
+
+// SetFlowLabel sets IPv6 flow label of packets sent by conn.
+//
+// Supported only on Linux, returns ErrFlowLabelNotSupported on other
+// platforms.
+func SetFlowLabel(_ net.Conn, label uint32) error {
+	if label > maxFlowLabel {
+		return ErrInvalidFlowLabel
+	}
+
+	return ErrFlowLabelNotSupported
+}
+
+// NewFlowLabelPacketConn returns conn that sends packets with IPv6 flow
+// label.
+//
+// Supported only on Linux, returns ErrFlowLabelNotSupported on other
+// platforms.
+func NewFlowLabelPacketConn(_ net.PacketConn, label uint32) (net.PacketConn, error) {
+	if label > maxFlowLabel {
+		return nil, ErrInvalidFlowLabel
+	}
+
+	return nil, ErrFlowLabelNotSupported
+}
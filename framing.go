@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"io"
+)
+
+// Stream transports.
+//
+// RFC 4571 framing prefixes each message with its 2-byte length, and is
+// used for STUN over TCP or TLS by ICE-TCP (RFC 6544) candidates.
+
+const (
+	frameHeaderSize = 2
+	maxFrameSize    = 0xFFFF
+)
+
+// ErrFrameTooLarge means that framed message exceeds size limit.
+var ErrFrameTooLarge = errors.New("framed message is too large")
+
+// ReadMessageFrom reads single RFC 4571 framed message from r and decodes
+// it into m, reusing m.Raw. Frame longer than maxSize bytes is rejected
+// with ErrFrameTooLarge before its payload is read, zero maxSize means
+// the 65535 bytes limit of framing.
+//
+// Returns io.EOF if r is closed between frames and io.ErrUnexpectedEOF if
+// it is closed within frame.
+func ReadMessageFrom(r io.Reader, m *Message, maxSize int) error {
+	if maxSize <= 0 || maxSize > maxFrameSize {
+		maxSize = maxFrameSize
+	}
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	m.Raw = append(m.Raw[:0], 0, 0)
+	if _, err := io.ReadFull(r, m.Raw[:frameHeaderSize]); err != nil {
+		return err
+	}
+	size := int(bin.Uint16(m.Raw))
+	if size > maxSize {
+		return ErrFrameTooLarge
+	}
+	if cap(m.Raw) < size {
+		m.Raw = make([]byte, size)
+	}
+	m.Raw = m.Raw[:size]
+	if _, err := io.ReadFull(r, m.Raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+
+	return m.Decode()
+}
+
+// WriteMessageTo writes m to w with RFC 4571 framing in single Write
+// call, so frame is not split into separate TLS records or TCP segments.
+func WriteMessageTo(w io.Writer, m *Message) error {
+	if len(m.Raw) > maxFrameSize {
+		return ErrFrameTooLarge
+	}
+	buff := bufferPool.Get().(*buffer) //nolint:forcetypeassert
+	defer bufferPool.Put(buff)
+	buff.buf = append(buff.buf[:0], byte(len(m.Raw)>>8), byte(len(m.Raw)))
+	buff.buf = append(buff.buf, m.Raw...)
+	_, err := w.Write(buff.buf)
+
+	return err
+}
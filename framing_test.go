@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+func TestFraming(t *testing.T) {
+	var (
+		stream bytes.Buffer
+		first  = MustBuild(TransactionID, BindingRequest, NewSoftware("software"))
+		second = MustBuild(TransactionID, BindingSuccess, Fingerprint)
+	)
+	for _, m := range []*Message{first, second} {
+		if err := WriteMessageTo(&stream, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := stream.Bytes()[:frameHeaderSize]; int(bin.Uint16(got)) != len(first.Raw) {
+		t.Errorf("unexpected frame header: %x", got)
+	}
+	m := new(Message)
+	for _, expected := range []*Message{first, second} {
+		if err := ReadMessageFrom(&stream, m, 0); err != nil {
+			t.Fatal(err)
+		}
+		if !m.Equal(expected) {
+			t.Errorf("unexpected message: %s", m)
+		}
+	}
+	if err := ReadMessageFrom(&stream, m, 0); !errors.Is(err, io.EOF) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	t.Run("TooLarge", func(t *testing.T) {
+		if err := WriteMessageTo(&stream, first); err != nil {
+			t.Fatal(err)
+		}
+		if err := ReadMessageFrom(&stream, m, len(first.Raw)-1); !errors.Is(err, ErrFrameTooLarge) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err := WriteMessageTo(&stream, &Message{Raw: make([]byte, maxFrameSize+1)}); !errors.Is(err, ErrFrameTooLarge) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Truncated", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := WriteMessageTo(&b, first); err != nil {
+			t.Fatal(err)
+		}
+		truncated := bytes.NewReader(b.Bytes()[:b.Len()-1])
+		if err := ReadMessageFrom(truncated, m, 0); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Allocations", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := WriteMessageTo(&b, first); err != nil {
+			t.Fatal(err)
+		}
+		frame := b.Bytes()
+		r := bytes.NewReader(frame)
+		testutil.ShouldNotAllocate(t, func() {
+			r.Reset(frame)
+			if err := ReadMessageFrom(r, m, 0); err != nil {
+				t.Error(err)
+			}
+		})
+	})
+}
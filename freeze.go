@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import "errors"
+
+// ErrMessageFrozen means that message is frozen by Message.Freeze and can
+// not be modified.
+var ErrMessageFrozen = errors.New("message is frozen")
+
+// Freeze makes m read-only, so decoded message can be shared between
+// goroutines, e.g. request that is passed to pool of workers. Methods of
+// m that modify it and return error, like Build, Decode or Delete, as well
+// as AddTo of setters, return ErrMessageFrozen for frozen message, while
+// methods without error result, like Add, Reset or WriteHeader, panic with
+// it. So concurrent modification, which silently corrupts m.Raw otherwise,
+// is caught on first call. Direct changes of fields of m are not detected.
+//
+// Index of attributes is built by Freeze if it is enabled, so Get and
+// Contains of frozen message do not modify it. Freeze can not be undone,
+// use Clone to get modifiable copy.
+func (m *Message) Freeze() {
+	if m.index.enabled && !m.indexValid() {
+		m.buildIndex()
+	}
+	m.frozen = true
+}
+
+// Frozen reports whether m is frozen by Freeze.
+func (m *Message) Frozen() bool {
+	return m.frozen
+}
+
+// checkMutable returns ErrMessageFrozen if m is frozen.
+func (m *Message) checkMutable() error {
+	if m.frozen {
+		return ErrMessageFrozen
+	}
+
+	return nil
+}
+
+// mustBeMutable panics if m is frozen, for methods that can not return
+// error.
+func (m *Message) mustBeMutable() {
+	if m.frozen {
+		panic(ErrMessageFrozen) //nolint
+	}
+}
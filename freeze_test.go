@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestMessage_Freeze(t *testing.T) {
+	integrity := NewShortTermIntegrity("password")
+	m := MustBuild(TransactionID, BindingRequest, NewUsername("user"), integrity, Fingerprint)
+	raw := append([]byte(nil), m.Raw...)
+	m.Freeze()
+	if !m.Frozen() {
+		t.Fatal("should be frozen")
+	}
+	for name, f := range map[string]func() error{
+		"Build":       func() error { return m.Build(BindingRequest) },
+		"Decode":      m.Decode,
+		"DecodeFunc":  func() error { return Decode(raw, m) },
+		"Delete":      func() error { return m.Delete(AttrUsername) },
+		"Replace":     func() error { return m.Replace(AttrUsername, []byte("other")) },
+		"NewID":       m.NewTransactionID,
+		"Unmarshal":   func() error { return m.UnmarshalBinary(raw) },
+		"CloneTo":     func() error { return New().CloneTo(m) },
+		"AddTo":       func() error { return New().AddTo(m) },
+		"Fingerprint": func() error { return Fingerprint.AddTo(m) },
+		"Integrity":   func() error { return integrity.AddTo(m) },
+		"Software":    func() error { return NewSoftware("software").AddTo(m) },
+		"Type":        func() error { return BindingSuccess.AddTo(m) },
+		"ID":          func() error { return NewTransactionIDSetter([TransactionIDSize]byte{}).AddTo(m) },
+		"XORAddr":     func() error { return (&XORMappedAddress{IP: net.IPv4(1, 2, 3, 4)}).AddTo(m) },
+		"RawAttr":     func() error { return RawAttr{Type: AttrUseCandidate}.AddTo(m) },
+		"ErrorCode":   func() error { return CodeBadRequest.AddTo(m) },
+		"Padding":     func() error { return Padding(4).AddTo(m) },
+		"Stream": func() error {
+			return NewStreamDecoder(bytes.NewReader(raw), 0).Decode(m)
+		},
+	} {
+		if err := f(); !errors.Is(err, ErrMessageFrozen) {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+	for name, f := range map[string]func(){
+		"Add":         func() { m.Add(AttrSoftware, []byte("software")) },
+		"Reset":       m.Reset,
+		"WriteHeader": m.WriteHeader,
+		"SetType":     func() { m.SetType(BindingSuccess) },
+		"CopyTo":      func() { New().CopyTo(m) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); !errors.Is(r.(error), ErrMessageFrozen) { //nolint:forcetypeassert
+					t.Errorf("%s: unexpected panic: %v", name, r)
+				}
+			}()
+			f()
+			t.Errorf("%s: should panic", name)
+		}()
+	}
+	if !bytes.Equal(m.Raw, raw) || m.Type != BindingRequest || len(m.Attributes) != 3 {
+		t.Error("frozen message is modified")
+	}
+	clone := m.Clone()
+	if clone.Frozen() {
+		t.Error("clone should not be frozen")
+	}
+	if err := clone.Delete(AttrFingerprint); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMessage_FreezeConcurrent(t *testing.T) {
+	integrity := NewShortTermIntegrity("password")
+	m := MustBuild(TransactionID, BindingRequest,
+		NewUsername("user"), NewSoftware("a"), NewSoftware("b"), integrity, Fingerprint,
+	)
+	m.BuildIndex()
+	m.Freeze()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var username Username
+			if err := username.GetFrom(m); err != nil || username.String() != "user" {
+				t.Errorf("unexpected username %q: %v", username, err)
+			}
+			if err := m.Check(integrity, Fingerprint); err != nil {
+				t.Error(err)
+			}
+			count := 0
+			if err := m.ForEach(AttrSoftware, func(m *Message) error {
+				count++
+
+				return nil
+			}); err != nil || count != 2 {
+				t.Errorf("unexpected count %d: %v", count, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
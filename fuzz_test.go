@@ -6,6 +6,7 @@ package stun
 import (
 	"encoding/binary"
 	"errors"
+	"net"
 	"testing"
 )
 
@@ -44,6 +45,100 @@ func FuzzMessage(f *testing.F) {
 	})
 }
 
+// getters returns Getter of each attribute type of package, so decoding
+// of arbitrary messages can be checked for panics.
+func getters() []Getter {
+	return []Getter{
+		new(AlternateServer), new(MappedAddress), new(OtherAddress),
+		new(ResponseOrigin), new(ResponsePort), new(PasswordAlgorithm),
+		new(PasswordAlgorithms), new(ErrorCodeAttribute), new(NonceCookie),
+		new(Username), new(Realm), new(Software), new(Nonce),
+		new(UnknownAttributes), new(XORMappedAddress), new(XORMappedAddr),
+		new(SourceAddress), new(ChangedAddress), new(ChannelNumber),
+		new(Padding),
+		&Uint32Attr{Type: AttrLifetime}, &Uint64Attr{Type: AttrICEControlling},
+	}
+}
+
+func FuzzMessageDecode(f *testing.F) {
+	f.Add(MustBuild(TransactionID, BindingRequest).Raw)
+	f.Add(MustBuild(TransactionID, BindingSuccess,
+		&XORMappedAddress{IP: net.IPv4(192, 0, 2, 1), Port: 3478},
+		NewSoftware("software"), Fingerprint,
+	).Raw)
+	m := new(Message)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := Decode(data, m); err != nil {
+			return
+		}
+		decoded := new(Message)
+		if err := DecodeMessage(m.AppendTo(make([]byte, 0, len(m.Raw))), decoded); err != nil {
+			t.Fatal(err)
+		}
+		// Equal is not used, as it distinguishes nil and empty attributes.
+		if decoded.Type != m.Type || decoded.TransactionID != m.TransactionID ||
+			len(decoded.Attributes) != len(m.Attributes) {
+			t.Fatalf("decoded message differs: %s, expected %s", decoded, m)
+		}
+		for _, g := range getters() {
+			_ = g.GetFrom(decoded)
+		}
+		_ = decoded.String()
+		_ = Fingerprint.Check(decoded)
+		_ = MessageIntegrity("key").Check(decoded)
+		_ = MessageIntegritySHA256("key").Check(decoded)
+	})
+}
+
+func FuzzAttrGetters(f *testing.F) {
+	f.Add(uint16(AttrXORMappedAddress), []byte{0, 1, 0, 0})
+	f.Add(uint16(AttrMappedAddress), []byte{0, 2, 0, 1, 1, 2, 3, 4})
+	f.Add(uint16(AttrErrorCode), []byte{0, 0, 4})
+	m := new(Message)
+	f.Fuzz(func(t *testing.T, attrType uint16, value []byte) {
+		m.Reset()
+		m.WriteHeader()
+		m.Add(AttrType(attrType), value)
+		if err := DecodeMessage(append(make([]byte, 0, len(m.Raw)), m.Raw...), m); err != nil {
+			t.Fatal(err)
+		}
+		for _, g := range getters() {
+			_ = g.GetFrom(m)
+		}
+	})
+}
+
+func TestGetters_Truncated(t *testing.T) {
+	value := make([]byte, 64)
+	for i := range value {
+		value[i] = byte(i * 7)
+	}
+	value[1] = byte(familyIPv6)
+	m := new(Message)
+	for _, g := range getters() {
+		for attrType := range attrNames() {
+			for n := 0; n <= len(value); n++ {
+				m.Reset()
+				m.WriteHeader()
+				m.Add(attrType, value[:n])
+				// Decoding from buffer of exact size, so reading past
+				// value is not masked by capacity of m.Raw.
+				if err := DecodeMessage(append(make([]byte, 0, len(m.Raw)), m.Raw...), m); err != nil {
+					t.Fatal(err)
+				}
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Errorf("%T panics on %d bytes of %s: %v", g, n, attrType, r)
+						}
+					}()
+					_ = g.GetFrom(m)
+				}()
+			}
+		}
+	}
+}
+
 func FuzzType(f *testing.F) {
 	f.Fuzz(func(t *testing.T, data uint16) {
 		v := data & 0x1fff // First 3 bits are empty
@@ -3,6 +3,11 @@
 
 package stun
 
+import (
+	"errors"
+	"sort"
+)
+
 // Interfaces that are implemented by message attributes, shorthands for them,
 // or helpers for message fields as type or transaction id.
 type (
@@ -37,6 +42,9 @@ type (
 //
 // See BenchmarkBuildOverhead.
 func (m *Message) Build(setters ...Setter) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
 	m.Reset()
 	m.WriteHeader()
 	for _, s := range setters {
@@ -90,6 +98,86 @@ func Build(setters ...Setter) (*Message, error) {
 	return m, nil
 }
 
+// ErrAttributeOrder means that MESSAGE-INTEGRITY, MESSAGE-INTEGRITY-SHA256
+// or FINGERPRINT attribute is followed by attribute that must precede it.
+var ErrAttributeOrder = errors.New("attribute after integrity or fingerprint")
+
+// attrRank returns position of attribute type t in message: integrity
+// attributes must be after all others, in order of increasing rank.
+func attrRank(t AttrType) int {
+	switch t {
+	case AttrMessageIntegrity:
+		return 1
+	case AttrMessageIntegritySHA256:
+		return 2
+	case AttrFingerprint:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// CheckAttributeOrder returns ErrAttributeOrder if MESSAGE-INTEGRITY,
+// MESSAGE-INTEGRITY-SHA256 and FINGERPRINT are not the last attributes of
+// m in that order, or are repeated.
+func CheckAttributeOrder(m *Message) error {
+	last := 0
+	for _, a := range m.Attributes {
+		rank := attrRank(a.Type)
+		if last > 0 && rank <= last {
+			return ErrAttributeOrder
+		}
+		last = rank
+	}
+
+	return nil
+}
+
+// setterRank returns position of setter s in Ordered, like attrRank.
+func setterRank(s Setter) int {
+	switch s.(type) {
+	case MessageIntegrity, *MessageIntegrity, authSetter:
+		return attrRank(AttrMessageIntegrity)
+	case MessageIntegritySHA256, *MessageIntegritySHA256:
+		return attrRank(AttrMessageIntegritySHA256)
+	case FingerprintAttr, *FingerprintAttr:
+		return attrRank(AttrFingerprint)
+	default:
+		return 0
+	}
+}
+
+type orderedSetter []Setter
+
+// Ordered returns Setter that applies setters with MESSAGE-INTEGRITY
+// setters (MessageIntegrity, ShortTermAuth and LongTermAuth),
+// MessageIntegritySHA256 and Fingerprint moved after all others in that
+// order, keeping order of other setters:
+//
+//	m, err := Build(Ordered(Fingerprint, integrity, TransactionID, BindingRequest, username))
+//
+// Resulting message is checked with CheckAttributeOrder, e.g. if setter
+// of other type adds integrity attribute.
+func Ordered(setters ...Setter) Setter {
+	ordered := make(orderedSetter, len(setters))
+	copy(ordered, setters)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return setterRank(ordered[i]) < setterRank(ordered[j])
+	})
+
+	return ordered
+}
+
+func (s orderedSetter) AddTo(m *Message) error {
+	for _, setter := range s {
+		if err := setter.AddTo(m); err != nil {
+			return err
+		}
+	}
+
+	return CheckAttributeOrder(m)
+}
+
 // ForEach is helper that iterates over message attributes allowing to call
 // Getter in f callback to get all attributes of type t and returning on first
 // f error.
@@ -97,9 +185,16 @@ func Build(setters ...Setter) (*Message, error) {
 // The m.Get method inside f will be returning next attribute on each f call.
 // Does not error if there are no results.
 func (m *Message) ForEach(t AttrType, f func(m *Message) error) error {
-	attrs := m.Attributes
+	if m.frozen {
+		// Frozen message is shared, so f gets shallow copy of m.
+		view := *m
+		m = &view
+	}
+	attrs, indexed := m.Attributes, m.index.enabled
+	// Get of f should see sub-slice of attributes instead of index.
+	m.index.enabled = false
 	defer func() {
-		m.Attributes = attrs
+		m.Attributes, m.index.enabled = attrs, indexed
 	}()
 	for i, a := range attrs {
 		if a.Type != t {
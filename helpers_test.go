@@ -4,6 +4,7 @@
 package stun
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"testing"
@@ -247,3 +248,78 @@ func BenchmarkMessage_ForEach(b *testing.B) {
 		}
 	}
 }
+
+func TestOrdered(t *testing.T) {
+	var (
+		id        = NewTransactionIDSetter([TransactionIDSize]byte{1, 2, 3})
+		username  = NewUsername("user")
+		integrity = NewShortTermIntegrity("password")
+
+		integritySHA256 = NewShortTermIntegritySHA256("password")
+	)
+	for _, tc := range []struct {
+		name     string
+		setters  []Setter
+		expected []Setter
+	}{
+		{
+			"Ordered",
+			[]Setter{id, BindingRequest, username, NewSoftware("s"), integrity, Fingerprint},
+			[]Setter{id, BindingRequest, username, NewSoftware("s"), integrity, Fingerprint},
+		},
+		{
+			"Reversed",
+			[]Setter{Fingerprint, integrity, id, BindingRequest, username, NewSoftware("s")},
+			[]Setter{id, BindingRequest, username, NewSoftware("s"), integrity, Fingerprint},
+		},
+		{
+			"Auth",
+			[]Setter{Fingerprint, ShortTermAuth("user", "password"), id, BindingRequest, NewSoftware("s")},
+			[]Setter{id, BindingRequest, NewSoftware("s"), username, integrity, Fingerprint},
+		},
+		{
+			"SHA256",
+			[]Setter{Fingerprint, integritySHA256, id, BindingRequest, username},
+			[]Setter{id, BindingRequest, username, integritySHA256, Fingerprint},
+		},
+		{
+			"Both",
+			[]Setter{integritySHA256, Fingerprint, integrity, id, BindingRequest, username},
+			[]Setter{id, BindingRequest, username, integrity, integritySHA256, Fingerprint},
+		},
+	} {
+		m, err := Build(Ordered(tc.setters...))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !bytes.Equal(m.Raw, MustBuild(tc.expected...).Raw) {
+			t.Errorf("%s: unexpected message: %s", tc.name, m)
+		}
+	}
+	// Unknown setter that adds integrity can not be reordered.
+	_, err := Build(Ordered(RawAttribute{Type: AttrFingerprint, Value: []byte{1, 2, 3, 4}}, username))
+	if !errors.Is(err, ErrAttributeOrder) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckAttributeOrder(t *testing.T) {
+	for _, tc := range []struct {
+		attrs []AttrType
+		err   error
+	}{
+		{[]AttrType{AttrUsername, AttrMessageIntegrity, AttrMessageIntegritySHA256, AttrFingerprint}, nil},
+		{[]AttrType{AttrUsername, AttrMessageIntegritySHA256}, nil},
+		{[]AttrType{AttrFingerprint, AttrUsername}, ErrAttributeOrder},
+		{[]AttrType{AttrMessageIntegritySHA256, AttrMessageIntegrity}, ErrAttributeOrder},
+		{[]AttrType{AttrFingerprint, AttrFingerprint}, ErrAttributeOrder},
+	} {
+		m := new(Message)
+		for _, attr := range tc.attrs {
+			m.Attributes = append(m.Attributes, RawAttribute{Type: attr})
+		}
+		if err := CheckAttributeOrder(m); !errors.Is(err, tc.err) {
+			t.Errorf("%v: unexpected error: %v", tc.attrs, err)
+		}
+	}
+}
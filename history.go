@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransactionRecord describes completed client transaction.
+type TransactionRecord struct {
+	ID       [TransactionIDSize]byte
+	Method   Method
+	Start    time.Time
+	Duration time.Duration
+	Attempts int
+	Err      error // nil if response was received
+}
+
+func (r TransactionRecord) String() string {
+	result := "ok"
+	if r.Err != nil {
+		result = r.Err.Error()
+	}
+
+	return fmt.Sprintf("%s %s at %s, took %s, %d attempt(s): %s",
+		hex.EncodeToString(r.ID[:]), r.Method, r.Start.Format(time.RFC3339Nano),
+		r.Duration, r.Attempts, result,
+	)
+}
+
+// transactionHistory is fixed-size ring of last transaction records.
+type transactionHistory struct {
+	mux     sync.Mutex
+	records []TransactionRecord
+	next    int
+	full    bool
+}
+
+func newTransactionHistory(size int) *transactionHistory {
+	return &transactionHistory{
+		records: make([]TransactionRecord, size),
+	}
+}
+
+func (h *transactionHistory) add(r TransactionRecord) {
+	h.mux.Lock()
+	h.records[h.next] = r
+	h.next++
+	if h.next == len(h.records) {
+		h.next = 0
+		h.full = true
+	}
+	h.mux.Unlock()
+}
+
+// list returns records from oldest to newest.
+func (h *transactionHistory) list() []TransactionRecord {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if !h.full {
+		return append([]TransactionRecord(nil), h.records[:h.next]...)
+	}
+	records := make([]TransactionRecord, 0, len(h.records))
+	records = append(records, h.records[h.next:]...)
+
+	return append(records, h.records[:h.next]...)
+}
+
+func (h *transactionHistory) String() string {
+	var b strings.Builder
+	for _, r := range h.list() {
+		b.WriteString("\n\t")
+		b.WriteString(r.String())
+	}
+
+	return b.String()
+}
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/logging"
+)
+
+func TestTransactionHistory(t *testing.T) {
+	h := newTransactionHistory(3)
+	if len(h.list()) != 0 {
+		t.Fatal("history should be empty")
+	}
+	for i := 0; i < 5; i++ {
+		h.add(TransactionRecord{Attempts: i})
+	}
+	records := h.list()
+	if len(records) != 3 {
+		t.Fatalf("unexpected length: %d", len(records))
+	}
+	for i, r := range records {
+		if r.Attempts != i+2 {
+			t.Errorf("records[%d]: unexpected attempts %d", i, r.Attempts)
+		}
+	}
+}
+
+func TestTransactionRecord_String(t *testing.T) {
+	r := TransactionRecord{
+		Method:   MethodBinding,
+		Start:    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		Duration: time.Millisecond,
+		Attempts: 2,
+		Err:      ErrTransactionTimeOut,
+	}
+	const expected = "000000000000000000000000 Binding at 2023-01-01T00:00:00Z, took 1ms, 2 attempt(s): transaction is timed out"
+	if r.String() != expected {
+		t.Errorf("%q != %q", r, expected)
+	}
+}
+
+type syncBuffer struct {
+	mux sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.buf.String()
+}
+
+func TestClient_TransactionHistory(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		m := new(Message)
+		m.Raw = make([]byte, 1024)
+		if _, err := m.ReadFrom(serverConn); err != nil {
+			t.Error(err)
+
+			return
+		}
+		res := MustBuild(m, BindingSuccess)
+		if _, err := res.WriteTo(serverConn); err != nil {
+			t.Error(err)
+		}
+		// Dropping connection after first transaction.
+		_ = serverConn.Close()
+	}()
+	logs := new(syncBuffer)
+	loggerFactory := &logging.DefaultLoggerFactory{
+		Writer:          logs,
+		DefaultLogLevel: logging.LogLevelDebug,
+	}
+	c, err := NewClient(clientConn,
+		WithNoRetransmit,
+		WithTransactionHistory(10),
+		WithLogger(loggerFactory.NewLogger("stun")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := MustBuild(TransactionID, BindingRequest)
+	if err = c.Do(request, func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-c.Err(); !errors.Is(err, io.EOF) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err = c.Do(MustBuild(TransactionID, BindingRequest), func(Event) {}); err == nil {
+		t.Error("should fail on dropped connection")
+	}
+	history := c.TransactionHistory()
+	if len(history) != 1 {
+		t.Fatalf("unexpected history length: %d", len(history))
+	}
+	if history[0].ID != request.TransactionID || history[0].Method != MethodBinding || history[0].Err != nil {
+		t.Errorf("unexpected record: %s", history[0])
+	}
+	if err = c.Close(); err != nil && !errors.As(err, &CloseErr{}) {
+		t.Error(err)
+	}
+	if !strings.Contains(logs.String(), history[0].String()) {
+		t.Errorf("history is not logged: %q", logs)
+	}
+	if c := new(Client); c.TransactionHistory() != nil {
+		t.Error("history should be nil if not enabled")
+	}
+}
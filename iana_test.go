@@ -119,5 +119,10 @@ func TestIANA(t *testing.T) { //nolint:cyclop
 				t.Errorf("%s: IANA %d != actual %d", name, mapped, val)
 			}
 		}
+		for name, val := range errorCodes {
+			if name != "Unassigned" && val.Reason() != name {
+				t.Errorf("no reason for IANA error code %d %s", val, name)
+			}
+		}
 	})
 }
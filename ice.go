@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// UseCandidate is USE-CANDIDATE attribute that is added by controlling
+// ICE agent to nominate candidate pair, see NewICEBindingRequest.
+//
+// RFC 8445 Section 7.1.2.
+var UseCandidate Setter = RawAttr{Type: AttrUseCandidate} //nolint:gochecknoglobals
+
+var (
+	// ErrNotBindingRequest means that message is not a Binding request.
+	ErrNotBindingRequest = errors.New("message is not a binding request")
+	// ErrInvalidICEUsername means that USERNAME is not "RFRAG:LFRAG" pair
+	// of non-empty ufrags.
+	ErrInvalidICEUsername = errors.New("invalid ICE username")
+)
+
+// NewICEUsername returns USERNAME of ICE connectivity check that is sent
+// by agent with local ufrag to agent with remote one.
+//
+// RFC 8445 Section 7.2.2.
+func NewICEUsername(remote, local string) Username {
+	return Username(remote + ":" + local)
+}
+
+// Split returns ufrags of remote and local agents from USERNAME of ICE
+// connectivity check, as seen by sender of request, or
+// ErrInvalidICEUsername if u is not pair of non-empty ufrags that are
+// delimited by colon.
+func (u Username) Split() (remote, local string, err error) {
+	i := bytes.IndexByte(u, ':')
+	if i <= 0 || i == len(u)-1 || bytes.IndexByte(u[i+1:], ':') >= 0 {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidICEUsername, u)
+	}
+
+	return string(u[:i]), string(u[i+1:]), nil
+}
+
+// NewICEBindingRequest returns Binding request of ICE connectivity check
+// (RFC 8445 Section 7.2.2). The username is "RFRAG:LFRAG", the ufrags of
+// remote and local agents, see NewICEUsername, and pwd is password of
+// remote agent.
+//
+// Attributes are added in order that is required for short-term
+// credentials: USERNAME, PRIORITY, ICE-CONTROLLING or ICE-CONTROLLED with
+// tiebreaker, then setters, e.g. UseCandidate, then MESSAGE-INTEGRITY and
+// FINGERPRINT.
+func NewICEBindingRequest(
+	username, pwd string, priority uint32, controlling bool, tiebreaker uint64, setters ...Setter,
+) (*Message, error) {
+	role := AttrICEControlled
+	if controlling {
+		role = AttrICEControlling
+	}
+	all := make([]Setter, 0, len(setters)+7)
+	all = append(all,
+		TransactionID, BindingRequest,
+		NewUsername(username),
+		Uint32Attr{Type: AttrPriority, Value: priority},
+		Uint64Attr{Type: role, Value: tiebreaker},
+	)
+	all = append(all, setters...)
+	all = append(all, NewShortTermIntegrity(pwd), Fingerprint)
+
+	return Build(all...)
+}
+
+// NewICEBindingResponse returns success response to Binding request of
+// ICE connectivity check with XOR-MAPPED-ADDRESS of mapped, the source
+// address of request, protected by pwd, the password of local agent.
+// Returns ErrNotBindingRequest if req is not a Binding request.
+//
+// RFC 8445 Section 7.3.1.
+func NewICEBindingResponse(req *Message, mapped netip.AddrPort, pwd string) (*Message, error) {
+	if !req.IsBindingRequest() {
+		return nil, fmt.Errorf("%w: %s", ErrNotBindingRequest, req.Type)
+	}
+
+	return Build(req, BindingSuccess,
+		NewXORMappedAddress(mapped),
+		NewShortTermIntegrity(pwd),
+		Fingerprint,
+	)
+}
@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func attrTypes(m *Message) []AttrType {
+	types := make([]AttrType, 0, len(m.Attributes))
+	for _, a := range m.Attributes {
+		types = append(types, a.Type)
+	}
+
+	return types
+}
+
+func equalAttrTypes(a, b []AttrType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestNewICEBindingRequest(t *testing.T) {
+	const (
+		username = "remote:local"
+		pwd      = "remote-password"
+	)
+	for _, tc := range []struct {
+		name        string
+		controlling bool
+		setters     []Setter
+		want        []AttrType
+	}{
+		{
+			name: "Controlled",
+			want: []AttrType{
+				AttrUsername, AttrPriority, AttrICEControlled,
+				AttrMessageIntegrity, AttrFingerprint,
+			},
+		},
+		{
+			name:        "Nomination",
+			controlling: true,
+			setters:     []Setter{UseCandidate},
+			want: []AttrType{
+				AttrUsername, AttrPriority, AttrICEControlling, AttrUseCandidate,
+				AttrMessageIntegrity, AttrFingerprint,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewICEBindingRequest(username, pwd, 0x6e0001ff, tc.controlling, 0xdeadbeef01020304, tc.setters...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := new(Message)
+			if err = Decode(m.Raw, decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Type != BindingRequest {
+				t.Errorf("unexpected type: %s", decoded.Type)
+			}
+			if got := attrTypes(decoded); !equalAttrTypes(got, tc.want) {
+				t.Errorf("unexpected attributes: %v", got)
+			}
+			if err = NewShortTermIntegrity(pwd).Check(decoded); err != nil {
+				t.Error(err)
+			}
+			if err = Fingerprint.Check(decoded); err != nil {
+				t.Error(err)
+			}
+			var u Username
+			if err = u.GetFrom(decoded); err != nil || u.String() != username {
+				t.Errorf("unexpected username: %q, %v", u, err)
+			}
+			priority := Uint32Attr{Type: AttrPriority}
+			if err = priority.GetFrom(decoded); err != nil || priority.Value != 0x6e0001ff {
+				t.Errorf("unexpected priority: %x, %v", priority.Value, err)
+			}
+			role := Uint64Attr{Type: tc.want[2]}
+			if err = role.GetFrom(decoded); err != nil || role.Value != 0xdeadbeef01020304 {
+				t.Errorf("unexpected tiebreaker: %x, %v", role.Value, err)
+			}
+		})
+	}
+}
+
+func TestNewICEBindingResponse(t *testing.T) {
+	const pwd = "local-password"
+	req, err := NewICEBindingRequest("local:remote", pwd, 1, true, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapped := netip.MustParseAddrPort("192.0.2.1:3478")
+	res, err := NewICEBindingResponse(req, mapped, pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(Message)
+	if err = Decode(res.Raw, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Type != BindingSuccess || decoded.TransactionID != req.TransactionID {
+		t.Errorf("unexpected response: %s", decoded)
+	}
+	want := []AttrType{AttrXORMappedAddress, AttrMessageIntegrity, AttrFingerprint}
+	if got := attrTypes(decoded); !equalAttrTypes(got, want) {
+		t.Errorf("unexpected attributes: %v", got)
+	}
+	if err = NewShortTermIntegrity(pwd).Check(decoded); err != nil {
+		t.Error(err)
+	}
+	var addr XORMappedAddress
+	if err = addr.GetFrom(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := addr.Addr(); got != mapped {
+		t.Errorf("unexpected address: %s", addr)
+	}
+	if _, err = NewICEBindingResponse(res, mapped, pwd); !errors.Is(err, ErrNotBindingRequest) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUsername_Split(t *testing.T) {
+	u := NewICEUsername("remote", "local")
+	if u.String() != "remote:local" {
+		t.Errorf("unexpected username: %q", u)
+	}
+	remote, local, err := u.Split()
+	if err != nil || remote != "remote" || local != "local" {
+		t.Errorf("unexpected split: %q, %q, %v", remote, local, err)
+	}
+	for _, bad := range []string{"", "remote", ":local", "remote:", ":", "a:b:c"} {
+		if _, _, err := Username(bad).Split(); !errors.Is(err, ErrInvalidICEUsername) {
+			t.Errorf("%q: unexpected error: %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/transport/v3/deadline"
+)
+
+// ICE integration.
+//
+// MultiplexedConn implements UDPMux interface of pion/ice:
+//
+//	type UDPMux interface {
+//		io.Closer
+//		GetConn(ufrag string, addr net.Addr) (net.PacketConn, error)
+//		RemoveConnByUfrag(ufrag string)
+//		GetListenAddresses() []net.Addr
+//	}
+//
+// so single socket can be shared by ICE agents, STUN clients created by
+// MultiplexedConn.Client (e.g. for server reflexive candidates gathering)
+// and the application, that reads other packets via ReadNonSTUN.
+//
+// STUN packets with USERNAME "ufrag:remote" are routed to connection of
+// ufrag, and source address of such packet is associated with that
+// connection, so all following packets from it, STUN or not, are routed
+// there too. Destination addresses of connection writes are associated
+// with it in the same way.
+
+// GetConn returns net.PacketConn for ICE agent with local username
+// fragment ufrag, creating it if necessary. Addr is ignored, because
+// connection is shared by all remote addresses.
+func (c *MultiplexedConn) GetConn(ufrag string, _ net.Addr) (net.PacketConn, error) {
+	select {
+	case <-c.closed:
+		return nil, c.readErr()
+	default:
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if conn, ok := c.ufrags[ufrag]; ok {
+		return conn, nil
+	}
+	conn := &iceConn{
+		parent:       c,
+		ufrag:        ufrag,
+		in:           make(chan muxPacket, muxQueueSize),
+		closed:       make(chan struct{}),
+		readDeadline: deadline.New(),
+	}
+	c.ufrags[ufrag] = conn
+
+	return conn, nil
+}
+
+// RemoveConnByUfrag closes connection of ufrag, if any.
+func (c *MultiplexedConn) RemoveConnByUfrag(ufrag string) {
+	c.mux.Lock()
+	conn, ok := c.ufrags[ufrag]
+	if ok {
+		delete(c.ufrags, ufrag)
+		for addr, addrConn := range c.iceAddrs {
+			if addrConn == conn {
+				delete(c.iceAddrs, addr)
+			}
+		}
+	}
+	c.mux.Unlock()
+	if ok {
+		conn.once.Do(func() { close(conn.closed) })
+	}
+}
+
+// GetListenAddresses returns local address of underlying connection.
+func (c *MultiplexedConn) GetListenAddresses() []net.Addr {
+	return []net.Addr{c.conn.LocalAddr()}
+}
+
+// iceConnByAddr returns ICE connection associated with addr.
+func (c *MultiplexedConn) iceConnByAddr(addr net.Addr) (*iceConn, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	conn, ok := c.iceAddrs[addr.String()]
+
+	return conn, ok
+}
+
+// iceConnByUsername returns ICE connection for USERNAME of m, associating
+// it with addr.
+func (c *MultiplexedConn) iceConnByUsername(m *Message, addr net.Addr) (*iceConn, bool) {
+	var username Username
+	if username.GetFrom(m) != nil {
+		return nil, false
+	}
+	ufrag, _, _ := strings.Cut(username.String(), ":")
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	conn, ok := c.ufrags[ufrag]
+	if ok {
+		c.iceAddrs[addr.String()] = conn
+	}
+
+	return conn, ok
+}
+
+// iceConn is net.PacketConn of single ICE agent.
+type iceConn struct {
+	parent       *MultiplexedConn
+	ufrag        string
+	in           chan muxPacket
+	closed       chan struct{}
+	once         sync.Once
+	readDeadline *deadline.Deadline
+}
+
+func (c *iceConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case <-c.closed:
+		return 0, nil, net.ErrClosed
+	default:
+	}
+	select {
+	case p := <-c.in:
+		return copy(b, p.data), p.addr, nil
+	case <-c.closed:
+		return 0, nil, net.ErrClosed
+	case <-c.parent.closed:
+		return 0, nil, c.parent.readErr()
+	case <-c.readDeadline.Done():
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+func (c *iceConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.parent.mux.Lock()
+	if c.parent.ufrags[c.ufrag] != c {
+		c.parent.mux.Unlock()
+
+		return 0, net.ErrClosed
+	}
+	c.parent.iceAddrs[addr.String()] = c
+	c.parent.mux.Unlock()
+
+	return c.parent.conn.WriteTo(b, addr)
+}
+
+func (c *iceConn) Close() error {
+	c.parent.RemoveConnByUfrag(c.ufrag)
+
+	return nil
+}
+
+func (c *iceConn) LocalAddr() net.Addr {
+	return c.parent.conn.LocalAddr()
+}
+
+func (c *iceConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *iceConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+
+	return nil
+}
+
+// SetWriteDeadline is no-op, writes are not blocking.
+func (c *iceConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
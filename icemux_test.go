@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMultiplexedConn_ICE(t *testing.T) {
+	mux := NewMultiplexedConn(listenLocalUDP(t))
+	defer mux.Close() //nolint:errcheck
+	if addrs := mux.GetListenAddresses(); len(addrs) != 1 || addrs[0] != mux.LocalAddr() {
+		t.Errorf("unexpected listen addresses: %v", addrs)
+	}
+	conn, err := mux.GetConn("local", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same, _ := mux.GetConn("local", nil); same != conn {
+		t.Error("GetConn should return existing connection")
+	}
+	peer := listenLocalUDP(t)
+	defer peer.Close() //nolint:errcheck
+
+	read := func() ([]byte, net.Addr) {
+		t.Helper()
+		if err = conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 1500)
+		n, addr, readErr := conn.ReadFrom(buf)
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+
+		return buf[:n], addr
+	}
+
+	// Connectivity check is routed by USERNAME.
+	check := MustBuild(TransactionID, BindingRequest, NewUsername("local:remote"))
+	if _, err = peer.WriteTo(check.Raw, mux.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if b, addr := read(); !IsMessage(b) || addr.String() != peer.LocalAddr().String() {
+		t.Errorf("unexpected packet from %s", addr)
+	}
+	// Following packets from peer are routed by address.
+	if _, err = peer.WriteTo([]byte{0x80, 0}, mux.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := read(); len(b) != 2 {
+		t.Errorf("unexpected packet: %v", b)
+	}
+	if _, err = conn.WriteTo([]byte{1}, peer.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read deadline.
+	if err = conn.SetDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = conn.ReadFrom(make([]byte, 10)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	mux.RemoveConnByUfrag("local")
+	if _, _, err = conn.ReadFrom(make([]byte, 10)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = conn.WriteTo([]byte{1}, peer.LocalAddr()); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, ok := mux.iceConnByAddr(peer.LocalAddr()); ok {
+		t.Error("address should be removed with connection")
+	}
+}
@@ -4,11 +4,11 @@
 package stun
 
 import (
-	"crypto/md5"  //nolint:gosec
-	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
 	"errors"
 	"fmt"
-	"strings"
+	"hash"
+	"sync"
 
 	"github.com/pion/stun/v3/internal/hmac"
 )
@@ -17,13 +17,17 @@ import (
 const credentialsSep = ":"
 
 // NewLongTermIntegrity returns new MessageIntegrity with key for long-term
-// credentials. Password, username, and realm must be SASL-prepared.
+// credentials. Username, realm and password are prepared with
+// PrepareOpaqueString; values that the profile rejects are used as is.
+//
+// Key is derived with MD5, which is not available in builds with fips
+// tag, so key is nil there and AddTo and Check return
+// ErrUnsupportedPasswordAlgorithm. Use NewLongTermIntegrityAlgorithm with
+// SHA-256 in such builds.
 func NewLongTermIntegrity(username, realm, password string) MessageIntegrity {
-	k := strings.Join([]string{username, realm, password}, credentialsSep)
-	h := md5.New()   //nolint:gosec
-	fmt.Fprint(h, k) //nolint:errcheck
+	key, _ := md5Key(longTermKey(username, realm, password))
 
-	return MessageIntegrity(h.Sum(nil))
+	return key
 }
 
 // NewShortTermIntegrity returns new MessageIntegrity with key for short-term
@@ -32,20 +36,56 @@ func NewShortTermIntegrity(password string) MessageIntegrity {
 	return MessageIntegrity(password)
 }
 
-// MessageIntegrity represents MESSAGE-INTEGRITY attribute.
+// MessageIntegrity represents MESSAGE-INTEGRITY attribute, HMAC-SHA1 of
+// message. AddTo and Check return ErrLegacyHashesDisabled if legacy
+// hashes are disabled, see SetLegacyHashes and MessageIntegritySHA256.
 //
 // AddTo and Check methods are using zero-allocation version of hmac, see
-// newHMAC function and internal/hmac/pool.go.
+// integrityScratch type and internal/hmac/pool.go.
 //
 // RFC 5389 Section 15.4.
 type MessageIntegrity []byte
 
-func newHMAC(key, message, buf []byte) []byte {
+// integrityScratch holds message header with adjusted length and HMAC
+// value, so HMAC is computed over view of message without modifying or
+// copying it.
+type integrityScratch struct {
+	header [messageHeaderSize]byte
+	sum    [sha256.Size]byte // fits HMAC-SHA1 too
+}
+
+var integrityScratchPool = &sync.Pool{ //nolint:gochecknoglobals
+	New: func() interface{} {
+		return new(integrityScratch)
+	},
+}
+
+// compute returns HMAC-SHA1 of message with length in header replaced by
+// length. Returned value is valid until s is put back to pool.
+func (s *integrityScratch) compute(key, message []byte, length int) []byte {
 	mac := hmac.AcquireSHA1(key)
-	writeOrPanic(mac, message)
 	defer hmac.PutSHA1(mac)
 
-	return mac.Sum(buf)
+	return s.sumOf(mac, message, length)
+}
+
+// computeSHA256 is like compute, but returns HMAC-SHA256.
+func (s *integrityScratch) computeSHA256(key, message []byte, length int) []byte {
+	mac := hmac.AcquireSHA256(key)
+	defer hmac.PutSHA256(mac)
+
+	return s.sumOf(mac, message, length)
+}
+
+// sumOf writes message with length in header replaced by length to mac,
+// returning HMAC value.
+func (s *integrityScratch) sumOf(mac hash.Hash, message []byte, length int) []byte {
+	copy(s.header[:], message[:messageHeaderSize])
+	bin.PutUint16(s.header[2:4], uint16(length)) //nolint:gosec // G115
+	writeOrPanic(mac, s.header[:])
+	writeOrPanic(mac, message[messageHeaderSize:])
+
+	return mac.Sum(s.sum[:0])
 }
 
 func (i MessageIntegrity) String() string {
@@ -58,10 +98,30 @@ const messageIntegritySize = 20
 // message, so MESSAGE-INTEGRITY attribute cannot be added.
 var ErrFingerprintBeforeIntegrity = errors.New("FINGERPRINT before MESSAGE-INTEGRITY attribute")
 
+// checkKey returns ErrLegacyHashesDisabled if HMAC-SHA1 is disabled, or
+// ErrUnsupportedPasswordAlgorithm for nil key, which NewLongTermIntegrity
+// returns if MD5 is not available.
+func (i MessageIntegrity) checkKey() error {
+	if !legacyHMACEnabled() {
+		return fmt.Errorf("%w: HMAC-SHA1 of MESSAGE-INTEGRITY", ErrLegacyHashesDisabled)
+	}
+	if i == nil && !md5Supported {
+		return fmt.Errorf("%w: MD5 in FIPS build", ErrUnsupportedPasswordAlgorithm)
+	}
+
+	return nil
+}
+
 // AddTo adds MESSAGE-INTEGRITY attribute to message.
 //
 // CPU costly, see BenchmarkMessageIntegrity_AddTo.
 func (i MessageIntegrity) AddTo(msg *Message) error {
+	if err := msg.checkMutable(); err != nil {
+		return err
+	}
+	if err := i.checkKey(); err != nil {
+		return err
+	}
 	for _, a := range msg.Attributes {
 		// Message should not contain FINGERPRINT attribute
 		// before MESSAGE-INTEGRITY.
@@ -71,20 +131,12 @@ func (i MessageIntegrity) AddTo(msg *Message) error {
 	}
 	// The text used as input to HMAC is the STUN message,
 	// including the header, up to and including the attribute preceding the
-	// MESSAGE-INTEGRITY attribute.
-	length := msg.Length
-	// Adjusting m.Length to contain MESSAGE-INTEGRITY TLV.
-	msg.Length += messageIntegritySize + attributeHeaderSize
-	msg.WriteLength()                                // writing length to m.Raw
-	v := newHMAC(i, msg.Raw, msg.Raw[len(msg.Raw):]) // calculating HMAC for adjusted m.Raw
-	msg.Length = length                              // changing m.Length back
-
-	// Copy hmac value to temporary variable to protect it from resetting
-	// while processing m.Add call.
-	vBuf := make([]byte, sha1.Size)
-	copy(vBuf, v)
-
-	msg.Add(AttrMessageIntegrity, vBuf)
+	// MESSAGE-INTEGRITY attribute, with length in header that includes
+	// MESSAGE-INTEGRITY TLV.
+	scratch := integrityScratchPool.Get().(*integrityScratch) //nolint:forcetypeassert
+	defer integrityScratchPool.Put(scratch)
+	length := int(msg.Length) + attributeHeaderSize + messageIntegritySize
+	msg.Add(AttrMessageIntegrity, scratch.compute(i, msg.Raw, length))
 
 	return nil
 }
@@ -96,35 +148,29 @@ var ErrIntegrityMismatch = errors.New("integrity check failed")
 //
 // CPU costly, see BenchmarkMessageIntegrity_Check.
 func (i MessageIntegrity) Check(msg *Message) error {
+	if err := i.checkKey(); err != nil {
+		return err
+	}
 	val, err := msg.Get(AttrMessageIntegrity)
 	if err != nil {
 		return err
 	}
 
-	// Adjusting length in header to match m.Raw that was
-	// used when computing HMAC.
-	var (
-		length         = msg.Length
-		afterIntegrity = false
-		sizeReduced    int
-	)
+	// HMAC is computed over attributes preceding MESSAGE-INTEGRITY, with
+	// length in header that does not include attributes after it, e.g.
+	// FINGERPRINT. Header with such length is written to scratch buffer,
+	// so msg.Raw is not modified.
+	start := messageHeaderSize // first byte of integrity attribute
 	for _, a := range msg.Attributes {
-		if afterIntegrity {
-			sizeReduced += nearestPaddedValueLength(int(a.Length))
-			sizeReduced += attributeHeaderSize
-		}
 		if a.Type == AttrMessageIntegrity {
-			afterIntegrity = true
+			break
 		}
+		start += attributeHeaderSize + nearestPaddedValueLength(int(a.Length))
 	}
-	msg.Length -= uint32(sizeReduced) //nolint:gosec // G115
-	msg.WriteLength()
-	// startOfHMAC should be first byte of integrity attribute.
-	startOfHMAC := messageHeaderSize + msg.Length - (attributeHeaderSize + messageIntegritySize)
-	b := msg.Raw[:startOfHMAC] // data before integrity attribute
-	expected := newHMAC(i, b, msg.Raw[len(msg.Raw):])
-	msg.Length = length
-	msg.WriteLength() // writing length back
+	scratch := integrityScratchPool.Get().(*integrityScratch) //nolint:forcetypeassert
+	defer integrityScratchPool.Put(scratch)
+	length := start - messageHeaderSize + attributeHeaderSize + messageIntegritySize
+	expected := scratch.compute(i, msg.Raw[:start], length)
 
 	return checkHMAC(val, expected)
 }
@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// NewShortTermIntegritySHA256 returns new MessageIntegritySHA256 with key
+// for short-term credentials. Password must be SASL-prepared.
+func NewShortTermIntegritySHA256(password string) MessageIntegritySHA256 {
+	return MessageIntegritySHA256(password)
+}
+
+// MessageIntegritySHA256 represents MESSAGE-INTEGRITY-SHA256 attribute,
+// HMAC-SHA256 of message. Key is the same as of MessageIntegrity, e.g.
+// LongTermCredentials.Key. Unlike MessageIntegrity, it is not affected by
+// SetLegacyHashes.
+//
+// RFC 8489 Section 14.6.
+type MessageIntegritySHA256 []byte
+
+func (i MessageIntegritySHA256) String() string {
+	return fmt.Sprintf("KEY: 0x%x", []byte(i))
+}
+
+const (
+	messageIntegritySHA256Size = sha256.Size
+	// Value can be truncated to 16 bytes, keeping multiple of 4 bytes.
+	messageIntegritySHA256MinSize = 16
+)
+
+// AddTo adds MESSAGE-INTEGRITY-SHA256 attribute with full HMAC value to
+// message. It can follow MESSAGE-INTEGRITY, but not FINGERPRINT.
+func (i MessageIntegritySHA256) AddTo(msg *Message) error {
+	if err := msg.checkMutable(); err != nil {
+		return err
+	}
+	for _, a := range msg.Attributes {
+		if a.Type == AttrFingerprint {
+			return ErrFingerprintBeforeIntegrity
+		}
+	}
+	scratch := integrityScratchPool.Get().(*integrityScratch) //nolint:forcetypeassert
+	defer integrityScratchPool.Put(scratch)
+	length := int(msg.Length) + attributeHeaderSize + messageIntegritySHA256Size
+	msg.Add(AttrMessageIntegritySHA256, scratch.computeSHA256(i, msg.Raw, length))
+
+	return nil
+}
+
+// Check checks MESSAGE-INTEGRITY-SHA256 attribute, which can be truncated
+// as RFC 8489 Section 14.6 allows. Returns ErrAttributeSizeInvalid if
+// value is shorter than 16 bytes or is not multiple of 4 bytes.
+func (i MessageIntegritySHA256) Check(msg *Message) error {
+	val, err := msg.Get(AttrMessageIntegritySHA256)
+	if err != nil {
+		return err
+	}
+	if len(val) < messageIntegritySHA256MinSize || len(val) > messageIntegritySHA256Size || len(val)%padding != 0 {
+		return ErrAttributeSizeInvalid
+	}
+	start := messageHeaderSize // first byte of integrity attribute
+	for _, a := range msg.Attributes {
+		if a.Type == AttrMessageIntegritySHA256 {
+			break
+		}
+		start += attributeHeaderSize + nearestPaddedValueLength(int(a.Length))
+	}
+	scratch := integrityScratchPool.Get().(*integrityScratch) //nolint:forcetypeassert
+	defer integrityScratchPool.Put(scratch)
+	length := start - messageHeaderSize + attributeHeaderSize + len(val)
+	expected := scratch.computeSHA256(i, msg.Raw[:start], length)
+
+	return checkHMAC(val, expected[:len(val)])
+}
+
+// Integrity is MessageIntegrity or MessageIntegritySHA256.
+type Integrity interface {
+	Setter
+	Checker
+}
+
+// IntegrityFor returns MessageIntegritySHA256 with key if m has
+// MESSAGE-INTEGRITY-SHA256, or MessageIntegrity otherwise, so integrity
+// of request can be checked and its response protected the same way, as
+// RFC 8489 Section 9.2.4 requires.
+func IntegrityFor(m *Message, key []byte) Integrity {
+	if m.Contains(AttrMessageIntegritySHA256) {
+		return MessageIntegritySHA256(key)
+	}
+
+	return MessageIntegrity(key)
+}
@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+func TestMessageIntegritySHA256(t *testing.T) {
+	integrity := NewShortTermIntegritySHA256("password")
+	m := MustBuild(TransactionID, BindingRequest, NewUsername("user"), integrity, Fingerprint)
+	decoded := new(Message)
+	if err := Decode(m.Raw, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := integrity.Check(decoded); err != nil {
+		t.Fatal(err)
+	}
+	v, _ := decoded.Get(AttrMessageIntegritySHA256)
+	if len(v) != sha256.Size {
+		t.Errorf("unexpected size: %d", len(v))
+	}
+	if err := NewShortTermIntegritySHA256("other").Check(decoded); err == nil {
+		t.Error("check with other key should fail")
+	}
+	if err := integrity.AddTo(decoded); !errors.Is(err, ErrFingerprintBeforeIntegrity) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := integrity.Check(MustBuild(TransactionID, BindingRequest)); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMessageIntegritySHA256_AfterIntegrity(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest,
+		NewShortTermIntegrity("password"), NewShortTermIntegritySHA256("password"),
+	)
+	if err := NewShortTermIntegrity("password").Check(m); err != nil {
+		t.Error(err)
+	}
+	if err := NewShortTermIntegritySHA256("password").Check(m); err != nil {
+		t.Error(err)
+	}
+}
+
+// buildTruncatedSHA256 returns message with MESSAGE-INTEGRITY-SHA256 that
+// is truncated to size bytes, computed with crypto/hmac.
+func buildTruncatedSHA256(t *testing.T, key []byte, size int) *Message {
+	t.Helper()
+	m := MustBuild(TransactionID, BindingRequest, NewUsername("user"))
+	m.Length += uint32(attributeHeaderSize + size) //nolint:gosec // G115
+	m.WriteLength()
+	mac := hmac.New(sha256.New, key)
+	mac.Write(m.Raw)                               //nolint:errcheck,gosec
+	m.Length -= uint32(attributeHeaderSize + size) //nolint:gosec // G115
+	m.Add(AttrMessageIntegritySHA256, mac.Sum(nil)[:size])
+
+	return m
+}
+
+func TestMessageIntegritySHA256_Truncated(t *testing.T) {
+	key := []byte("password")
+	for _, size := range []int{16, 20, 28, 32} {
+		if err := MessageIntegritySHA256(key).Check(buildTruncatedSHA256(t, key, size)); err != nil {
+			t.Errorf("%d: %v", size, err)
+		}
+	}
+	for _, size := range []int{4, 12, 18} {
+		err := MessageIntegritySHA256(key).Check(buildTruncatedSHA256(t, key, size))
+		if !errors.Is(err, ErrAttributeSizeInvalid) {
+			t.Errorf("%d: unexpected error: %v", size, err)
+		}
+	}
+}
+
+func TestIntegrityFor(t *testing.T) {
+	key := []byte("password")
+	m := MustBuild(TransactionID, BindingRequest, MessageIntegritySHA256(key))
+	if _, ok := IntegrityFor(m, key).(MessageIntegritySHA256); !ok {
+		t.Error("MESSAGE-INTEGRITY-SHA256 expected")
+	}
+	if err := IntegrityFor(m, key).Check(m); err != nil {
+		t.Error(err)
+	}
+	m = MustBuild(TransactionID, BindingRequest, MessageIntegrity(key))
+	if _, ok := IntegrityFor(m, key).(MessageIntegrity); !ok {
+		t.Error("MESSAGE-INTEGRITY expected")
+	}
+}
+
+func TestMessageIntegritySHA256_Allocs(t *testing.T) {
+	integrity := NewShortTermIntegritySHA256("password")
+	m := New()
+	addTo := func() {
+		m.Reset()
+		m.WriteHeader()
+		if err := integrity.AddTo(m); err != nil {
+			t.Error(err)
+		}
+	}
+	t.Run("AddTo", func(t *testing.T) {
+		testutil.ShouldNotAllocate(t, addTo)
+	})
+	addTo()
+	t.Run("Check", func(t *testing.T) {
+		testutil.ShouldNotAllocate(t, func() {
+			if err := integrity.Check(m); err != nil {
+				t.Error(err)
+			}
+		})
+	})
+}
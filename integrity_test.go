@@ -7,9 +7,12 @@ import (
 	"bytes"
 	"encoding/hex"
 	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
 )
 
 func TestMessageIntegrity_AddTo_Simple(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
 	integrity := NewLongTermIntegrity("user", "realm", "pass")
 	expected, err := hex.DecodeString("8493fbc53ba582fb4c044c456bdc40eb")
 	if err != nil {
@@ -91,6 +94,48 @@ func TestMessageIntegrityBeforeFingerprint(t *testing.T) {
 	}
 }
 
+func TestMessageIntegrity_Allocs(t *testing.T) {
+	integrity := NewShortTermIntegrity("password")
+	req := MustBuild(TransactionID, BindingRequest, NewUsername("user"), integrity, Fingerprint)
+	// Message is followed by unrelated data in buffer, like coalesced
+	// messages of stream transport, which should not be overwritten.
+	buf := append(append([]byte(nil), req.Raw...), "trailing data of another message"...)
+	m := &Message{Raw: buf[:len(req.Raw)]}
+	if err := m.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("Check", func(t *testing.T) {
+		testutil.ShouldNotAllocate(t, func() {
+			if err := integrity.Check(m); err != nil {
+				t.Fatal(err)
+			}
+		})
+	})
+	if err := integrity.Check(m); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.Raw, req.Raw) || string(buf[len(req.Raw):]) != "trailing data of another message" {
+		t.Error("buffer is modified by Check")
+	}
+	res := New()
+	res.Raw = make([]byte, 0, 256)
+	addTo := func() {
+		res.Reset()
+		res.SetType(BindingSuccess)
+		res.WriteHeader()
+		if err := integrity.AddTo(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Run("AddTo", func(t *testing.T) {
+		testutil.ShouldNotAllocate(t, addTo)
+	})
+	addTo()
+	if err := integrity.Check(res); err != nil {
+		t.Error(err)
+	}
+}
+
 func BenchmarkMessageIntegrity_AddTo(b *testing.B) {
 	m := new(Message)
 	integrity := NewShortTermIntegrity("password")
@@ -4,6 +4,7 @@
 package hmac
 
 import (
+	"crypto"
 	"crypto/sha1" //nolint:gosec
 	"crypto/sha256"
 	"hash"
@@ -85,11 +86,63 @@ func PutSHA256(h hash.Hash) {
 	hmacSHA256Pool.Put(hm)
 }
 
+// hmacPool is pool of HMACs of hash function.
+type hmacPool struct {
+	pool      *sync.Pool
+	size      int
+	blocksize int
+}
+
+// hmacPools are pools of hash functions, created on demand by Acquire.
+var hmacPools sync.Map //nolint:gochecknoglobals
+
+func getHMACPool(h crypto.Hash) *hmacPool {
+	if p, ok := hmacPools.Load(h); ok {
+		return p.(*hmacPool) //nolint:forcetypeassert
+	}
+	p := &hmacPool{pool: hmacSHA1Pool, size: sha1.Size, blocksize: sha1.BlockSize}
+	switch h { //nolint:exhaustive
+	case crypto.SHA1:
+	case crypto.SHA256:
+		p = &hmacPool{pool: hmacSHA256Pool, size: sha256.Size, blocksize: sha256.BlockSize}
+	default:
+		p = &hmacPool{size: h.Size(), blocksize: h.New().BlockSize()}
+		p.pool = &sync.Pool{
+			New: func() interface{} {
+				return New(h.New, make([]byte, p.blocksize))
+			},
+		}
+	}
+	actual, _ := hmacPools.LoadOrStore(h, p)
+
+	return actual.(*hmacPool) //nolint:forcetypeassert
+}
+
+// Acquire returns new HMAC of hash function h from pool, e.g. for
+// crypto.SHA224 or crypto.SHA512. Hash function must be linked into
+// binary, see crypto.Hash.Available.
+func Acquire(h crypto.Hash, key []byte) hash.Hash {
+	p := getHMACPool(h)
+	hm := p.pool.Get().(*hmac) //nolint:forcetypeassert
+	assertHMACSize(hm, p.size, p.blocksize)
+	hm.resetTo(key)
+
+	return hm
+}
+
+// Put puts HMAC that was acquired for hash function h to pool.
+func Put(h crypto.Hash, mac hash.Hash) {
+	p := getHMACPool(h)
+	hm := mac.(*hmac) //nolint:forcetypeassert
+	assertHMACSize(hm, p.size, p.blocksize)
+	p.pool.Put(hm)
+}
+
 // assertHMACSize panics if h.size != size or h.blocksize != blocksize.
 //
 // Put and Acquire functions are internal functions to project, so
 // checking it via such assert is optimal.
-func assertHMACSize(h *hmac, size, blocksize int) { //nolint:unparam
+func assertHMACSize(h *hmac, size, blocksize int) {
 	if h.Size() != size || h.BlockSize() != blocksize {
 		panic("BUG: hmac size invalid") //nolint
 	}
@@ -4,8 +4,11 @@
 package hmac
 
 import (
-	"crypto/sha1" //nolint:gosec
+	"crypto"
+	_ "crypto/md5" //nolint:gosec
+	"crypto/sha1"  //nolint:gosec
 	"crypto/sha256"
+	_ "crypto/sha512"
 	"fmt"
 	"testing"
 )
@@ -142,6 +145,43 @@ func TestHMACPool_SHA256(t *testing.T) { //nolint:dupl,cyclop
 	}
 }
 
+func TestHMACPool_Acquire(t *testing.T) {
+	hashes := []crypto.Hash{crypto.MD5, crypto.SHA1, crypto.SHA224, crypto.SHA256, crypto.SHA384, crypto.SHA512}
+	for i, tt := range hmacTests() {
+		for _, h := range hashes {
+			if h.Size() != tt.size || h.New().BlockSize() != tt.blocksize {
+				continue
+			}
+			for j := 0; j < 2; j++ {
+				// Second iteration uses HMAC from pool.
+				hsh := Acquire(h, tt.key)
+				if _, err := hsh.Write(tt.in); err != nil {
+					t.Fatal(err)
+				}
+				if sum := fmt.Sprintf("%x", hsh.Sum(nil)); sum != tt.out {
+					t.Errorf("test %d.%d (%s): have %s want %s", i, j, h, sum, tt.out)
+				}
+				Put(h, hsh)
+			}
+		}
+	}
+}
+
+func BenchmarkHMACSHA512_512_Pool(b *testing.B) {
+	key := make([]byte, 32)
+	buf := make([]byte, 512)
+	tBuf := make([]byte, 0, 512)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		h := Acquire(crypto.SHA512, key)
+		h.Write(buf) //nolint:errcheck,gosec
+		mac := h.Sum(tBuf)
+		buf[0] = mac[0]
+		Put(crypto.SHA512, h)
+	}
+}
+
 func TestAssertBlockSize(t *testing.T) {
 	t.Run("Positive", func(*testing.T) {
 		h := AcquireSHA1(make([]byte, 0, 1024))
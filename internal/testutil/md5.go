@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package testutil
+
+import "testing"
+
+// SkipWithoutMD5 skips test that derives long-term keys with MD5, which
+// is not available in builds with fips tag.
+func SkipWithoutMD5(t testing.TB) {
+	t.Helper()
+
+	if FIPS {
+		t.Skip("MD5 is not available with fips tag")
+	}
+}
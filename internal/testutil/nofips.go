@@ -0,0 +1,11 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !fips
+// +build !fips
+
+package testutil
+
+// FIPS reports if package is built with fips tag, where MD5 is not
+// available.
+const FIPS = false
@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// KeepAliveState is liveness state of server as observed by keepalive
+// Binding requests.
+type KeepAliveState byte
+
+// Possible values for KeepAliveState.
+const (
+	KeepAliveUnknown KeepAliveState = iota // no responses yet
+	KeepAliveAlive
+	KeepAliveDead
+)
+
+func (s KeepAliveState) String() string {
+	switch s {
+	case KeepAliveAlive:
+		return "alive"
+	case KeepAliveDead:
+		return "dead"
+	default:
+		return "unknown"
+	}
+}
+
+// defaultKeepAliveFailures is count of consecutive failed keepalive
+// transactions after which server is considered dead.
+const defaultKeepAliveFailures = 3
+
+// KeepAliveEvent is passed to KeepAliveHandler on liveness state or
+// reflexive address change.
+type KeepAliveEvent struct {
+	State          KeepAliveState
+	Address        XORMappedAddress // last observed reflexive address
+	AddressChanged bool             // Address differs from previous one
+	Failures       int              // consecutive failures
+}
+
+// KeepAliveHandler handles KeepAliveEvent.
+type KeepAliveHandler func(e KeepAliveEvent)
+
+// WithKeepAlive enables keepalive mode, where client sends Binding
+// request every interval, tracking liveness of server and changes of
+// reflexive address. See WithKeepAliveHandler.
+func WithKeepAlive(interval time.Duration) ClientOption {
+	return func(c *Client) {
+		c.keepAlive.interval = interval
+	}
+}
+
+// WithKeepAliveIndications makes keepalive mode send Binding indications
+// instead of requests. Indications are not answered, so liveness and
+// address are not tracked.
+func WithKeepAliveIndications() ClientOption {
+	return func(c *Client) {
+		c.keepAlive.indications = true
+	}
+}
+
+// WithKeepAliveFailures sets count of consecutive failed keepalive
+// requests after which server is considered dead, 3 by default.
+func WithKeepAliveFailures(n int) ClientOption {
+	return func(c *Client) {
+		c.keepAlive.maxFailures = n
+	}
+}
+
+// WithKeepAliveHandler sets handler that is called when liveness state
+// or reflexive address changes.
+func WithKeepAliveHandler(h KeepAliveHandler) ClientOption {
+	return func(c *Client) {
+		c.keepAlive.handler = h
+	}
+}
+
+type keepAlive struct {
+	interval    time.Duration
+	indications bool
+	maxFailures int
+	handler     KeepAliveHandler
+
+	mux        sync.Mutex
+	state      KeepAliveState
+	failures   int
+	address    XORMappedAddress
+	hasAddress bool
+}
+
+// handle updates state with result of keepalive transaction, calling
+// handler on changes.
+func (k *keepAlive) handle(e Event) {
+	k.mux.Lock()
+	var event KeepAliveEvent
+	changed := false
+	if e.Error != nil {
+		k.failures++
+		if k.failures >= k.maxFailures && k.state != KeepAliveDead {
+			k.state, changed = KeepAliveDead, true
+		}
+	} else {
+		k.failures = 0
+		if k.state != KeepAliveAlive {
+			k.state, changed = KeepAliveAlive, true
+		}
+		var addr XORMappedAddress
+		if getMappedAddress(e.Message, &addr) == nil {
+			if k.hasAddress && (!addr.IP.Equal(k.address.IP) || addr.Port != k.address.Port) {
+				event.AddressChanged, changed = true, true
+			}
+			k.address, k.hasAddress = addr, true
+		}
+	}
+	event.State, event.Address, event.Failures = k.state, k.address, k.failures
+	h := k.handler
+	k.mux.Unlock()
+	if changed && h != nil {
+		h(event)
+	}
+}
+
+// KeepAliveState returns current liveness state of server.
+func (c *Client) KeepAliveState() KeepAliveState {
+	c.keepAlive.mux.Lock()
+	defer c.keepAlive.mux.Unlock()
+
+	return c.keepAlive.state
+}
+
+// MappedAddress returns last reflexive address observed by keepalive
+// requests, if any.
+func (c *Client) MappedAddress() (XORMappedAddress, bool) {
+	c.keepAlive.mux.Lock()
+	defer c.keepAlive.mux.Unlock()
+
+	return c.keepAlive.address, c.keepAlive.hasAddress
+}
+
+// keepAliveUntilClosed sends keepalive messages every interval until
+// client is closed.
+func (c *Client) keepAliveUntilClosed() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.keepAlive.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.close:
+			return
+		case <-ticker.C:
+		}
+		t := BindingRequest
+		if c.keepAlive.indications {
+			t = NewType(MethodBinding, ClassIndication)
+		}
+		m, err := Build(TransactionIDFrom(c.ids), t)
+		if err != nil {
+			c.log.Warnf("client: failed to build keepalive message: %v", err)
+
+			continue
+		}
+		if c.keepAlive.indications {
+			_ = c.Indicate(m)
+
+			continue
+		}
+		err = c.Start(m, c.keepAlive.handle)
+		if err != nil && !errors.Is(err, ErrClientClosed) {
+			c.keepAlive.handle(Event{Error: err})
+		}
+	}
+}
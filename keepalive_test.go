@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestKeepAlive_handle(t *testing.T) {
+	var events []KeepAliveEvent
+	k := &keepAlive{
+		maxFailures: 2,
+		handler: func(e KeepAliveEvent) {
+			events = append(events, e)
+		},
+	}
+	response := func(port int) Event {
+		return Event{Message: MustBuild(TransactionID, BindingSuccess, &XORMappedAddress{
+			IP: net.IPv4(192, 0, 2, 1), Port: port,
+		})}
+	}
+	k.handle(response(1000))
+	k.handle(response(1000))
+	k.handle(Event{Error: ErrTransactionTimeOut})
+	k.handle(Event{Error: ErrTransactionTimeOut})
+	k.handle(Event{Error: ErrTransactionTimeOut})
+	k.handle(response(2000))
+	expected := []KeepAliveEvent{
+		{State: KeepAliveAlive},
+		{State: KeepAliveDead, Failures: 2},
+		{State: KeepAliveAlive, AddressChanged: true},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	for i, e := range expected {
+		got := events[i]
+		if got.State != e.State || got.AddressChanged != e.AddressChanged || got.Failures != e.Failures {
+			t.Errorf("events[%d]: %+v, expected %+v", i, got, e)
+		}
+	}
+	if events[2].Address.Port != 2000 {
+		t.Errorf("unexpected address: %s", events[2].Address)
+	}
+}
+
+func TestKeepAliveState_String(t *testing.T) {
+	for s, str := range map[KeepAliveState]string{
+		KeepAliveUnknown: "unknown",
+		KeepAliveAlive:   "alive",
+		KeepAliveDead:    "dead",
+	} {
+		if s.String() != str {
+			t.Errorf("%q != %q", s, str)
+		}
+	}
+}
+
+func TestClient_KeepAlive(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m := new(Message)
+			if Decode(buf[:n], m) != nil {
+				continue
+			}
+			udpAddr := addr.(*net.UDPAddr) //nolint:forcetypeassert
+			res := MustBuild(m, BindingSuccess, &XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port})
+			_, _ = server.WriteTo(res.Raw, addr)
+		}
+	}()
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	alive := make(chan KeepAliveEvent, 1)
+	client, err := NewClient(conn,
+		WithKeepAlive(time.Millisecond*10),
+		WithKeepAliveHandler(func(e KeepAliveEvent) {
+			alive <- e
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-alive:
+		if e.State != KeepAliveAlive {
+			t.Errorf("unexpected state: %s", e.State)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+	if client.KeepAliveState() != KeepAliveAlive {
+		t.Errorf("unexpected state: %s", client.KeepAliveState())
+	}
+	addr, ok := client.MappedAddress()
+	if !ok || addr.Port != conn.LocalAddr().(*net.UDPAddr).Port { //nolint:forcetypeassert
+		t.Errorf("unexpected mapped address: %s", addr)
+	}
+	if err = client.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient_KeepAliveTransactionIDSource(t *testing.T) {
+	source := bytes.Repeat([]byte{3}, TransactionIDSize)
+	written := make(chan [TransactionIDSize]byte, 1)
+	conn := &testConnection{
+		write: func(b []byte) (int, error) {
+			m := new(Message)
+			if err := Decode(b, m); err == nil {
+				select {
+				case written <- m.TransactionID:
+				default:
+				}
+			}
+
+			return len(b), nil
+		},
+	}
+	client, err := NewClient(conn,
+		WithKeepAlive(time.Millisecond*10),
+		WithKeepAliveIndications(),
+		WithTransactionIDSource(bytes.NewReader(source)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-written:
+		if !bytes.Equal(id[:], source) {
+			t.Errorf("unexpected id: %x", id)
+		}
+	case <-time.After(time.Second * 5):
+		t.Error("timed out")
+	}
+	if err = client.Close(); err != nil {
+		t.Error(err)
+	}
+}
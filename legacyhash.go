@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// legacyHashesSetting is GODEBUG setting that disables legacy hashes
+// when set to 0, e.g. GODEBUG=stunlegacyhash=0.
+const legacyHashesSetting = "stunlegacyhash"
+
+// legacyHashesDisabled is 1 if legacy hashes are disabled at run time.
+var legacyHashesDisabled = godebugDisabled(os.Getenv("GODEBUG"), legacyHashesSetting) //nolint:gochecknoglobals
+
+// SetLegacyHashes enables or disables legacy hashes at run time, for
+// deployments that allow only SHA-256: MD5 key derivation of long-term
+// credentials and HMAC-SHA1 of MESSAGE-INTEGRITY.
+//
+// With legacy hashes disabled, NewLongTermIntegrityAlgorithm,
+// LongTermCredentials and LongTermAuth reject MD5 and zero algorithm with
+// ErrUnsupportedPasswordAlgorithm, NegotiatePasswordAlgorithm selects only
+// SHA-256 by default, and AddTo and Check of MessageIntegrity return
+// ErrLegacyHashesDisabled, so only MessageIntegritySHA256 can protect
+// messages. Note that this also rules out short-term credentials of ICE,
+// which are defined with MESSAGE-INTEGRITY only.
+//
+// Legacy hashes are enabled by default, unless GODEBUG environment
+// variable has stunlegacyhash=0. MD5 can not be enabled in builds with
+// fips tag, see LegacyHashesEnabled.
+func SetLegacyHashes(enabled bool) {
+	var disabled int32
+	if !enabled {
+		disabled = 1
+	}
+	atomic.StoreInt32(&legacyHashesDisabled, disabled)
+}
+
+// LegacyHashesEnabled reports whether MD5 key derivation of long-term
+// credentials is allowed, see SetLegacyHashes. It is always false in
+// builds with fips tag, where HMAC-SHA1 is still allowed unless disabled
+// by SetLegacyHashes.
+func LegacyHashesEnabled() bool {
+	return md5Supported && legacyHMACEnabled()
+}
+
+// legacyHMACEnabled reports whether HMAC-SHA1 of MESSAGE-INTEGRITY is
+// allowed, see SetLegacyHashes.
+func legacyHMACEnabled() bool {
+	return atomic.LoadInt32(&legacyHashesDisabled) == 0
+}
+
+// ErrLegacyHashesDisabled means that MESSAGE-INTEGRITY can not be added
+// or checked because legacy hashes are disabled by SetLegacyHashes.
+var ErrLegacyHashesDisabled = errors.New("legacy hashes are disabled")
+
+// godebugDisabled returns 1 if godebug has key=0 setting, the last
+// setting of key taking precedence.
+func godebugDisabled(godebug, key string) int32 {
+	var disabled int32
+	for _, setting := range strings.Split(godebug, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(setting), "=")
+		if k == key {
+			disabled = 0
+			if v == "0" {
+				disabled = 1
+			}
+		}
+	}
+
+	return disabled
+}
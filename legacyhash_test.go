@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGodebugDisabled(t *testing.T) {
+	for _, tc := range []struct {
+		godebug  string
+		disabled int32
+	}{
+		{"", 0},
+		{"stunlegacyhash=0", 1},
+		{"http2client=0, stunlegacyhash=0", 1},
+		{"stunlegacyhash=0,stunlegacyhash=1", 0},
+		{"stunlegacyhash=1", 0},
+		{"xstunlegacyhash=0", 0},
+	} {
+		if got := godebugDisabled(tc.godebug, legacyHashesSetting); got != tc.disabled {
+			t.Errorf("%q: unexpected value %d", tc.godebug, got)
+		}
+	}
+}
+
+func TestSetLegacyHashes(t *testing.T) {
+	if !LegacyHashesEnabled() {
+		t.Skip("legacy hashes are disabled")
+	}
+	prev := LegacyHashesEnabled()
+	SetLegacyHashes(false)
+	t.Cleanup(func() { SetLegacyHashes(prev) })
+	if LegacyHashesEnabled() {
+		t.Fatal("legacy hashes should be disabled")
+	}
+	if _, err := NewLongTermIntegrityAlgorithm("user", "realm", "pass", PasswordAlgorithmMD5); !errors.Is(
+		err, ErrUnsupportedPasswordAlgorithm,
+	) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Build(BindingRequest, LongTermAuth(Credentials{Username: "user"}, "realm", "nonce", 0)); !errors.Is(
+		err, ErrUnsupportedPasswordAlgorithm,
+	) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	creds := NewLongTermCredentials("user", "realm", "pass")
+	if _, err := creds.Key(PasswordAlgorithmMD5); !errors.Is(err, ErrUnsupportedPasswordAlgorithm) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if algorithms := supportedPasswordAlgorithms(); len(algorithms) != 1 || algorithms[0] != PasswordAlgorithmSHA256 {
+		t.Errorf("unexpected algorithms: %v", algorithms)
+	}
+	integrity := NewShortTermIntegrity("password")
+	if _, err := Build(BindingRequest, integrity); !errors.Is(err, ErrLegacyHashesDisabled) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	m, err := Build(BindingRequest, NewShortTermIntegritySHA256("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = creds.Check(m, PasswordAlgorithmSHA256); err == nil || errors.Is(err, ErrLegacyHashesDisabled) {
+		t.Errorf("MESSAGE-INTEGRITY-SHA256 with wrong key should fail: %v", err)
+	}
+	SetLegacyHashes(true)
+	m = MustBuild(BindingRequest, integrity)
+	SetLegacyHashes(false)
+	if err = integrity.Check(m); !errors.Is(err, ErrLegacyHashesDisabled) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	SetLegacyHashes(true)
+	if err = integrity.Check(m); err != nil {
+		t.Error(err)
+	}
+	if _, err := creds.Key(PasswordAlgorithmMD5); err != nil {
+		t.Error(err)
+	}
+}
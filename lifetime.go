@@ -0,0 +1,199 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// BindingLifetimeOptions configures DiscoverBindingLifetime.
+type BindingLifetimeOptions struct {
+	// Server is address of STUN server that client is connected to. It
+	// must support RESPONSE-PORT attribute.
+	Server net.Addr
+	// ListenPacket opens probe socket. Defaults to listening UDP on
+	// random port.
+	ListenPacket func() (net.PacketConn, error)
+	// Min and Max are bounds of lifetime search, 1 second and 5 minutes
+	// by default.
+	Min time.Duration
+	Max time.Duration
+	// Precision is the difference of estimate bounds that stops the
+	// search, 5 seconds by default.
+	Precision time.Duration
+	// Timeout is time to wait for each response, 1 second by default.
+	Timeout time.Duration
+	// Attempts is count of probe requests sent after idle period, 3 by
+	// default.
+	Attempts int
+}
+
+// Defaults for BindingLifetimeOptions.
+const (
+	defaultLifetimeMin       = time.Second
+	defaultLifetimeMax       = time.Minute * 5
+	defaultLifetimePrecision = time.Second * 5
+	defaultLifetimeTimeout   = time.Second
+	defaultLifetimeAttempts  = 3
+)
+
+func (o *BindingLifetimeOptions) setDefaults() {
+	if o.ListenPacket == nil {
+		o.ListenPacket = func() (net.PacketConn, error) {
+			return net.ListenPacket("udp", ":0") //nolint:noctx
+		}
+	}
+	if o.Min <= 0 {
+		o.Min = defaultLifetimeMin
+	}
+	if o.Max <= 0 {
+		o.Max = defaultLifetimeMax
+	}
+	if o.Precision <= 0 {
+		o.Precision = defaultLifetimePrecision
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultLifetimeTimeout
+	}
+	if o.Attempts <= 0 {
+		o.Attempts = defaultLifetimeAttempts
+	}
+}
+
+// BindingLifetime is estimate of NAT UDP binding timeout: binding
+// survived Min of inactivity and expired after Max. Zero Max means that
+// binding did not expire during search.
+type BindingLifetime struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// ErrNoServerAddress means that BindingLifetimeOptions.Server is not set.
+var ErrNoServerAddress = errors.New("server address is not set")
+
+// DiscoverBindingLifetime estimates NAT UDP binding lifetime as
+// described in RFC 5780 Section 4.6, using binary search between
+// opts.Min and opts.Max.
+//
+// Each probe creates binding from new socket X, waits for idle period
+// and then sends Binding request with RESPONSE-PORT of X binding via
+// client, which is connected to the same server from another socket Y.
+// Binding is alive if X receives response.
+//
+// Discovery takes several idle periods, use ctx to cancel it.
+func DiscoverBindingLifetime(ctx context.Context, client *Client, opts BindingLifetimeOptions) (BindingLifetime, error) {
+	if opts.Server == nil {
+		return BindingLifetime{}, ErrNoServerAddress
+	}
+	opts.setDefaults()
+	alive, err := probeBinding(ctx, client, &opts, opts.Min)
+	if err != nil || !alive {
+		return BindingLifetime{Max: opts.Min}, err
+	}
+	result := BindingLifetime{Min: opts.Min, Max: opts.Max}
+	expired := false
+	for result.Max-result.Min > opts.Precision {
+		idle := result.Min + (result.Max-result.Min)/2
+		if alive, err = probeBinding(ctx, client, &opts, idle); err != nil {
+			return result, err
+		}
+		if alive {
+			result.Min = idle
+		} else {
+			result.Max, expired = idle, true
+		}
+	}
+	if expired {
+		return result, nil
+	}
+	if alive, err = probeBinding(ctx, client, &opts, opts.Max); err != nil {
+		return result, err
+	}
+	if alive {
+		return BindingLifetime{Min: opts.Max}, nil
+	}
+
+	return result, nil
+}
+
+// probeBinding reports whether new binding survives idle period.
+func probeBinding(ctx context.Context, client *Client, opts *BindingLifetimeOptions, idle time.Duration) (bool, error) {
+	conn, err := opts.ListenPacket()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close() //nolint:errcheck
+	var (
+		request = MustBuild(TransactionID, BindingRequest)
+		mapped  XORMappedAddress
+		found   bool
+	)
+	for i := 0; i < opts.Attempts && !found; i++ {
+		if _, err = conn.WriteTo(request.Raw, opts.Server); err != nil {
+			return false, err
+		}
+		var response *Message
+		if response, err = waitResponse(conn, request.TransactionID, opts.Timeout); err != nil {
+			return false, err
+		}
+		found = response != nil && getMappedAddress(response, &mapped) == nil
+	}
+	if !found {
+		return false, ErrTransactionTimeOut
+	}
+	if err = lifetimeSleep(ctx, idle); err != nil {
+		return false, err
+	}
+	probe := MustBuild(TransactionID, BindingRequest, ResponsePort(mapped.Port))
+	for i := 0; i < opts.Attempts; i++ {
+		if err = client.Start(probe, nil); err != nil {
+			return false, err
+		}
+		response, err := waitResponse(conn, probe.TransactionID, opts.Timeout)
+		if err != nil || response != nil {
+			return response != nil, err
+		}
+	}
+
+	return false, nil
+}
+
+// lifetimeSleep waits for d or until ctx is done. It is variable so
+// tests can emulate idle periods without waiting.
+var lifetimeSleep = func(ctx context.Context, d time.Duration) error { //nolint:gochecknoglobals
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// waitResponse reads from conn until message with transaction id is
+// received, returning nil message on timeout.
+func waitResponse(conn net.PacketConn, id [TransactionIDSize]byte, timeout time.Duration) (*Message, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, nil //nolint:nilnil
+		}
+		if err != nil {
+			return nil, err
+		}
+		m := new(Message)
+		if Decode(buf[:n], m) == nil && m.TransactionID == id {
+			return m, nil
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// natEmulator emulates STUN server behind NAT that expires bindings
+// after lifetime of inactivity. Probe sockets and client connection are
+// in-memory and time is virtual, advanced by sleep, to make test
+// independent of scheduling.
+type natEmulator struct {
+	lifetime time.Duration
+	server   net.Addr
+
+	mux      sync.Mutex
+	now      time.Duration
+	nextPort int
+	lastSeen map[int]time.Duration
+	conns    map[int]*natProbeConn
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newNATEmulator(lifetime time.Duration) *natEmulator {
+	return &natEmulator{
+		lifetime: lifetime,
+		server:   &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 3478},
+		nextPort: 40000,
+		lastSeen: make(map[int]time.Duration),
+		conns:    make(map[int]*natProbeConn),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (e *natEmulator) sleep(ctx context.Context, d time.Duration) error {
+	e.mux.Lock()
+	e.now += d
+	e.mux.Unlock()
+
+	return ctx.Err()
+}
+
+func (e *natEmulator) ListenPacket() (net.PacketConn, error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.nextPort++
+	conn := &natProbeConn{
+		nat:  e,
+		addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: e.nextPort},
+		in:   make(chan []byte, 10),
+	}
+	e.conns[e.nextPort] = conn
+
+	return conn, nil
+}
+
+// Write handles request from client socket.
+func (e *natEmulator) Write(b []byte) (int, error) {
+	m := new(Message)
+	if err := Decode(b, m); err != nil {
+		return 0, err
+	}
+	var port ResponsePort
+	if err := port.GetFrom(m); err != nil {
+		return 0, err
+	}
+	e.mux.Lock()
+	seen, ok := e.lastSeen[int(port)]
+	alive := ok && e.now-seen < e.lifetime
+	conn := e.conns[int(port)]
+	e.mux.Unlock()
+	if alive {
+		conn.in <- MustBuild(m, BindingSuccess).Raw
+	}
+
+	return len(b), nil
+}
+
+func (e *natEmulator) Read([]byte) (int, error) {
+	<-e.closed
+
+	return 0, io.EOF
+}
+
+func (e *natEmulator) Close() error {
+	e.once.Do(func() { close(e.closed) })
+
+	return nil
+}
+
+type natProbeConn struct {
+	nat      *natEmulator
+	addr     *net.UDPAddr
+	in       chan []byte
+	deadline time.Time
+}
+
+func (c *natProbeConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	timer := time.NewTimer(time.Until(c.deadline))
+	defer timer.Stop()
+	select {
+	case p := <-c.in:
+		return copy(b, p), c.nat.server, nil
+	case <-timer.C:
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+func (c *natProbeConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	m := new(Message)
+	if err := Decode(b, m); err != nil {
+		return 0, err
+	}
+	c.nat.mux.Lock()
+	c.nat.lastSeen[c.addr.Port] = c.nat.now
+	c.nat.mux.Unlock()
+	c.in <- MustBuild(m, BindingSuccess, &XORMappedAddress{IP: c.addr.IP, Port: c.addr.Port}).Raw
+
+	return len(b), nil
+}
+
+func (c *natProbeConn) Close() error {
+	return nil
+}
+
+func (c *natProbeConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+func (c *natProbeConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *natProbeConn) SetReadDeadline(t time.Time) error {
+	c.deadline = t
+
+	return nil
+}
+
+func (c *natProbeConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func TestDiscoverBindingLifetime(t *testing.T) {
+	const lifetime = time.Second * 30
+	nat := newNATEmulator(lifetime)
+	defer func(sleep func(context.Context, time.Duration) error) {
+		lifetimeSleep = sleep
+	}(lifetimeSleep)
+	lifetimeSleep = nat.sleep
+	client, err := NewClient(nat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close() //nolint:errcheck
+	opts := BindingLifetimeOptions{
+		Server:       nat.server,
+		ListenPacket: nat.ListenPacket,
+		Min:          time.Second,
+		Max:          time.Minute,
+		Precision:    time.Second,
+		Timeout:      time.Millisecond * 10,
+		Attempts:     2,
+	}
+	result, err := DiscoverBindingLifetime(context.Background(), client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Min >= lifetime || result.Max < lifetime || result.Max-result.Min > opts.Precision {
+		t.Errorf("unexpected estimate: %+v", result)
+	}
+
+	t.Run("NotExpired", func(t *testing.T) {
+		opts.Max = time.Second * 20
+		result, err := DiscoverBindingLifetime(context.Background(), client, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Min != opts.Max || result.Max != 0 {
+			t.Errorf("unexpected estimate: %+v", result)
+		}
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := DiscoverBindingLifetime(ctx, client, opts); !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("NoServer", func(t *testing.T) {
+		if _, err := DiscoverBindingLifetime(context.Background(), client, BindingLifetimeOptions{}); !errors.Is(err, ErrNoServerAddress) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,20 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !fips
+// +build !fips
+
+package stun
+
+import "crypto/md5" //nolint:gosec
+
+// md5Supported reports whether MD5 key derivation of long-term credentials
+// is compiled in, which is not the case in builds with fips tag.
+const md5Supported = true
+
+// md5Key returns MD5 hash of long-term key.
+func md5Key(k string) (MessageIntegrity, error) {
+	sum := md5.Sum([]byte(k)) //nolint:gosec
+
+	return MessageIntegrity(sum[:]), nil
+}
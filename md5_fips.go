@@ -0,0 +1,19 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build fips
+// +build fips
+
+package stun
+
+import "fmt"
+
+// md5Supported reports whether MD5 key derivation of long-term credentials
+// is compiled in. MD5 is not approved by FIPS 140, so only SHA-256 keys are
+// derived in builds with fips tag.
+const md5Supported = false
+
+// md5Key returns ErrUnsupportedPasswordAlgorithm, as MD5 is not allowed.
+func md5Key(string) (MessageIntegrity, error) {
+	return nil, fmt.Errorf("%w: MD5 in FIPS build", ErrUnsupportedPasswordAlgorithm)
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build fips
+// +build fips
+
+package stun
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFIPS_MD5NotAllowed(t *testing.T) {
+	if _, err := NewLongTermIntegrityAlgorithm("user", "realm", "pass", PasswordAlgorithmMD5); !errors.Is(
+		err, ErrUnsupportedPasswordAlgorithm,
+	) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	creds := NewLongTermCredentials("user", "realm", "pass")
+	if _, err := creds.Key(0); !errors.Is(err, ErrUnsupportedPasswordAlgorithm) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := creds.Key(PasswordAlgorithmSHA256); err != nil {
+		t.Error(err)
+	}
+	if err := creds.Auth("nonce", 0).AddTo(New()); !errors.Is(err, ErrUnsupportedPasswordAlgorithm) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if algorithms := supportedPasswordAlgorithms(); len(algorithms) != 1 || algorithms[0] != PasswordAlgorithmSHA256 {
+		t.Errorf("unexpected algorithms: %v", algorithms)
+	}
+}
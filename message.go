@@ -4,11 +4,12 @@
 package stun
 
 import (
-	"crypto/rand"
+	"bytes"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 )
 
 const (
@@ -28,14 +29,22 @@ const (
 	TransactionIDSize = 12 // 96 bit
 )
 
-// NewTransactionID returns new random transaction ID using crypto/rand
-// as source.
+// NewTransactionID returns new random transaction ID read from source
+// that is set by SetRandSource, crypto/rand by default.
 func NewTransactionID() (b [TransactionIDSize]byte) {
-	readFullOrPanic(rand.Reader, b[:])
+	readFullOrPanic(randReader(), b[:])
 
 	return b
 }
 
+// NewTransactionIDFrom returns new transaction ID read from r, e.g. from
+// deterministic source in fuzzing or reproducible tests.
+func NewTransactionIDFrom(r io.Reader) (b [TransactionIDSize]byte, err error) {
+	_, err = io.ReadFull(r, b[:])
+
+	return b, err
+}
+
 // IsMessage returns true if b looks like STUN message.
 // Useful for multiplexing. IsMessage does not guarantee
 // that decoding will be successful.
@@ -60,23 +69,54 @@ func Decode(data []byte, m *Message) error {
 	if m == nil {
 		return ErrDecodeToNil
 	}
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
 	m.Raw = append(m.Raw[:0], data...)
 
 	return m.Decode()
 }
 
+// DecodeMessage decodes Message from buf to m without copying: m.Raw is
+// set to buf, so attribute values of m point into buf and are valid only
+// until buf is modified. Decoding does not allocate if m.Attributes has
+// enough capacity, e.g. if m is reused.
+func DecodeMessage(buf []byte, m *Message) error {
+	if m == nil {
+		return ErrDecodeToNil
+	}
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	m.Raw = buf
+
+	return m.Decode()
+}
+
 // Message represents a single STUN packet. It uses aggressive internal
 // buffering to enable zero-allocation encoding and decoding,
 // so there are some usage constraints:
 //
 //	Message, its fields, results of m.Get or any attribute a.GetFrom
 //	are valid only until Message.Raw is not modified.
+//
+// Message is not goroutine-safe, but it can be shared between goroutines
+// for reading after Freeze.
 type Message struct {
 	Type          MessageType
 	Length        uint32 // len(Raw) not including header
 	TransactionID [TransactionIDSize]byte
 	Attributes    Attributes
 	Raw           []byte
+
+	index  attrIndex // see BuildIndex
+	frozen bool      // see Freeze
+}
+
+// AppendTo appends encoded message to buf and returns the extended buffer.
+// It does not allocate if buf has enough capacity.
+func (m *Message) AppendTo(buf []byte) []byte {
+	return append(buf, m.Raw...)
 }
 
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
@@ -92,6 +132,9 @@ func (m Message) MarshalBinary() (data []byte, err error) {
 // UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
 func (m *Message) UnmarshalBinary(data []byte) error {
 	// We can't retain data, copy is expected by interface contract.
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
 	m.Raw = append(m.Raw[:0], data...)
 
 	return m.Decode()
@@ -111,16 +154,22 @@ func (m *Message) GobDecode(data []byte) error {
 //
 // Implements Setter to aid in crafting responses.
 func (m *Message) AddTo(b *Message) error {
+	if err := b.checkMutable(); err != nil {
+		return err
+	}
 	b.TransactionID = m.TransactionID
 	b.WriteTransactionID()
 
 	return nil
 }
 
-// NewTransactionID sets m.TransactionID to random value from crypto/rand
-// and returns error if any.
+// NewTransactionID sets m.TransactionID to random value from source that
+// is set by SetRandSource and returns error if any.
 func (m *Message) NewTransactionID() error {
-	_, err := io.ReadFull(rand.Reader, m.TransactionID[:])
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(randReader(), m.TransactionID[:])
 	if err == nil {
 		m.WriteTransactionID()
 	}
@@ -140,9 +189,11 @@ func (m *Message) String() string {
 
 // Reset resets Message, attributes and underlying buffer length.
 func (m *Message) Reset() {
+	m.mustBeMutable()
 	m.Raw = m.Raw[:0]
 	m.Length = 0
 	m.Attributes = m.Attributes[:0]
+	m.invalidateIndex()
 }
 
 // grow ensures that internal buffer has n length.
@@ -163,6 +214,7 @@ func (m *Message) grow(n int) {
 // Value of attribute is copied to internal buffer so
 // it is safe to reuse v.
 func (m *Message) Add(attrType AttrType, val []byte) {
+	m.mustBeMutable()
 	// Allocating buffer for TLV (type-length-value).
 	// T = t, L = len(v), V = v.
 	// m.Raw will look like:
@@ -213,7 +265,104 @@ func (m *Message) Add(attrType AttrType, val []byte) {
 		m.Length += uint32(bytesToAdd) // rendering length change
 	}
 	m.Attributes = append(m.Attributes, attr)
+	m.invalidateIndex()
+	m.WriteLength()
+}
+
+// Delete removes all attributes of type t from m, rewriting m.Raw and
+// m.Length. Returns ErrAttributeNotFound if there is no such attribute.
+//
+// FINGERPRINT and MESSAGE-INTEGRITY are not updated, so they should be
+// deleted and added again if present. Not goroutine-safe.
+func (m *Message) Delete(t AttrType) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	found := false
+	for i := 0; i < len(m.Attributes); {
+		if m.Attributes[i].Type != t {
+			i++
+
+			continue
+		}
+		m.splice(i, nil, true)
+		found = true
+	}
+	if !found {
+		return ErrAttributeNotFound
+	}
+
+	return nil
+}
+
+// Replace sets value of first attribute of type t to v, rewriting m.Raw
+// and m.Length, e.g. to update XOR-MAPPED-ADDRESS without building the
+// whole message again. Returns ErrAttributeNotFound if there is no such
+// attribute.
+//
+// Value is copied, so it is safe to reuse v. See Delete for integrity
+// attributes. Not goroutine-safe.
+func (m *Message) Replace(t AttrType, v []byte) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	for i := range m.Attributes {
+		if m.Attributes[i].Type == t {
+			m.splice(i, v, false)
+
+			return nil
+		}
+	}
+
+	return ErrAttributeNotFound
+}
+
+// splice removes i-th attribute of m or replaces its value with v,
+// moving following attributes in m.Raw.
+func (m *Message) splice(i int, v []byte, remove bool) {
+	offset := messageHeaderSize
+	for _, a := range m.Attributes[:i] {
+		offset += attributeHeaderSize + nearestPaddedValueLength(len(a.Value))
+	}
+	var (
+		oldEnd = offset + attributeHeaderSize + nearestPaddedValueLength(len(m.Attributes[i].Value))
+		newEnd = offset
+	)
+	if !remove {
+		newEnd += attributeHeaderSize + nearestPaddedValueLength(len(v))
+		// Value can alias m.Raw that is moved below.
+		buff := bufferPool.Get().(*buffer) //nolint:forcetypeassert
+		defer bufferPool.Put(buff)
+		buff.buf = append(buff.buf[:0], v...)
+		v = buff.buf
+	}
+	tail := len(m.Raw) - oldEnd
+	m.grow(newEnd + tail)
+	copy(m.Raw[newEnd:], m.Raw[oldEnd:oldEnd+tail])
+	m.Raw = m.Raw[:newEnd+tail]
+	if remove {
+		m.Attributes = append(m.Attributes[:i], m.Attributes[i+1:]...)
+		m.invalidateIndex()
+	} else {
+		attr := &m.Attributes[i]
+		attr.Length = uint16(len(v)) //nolint:gosec // G115
+		bin.PutUint16(m.Raw[offset:], attr.Type.Value())
+		bin.PutUint16(m.Raw[offset+2:], attr.Length)
+		value := m.Raw[offset+attributeHeaderSize : newEnd]
+		for j := copy(value, v); j < len(value); j++ {
+			value[j] = 0 // padding
+		}
+	}
+	m.Length = uint32(len(m.Raw) - messageHeaderSize) //nolint:gosec // G115
 	m.WriteLength()
+	// Values are re-sliced, as m.Raw can be moved or reallocated.
+	offset = messageHeaderSize
+	for j := range m.Attributes {
+		a := &m.Attributes[j]
+		start := offset + attributeHeaderSize
+		a.Value = m.Raw[start : start+int(a.Length)]
+		offset = start + nearestPaddedValueLength(int(a.Length))
+	}
 }
 
 func attrSliceEqual(a, b Attributes) bool {
@@ -282,14 +431,86 @@ func (m *Message) Equal(msg *Message) bool {
 	return true
 }
 
+// EqualOptions relaxes message comparison of Message.EqualCanonical.
+type EqualOptions struct {
+	IgnoreOrder       bool // attributes can be in any order
+	IgnorePadding     bool // padding bytes of attributes in Raw are not compared
+	IgnoreSoftware    bool // SOFTWARE attribute is skipped
+	IgnoreFingerprint bool // FINGERPRINT attribute is skipped
+}
+
+// canonicalAttr is attribute with its padding bytes in Raw.
+type canonicalAttr struct {
+	RawAttribute
+	padding []byte
+}
+
+// canonicalAttributes returns attributes of m that are compared with
+// opts, along with their padding if it is compared.
+func (m *Message) canonicalAttributes(opts EqualOptions) []canonicalAttr {
+	attrs := make([]canonicalAttr, 0, len(m.Attributes))
+	offset := messageHeaderSize
+	for _, a := range m.Attributes {
+		var padding []byte
+		end := offset + attributeHeaderSize + nearestPaddedValueLength(len(a.Value))
+		if !opts.IgnorePadding && end <= len(m.Raw) {
+			padding = m.Raw[offset+attributeHeaderSize+len(a.Value) : end]
+		}
+		offset = end
+		if (opts.IgnoreSoftware && a.Type == AttrSoftware) ||
+			(opts.IgnoreFingerprint && a.Type == AttrFingerprint) {
+			continue
+		}
+		attrs = append(attrs, canonicalAttr{RawAttribute: a, padding: padding})
+	}
+	if opts.IgnoreOrder {
+		sort.SliceStable(attrs, func(i, j int) bool {
+			if attrs[i].Type != attrs[j].Type {
+				return attrs[i].Type < attrs[j].Type
+			}
+
+			return bytes.Compare(attrs[i].Value, attrs[j].Value) < 0
+		})
+	}
+
+	return attrs
+}
+
+// EqualCanonical is like Equal, but compares attributes in order along
+// with padding bytes of m.Raw, while opts allow to ignore differences
+// that are irrelevant for test expectations, e.g. SOFTWARE value of
+// captured message.
+func (m *Message) EqualCanonical(msg *Message, opts EqualOptions) bool {
+	if m == nil || msg == nil {
+		return m == msg
+	}
+	if m.Type != msg.Type || m.TransactionID != msg.TransactionID {
+		return false
+	}
+	attrsA, attrsB := m.canonicalAttributes(opts), msg.canonicalAttributes(opts)
+	if len(attrsA) != len(attrsB) {
+		return false
+	}
+	for i, a := range attrsA {
+		b := attrsB[i]
+		if !a.RawAttribute.Equal(b.RawAttribute) || !bytes.Equal(a.padding, b.padding) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // WriteLength writes m.Length to m.Raw.
 func (m *Message) WriteLength() {
+	m.mustBeMutable()
 	m.grow(4)
 	bin.PutUint16(m.Raw[2:4], uint16(m.Length)) //nolint:gosec // G115
 }
 
 // WriteHeader writes header to underlying buffer. Not goroutine-safe.
 func (m *Message) WriteHeader() {
+	m.mustBeMutable()
 	m.grow(messageHeaderSize)
 	_ = m.Raw[:messageHeaderSize] // early bounds check to guarantee safety of writes below
 
@@ -301,11 +522,13 @@ func (m *Message) WriteHeader() {
 
 // WriteTransactionID writes m.TransactionID to m.Raw.
 func (m *Message) WriteTransactionID() {
+	m.mustBeMutable()
 	copy(m.Raw[8:messageHeaderSize], m.TransactionID[:]) // transaction ID
 }
 
 // WriteAttributes encodes all m.Attributes to m.
 func (m *Message) WriteAttributes() {
+	m.mustBeMutable()
 	attributes := m.Attributes
 	m.Attributes = attributes[:0]
 	for _, a := range attributes {
@@ -316,18 +539,21 @@ func (m *Message) WriteAttributes() {
 
 // WriteType writes m.Type to m.Raw.
 func (m *Message) WriteType() {
+	m.mustBeMutable()
 	m.grow(2)
 	bin.PutUint16(m.Raw[0:2], m.Type.Value()) // message type
 }
 
 // SetType sets m.Type and writes it to m.Raw.
 func (m *Message) SetType(t MessageType) {
+	m.mustBeMutable()
 	m.Type = t
 	m.WriteType()
 }
 
 // Encode re-encodes message into m.Raw.
 func (m *Message) Encode() {
+	m.mustBeMutable()
 	m.Raw = m.Raw[:0]
 	m.WriteHeader()
 	m.Length = 0
@@ -348,6 +574,9 @@ func (m *Message) WriteTo(w io.Writer) (int64, error) {
 //
 // Can return *DecodeErr while decoding too.
 func (m *Message) ReadFrom(r io.Reader) (int64, error) {
+	if err := m.checkMutable(); err != nil {
+		return 0, err
+	}
 	tBuf := m.Raw[:cap(m.Raw)]
 	var (
 		n   int
@@ -365,8 +594,69 @@ func (m *Message) ReadFrom(r io.Reader) (int64, error) {
 // m.Raw to read header.
 var ErrUnexpectedHeaderEOF = errors.New("unexpected EOF: not enough bytes to read header")
 
-// Decode decodes m.Raw into m.
+// Decode decodes m.Raw into m. Decode and GetFrom methods of attributes
+// return error on malformed data and never panic, see FuzzMessageDecode
+// and FuzzAttrGetters.
 func (m *Message) Decode() error {
+	return m.DecodeAs(DecodeDefault)
+}
+
+// DecodeRFC3489 decodes m.Raw into m like Decode, but does not check the
+// magic cookie, so messages of RFC 3489 implementations that use all 128
+// bits as transaction ID are decoded too. First 32 bits of such ID are
+// only kept in m.Raw, see IsRFC3489.
+func (m *Message) DecodeRFC3489() error {
+	return m.DecodeAs(DecodeLegacy)
+}
+
+// DecodeMode is compatibility mode of message decoding with
+// implementations that predate RFC 5389, see Message.DecodeAs.
+type DecodeMode byte
+
+// Possible values for DecodeMode.
+const (
+	// DecodeDefault requires magic cookie, but accepts attribute type
+	// 0x8020 of early drafts as XOR-MAPPED-ADDRESS.
+	DecodeDefault DecodeMode = iota
+	// DecodeStrict requires magic cookie, zero most significant bits of
+	// message type and message length that is multiple of 4, and keeps
+	// attribute types as is.
+	DecodeStrict
+	// DecodeLegacy accepts RFC 3489 messages without magic cookie.
+	DecodeLegacy
+)
+
+func (d DecodeMode) String() string {
+	switch d {
+	case DecodeDefault:
+		return "default"
+	case DecodeStrict:
+		return "strict"
+	case DecodeLegacy:
+		return "legacy"
+	default:
+		return "unknown"
+	}
+}
+
+// DecodeAs decodes m.Raw into m with compatibility mode, so deployments
+// can either hard-fail on legacy clients with DecodeStrict or accept them
+// with DecodeLegacy.
+func (m *Message) DecodeAs(mode DecodeMode) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+
+	return m.decode(mode)
+}
+
+// IsRFC3489 reports whether m is classic STUN message of RFC 3489, which
+// has no magic cookie in header of m.Raw, e.g. decoded by DecodeRFC3489.
+func IsRFC3489(m *Message) bool {
+	return len(m.Raw) >= messageHeaderSize && bin.Uint32(m.Raw[4:8]) != magicCookie
+}
+
+func (m *Message) decode(mode DecodeMode) error { //nolint:cyclop
 	// decoding message header
 	buf := m.Raw
 	if len(buf) < messageHeaderSize {
@@ -378,11 +668,16 @@ func (m *Message) Decode() error {
 		cookie   = bin.Uint32(buf[4:8])      // last 4 bytes
 		fullSize = messageHeaderSize + size  // len(m.Raw)
 	)
-	if cookie != magicCookie {
+	if cookie != magicCookie && mode != DecodeLegacy {
 		msg := fmt.Sprintf("%x is invalid magic cookie (should be %x)", cookie, magicCookie)
 
 		return newDecodeErr("message", "cookie", msg)
 	}
+	if mode == DecodeStrict && (msgType&0xC000 != 0 || size%padding != 0) {
+		msg := fmt.Sprintf("type 0x%04x or length %d is invalid", msgType, size)
+
+		return newDecodeErr("message", "header", msg)
+	}
 	if len(buf) < fullSize {
 		msg := fmt.Sprintf("buffer length %d is less than %d (expected message size)", len(buf), fullSize)
 
@@ -394,6 +689,7 @@ func (m *Message) Decode() error {
 	copy(m.TransactionID[:], buf[8:messageHeaderSize])
 
 	m.Attributes = m.Attributes[:0]
+	m.invalidateIndex()
 	var (
 		offset = 0
 		b      = buf[messageHeaderSize:fullSize]
@@ -407,8 +703,8 @@ func (m *Message) Decode() error {
 		}
 		var (
 			attr = RawAttribute{
-				Type:   compatAttrType(bin.Uint16(b[0:2])), // first 2 bytes
-				Length: bin.Uint16(b[2:4]),                 // second 2 bytes
+				Type:   AttrType(bin.Uint16(b[0:2])), // first 2 bytes
+				Length: bin.Uint16(b[2:4]),           // second 2 bytes
 			}
 			aL     = int(attr.Length)             // attribute length
 			aBuffL = nearestPaddedValueLength(aL) // expected buffer length (with padding)
@@ -420,6 +716,9 @@ func (m *Message) Decode() error {
 
 			return newAttrDecodeErr("value", msg)
 		}
+		if mode != DecodeStrict {
+			attr.Type = compatAttrType(uint16(attr.Type))
+		}
 		attr.Value = b[:aL]
 		offset += aBuffL
 		b = b[aBuffL:]
@@ -434,6 +733,9 @@ func (m *Message) Decode() error {
 //
 // Any error is unrecoverable, but message could be partially decoded.
 func (m *Message) Write(tBuf []byte) (int, error) {
+	if err := m.checkMutable(); err != nil {
+		return 0, err
+	}
 	m.Raw = append(m.Raw[:0], tBuf...)
 
 	return len(tBuf), m.Decode()
@@ -441,11 +743,52 @@ func (m *Message) Write(tBuf []byte) (int, error) {
 
 // CloneTo clones m to b securing any further m mutations.
 func (m *Message) CloneTo(b *Message) error {
+	if err := b.checkMutable(); err != nil {
+		return err
+	}
 	b.Raw = append(b.Raw[:0], m.Raw...)
 
 	return b.Decode()
 }
 
+// Clone returns deep copy of m, which can be retained after m is reused,
+// e.g. message of Event after Handler returns.
+func (m *Message) Clone() *Message {
+	c := &Message{
+		Raw:        make([]byte, 0, len(m.Raw)),
+		Attributes: make(Attributes, 0, len(m.Attributes)),
+	}
+	m.CopyTo(c)
+
+	return c
+}
+
+// CopyTo makes dst deep copy of m, reusing buffers of dst. Unlike CloneTo,
+// message is not decoded again: values of dst.Attributes are re-sliced
+// from dst.Raw, so they do not alias m.Raw.
+func (m *Message) CopyTo(dst *Message) {
+	dst.mustBeMutable()
+	dst.Type = m.Type
+	dst.Length = m.Length
+	dst.TransactionID = m.TransactionID
+	dst.Raw = append(dst.Raw[:0], m.Raw...)
+	dst.Attributes = dst.Attributes[:0]
+	dst.invalidateIndex()
+	offset := messageHeaderSize
+	for _, a := range m.Attributes {
+		start := offset + attributeHeaderSize
+		end := start + len(a.Value)
+		offset = start + nearestPaddedValueLength(len(a.Value))
+		if end <= len(m.Raw) && bytes.Equal(m.Raw[start:end], a.Value) {
+			a.Value = dst.Raw[start:end:end]
+		} else {
+			// Attribute is not encoded in m.Raw, copying its value.
+			a.Value = append([]byte(nil), a.Value...)
+		}
+		dst.Attributes = append(dst.Attributes, a)
+	}
+}
+
 // MessageClass is 8-bit representation of 2-bit class of STUN Message Class.
 type MessageClass byte
 
@@ -522,6 +865,9 @@ func methodName() map[Method]string {
 
 func (m Method) String() string {
 	s, ok := methodName()[m]
+	if !ok {
+		s, ok = registeredMethodName(m)
+	}
 	if !ok {
 		// Falling back to hex representation.
 		s = fmt.Sprintf("0x%x", uint16(m))
@@ -538,6 +884,9 @@ type MessageType struct {
 
 // AddTo sets m type to t.
 func (t MessageType) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
 	m.SetType(t)
 
 	return nil
@@ -624,15 +973,49 @@ func (t MessageType) String() string {
 	return fmt.Sprintf("%s %s", t.Method, t.Class)
 }
 
+// IsRequest reports whether t is request.
+func (t MessageType) IsRequest() bool {
+	return t.Class == ClassRequest
+}
+
+// IsIndication reports whether t is indication.
+func (t MessageType) IsIndication() bool {
+	return t.Class == ClassIndication
+}
+
+// IsSuccessResponse reports whether t is success response.
+func (t MessageType) IsSuccessResponse() bool {
+	return t.Class == ClassSuccessResponse
+}
+
+// IsErrorResponse reports whether t is error response.
+func (t MessageType) IsErrorResponse() bool {
+	return t.Class == ClassErrorResponse
+}
+
+// IsResponse reports whether t is success or error response.
+func (t MessageType) IsResponse() bool {
+	return t.IsSuccessResponse() || t.IsErrorResponse()
+}
+
+// IsBindingRequest reports whether m is Binding request.
+func (m *Message) IsBindingRequest() bool {
+	return m.Type == BindingRequest
+}
+
+// IsBindingSuccess reports whether m is Binding success response.
+func (m *Message) IsBindingSuccess() bool {
+	return m.Type == BindingSuccess
+}
+
+// IsBindingError reports whether m is Binding error response.
+func (m *Message) IsBindingError() bool {
+	return m.Type == BindingError
+}
+
 // Contains return true if message contain t attribute.
 func (m *Message) Contains(t AttrType) bool {
-	for _, a := range m.Attributes {
-		if a.Type == t {
-			return true
-		}
-	}
-
-	return false
+	return m.lookup(t) >= 0
 }
 
 type transactionIDValueSetter [TransactionIDSize]byte
@@ -644,6 +1027,9 @@ func NewTransactionIDSetter(value [TransactionIDSize]byte) Setter {
 }
 
 func (t transactionIDValueSetter) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
 	m.TransactionID = t
 	m.WriteTransactionID()
 
@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !fips
+// +build !fips
+
+package stun
+
+import (
+	"bytes"
+	"fmt"
+)
+
+func ExampleMessage() {
+	buf := new(bytes.Buffer)
+	msg := new(Message)
+	msg.Build(BindingRequest, //nolint:errcheck,gosec
+		NewTransactionIDSetter([TransactionIDSize]byte{
+			1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 1,
+		}),
+		NewSoftware("ernado/stun"),
+		NewLongTermIntegrity("username", "realm", "password"),
+		Fingerprint,
+	)
+	// Instead of calling Build, use AddTo(m) directly for all setters
+	// to reduce allocations.
+	// For example:
+	//	software := NewSoftware("ernado/stun")
+	//	software.AddTo(m)  // no allocations
+	// Or pass software as follows:
+	//	m.Build(&software) // no allocations
+	// If you pass software as value, there will be 1 allocation.
+	// This rule is correct for all setters.
+	fmt.Println(msg, "buff length:", len(msg.Raw))
+	n, err := msg.WriteTo(buf)
+	fmt.Println("wrote", n, "err", err)
+
+	// Decoding from buf new *Message.
+	decoded := new(Message)
+	decoded.Raw = make([]byte, 0, 1024) // for ReadFrom that reuses m.Raw
+	// ReadFrom does not allocate internal buffer for reading from io.Reader,
+	// instead it uses m.Raw, expanding it length to capacity.
+	decoded.ReadFrom(buf) //nolint:errcheck,gosec
+	fmt.Println("has software:", decoded.Contains(AttrSoftware))
+	fmt.Println("has nonce:", decoded.Contains(AttrNonce))
+	var software Software
+	decoded.Parse(&software) //nolint:errcheck,gosec
+	// Rule for Parse method is same as for Build.
+	fmt.Println("software:", software)
+	if err := Fingerprint.Check(decoded); err == nil {
+		fmt.Println("fingerprint is correct")
+	} else {
+		fmt.Println("fingerprint is incorrect:", err)
+	}
+	// Checking integrity
+	i := NewLongTermIntegrity("username", "realm", "password")
+	if err := i.Check(decoded); err == nil {
+		fmt.Println("integrity ok")
+	} else {
+		fmt.Println("integrity bad:", err)
+	}
+	fmt.Println("for corrupted message:")
+	decoded.Raw[22] = 33
+	if Fingerprint.Check(decoded) == nil {
+		fmt.Println("fingerprint: ok")
+	} else {
+		fmt.Println("fingerprint: failed")
+	}
+
+	//nolint:lll
+	// Output:
+	// Binding request l=48 attrs=3 id=AQIDBAUGBwgJAAEA, attr0=SOFTWARE attr1=MESSAGE-INTEGRITY attr2=FINGERPRINT  buff length: 68
+	// wrote 68 err <nil>
+	// has software: true
+	// has nonce: false
+	// software: ernado/stun
+	// fingerprint is correct
+	// integrity ok
+	// for corrupted message:
+	// fingerprint: failed
+}
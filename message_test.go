@@ -21,6 +21,8 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
 )
 
 type attributeEncoder interface {
@@ -130,6 +132,38 @@ func TestMessageType_ReadWriteValue(t *testing.T) {
 	}
 }
 
+func TestMessageType_Class(t *testing.T) {
+	for _, tc := range []struct {
+		class                                       MessageClass
+		request, indication, success, errorResponse bool
+	}{
+		{class: ClassRequest, request: true},
+		{class: ClassIndication, indication: true},
+		{class: ClassSuccessResponse, success: true},
+		{class: ClassErrorResponse, errorResponse: true},
+	} {
+		mt := NewType(MethodAllocate, tc.class)
+		if mt.IsRequest() != tc.request || mt.IsIndication() != tc.indication ||
+			mt.IsSuccessResponse() != tc.success || mt.IsErrorResponse() != tc.errorResponse {
+			t.Errorf("unexpected predicates of %s", mt)
+		}
+		if mt.IsResponse() != (tc.success || tc.errorResponse) {
+			t.Errorf("unexpected IsResponse of %s", mt)
+		}
+	}
+}
+
+func TestMessage_IsBinding(t *testing.T) {
+	for _, mt := range []MessageType{BindingRequest, BindingSuccess, BindingError, NewType(MethodAllocate, ClassRequest)} {
+		m := MustBuild(mt)
+		if m.IsBindingRequest() != (mt == BindingRequest) ||
+			m.IsBindingSuccess() != (mt == BindingSuccess) ||
+			m.IsBindingError() != (mt == BindingError) {
+			t.Errorf("unexpected predicates of %s", mt)
+		}
+	}
+}
+
 func TestMessage_WriteTo(t *testing.T) {
 	msg := New()
 	msg.Type = MessageType{Method: MethodBinding, Class: ClassRequest}
@@ -692,75 +726,6 @@ func TestMessage_Contains(t *testing.T) {
 	}
 }
 
-func ExampleMessage() {
-	buf := new(bytes.Buffer)
-	msg := new(Message)
-	msg.Build(BindingRequest, //nolint:errcheck,gosec
-		NewTransactionIDSetter([TransactionIDSize]byte{
-			1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 1,
-		}),
-		NewSoftware("ernado/stun"),
-		NewLongTermIntegrity("username", "realm", "password"),
-		Fingerprint,
-	)
-	// Instead of calling Build, use AddTo(m) directly for all setters
-	// to reduce allocations.
-	// For example:
-	//	software := NewSoftware("ernado/stun")
-	//	software.AddTo(m)  // no allocations
-	// Or pass software as follows:
-	//	m.Build(&software) // no allocations
-	// If you pass software as value, there will be 1 allocation.
-	// This rule is correct for all setters.
-	fmt.Println(msg, "buff length:", len(msg.Raw))
-	n, err := msg.WriteTo(buf)
-	fmt.Println("wrote", n, "err", err)
-
-	// Decoding from buf new *Message.
-	decoded := new(Message)
-	decoded.Raw = make([]byte, 0, 1024) // for ReadFrom that reuses m.Raw
-	// ReadFrom does not allocate internal buffer for reading from io.Reader,
-	// instead it uses m.Raw, expanding it length to capacity.
-	decoded.ReadFrom(buf) //nolint:errcheck,gosec
-	fmt.Println("has software:", decoded.Contains(AttrSoftware))
-	fmt.Println("has nonce:", decoded.Contains(AttrNonce))
-	var software Software
-	decoded.Parse(&software) //nolint:errcheck,gosec
-	// Rule for Parse method is same as for Build.
-	fmt.Println("software:", software)
-	if err := Fingerprint.Check(decoded); err == nil {
-		fmt.Println("fingerprint is correct")
-	} else {
-		fmt.Println("fingerprint is incorrect:", err)
-	}
-	// Checking integrity
-	i := NewLongTermIntegrity("username", "realm", "password")
-	if err := i.Check(decoded); err == nil {
-		fmt.Println("integrity ok")
-	} else {
-		fmt.Println("integrity bad:", err)
-	}
-	fmt.Println("for corrupted message:")
-	decoded.Raw[22] = 33
-	if Fingerprint.Check(decoded) == nil {
-		fmt.Println("fingerprint: ok")
-	} else {
-		fmt.Println("fingerprint: failed")
-	}
-
-	//nolint:lll
-	// Output:
-	// Binding request l=48 attrs=3 id=AQIDBAUGBwgJAAEA, attr0=SOFTWARE attr1=MESSAGE-INTEGRITY attr2=FINGERPRINT  buff length: 68
-	// wrote 68 err <nil>
-	// has software: true
-	// has nonce: false
-	// software: ernado/stun
-	// fingerprint is correct
-	// integrity ok
-	// for corrupted message:
-	// fingerprint: failed
-}
-
 func TestAllocations(t *testing.T) {
 	// Not testing AttrMessageIntegrity because it allocates.
 	setters := []Setter{
@@ -843,6 +808,7 @@ func TestAllocationsGetters(t *testing.T) {
 }
 
 func TestMessageFullSize(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
 	msg := new(Message)
 	if err := msg.Build(BindingRequest,
 		NewTransactionIDSetter([TransactionIDSize]byte{
@@ -864,6 +830,7 @@ func TestMessageFullSize(t *testing.T) {
 }
 
 func TestMessage_CloneTo(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
 	msg := new(Message)
 	if err := msg.Build(BindingRequest,
 		NewTransactionIDSetter([TransactionIDSize]byte{
@@ -894,6 +861,51 @@ func TestMessage_CloneTo(t *testing.T) {
 	}
 }
 
+func TestMessage_CopyTo(t *testing.T) {
+	msg := MustBuild(BindingRequest, TransactionID,
+		NewSoftware("pion/stun"),
+		NewUsername("user"),
+		Fingerprint,
+	)
+	clone := msg.Clone()
+	if !clone.Equal(msg) {
+		t.Fatal("not equal")
+	}
+	software, _ := msg.Attributes.Get(AttrSoftware)
+	software.Value[0] = 'k'
+	msg.Raw[len(msg.Raw)-1]++
+	if s, _ := clone.Attributes.Get(AttrSoftware); string(s.Value) != "pion/stun" {
+		t.Errorf("attribute of clone aliases original: %q", s.Value)
+	}
+	if err := Fingerprint.Check(clone); err != nil {
+		t.Error(err)
+	}
+	t.Run("Decoded", func(t *testing.T) {
+		decoded := new(Message)
+		if err := clone.CloneTo(decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Equal(clone) {
+			t.Error("clone should be valid message")
+		}
+	})
+	t.Run("NotEncoded", func(t *testing.T) {
+		m := &Message{Attributes: Attributes{{Type: AttrSoftware, Value: []byte("value")}}}
+		dst := new(Message)
+		m.CopyTo(dst)
+		m.Attributes[0].Value[0] = 'k'
+		if string(dst.Attributes[0].Value) != "value" {
+			t.Errorf("unexpected value: %q", dst.Attributes[0].Value)
+		}
+	})
+	t.Run("Allocations", func(t *testing.T) {
+		dst := clone.Clone()
+		testutil.ShouldNotAllocate(t, func() {
+			clone.CopyTo(dst)
+		})
+	})
+}
+
 func BenchmarkMessage_CloneTo(b *testing.B) {
 	b.ReportAllocs()
 	msg := new(Message)
@@ -1070,3 +1082,266 @@ func TestMessage_GobDecode(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestNewTransactionIDFrom(t *testing.T) {
+	source := bytes.Repeat([]byte{1}, TransactionIDSize*2)
+	id, err := NewTransactionIDFrom(bytes.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(id[:], source[:TransactionIDSize]) {
+		t.Errorf("unexpected id: %x", id)
+	}
+	if _, err = NewTransactionIDFrom(bytes.NewReader(source[:4])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	t.Run("Setter", func(t *testing.T) {
+		r := bytes.NewReader(source)
+		m, err := Build(TransactionIDFrom(r), BindingRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := new(Message)
+		if err = Decode(m.Raw, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded.TransactionID[:], source[:TransactionIDSize]) {
+			t.Errorf("unexpected id: %x", decoded.TransactionID)
+		}
+		if _, err = Build(TransactionIDFrom(r), BindingRequest); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = Build(TransactionIDFrom(r), BindingRequest); !errors.Is(err, io.EOF) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if TransactionIDFrom(nil) != TransactionID {
+			t.Error("nil source should be crypto/rand")
+		}
+	})
+}
+
+func TestMessage_AppendTo(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest, NewSoftware("software"), Fingerprint)
+	buf := make([]byte, 2, 1500)
+	buf = m.AppendTo(buf)
+	if !bytes.Equal(buf[2:], m.Raw) {
+		t.Fatal("unexpected encoding")
+	}
+	testutil.ShouldNotAllocate(t, func() {
+		buf = m.AppendTo(buf[:0])
+	})
+}
+
+func TestDecodeMessage(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest, NewSoftware("software"), Fingerprint)
+	buf := m.AppendTo(nil)
+	decoded := new(Message)
+	if err := DecodeMessage(buf, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(m) {
+		t.Error("not equal")
+	}
+	if &decoded.Raw[0] != &buf[0] {
+		t.Error("message should use provided buffer")
+	}
+	t.Run("Allocations", func(t *testing.T) {
+		testutil.ShouldNotAllocate(t, func() {
+			if err := DecodeMessage(buf, decoded); err != nil {
+				t.Error(err)
+			}
+		})
+	})
+	if err := DecodeMessage(buf, nil); !errors.Is(err, ErrDecodeToNil) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := DecodeMessage(buf[:10], decoded); !errors.Is(err, ErrUnexpectedHeaderEOF) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMessage_DecodeRFC3489(t *testing.T) {
+	m := MustBuild(TransactionID, BindingSuccess, &MappedAddress{
+		IP: net.IPv4(192, 0, 2, 1), Port: 3478,
+	})
+	// RFC 3489 transaction ID is 128 bits long, including cookie bytes.
+	copy(m.Raw[4:8], []byte{1, 2, 3, 4})
+	decoded := &Message{Raw: append([]byte(nil), m.Raw...)}
+	var dErr *DecodeErr
+	if err := decoded.Decode(); !errors.As(err, &dErr) || !dErr.IsInvalidCookie() {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := decoded.DecodeRFC3489(); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Type != BindingSuccess || decoded.TransactionID != m.TransactionID {
+		t.Errorf("unexpected message: %s", decoded)
+	}
+	var addr MappedAddress
+	if err := addr.GetFrom(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if addr.Port != 3478 {
+		t.Errorf("unexpected address: %s", addr)
+	}
+}
+
+func TestMessage_DecodeAs(t *testing.T) {
+	m := MustBuild(TransactionID, BindingSuccess)
+	m.Add(AttrType(0x8020), make([]byte, 8))
+	m.WriteHeader()
+	for _, tc := range []struct {
+		mode     DecodeMode
+		expected AttrType
+	}{
+		{DecodeDefault, AttrXORMappedAddress},
+		{DecodeStrict, AttrType(0x8020)},
+		{DecodeLegacy, AttrXORMappedAddress},
+	} {
+		t.Run(tc.mode.String(), func(t *testing.T) {
+			decoded := &Message{Raw: append([]byte(nil), m.Raw...)}
+			if err := decoded.DecodeAs(tc.mode); err != nil {
+				t.Fatal(err)
+			}
+			if len(decoded.Attributes) != 1 || decoded.Attributes[0].Type != tc.expected {
+				t.Errorf("unexpected attributes: %s", decoded.Attributes)
+			}
+			if IsRFC3489(decoded) {
+				t.Error("should not be RFC 3489 message")
+			}
+		})
+	}
+	t.Run("StrictHeader", func(t *testing.T) {
+		badType := &Message{Raw: append([]byte(nil), m.Raw...)}
+		badType.Raw[0] |= 0x80
+		badLength := MustBuild(TransactionID, BindingRequest)
+		badLength.Raw = append(badLength.Raw, 0, 0)
+		bin.PutUint16(badLength.Raw[2:4], 2)
+		for _, b := range []*Message{badType, badLength} {
+			var dErr *DecodeErr
+			if err := b.DecodeAs(DecodeStrict); !errors.As(err, &dErr) || !dErr.IsPlace(DecodeErrPlace{"message", "header"}) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+	})
+	t.Run("Legacy", func(t *testing.T) {
+		legacy := &Message{Raw: append([]byte(nil), m.Raw...)}
+		copy(legacy.Raw[4:8], []byte{1, 2, 3, 4})
+		if err := legacy.DecodeAs(DecodeStrict); err == nil {
+			t.Error("should error")
+		}
+		if err := legacy.DecodeAs(DecodeLegacy); err != nil {
+			t.Fatal(err)
+		}
+		if !IsRFC3489(legacy) {
+			t.Error("should be RFC 3489 message")
+		}
+		if IsRFC3489(new(Message)) {
+			t.Error("empty message should not be RFC 3489 message")
+		}
+	})
+	if s := DecodeMode(0xff).String(); s != "unknown" {
+		t.Errorf("unexpected string: %s", s)
+	}
+}
+
+func TestMessage_EqualCanonical(t *testing.T) {
+	var (
+		id       = NewTransactionIDSetter([TransactionIDSize]byte{1, 2, 3})
+		username = NewUsername("user")
+		realm    = NewRealm("realm")
+		expected = MustBuild(id, BindingRequest, username, realm, NewSoftware("a"), Fingerprint)
+	)
+	if !expected.EqualCanonical(expected.Clone(), EqualOptions{}) {
+		t.Error("clone should be equal")
+	}
+	padded := expected.Clone()
+	padded.Raw[messageHeaderSize+2*attributeHeaderSize+len("user")+len("realm")] = 0xff // REALM padding
+	reordered := MustBuild(id, BindingRequest, realm, username, NewSoftware("a"), Fingerprint)
+	otherSoftware := MustBuild(id, BindingRequest, username, realm, NewSoftware("b"), Fingerprint)
+	noFingerprint := MustBuild(id, BindingRequest, username, realm)
+	for _, tc := range []struct {
+		name  string
+		m     *Message
+		opts  EqualOptions
+		equal bool
+	}{
+		{"Padding", padded, EqualOptions{}, false},
+		{"IgnorePadding", padded, EqualOptions{IgnorePadding: true}, true},
+		{"Order", reordered, EqualOptions{}, false},
+		{"IgnoreOrder", reordered, EqualOptions{IgnoreOrder: true, IgnoreFingerprint: true}, true},
+		{"Software", otherSoftware, EqualOptions{IgnoreFingerprint: true}, false},
+		{"IgnoreSoftware", otherSoftware, EqualOptions{IgnoreSoftware: true, IgnoreFingerprint: true}, true},
+		{"Fingerprint", noFingerprint, EqualOptions{IgnoreSoftware: true}, false},
+		{"IgnoreAll", noFingerprint, EqualOptions{IgnoreSoftware: true, IgnoreFingerprint: true}, true},
+		{"Type", MustBuild(id, BindingSuccess, username, realm), EqualOptions{
+			IgnoreSoftware: true, IgnoreFingerprint: true,
+		}, false},
+		{"Nil", nil, EqualOptions{}, false},
+	} {
+		if got := expected.EqualCanonical(tc.m, tc.opts); got != tc.equal {
+			t.Errorf("%s: EqualCanonical = %v, expected %v", tc.name, got, tc.equal)
+		}
+	}
+}
+
+func TestMessage_Delete(t *testing.T) {
+	id := NewTransactionIDSetter([TransactionIDSize]byte{1, 2, 3})
+	m := MustBuild(id, BindingRequest,
+		NewUsername("user"), NewSoftware("a"), NewRealm("realm"), NewSoftware("b"),
+	)
+	if err := m.Delete(AttrSoftware); err != nil {
+		t.Fatal(err)
+	}
+	expected := MustBuild(id, BindingRequest, NewUsername("user"), NewRealm("realm"))
+	if !bytes.Equal(m.Raw, expected.Raw) || !m.Equal(expected) {
+		t.Errorf("unexpected message: %s", m)
+	}
+	if err := m.Delete(AttrSoftware); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMessage_Replace(t *testing.T) {
+	id := NewTransactionIDSetter([TransactionIDSize]byte{1, 2, 3})
+	m := MustBuild(id, BindingRequest, NewUsername("user"), NewSoftware("a"), NewRealm("realm"))
+	for _, software := range []string{"longer software", "", "abc"} {
+		if err := m.Replace(AttrSoftware, []byte(software)); err != nil {
+			t.Fatal(err)
+		}
+		expected := MustBuild(id, BindingRequest, NewUsername("user"), NewSoftware(software), NewRealm("realm"))
+		if !bytes.Equal(m.Raw, expected.Raw) || !m.Equal(expected) {
+			t.Errorf("%q: unexpected message: %s", software, m)
+		}
+		decoded := new(Message)
+		if err := Decode(m.Raw, decoded); err != nil || !decoded.Equal(m) {
+			t.Errorf("%q: failed to decode: %v", software, err)
+		}
+	}
+	t.Run("Alias", func(t *testing.T) {
+		realm, err := m.Get(AttrRealm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = m.Replace(AttrUsername, realm); err != nil {
+			t.Fatal(err)
+		}
+		expected := MustBuild(id, BindingRequest, NewUsername("realm"), NewSoftware("abc"), NewRealm("realm"))
+		if !bytes.Equal(m.Raw, expected.Raw) {
+			t.Errorf("unexpected message: %s", m)
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		if err := m.Replace(AttrNonce, nil); !errors.Is(err, ErrAttributeNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Allocations", func(t *testing.T) {
+		value := []byte("software")
+		testutil.ShouldNotAllocate(t, func() {
+			if err := m.Replace(AttrSoftware, value); err != nil {
+				t.Error(err)
+			}
+		})
+	})
+}
@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// Default sizes of ProbeMTU, in bytes of UDP payload.
+const (
+	DefaultMTUProbeMin = 508  // 576 bytes of minimum IPv4 reassembly buffer without headers
+	DefaultMTUProbeMax = 1472 // 1500 bytes of Ethernet without IPv4 and UDP headers
+
+	defaultMTUProbeAttempts = 3 // MAX_PROBES of RFC 8899 Section 5.1.2
+	defaultMTUProbeTimeout  = time.Second
+
+	// mtuProbeOverhead is size of header, PADDING header and FINGERPRINT of
+	// probe, which is the smallest probe size.
+	mtuProbeOverhead = messageHeaderSize + attributeHeaderSize + attributeHeaderSize + fingerprintSize
+)
+
+var (
+	// ErrMTUProbeFailed means that even the smallest probe of ProbeMTU
+	// was not answered.
+	ErrMTUProbeFailed = errors.New("no response to smallest MTU probe")
+	// ErrDontFragmentNotSupported means that Don't Fragment bit can not be
+	// set on connection or platform, see SetDontFragment.
+	ErrDontFragmentNotSupported = errors.New("don't fragment is not supported")
+)
+
+// MTUProbeConfig configures ProbeMTU, zero values mean defaults.
+type MTUProbeConfig struct {
+	// Min and Max are sizes of the smallest and the largest probe in
+	// bytes of STUN message, i.e. UDP payload, rounded down to multiple
+	// of 4.
+	Min int
+	Max int
+	// Attempts is count of probes of single size that are lost before
+	// the size is considered too large.
+	Attempts int
+	// Timeout is time to wait for response to single probe, used only by
+	// ProbeMTU function, see Client.ProbeMTU.
+	Timeout time.Duration
+}
+
+func (cfg MTUProbeConfig) withDefaults() MTUProbeConfig {
+	if cfg.Min <= 0 {
+		cfg.Min = DefaultMTUProbeMin
+	}
+	if cfg.Max <= 0 {
+		cfg.Max = DefaultMTUProbeMax
+	}
+	if cfg.Min < mtuProbeOverhead {
+		cfg.Min = mtuProbeOverhead
+	}
+	cfg.Min -= cfg.Min % padding
+	cfg.Max -= cfg.Max % padding
+	if cfg.Max < cfg.Min {
+		cfg.Max = cfg.Min
+	}
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = defaultMTUProbeAttempts
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultMTUProbeTimeout
+	}
+
+	return cfg
+}
+
+// ProbeMTU discovers path MTU towards STUN server at uri, e.g.
+// "stun:stun.l.google.com:19302", before choosing media packetization.
+//
+// It dials UDP socket with Don't Fragment bit set, see SetDontFragment,
+// and sends Binding requests that are padded with PADDING attribute
+// (RFC 5780 Section 7.6), searching for the largest size in cfg range that
+// is answered, like datagram packetization layer PMTU discovery of RFC
+// 8899. Returned size is of STUN message, so IP MTU is larger by IP and
+// UDP headers.
+func ProbeMTU(ctx context.Context, uri string, cfg MTUProbeConfig, opts ...ClientOption) (int, error) {
+	u, err := ParseURI(uri)
+	if err != nil {
+		return 0, err
+	}
+	if u.Scheme != SchemeTypeSTUN {
+		return 0, ErrUnsupportedURI
+	}
+	conn, err := dialURI(u, &DialConfig{})
+	if err != nil {
+		return 0, err
+	}
+	netConn, ok := conn.(net.Conn)
+	if !ok {
+		err = ErrDontFragmentNotSupported
+	} else {
+		err = SetDontFragment(netConn)
+	}
+	if err != nil {
+		_ = conn.Close()
+
+		return 0, err
+	}
+	cfg = cfg.withDefaults()
+	// Lost probes are expected, so they are not retransmitted.
+	opts = append([]ClientOption{WithNoRetransmit, WithRTO(cfg.Timeout)}, opts...)
+	client, err := NewClient(conn, opts...)
+	if err != nil {
+		_ = conn.Close()
+
+		return 0, err
+	}
+	defer client.Close() //nolint:errcheck
+
+	return client.ProbeMTU(ctx, cfg)
+}
+
+// ProbeMTU is like ProbeMTU function, but sends probes over c, which
+// should have Don't Fragment bit set. Each probe is single transaction,
+// so client should be created with WithNoRetransmit option, otherwise
+// each lost probe is retransmitted, delaying search. Timeout of cfg is
+// not used.
+//
+// Returns ErrMTUProbeFailed if probe of cfg.Min size is not answered.
+func (c *Client) ProbeMTU(ctx context.Context, cfg MTUProbeConfig) (int, error) {
+	cfg = cfg.withDefaults()
+	ok, err := c.probeSize(ctx, cfg.Min, cfg.Attempts)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrMTUProbeFailed
+	}
+	low, high := cfg.Min, cfg.Max // low is answered, sizes above high are not
+	for low < high {
+		mid := low + (high-low+padding)/2
+		mid -= mid % padding
+		if ok, err = c.probeSize(ctx, mid, cfg.Attempts); err != nil {
+			return 0, err
+		}
+		if ok {
+			low = mid
+		} else {
+			high = mid - padding
+		}
+	}
+
+	return low, nil
+}
+
+// probeSize reports whether Binding request of size bytes is answered in
+// one of attempts.
+func (c *Client) probeSize(ctx context.Context, size, attempts int) (bool, error) {
+	for i := 0; i < attempts; i++ {
+		m, err := Build(TransactionID, BindingRequest, Padding(size-mtuProbeOverhead), Fingerprint)
+		if err != nil {
+			return false, err
+		}
+		done := make(chan bool, 1)
+		if err = c.Start(m, func(e Event) {
+			done <- e.Error == nil
+		}); err != nil {
+			if errors.Is(err, ErrClientClosed) {
+				return false, err
+			}
+			// E.g. EMSGSIZE for probe that does not fit into MTU of
+			// local interface.
+			c.log.Debugf("client: failed to send %d bytes MTU probe: %v", size, err)
+
+			return false, nil
+		}
+		select {
+		case ok := <-done:
+			if ok {
+				return true, nil
+			}
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+
+	return false, nil
+}
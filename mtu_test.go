@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newMTUClient returns client over connection that drops requests larger
+// than mtu bytes and answers other ones.
+func newMTUClient(t *testing.T, mtu int) (*Client, *[]int) {
+	t.Helper()
+	var (
+		conn  *TransportConn
+		sizes []int
+	)
+	conn = NewTransportConn(func(b []byte) error {
+		sizes = append(sizes, len(b))
+		if len(b) > mtu {
+			return nil
+		}
+		req := new(Message)
+		if err := Decode(b, req); err != nil {
+			return err
+		}
+		if err := req.Check(Fingerprint); err != nil {
+			return err
+		}
+
+		return conn.Deliver(MustBuild(req, BindingSuccess).Raw)
+	})
+	c, err := NewClient(conn, WithNoRetransmit, WithRTO(time.Millisecond*20), WithTimeoutRate(time.Millisecond*5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c, &sizes
+}
+
+func TestClient_ProbeMTU(t *testing.T) {
+	c, sizes := newMTUClient(t, 1001)
+	mtu, err := c.ProbeMTU(context.Background(), MTUProbeConfig{Attempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mtu != 1000 {
+		t.Errorf("unexpected MTU: %d", mtu)
+	}
+	if (*sizes)[0] != DefaultMTUProbeMin {
+		t.Errorf("first probe should be of minimum size: %d", (*sizes)[0])
+	}
+	for _, size := range *sizes {
+		if size%padding != 0 || size < DefaultMTUProbeMin || size > DefaultMTUProbeMax {
+			t.Errorf("unexpected probe size: %d", size)
+		}
+	}
+
+	t.Run("Failed", func(t *testing.T) {
+		c, sizes := newMTUClient(t, 100)
+		if _, err := c.ProbeMTU(context.Background(), MTUProbeConfig{Min: 200, Attempts: 2}); !errors.Is(err, ErrMTUProbeFailed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(*sizes) != 2 {
+			t.Errorf("unexpected probes: %v", *sizes)
+		}
+	})
+	t.Run("Range", func(t *testing.T) {
+		c, _ := newMTUClient(t, 1500)
+		if mtu, err := c.ProbeMTU(context.Background(), MTUProbeConfig{Min: 10, Max: 1203}); err != nil || mtu != 1200 {
+			t.Errorf("unexpected result: %d, %v", mtu, err)
+		}
+	})
+	t.Run("Context", func(t *testing.T) {
+		c, _ := newMTUClient(t, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := c.ProbeMTU(ctx, MTUProbeConfig{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
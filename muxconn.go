@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"net"
+	"sync"
+)
+
+// muxReceiveMTU is the size of MultiplexedConn read buffer.
+const muxReceiveMTU = 8192
+
+// muxQueueSize is the number of packets that can be queued for reading
+// before MultiplexedConn starts dropping them.
+const muxQueueSize = 128
+
+type muxPacket struct {
+	data []byte
+	addr net.Addr
+}
+
+// MultiplexedConnOption sets MultiplexedConn option.
+type MultiplexedConnOption func(c *MultiplexedConn)
+
+// WithSTUNHandler sets handler for STUN messages from addresses that are
+// not dialed via MultiplexedConn.Dial and are not routed to ICE
+// connections, e.g. incoming connectivity checks of unknown agents. Such
+// messages are dropped if handler is not set.
+//
+// Handler is called from read goroutine and must not retain m.
+func WithSTUNHandler(h func(m *Message, addr net.Addr)) MultiplexedConnOption {
+	return func(c *MultiplexedConn) {
+		c.handler = h
+	}
+}
+
+// MultiplexedConn shares net.PacketConn between STUN and other protocols,
+// e.g. media. STUN packets (see IsSTUNPacket) are routed to connections
+// returned by Dial, which can be passed to NewClient, and other packets
+// are returned by ReadNonSTUN.
+//
+// Packets are dropped if reader is too slow, like socket does when its
+// buffer is full.
+type MultiplexedConn struct {
+	conn    net.PacketConn
+	handler func(m *Message, addr net.Addr)
+	nonSTUN chan muxPacket
+	closed  chan struct{}
+	wg      sync.WaitGroup
+
+	mux      sync.Mutex
+	remotes  map[string]*muxRemoteConn
+	ufrags   map[string]*iceConn // see icemux.go
+	iceAddrs map[string]*iceConn
+	err      error // read error of conn
+	once     sync.Once
+}
+
+// NewMultiplexedConn wraps conn, starting read goroutine. Call Close to
+// stop it and close conn.
+func NewMultiplexedConn(conn net.PacketConn, options ...MultiplexedConnOption) *MultiplexedConn {
+	c := &MultiplexedConn{
+		conn:     conn,
+		nonSTUN:  make(chan muxPacket, muxQueueSize),
+		closed:   make(chan struct{}),
+		remotes:  make(map[string]*muxRemoteConn),
+		ufrags:   make(map[string]*iceConn),
+		iceAddrs: make(map[string]*iceConn),
+	}
+	for _, o := range options {
+		o(c)
+	}
+	c.wg.Add(1)
+	go c.readUntilClosed()
+
+	return c
+}
+
+func (c *MultiplexedConn) readUntilClosed() {
+	defer c.wg.Done()
+	buf := make([]byte, muxReceiveMTU)
+	m := new(Message)
+	for {
+		n, addr, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			c.mux.Lock()
+			c.err = err
+			c.mux.Unlock()
+			c.once.Do(func() { close(c.closed) })
+
+			return
+		}
+		if !IsSTUNPacket(buf[:n]) {
+			if ice, ok := c.iceConnByAddr(addr); ok {
+				enqueue(ice.in, buf[:n], addr)
+			} else {
+				enqueue(c.nonSTUN, buf[:n], addr)
+			}
+
+			continue
+		}
+		c.mux.Lock()
+		remote, found := c.remotes[addr.String()]
+		c.mux.Unlock()
+		if found {
+			enqueue(remote.in, buf[:n], addr)
+
+			continue
+		}
+		m.Raw = append(m.Raw[:0], buf[:n]...)
+		if m.Decode() != nil {
+			continue
+		}
+		if ice, ok := c.iceConnByUsername(m, addr); ok {
+			enqueue(ice.in, buf[:n], addr)
+		} else if ice, ok = c.iceConnByAddr(addr); ok {
+			enqueue(ice.in, buf[:n], addr)
+		} else if c.handler != nil {
+			c.handler(m, addr)
+		}
+	}
+}
+
+// enqueue copies b to queue, dropping it if queue is full.
+func enqueue(queue chan muxPacket, b []byte, addr net.Addr) {
+	select {
+	case queue <- muxPacket{data: append([]byte(nil), b...), addr: addr}:
+	default:
+	}
+}
+
+// readErr returns error that should be returned by reads after close.
+func (c *MultiplexedConn) readErr() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+
+	return net.ErrClosed
+}
+
+// ReadNonSTUN reads next packet that is not STUN into b.
+func (c *MultiplexedConn) ReadNonSTUN(b []byte) (int, net.Addr, error) {
+	select {
+	case p := <-c.nonSTUN:
+		return copy(b, p.data), p.addr, nil
+	case <-c.closed:
+		return 0, nil, c.readErr()
+	}
+}
+
+// WriteTo writes b to addr via underlying connection.
+func (c *MultiplexedConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.conn.WriteTo(b, addr)
+}
+
+// LocalAddr returns local address of underlying connection.
+func (c *MultiplexedConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// Close closes underlying connection and waits for read goroutine.
+func (c *MultiplexedConn) Close() error {
+	err := c.conn.Close()
+	c.once.Do(func() { close(c.closed) })
+	c.wg.Wait()
+
+	return err
+}
+
+// Dial returns Connection that writes to remote and reads STUN packets
+// from remote. Closing it does not close c.
+func (c *MultiplexedConn) Dial(remote net.Addr) Connection {
+	conn := &muxRemoteConn{
+		parent: c,
+		remote: remote,
+		in:     make(chan muxPacket, muxQueueSize),
+		closed: make(chan struct{}),
+	}
+	c.mux.Lock()
+	c.remotes[remote.String()] = conn
+	c.mux.Unlock()
+
+	return conn
+}
+
+// Client returns Client for STUN server at remote that shares c.
+func (c *MultiplexedConn) Client(remote net.Addr, options ...ClientOption) (*Client, error) {
+	return NewClient(c.Dial(remote), options...)
+}
+
+type muxRemoteConn struct {
+	parent *MultiplexedConn
+	remote net.Addr
+	in     chan muxPacket
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (c *muxRemoteConn) Read(b []byte) (int, error) {
+	select {
+	case p := <-c.in:
+		return copy(b, p.data), nil
+	case <-c.closed:
+		return 0, net.ErrClosed
+	case <-c.parent.closed:
+		return 0, c.parent.readErr()
+	}
+}
+
+func (c *muxRemoteConn) Write(b []byte) (int, error) {
+	return c.parent.conn.WriteTo(b, c.remote)
+}
+
+// LocalAddr returns local address of parent connection.
+func (c *muxRemoteConn) LocalAddr() net.Addr {
+	return c.parent.conn.LocalAddr()
+}
+
+// RemoteAddr returns dialed address.
+func (c *muxRemoteConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *muxRemoteConn) Close() error {
+	c.once.Do(func() {
+		c.parent.mux.Lock()
+		if c.parent.remotes[c.remote.String()] == c {
+			delete(c.parent.remotes, c.remote.String())
+		}
+		c.parent.mux.Unlock()
+		close(c.closed)
+	})
+
+	return nil
+}
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func listenLocalUDP(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func TestMultiplexedConn(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m := new(Message)
+			if Decode(buf[:n], m) != nil {
+				continue
+			}
+			res := MustBuild(m, BindingSuccess, &XORMappedAddress{
+				IP:   addr.(*net.UDPAddr).IP,   //nolint:forcetypeassert
+				Port: addr.(*net.UDPAddr).Port, //nolint:forcetypeassert
+			})
+			_, _ = server.WriteTo(res.Raw, addr)
+			// Sending media after response.
+			_, _ = server.WriteTo([]byte{0x80, 1, 2, 3}, addr)
+		}
+	}()
+	unmatched := make(chan MessageType, 1)
+	conn := NewMultiplexedConn(listenLocalUDP(t), WithSTUNHandler(func(m *Message, _ net.Addr) {
+		unmatched <- m.Type
+	}))
+	client, err := conn.Client(server.LocalAddr(), WithNoRetransmit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+
+			return
+		}
+		var addr XORMappedAddress
+		if getErr := addr.GetFrom(e.Message); getErr != nil {
+			t.Error(getErr)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, addr, err := conn.ReadNonSTUN(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 || addr.String() != server.LocalAddr().String() {
+		t.Errorf("unexpected non-STUN packet of %d bytes from %s", n, addr)
+	}
+
+	// Incoming request from unknown peer.
+	peer := listenLocalUDP(t)
+	defer peer.Close() //nolint:errcheck
+	if _, err = peer.WriteTo(MustBuild(TransactionID, BindingRequest).Raw, conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case typ := <-unmatched:
+		if typ != BindingRequest {
+			t.Errorf("unexpected type: %s", typ)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("handler not called")
+	}
+
+	if err = client.Close(); err != nil {
+		t.Error(err)
+	}
+	if err = conn.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, _, err = conn.ReadNonSTUN(buf); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import "sync"
+
+// registeredNames are names of attribute types and methods that are not
+// implemented by package, see RegisterAttrName and RegisterMethodName.
+var registeredNames = struct { //nolint:gochecknoglobals
+	mux     sync.RWMutex
+	attrs   map[AttrType]string
+	methods map[Method]string
+}{
+	attrs:   map[AttrType]string{},
+	methods: map[Method]string{},
+}
+
+// RegisterAttrName sets name of attribute type t that is printed by
+// AttrType.String and in message dumps instead of hex value, e.g. for
+// vendor attributes from 0xC000-0xFFFF range. Empty name removes
+// registration. Names of attributes that are implemented by package take
+// precedence over registered ones.
+//
+// Safe for concurrent use.
+func RegisterAttrName(t AttrType, name string) {
+	registeredNames.mux.Lock()
+	defer registeredNames.mux.Unlock()
+	if name == "" {
+		delete(registeredNames.attrs, t)
+	} else {
+		registeredNames.attrs[t] = name
+	}
+}
+
+// RegisterMethodName sets name of method m that is printed by
+// Method.String, MessageType.String and in message dumps instead of hex
+// value. Empty name removes registration. Names of methods that are
+// implemented by package take precedence over registered ones.
+//
+// Safe for concurrent use.
+func RegisterMethodName(m Method, name string) {
+	registeredNames.mux.Lock()
+	defer registeredNames.mux.Unlock()
+	if name == "" {
+		delete(registeredNames.methods, m)
+	} else {
+		registeredNames.methods[m] = name
+	}
+}
+
+func registeredAttrName(t AttrType) (string, bool) {
+	registeredNames.mux.RLock()
+	defer registeredNames.mux.RUnlock()
+	name, ok := registeredNames.attrs[t]
+
+	return name, ok
+}
+
+func registeredMethodName(m Method) (string, bool) {
+	registeredNames.mux.RLock()
+	defer registeredNames.mux.RUnlock()
+	name, ok := registeredNames.methods[m]
+
+	return name, ok
+}
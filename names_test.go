@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegisterAttrName(t *testing.T) {
+	const vendor AttrType = 0xC0FE
+	if s := vendor.String(); s != "0xc0fe" {
+		t.Fatalf("unexpected name: %s", s)
+	}
+	RegisterAttrName(vendor, "VENDOR-ATTR")
+	defer RegisterAttrName(vendor, "")
+	if s := vendor.String(); s != "VENDOR-ATTR" {
+		t.Errorf("unexpected name: %s", s)
+	}
+	m := MustBuild(TransactionID, BindingRequest, RawAttr{Type: vendor, Value: []byte{1}})
+	var dump strings.Builder
+	if err := m.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dump.String(), "VENDOR-ATTR (0xc0fe)") {
+		t.Errorf("name is not in dump:\n%s", dump.String())
+	}
+	RegisterAttrName(AttrSoftware, "OVERRIDE")
+	defer RegisterAttrName(AttrSoftware, "")
+	if s := AttrSoftware.String(); s != "SOFTWARE" {
+		t.Errorf("built-in name should take precedence: %s", s)
+	}
+	RegisterAttrName(vendor, "")
+	if s := vendor.String(); s != "0xc0fe" {
+		t.Errorf("unexpected name after removal: %s", s)
+	}
+}
+
+func TestRegisterMethodName(t *testing.T) {
+	const vendor Method = 0x0ff
+	RegisterMethodName(vendor, "Vendor")
+	defer RegisterMethodName(vendor, "")
+	if s := vendor.String(); s != "Vendor" {
+		t.Errorf("unexpected name: %s", s)
+	}
+	if s := NewType(vendor, ClassRequest).String(); s != "Vendor request" {
+		t.Errorf("unexpected name: %s", s)
+	}
+	RegisterMethodName(MethodBinding, "Override")
+	defer RegisterMethodName(MethodBinding, "")
+	if s := MethodBinding.String(); s != "Binding" {
+		t.Errorf("built-in name should take precedence: %s", s)
+	}
+	RegisterMethodName(vendor, "")
+	if s := vendor.String(); s != "0xff" {
+		t.Errorf("unexpected name after removal: %s", s)
+	}
+}
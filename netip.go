@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+)
+
+// NewXORMappedAddress returns XORMappedAddress of addr, with IPv4-mapped
+// IPv6 address converted to IPv4.
+func NewXORMappedAddress(addr netip.AddrPort) XORMappedAddress {
+	return XORMappedAddress{
+		IP:   addr.Addr().Unmap().AsSlice(),
+		Port: int(addr.Port()),
+	}
+}
+
+// Addr returns address as netip.AddrPort, which is invalid if a.IP is
+// invalid.
+func (a XORMappedAddress) Addr() netip.AddrPort {
+	ip, ok := netip.AddrFromSlice(a.IP)
+	if !ok {
+		return netip.AddrPort{}
+	}
+
+	return netip.AddrPortFrom(ip.Unmap(), uint16(a.Port)) //nolint:gosec // G115, port
+}
+
+// XORMappedAddr implements XOR-MAPPED-ADDRESS attribute as netip.AddrPort.
+//
+// Unlike XORMappedAddress, it is a value type, so encoding and decoding
+// do not allocate.
+//
+// RFC 5389 Section 15.2.
+type XORMappedAddr netip.AddrPort
+
+// NewXORMappedAddr returns XORMappedAddr of addr.
+func NewXORMappedAddr(addr netip.AddrPort) XORMappedAddr {
+	return XORMappedAddr(addr)
+}
+
+// AddrPort returns a as netip.AddrPort.
+func (a XORMappedAddr) AddrPort() netip.AddrPort {
+	return netip.AddrPort(a)
+}
+
+func (a XORMappedAddr) String() string {
+	return netip.AddrPort(a).String()
+}
+
+// xorKey returns key of XOR-MAPPED-ADDRESS value of m.
+func xorKey(m *Message) (key [4 + TransactionIDSize]byte) {
+	bin.PutUint32(key[0:4], magicCookie)
+	copy(key[4:], m.TransactionID[:])
+
+	return key
+}
+
+// AddToAs adds XOR-MAPPED-ADDRESS value to m as attr attribute. Can return
+// ErrBadIPLength if address is invalid.
+func (a XORMappedAddr) AddToAs(m *Message, attr AttrType) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	addr := netip.AddrPort(a)
+	ip := addr.Addr().Unmap()
+	var (
+		value  [4 + net.IPv6len]byte
+		raw    = ip.As16()
+		family = familyIPv6
+		ipLen  = net.IPv6len
+	)
+	switch {
+	case ip.Is4():
+		family, ipLen = familyIPv4, net.IPv4len
+		copy(raw[:], raw[12:16])
+	case !ip.Is6():
+		return ErrBadIPLength
+	}
+	key := xorKey(m)
+	bin.PutUint16(value[0:2], family)
+	bin.PutUint16(value[2:4], addr.Port()^uint16(magicCookie>>16)) //nolint:gosec // G115
+	for i := 0; i < ipLen; i++ {
+		value[4+i] = raw[i] ^ key[i]
+	}
+	m.Add(attr, value[:4+ipLen])
+
+	return nil
+}
+
+// AddTo adds XOR-MAPPED-ADDRESS to m.
+func (a XORMappedAddr) AddTo(m *Message) error {
+	return a.AddToAs(m, AttrXORMappedAddress)
+}
+
+// GetFromAs decodes XOR-MAPPED-ADDRESS value of attr attribute of m.
+// IPv4-mapped IPv6 address is decoded as IPv4.
+func (a *XORMappedAddr) GetFromAs(m *Message, attr AttrType) error {
+	value, err := m.Get(attr)
+	if err != nil {
+		return err
+	}
+	ipLen, err := addressLength(attr, value)
+	if err != nil {
+		return err
+	}
+	var (
+		key  = xorKey(m)
+		raw  [net.IPv6len]byte
+		port = bin.Uint16(value[2:4]) ^ uint16(magicCookie>>16) //nolint:gosec // G115
+		ip   netip.Addr
+	)
+	for i := 0; i < ipLen; i++ {
+		raw[i] = value[4+i] ^ key[i]
+	}
+	if ipLen == net.IPv4len {
+		ip = netip.AddrFrom4([net.IPv4len]byte(raw[:net.IPv4len]))
+	} else {
+		ip = netip.AddrFrom16(raw).Unmap()
+	}
+	*a = XORMappedAddr(netip.AddrPortFrom(ip, port))
+
+	return nil
+}
+
+// GetFrom decodes XOR-MAPPED-ADDRESS attribute of m.
+func (a *XORMappedAddr) GetFrom(m *Message) error {
+	return a.GetFromAs(m, AttrXORMappedAddress)
+}
+
+// MappedAddress returns reflexive transport address of m, decoded from
+// XOR-MAPPED-ADDRESS or, if it is absent, from MAPPED-ADDRESS that is
+// sent by RFC 3489 servers. IPv4-mapped IPv6 address is returned as IPv4.
+func (m *Message) MappedAddress() (netip.AddrPort, error) {
+	var xorAddr XORMappedAddr
+	err := xorAddr.GetFrom(m)
+	if err == nil {
+		return xorAddr.AddrPort(), nil
+	}
+	if !errors.Is(err, ErrAttributeNotFound) {
+		return netip.AddrPort{}, err
+	}
+	value, err := m.Get(AttrMappedAddress)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	ipLen, err := addressLength(AttrMappedAddress, value)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	ip, _ := netip.AddrFromSlice(value[4 : 4+ipLen])
+
+	return netip.AddrPortFrom(ip.Unmap(), bin.Uint16(value[2:4])), nil
+}
@@ -0,0 +1,152 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+func TestXORMappedAddr(t *testing.T) {
+	for _, s := range []string{
+		"192.0.2.1:3478",
+		"[2001:db8::1]:5349",
+	} {
+		addr := netip.MustParseAddrPort(s)
+		t.Run(s, func(t *testing.T) {
+			m := MustBuild(TransactionID, BindingSuccess, NewXORMappedAddr(addr))
+			var got XORMappedAddr
+			if err := got.GetFrom(m); err != nil {
+				t.Fatal(err)
+			}
+			if got.AddrPort() != addr {
+				t.Errorf("unexpected address: %s", got)
+			}
+			// Both types should be interchangeable.
+			var old XORMappedAddress
+			if err := old.GetFrom(m); err != nil {
+				t.Fatal(err)
+			}
+			if old.Addr() != addr {
+				t.Errorf("unexpected address: %s", old)
+			}
+			m = MustBuild(TransactionID, BindingSuccess, NewXORMappedAddress(addr))
+			if err := got.GetFrom(m); err != nil {
+				t.Fatal(err)
+			}
+			if got.AddrPort() != addr {
+				t.Errorf("unexpected address: %s", got)
+			}
+		})
+	}
+	t.Run("IPv4Mapped", func(t *testing.T) {
+		addr := XORMappedAddr(netip.MustParseAddrPort("[::ffff:192.0.2.1]:3478"))
+		m := MustBuild(TransactionID, BindingSuccess, addr)
+		var got XORMappedAddress
+		if err := got.GetFrom(m); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.IP) != 4 || got.Addr().String() != "192.0.2.1:3478" {
+			t.Errorf("unexpected address: %s", got)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		m := MustBuild(TransactionID, BindingSuccess)
+		if err := (XORMappedAddr{}).AddTo(m); !errors.Is(err, ErrBadIPLength) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if (XORMappedAddress{}).Addr().IsValid() {
+			t.Error("address should be invalid")
+		}
+		var got XORMappedAddr
+		if err := got.GetFrom(m); !errors.Is(err, ErrAttributeNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Allocations", func(t *testing.T) {
+		addr := NewXORMappedAddr(netip.MustParseAddrPort("[2001:db8::1]:5349"))
+		m := MustBuild(TransactionID, BindingSuccess, addr)
+		var got XORMappedAddr
+		testutil.ShouldNotAllocate(t, func() {
+			m.Reset()
+			m.WriteHeader()
+			if err := addr.AddTo(m); err != nil {
+				t.Error(err)
+			}
+			if err := got.GetFrom(m); err != nil {
+				t.Error(err)
+			}
+		})
+	})
+}
+
+func BenchmarkXORMappedAddr_GetFrom(b *testing.B) {
+	m := MustBuild(TransactionID, BindingSuccess,
+		NewXORMappedAddr(netip.MustParseAddrPort("[2001:db8::1]:5349")),
+	)
+	var addr XORMappedAddr
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := addr.GetFrom(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMessage_MappedAddress(t *testing.T) {
+	for _, s := range []string{
+		"192.0.2.1:3478",
+		"[2001:db8::1]:5349",
+	} {
+		addr := netip.MustParseAddrPort(s)
+		t.Run(s, func(t *testing.T) {
+			legacy := &MappedAddress{IP: addr.Addr().AsSlice(), Port: int(addr.Port())}
+			for name, m := range map[string]*Message{
+				"XOR":    MustBuild(TransactionID, BindingSuccess, NewXORMappedAddr(addr)),
+				"Legacy": MustBuild(TransactionID, BindingSuccess, legacy),
+				"Both": MustBuild(TransactionID, BindingSuccess,
+					&MappedAddress{IP: net.IPv4(203, 0, 113, 1), Port: 1}, NewXORMappedAddr(addr),
+				),
+			} {
+				got, err := m.MappedAddress()
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if got != addr {
+					t.Errorf("%s: unexpected address: %s", name, got)
+				}
+			}
+		})
+	}
+	t.Run("IPv4Mapped", func(t *testing.T) {
+		// AddTo encodes IPv4-mapped address as IPv4, so raw value is used.
+		m := MustBuild(TransactionID, BindingSuccess)
+		value := []byte{0, byte(familyIPv6), 0x0d, 0x96}
+		value = append(value, net.ParseIP("::ffff:192.0.2.1")...)
+		m.Add(AttrMappedAddress, value)
+		got, err := m.MappedAddress()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != "192.0.2.1:3478" {
+			t.Errorf("unexpected address: %s", got)
+		}
+	})
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := MustBuild(TransactionID, BindingSuccess).MappedAddress(); !errors.Is(err, ErrAttributeNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		m := MustBuild(TransactionID, BindingSuccess,
+			RawAttribute{Type: AttrXORMappedAddress, Value: []byte{0, 1, 0, 0}},
+			&MappedAddress{IP: net.IPv4(192, 0, 2, 1), Port: 3478},
+		)
+		if _, err := m.MappedAddress(); err == nil {
+			t.Error("malformed XOR-MAPPED-ADDRESS should not fall back")
+		}
+	})
+}
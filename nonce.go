@@ -0,0 +1,278 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Nonce validation errors.
+var (
+	ErrInvalidNonce = errors.New("invalid nonce")
+	ErrStaleNonce   = errors.New("stale nonce")
+)
+
+// SecurityFeatures are security feature bits of nonce cookie that server
+// uses to advertise support of RFC 8489 features.
+//
+// RFC 8489 Section 9.2 and Section 18.1.
+type SecurityFeatures uint32
+
+// Security features from RFC 8489 Section 18.1. Bit 0 is the most
+// significant bit of 24-bit value.
+const (
+	FeaturePasswordAlgorithms SecurityFeatures = 1 << 23 // bit 0
+	FeatureUsernameAnonymity  SecurityFeatures = 1 << 22 // bit 1
+)
+
+func (f SecurityFeatures) String() string {
+	if f == 0 {
+		return "none"
+	}
+	var parts []string
+	for _, feature := range []struct {
+		f    SecurityFeatures
+		name string
+	}{
+		{FeaturePasswordAlgorithms, "password-algorithms"},
+		{FeatureUsernameAnonymity, "username-anonymity"},
+	} {
+		if f&feature.f != 0 {
+			parts = append(parts, feature.name)
+			f &^= feature.f
+		}
+	}
+	if f != 0 {
+		parts = append(parts, fmt.Sprintf("0x%06x", uint32(f)))
+	}
+
+	return strings.Join(parts, "|")
+}
+
+// nonceCookiePrefix starts nonce that carries security feature bits.
+const nonceCookiePrefix = "obMatJos2"
+
+// nonceCookieSize is size of nonce cookie: prefix and 24 feature bits
+// encoded with base64.
+const nonceCookieSize = len(nonceCookiePrefix) + 4
+
+// appendNonceCookie appends nonce cookie with features to b.
+func appendNonceCookie(b []byte, features SecurityFeatures) []byte {
+	v := [3]byte{byte(features >> 16), byte(features >> 8), byte(features)}
+	b = append(b, nonceCookiePrefix...)
+	n := len(b)
+	b = append(b, make([]byte, 4)...)
+	base64.StdEncoding.Encode(b[n:], v[:])
+
+	return b
+}
+
+// NonceCookie is NONCE value that can start with RFC 8489 nonce cookie:
+// "obMatJos2" prefix followed by 24 security feature bits encoded with
+// base64, that server uses to advertise supported features.
+//
+// RFC 8489 Section 9.2.
+type NonceCookie Nonce
+
+// NewNonceCookie returns nonce that starts with cookie advertising
+// features, followed by value.
+func NewNonceCookie(features SecurityFeatures, value string) NonceCookie {
+	return append(appendNonceCookie(make([]byte, 0, nonceCookieSize+len(value)), features), value...)
+}
+
+// features decodes security features of cookie, returning false if n
+// does not start with valid nonce cookie.
+func (n NonceCookie) features() (SecurityFeatures, bool) {
+	if len(n) < nonceCookieSize || !strings.HasPrefix(string(n), nonceCookiePrefix) {
+		return 0, false
+	}
+	var v [3]byte
+	if _, err := base64.StdEncoding.Decode(v[:], n[len(nonceCookiePrefix):nonceCookieSize]); err != nil {
+		return 0, false
+	}
+
+	return SecurityFeatures(v[0])<<16 | SecurityFeatures(v[1])<<8 | SecurityFeatures(v[2]), true
+}
+
+// HasCookie reports whether n starts with valid nonce cookie.
+func (n NonceCookie) HasCookie() bool {
+	_, ok := n.features()
+
+	return ok
+}
+
+// Features returns security features advertised by nonce cookie, zero if
+// n has no cookie and server follows RFC 5389.
+func (n NonceCookie) Features() SecurityFeatures {
+	f, _ := n.features()
+
+	return f
+}
+
+func (n NonceCookie) String() string {
+	return string(n)
+}
+
+// AddTo adds NONCE to message.
+func (n NonceCookie) AddTo(m *Message) error {
+	return Nonce(n).AddTo(m)
+}
+
+// GetFrom gets NONCE from message.
+func (n *NonceCookie) GetFrom(m *Message) error {
+	return (*Nonce)(n).GetFrom(m)
+}
+
+// Nonce manager parameters.
+const (
+	DefaultNonceTTL = time.Minute * 10
+	nonceSecretSize = 32
+	nonceMACSize    = 16 // truncated HMAC-SHA256
+	nonceBucketSize = 8
+)
+
+// NonceOption sets NonceManager option.
+type NonceOption func(m *NonceManager)
+
+// WithNonceTTL sets lifetime of issued nonces, DefaultNonceTTL by
+// default.
+func WithNonceTTL(ttl time.Duration) NonceOption {
+	return func(m *NonceManager) {
+		m.ttl = ttl
+	}
+}
+
+// WithNonceSecret sets secret key that signs nonces. Managers that share
+// the secret, e.g. servers behind load balancer, accept nonces issued by
+// each other. Defaults to random secret, see SetRandSource.
+func WithNonceSecret(secret []byte) NonceOption {
+	return func(m *NonceManager) {
+		m.secret = append([]byte(nil), secret...)
+	}
+}
+
+// WithNonceClock sets Clock of manager, the source of current time.
+func WithNonceClock(clock Clock) NonceOption {
+	return func(m *NonceManager) {
+		m.clock = clock
+	}
+}
+
+// WithNonceFeatures prefixes issued nonces with RFC 8489 nonce cookie
+// that advertises features, so clients can use PASSWORD-ALGORITHMS and
+// USERHASH. Cookie is covered by signature, so clients can't strip it
+// to downgrade authentication.
+func WithNonceFeatures(features SecurityFeatures) NonceOption {
+	return func(m *NonceManager) {
+		m.cookie = appendNonceCookie(nil, features)
+	}
+}
+
+// NonceManager issues and validates NONCE values of long-term credential
+// mechanism without storing them. Nonce encodes time bucket of issue and
+// is signed with HMAC-SHA256, so it is valid for at least TTL and for
+// less than 2*TTL, while all nonces of the same bucket are equal.
+//
+// NonceManager is safe for concurrent use.
+type NonceManager struct {
+	secret []byte
+	ttl    time.Duration
+	clock  Clock
+	cookie []byte
+}
+
+// NewNonceManager initializes new NonceManager with provided options.
+func NewNonceManager(options ...NonceOption) (*NonceManager, error) {
+	m := &NonceManager{
+		ttl:   DefaultNonceTTL,
+		clock: systemClock(),
+	}
+	for _, o := range options {
+		o(m)
+	}
+	if m.ttl <= 0 {
+		m.ttl = DefaultNonceTTL
+	}
+	if m.secret == nil {
+		m.secret = make([]byte, nonceSecretSize)
+		if _, err := io.ReadFull(randReader(), m.secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// bucket returns time bucket of t.
+func (m *NonceManager) bucket(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(m.ttl))
+}
+
+// sign returns signature of nonce cookie and bucket.
+func (m *NonceManager) sign(cookie []byte, bucket []byte) []byte {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(cookie) //nolint:errcheck,gosec
+	mac.Write(bucket) //nolint:errcheck,gosec
+
+	return mac.Sum(nil)[:nonceMACSize]
+}
+
+// Nonce returns nonce for current time.
+func (m *NonceManager) Nonce() Nonce {
+	var v [nonceBucketSize + nonceMACSize]byte
+	bin.PutUint64(v[:nonceBucketSize], m.bucket(m.clock.Now()))
+	copy(v[nonceBucketSize:], m.sign(m.cookie, v[:nonceBucketSize]))
+	nonce := make([]byte, len(m.cookie), len(m.cookie)+base64.RawURLEncoding.EncodedLen(len(v)))
+	copy(nonce, m.cookie)
+	nonce = nonce[:cap(nonce)]
+	base64.RawURLEncoding.Encode(nonce[len(m.cookie):], v[:])
+
+	return Nonce(nonce)
+}
+
+// AddTo adds current nonce to message.
+func (m *NonceManager) AddTo(msg *Message) error {
+	return m.Nonce().AddTo(msg)
+}
+
+// Validate checks that nonce was issued by manager and is not expired,
+// returning ErrInvalidNonce or ErrStaleNonce otherwise. Server should
+// answer with 438 (Stale Nonce) error in both cases.
+func (m *NonceManager) Validate(nonce []byte) error {
+	var cookie []byte
+	if len(m.cookie) > 0 {
+		if !NonceCookie(nonce).HasCookie() {
+			return ErrInvalidNonce
+		}
+		cookie, nonce = nonce[:nonceCookieSize], nonce[nonceCookieSize:]
+	}
+	var v [nonceBucketSize + nonceMACSize]byte
+	if base64.RawURLEncoding.DecodedLen(len(nonce)) != len(v) {
+		return ErrInvalidNonce
+	}
+	if _, err := base64.RawURLEncoding.Decode(v[:], nonce); err != nil {
+		return ErrInvalidNonce
+	}
+	if !hmac.Equal(v[nonceBucketSize:], m.sign(cookie, v[:nonceBucketSize])) {
+		return ErrInvalidNonce
+	}
+	if current := m.bucket(m.clock.Now()); current-bin.Uint64(v[:nonceBucketSize]) > 1 {
+		return ErrStaleNonce
+	}
+
+	return nil
+}
+
+// Fresh reports whether nonce is valid, see Validate. It can be passed
+// to SecurityReport.CheckNonce.
+func (m *NonceManager) Fresh(nonce []byte) bool {
+	return m.Validate(nonce) == nil
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time { return c.now }
+
+func TestNonceManager(t *testing.T) {
+	const ttl = time.Minute
+	clock := &fixedClock{now: time.Unix(1700000000, 0)}
+	secret := []byte("secret")
+	m, err := NewNonceManager(WithNonceTTL(ttl), WithNonceSecret(secret), WithNonceClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := m.Nonce()
+	if err = m.Validate(nonce); err != nil {
+		t.Fatal(err)
+	}
+	if len(nonce) >= maxNonceB {
+		t.Errorf("nonce is too long: %d", len(nonce))
+	}
+	if string(m.Nonce()) != string(nonce) {
+		t.Error("nonces of the same bucket should be equal")
+	}
+	t.Run("Rotation", func(t *testing.T) {
+		defer func(now time.Time) { clock.now = now }(clock.now)
+		clock.now = clock.now.Add(ttl)
+		if !m.Fresh(nonce) {
+			t.Error("nonce of previous bucket should be valid")
+		}
+		if string(m.Nonce()) == string(nonce) {
+			t.Error("nonce should rotate")
+		}
+		clock.now = clock.now.Add(ttl)
+		if err := m.Validate(nonce); !errors.Is(err, ErrStaleNonce) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("SharedSecret", func(t *testing.T) {
+		other, err := NewNonceManager(WithNonceTTL(ttl), WithNonceSecret(secret), WithNonceClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := other.Validate(nonce); err != nil {
+			t.Error(err)
+		}
+		random, err := NewNonceManager(WithNonceTTL(ttl), WithNonceClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := random.Validate(nonce); !errors.Is(err, ErrInvalidNonce) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		tampered := append([]byte(nil), nonce...)
+		tampered[0]++
+		for _, v := range [][]byte{nil, []byte("nonce"), tampered, append([]byte(nonce), 'A')} {
+			if err := m.Validate(v); !errors.Is(err, ErrInvalidNonce) {
+				t.Errorf("%q: unexpected error: %v", v, err)
+			}
+		}
+	})
+	t.Run("Features", func(t *testing.T) {
+		features := FeaturePasswordAlgorithms | FeatureUsernameAnonymity
+		withCookie, err := NewNonceManager(
+			WithNonceSecret(secret), WithNonceClock(clock), WithNonceFeatures(features),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := withCookie.Nonce()
+		if !strings.HasPrefix(nonce.String(), "obMatJos2wAAA") {
+			t.Errorf("unexpected cookie: %s", nonce)
+		}
+		if err := withCookie.Validate(nonce); err != nil {
+			t.Error(err)
+		}
+		downgraded := append([]byte("obMatJos2AAAA"), nonce[nonceCookieSize:]...)
+		if err := withCookie.Validate(downgraded); !errors.Is(err, ErrInvalidNonce) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err := withCookie.Validate(nonce[nonceCookieSize:]); !errors.Is(err, ErrInvalidNonce) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("AddTo", func(t *testing.T) {
+		msg := MustBuild(TransactionID, BindingRequest, m)
+		var got Nonce
+		if err := got.GetFrom(msg); err != nil {
+			t.Fatal(err)
+		}
+		if !m.Fresh(got) {
+			t.Error("nonce should be valid")
+		}
+	})
+}
+
+func TestNonceCookie(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		nonce    NonceCookie
+		cookie   bool
+		features SecurityFeatures
+	}{
+		{"RFC5389", NonceCookie("f//499k954d6OL34oL9FSTvy64sA"), false, 0},
+		{"Empty", NonceCookie("obMatJos2AAAAnonce"), true, 0},
+		{"PasswordAlgorithms", NewNonceCookie(FeaturePasswordAlgorithms, "nonce"), true, FeaturePasswordAlgorithms},
+		{"UsernameAnonymity", NonceCookie("obMatJos2QAAAnonce"), true, FeatureUsernameAnonymity},
+		{"Short", NonceCookie("obMatJos2gA"), false, 0},
+		{"Malformed", NonceCookie("obMatJos2****nonce"), false, 0},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.nonce.HasCookie() != tc.cookie {
+				t.Errorf("unexpected HasCookie: %v", !tc.cookie)
+			}
+			if f := tc.nonce.Features(); f != tc.features {
+				t.Errorf("unexpected features: %s, expected %s", f, tc.features)
+			}
+		})
+	}
+	t.Run("Message", func(t *testing.T) {
+		m := MustBuild(TransactionID, BindingError, NewNonceCookie(FeatureUsernameAnonymity, "nonce"))
+		var nonce NonceCookie
+		if err := nonce.GetFrom(m); err != nil {
+			t.Fatal(err)
+		}
+		if nonce.String() != "obMatJos2QAAAnonce" {
+			t.Errorf("unexpected nonce: %s", nonce)
+		}
+	})
+}
+
+func TestSecurityFeatures_String(t *testing.T) {
+	for f, s := range map[SecurityFeatures]string{
+		0:                         "none",
+		FeaturePasswordAlgorithms: "password-algorithms",
+		FeaturePasswordAlgorithms | FeatureUsernameAnonymity | 1: "password-algorithms|username-anonymity|0x000001",
+	} {
+		if f.String() != s {
+			t.Errorf("%q, expected %q", f, s)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrNoDestination means that message is sent by client of unconnected
+// connection without destination address, e.g. via Start instead of
+// StartTo.
+var ErrNoDestination = errors.New("no destination for unconnected connection")
+
+// packetConnection is Connection of unconnected net.PacketConn, that
+// can be written only via WriteTo.
+type packetConnection struct {
+	net.PacketConn
+}
+
+func (c *packetConnection) Read(b []byte) (int, error) {
+	n, _, err := c.ReadFrom(b)
+
+	return n, err
+}
+
+func (c *packetConnection) Write([]byte) (int, error) {
+	return 0, ErrNoDestination
+}
+
+// NewPacketClient initializes new Client over unconnected conn, e.g. ICE
+// agent socket that is shared between many remote candidates. Messages
+// are sent via StartTo and DoTo with destination address, and responses
+// are matched by transaction ID and source address, so response from
+// other address than request was sent to is dropped.
+//
+// Options that require connected conn, like WithReconnect, are not
+// supported.
+//
+// Source of responses is checked with SourceCheckDestination level by
+// default, see WithSourceCheck.
+func NewPacketClient(conn net.PacketConn, options ...ClientOption) (*Client, error) {
+	if conn == nil {
+		return nil, ErrNoConnection
+	}
+	options = append([]ClientOption{WithSourceCheck(SourceCheckDestination)}, options...)
+
+	return NewClient(&packetConnection{PacketConn: conn}, options...)
+}
+
+// SourceCheck is level of response source address validation, that
+// discards off-path spoofed responses.
+type SourceCheck byte
+
+const (
+	// SourceCheckNone disables source address validation.
+	SourceCheckNone SourceCheck = iota
+	// SourceCheckDestination accepts response only from destination of
+	// request. Responses to requests with CHANGE-REQUEST are not checked.
+	SourceCheckDestination
+	// SourceCheckOtherAddress is like SourceCheckDestination, but response
+	// to request with CHANGE-REQUEST is accepted only from address that is
+	// changed as requested to OTHER-ADDRESS (or CHANGED-ADDRESS) of previous
+	// response from destination. Such response is not checked if other
+	// address of destination is not known yet.
+	SourceCheckOtherAddress
+)
+
+// WithSourceCheck sets level of response source address validation.
+//
+// Source address is only known to client of unconnected connection, see
+// NewPacketClient, as connected socket only receives packets from its
+// remote address anyway.
+func WithSourceCheck(level SourceCheck) ClientOption {
+	return func(c *Client) {
+		c.sourceCheck = level
+	}
+}
+
+// CHANGE-REQUEST flags, RFC 5780 Section 7.2.
+const (
+	changeIPFlag   = 0x04
+	changePortFlag = 0x02
+)
+
+// expectedSource returns address that response to msg sent to to is
+// expected from, or nil if source should not be checked.
+func (c *Client) expectedSource(msg *Message, to net.Addr) net.Addr {
+	if c.sourceCheck == SourceCheckNone || to == nil {
+		return nil
+	}
+	v, err := msg.Get(AttrChangeRequest)
+	if err != nil || len(v) != 4 || v[3]&(changeIPFlag|changePortFlag) == 0 {
+		return to
+	}
+	if c.sourceCheck != SourceCheckOtherAddress {
+		return nil
+	}
+	c.mux.RLock()
+	other := c.other[to.String()]
+	c.mux.RUnlock()
+	dst, dstOK := to.(*net.UDPAddr)
+	src, srcOK := other.(*net.UDPAddr)
+	if !dstOK || !srcOK {
+		return nil
+	}
+	expected := &net.UDPAddr{IP: dst.IP, Port: dst.Port}
+	if v[3]&changeIPFlag != 0 {
+		expected.IP = src.IP
+	}
+	if v[3]&changePortFlag != 0 {
+		expected.Port = src.Port
+	}
+
+	return expected
+}
+
+// learnOtherAddress saves OTHER-ADDRESS of response m from to, falling
+// back to CHANGED-ADDRESS of RFC 3489 servers.
+func (c *Client) learnOtherAddress(to net.Addr, m *Message) {
+	var other OtherAddress
+	if other.GetFrom(m) != nil && (*ChangedAddress)(&other).GetFrom(m) != nil {
+		return
+	}
+	c.mux.Lock()
+	if c.other == nil {
+		c.other = make(map[string]net.Addr)
+	}
+	c.other[to.String()] = &net.UDPAddr{IP: other.IP, Port: other.Port}
+	c.mux.Unlock()
+}
+
+// expectedFrom reports whether message of transaction id can be received
+// from address, i.e. transaction is unknown or response is expected from
+// any address or from.
+func (c *Client) expectedFrom(id transactionID, from net.Addr) bool {
+	c.mux.RLock()
+	t, found := c.t[id]
+	var source net.Addr
+	if found {
+		source = t.source
+	}
+	c.mux.RUnlock()
+
+	return source == nil || sameAddr(source, from)
+}
+
+// sameAddr reports whether a and b are same address, treating
+// IPv4-mapped IPv6 addresses as IPv4.
+func sameAddr(a, b net.Addr) bool {
+	ua, aOK := a.(*net.UDPAddr)
+	ub, bOK := b.(*net.UDPAddr)
+	if aOK && bOK {
+		return ua.Port == ub.Port && ua.IP.Equal(ub.IP)
+	}
+
+	return a.Network() == b.Network() && a.String() == b.String()
+}
@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPacketClient(t *testing.T) {
+	var servers []net.PacketConn
+	for i := 0; i < 2; i++ {
+		server := listenLocalUDP(t)
+		defer server.Close() //nolint:errcheck
+		go serveBinding(server, func(req *Message, addr net.Addr) *Message {
+			udpAddr := addr.(*net.UDPAddr) //nolint:forcetypeassert
+
+			return MustBuild(req, BindingSuccess, &XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port})
+		})
+		servers = append(servers, server)
+	}
+	conn := listenLocalUDP(t)
+	c, err := NewPacketClient(conn, WithRTO(time.Millisecond*50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	for _, server := range servers {
+		var res Event
+		if err = c.DoTo(MustBuild(TransactionID, BindingRequest), server.LocalAddr(), func(e Event) {
+			res = e
+			if e.Message != nil {
+				res.Message = e.Message.Clone()
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if res.Remote.String() != server.LocalAddr().String() {
+			t.Errorf("unexpected remote: %s", res.Remote)
+		}
+		addr, err := res.Message.MappedAddress()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(addr.Port()) != conn.LocalAddr().(*net.UDPAddr).Port { //nolint:forcetypeassert
+			t.Errorf("unexpected mapped address: %s", addr)
+		}
+	}
+	if err = c.Do(MustBuild(TransactionID, BindingRequest), func(Event) {}); !errors.Is(err, ErrNoDestination) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = NewPacketClient(nil); !errors.Is(err, ErrNoConnection) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPacketClient_UnexpectedSource(t *testing.T) {
+	for level, spoofed := range map[SourceCheck]bool{
+		SourceCheckNone:        true,
+		SourceCheckDestination: false,
+	} {
+		var (
+			server   = listenLocalUDP(t)
+			attacker = listenLocalUDP(t)
+		)
+		defer server.Close()   //nolint:errcheck
+		defer attacker.Close() //nolint:errcheck
+		go serveBinding(server, func(req *Message, addr net.Addr) *Message {
+			// Off-path response with same transaction ID arrives first.
+			_, _ = attacker.WriteTo(MustBuild(req, BindingSuccess, &XORMappedAddress{
+				IP: net.IPv4(192, 0, 2, 1), Port: 1,
+			}).Raw, addr)
+			time.Sleep(time.Millisecond * 10)
+			udpAddr := addr.(*net.UDPAddr) //nolint:forcetypeassert
+
+			return MustBuild(req, BindingSuccess, &XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port})
+		})
+		addr := packetClientDo(t, listenLocalUDP(t), MustBuild(TransactionID, BindingRequest),
+			server.LocalAddr(), WithSourceCheck(level),
+		)
+		if addr.Addr().IsLoopback() == spoofed {
+			t.Errorf("%d: unexpected address %s", level, addr)
+		}
+	}
+}
+
+func TestPacketClient_OtherAddress(t *testing.T) {
+	var (
+		primary   = listenLocalUDP(t)
+		alternate = listenLocalUDP(t)
+		attacker  = listenLocalUDP(t)
+	)
+	defer primary.Close()   //nolint:errcheck
+	defer alternate.Close() //nolint:errcheck
+	defer attacker.Close()  //nolint:errcheck
+	go serveBinding(primary, func(req *Message, addr net.Addr) *Message {
+		udpAddr := addr.(*net.UDPAddr)                //nolint:forcetypeassert
+		other := alternate.LocalAddr().(*net.UDPAddr) //nolint:forcetypeassert
+		res := MustBuild(req, BindingSuccess,
+			&XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port},
+			&OtherAddress{IP: other.IP, Port: other.Port},
+		)
+		if _, err := req.Get(AttrChangeRequest); err != nil {
+			return res
+		}
+		_, _ = attacker.WriteTo(MustBuild(req, BindingSuccess, &XORMappedAddress{
+			IP: net.IPv4(192, 0, 2, 1), Port: 1,
+		}).Raw, addr)
+		time.Sleep(time.Millisecond * 10)
+		_, _ = alternate.WriteTo(res.Raw, addr)
+
+		return nil
+	})
+	var (
+		conn = listenLocalUDP(t)
+		c    = newPacketClient(t, conn, WithSourceCheck(SourceCheckOtherAddress))
+	)
+	defer c.Close() //nolint:errcheck
+	for _, m := range []*Message{
+		MustBuild(TransactionID, BindingRequest),
+		MustBuild(TransactionID, BindingRequest, RawAttribute{
+			Type: AttrChangeRequest, Value: []byte{0, 0, 0, changePortFlag},
+		}),
+	} {
+		var (
+			res     Event
+			resAddr netip.AddrPort
+		)
+		if err := c.DoTo(m, primary.LocalAddr(), func(e Event) {
+			res = e
+			if e.Error == nil {
+				resAddr, res.Error = e.Message.MappedAddress()
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if !resAddr.Addr().IsLoopback() {
+			t.Errorf("spoofed response is accepted: %s", resAddr)
+		}
+	}
+	if _, ok := c.other[primary.LocalAddr().String()]; !ok {
+		t.Error("OTHER-ADDRESS is not saved")
+	}
+}
+
+func newPacketClient(t *testing.T, conn net.PacketConn, options ...ClientOption) *Client {
+	t.Helper()
+	c, err := NewPacketClient(conn, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// packetClientDo performs transaction of m with to over new client of
+// conn, returning mapped address of response.
+func packetClientDo(
+	t *testing.T, conn net.PacketConn, m *Message, to net.Addr, options ...ClientOption,
+) netip.AddrPort {
+	t.Helper()
+	c := newPacketClient(t, conn, options...)
+	defer func() {
+		if err := c.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	var (
+		addr netip.AddrPort
+		err  error
+	)
+	if doErr := c.DoTo(m, to, func(e Event) {
+		if err = e.Error; err == nil {
+			addr, err = e.Message.MappedAddress()
+		}
+	}); doErr != nil {
+		t.Fatal(doErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return addr
+}
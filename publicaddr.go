@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PublicAddr returns public (server reflexive) address of the host as
+// seen by STUN server at uri, e.g. "stun:stun.l.google.com:19302".
+//
+// It dials the server, performs single Binding transaction with
+// retransmissions configured by opts and closes connection. The
+// transaction is stopped if ctx is done before response is received.
+func PublicAddr(ctx context.Context, uri string, opts ...ClientOption) (netip.AddrPort, error) {
+	u, err := ParseURI(uri)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	if err = ctx.Err(); err != nil {
+		return netip.AddrPort{}, err
+	}
+	conn, err := dialURI(u, &DialConfig{})
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	client, err := NewClient(conn, opts...)
+	if err != nil {
+		_ = conn.Close()
+
+		return netip.AddrPort{}, err
+	}
+	defer client.Close() //nolint:errcheck
+	addr, _, err := bindingAddr(ctx, client)
+
+	return addr, err
+}
+
+// QueryResult is result of Binding transaction with single server.
+type QueryResult struct {
+	URI  string
+	Addr netip.AddrPort // mapped address
+	RTT  time.Duration
+	Err  error
+}
+
+// QueryResults are results of QueryAll in order of queried URIs.
+type QueryResults []QueryResult
+
+// Disagree reports whether servers returned different mapped addresses.
+// As QueryAll sends all requests from single socket, this hints that NAT
+// mapping depends on destination, i.e. NAT is symmetric (RFC 4787
+// Section 4.1).
+func (r QueryResults) Disagree() bool {
+	var first netip.AddrPort
+	for _, res := range r {
+		switch {
+		case res.Err != nil:
+		case !first.IsValid():
+			first = res.Addr
+		case res.Addr != first:
+			return true
+		}
+	}
+
+	return false
+}
+
+// QueryAll concurrently queries STUN servers at uris for mapped address
+// from single local UDP socket, e.g. for NAT type heuristics or server
+// health checking. Only "stun" scheme URIs of distinct IPv4 servers are
+// supported, errors of each server are reported in its QueryResult.
+//
+// Returns error only if local socket can not be opened.
+func QueryAll(ctx context.Context, uris []string, opts ...ClientOption) (QueryResults, error) {
+	conn, err := net.ListenPacket("udp4", ":0") //nolint:noctx
+	if err != nil {
+		return nil, err
+	}
+	mux := NewMultiplexedConn(conn)
+	defer mux.Close() //nolint:errcheck
+	var (
+		results = make(QueryResults, len(uris))
+		wg      sync.WaitGroup
+	)
+	for i, uri := range uris {
+		results[i].URI = uri
+		wg.Add(1)
+		go func(res *QueryResult) {
+			defer wg.Done()
+			res.Addr, res.RTT, res.Err = queryURI(ctx, mux, res.URI, opts)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func queryURI(
+	ctx context.Context, mux *MultiplexedConn, uri string, opts []ClientOption,
+) (netip.AddrPort, time.Duration, error) {
+	u, err := ParseURI(uri)
+	if err != nil {
+		return netip.AddrPort{}, 0, err
+	}
+	if u.Scheme != SchemeTypeSTUN {
+		return netip.AddrPort{}, 0, ErrUnsupportedURI
+	}
+	addrs, err := resolveAddrs(ctx, defaultDNSCache, "udp4", u.Host, strconv.Itoa(u.Port))
+	if err != nil {
+		return netip.AddrPort{}, 0, err
+	}
+	remote, err := net.ResolveUDPAddr("udp4", addrs[0])
+	if err != nil {
+		return netip.AddrPort{}, 0, err
+	}
+	client, err := mux.Client(remote, opts...)
+	if err != nil {
+		return netip.AddrPort{}, 0, err
+	}
+	defer client.Close() //nolint:errcheck
+
+	return bindingAddr(ctx, client)
+}
+
+// bindingAddr performs Binding transaction over c, returning mapped
+// address of response and RTT.
+func bindingAddr(ctx context.Context, c *Client) (netip.AddrPort, time.Duration, error) {
+	type result struct {
+		addr netip.AddrPort
+		rtt  time.Duration
+		err  error
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	if err := c.Start(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		if e.Error != nil {
+			done <- result{err: e.Error}
+
+			return
+		}
+		if e.Message.Type.Class == ClassErrorResponse {
+			done <- result{err: newTransactionError(e.Message)}
+
+			return
+		}
+		// RTT is not measured for retransmitted requests, so time of
+		// whole transaction is used instead.
+		rtt := e.RTT
+		if rtt == 0 {
+			rtt = time.Since(start)
+		}
+		addr, err := e.Message.MappedAddress()
+		done <- result{addr: addr, rtt: rtt, err: err}
+	}); err != nil {
+		return netip.AddrPort{}, 0, err
+	}
+	select {
+	case r := <-done:
+		return r.addr, r.rtt, r.err
+	case <-ctx.Done():
+		return netip.AddrPort{}, 0, ctx.Err()
+	}
+}
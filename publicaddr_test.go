@@ -0,0 +1,135 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// serveBinding runs STUN server on conn that responds to Binding
+// requests with message built by respond, ignoring request if respond
+// returns nil.
+func serveBinding(conn net.PacketConn, respond func(req *Message, addr net.Addr) *Message) {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req := new(Message)
+		if Decode(buf[:n], req) != nil {
+			continue
+		}
+		if res := respond(req, addr); res != nil {
+			_, _ = conn.WriteTo(res.Raw, addr)
+		}
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go serveBinding(server, func(req *Message, addr net.Addr) *Message {
+		udpAddr := addr.(*net.UDPAddr) //nolint:forcetypeassert
+
+		return MustBuild(req, BindingSuccess, &XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port})
+	})
+	addr, err := PublicAddr(context.Background(), "stun:"+server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.Addr() != netip.MustParseAddr("127.0.0.1") || addr.Port() == 0 {
+		t.Errorf("unexpected address: %s", addr)
+	}
+	if _, err = PublicAddr(context.Background(), "http://example.com"); err == nil {
+		t.Error("should fail on invalid URI")
+	}
+}
+
+func TestPublicAddr_ErrorResponse(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go serveBinding(server, func(req *Message, _ net.Addr) *Message {
+		return MustBuild(req, BindingError, CodeBadRequest)
+	})
+	_, err := PublicAddr(context.Background(), "stun:"+server.LocalAddr().String())
+	if !errors.Is(err, ErrErrorResponse) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPublicAddr_Context(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go serveBinding(server, func(*Message, net.Addr) *Message {
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err := PublicAddr(ctx, "stun:"+server.LocalAddr().String())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = PublicAddr(ctx, "stun:"+server.LocalAddr().String()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestQueryAll(t *testing.T) {
+	reflect := func(req *Message, addr net.Addr) *Message {
+		udpAddr := addr.(*net.UDPAddr) //nolint:forcetypeassert
+
+		return MustBuild(req, BindingSuccess, &XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port})
+	}
+	var uris []string
+	for i := 0; i < 2; i++ {
+		server := listenLocalUDP(t)
+		defer server.Close() //nolint:errcheck
+		go serveBinding(server, reflect)
+		uris = append(uris, "stun:"+server.LocalAddr().String())
+	}
+	results, err := QueryAll(context.Background(), append(uris, "turn:127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	for _, res := range results[:2] {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if res.Addr != results[0].Addr || res.RTT <= 0 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+	}
+	if !errors.Is(results[2].Err, ErrUnsupportedURI) {
+		t.Errorf("unexpected error: %v", results[2].Err)
+	}
+	if results.Disagree() {
+		t.Error("servers should agree")
+	}
+	// Server behind other NAT mapping.
+	other := listenLocalUDP(t)
+	defer other.Close() //nolint:errcheck
+	go serveBinding(other, func(req *Message, _ net.Addr) *Message {
+		return MustBuild(req, BindingSuccess, &XORMappedAddress{IP: net.IPv4(192, 0, 2, 1), Port: 3478})
+	})
+	if results, err = QueryAll(context.Background(), []string{
+		uris[0], "stun:" + other.LocalAddr().String(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !results.Disagree() {
+		t.Errorf("servers should disagree: %+v", results)
+	}
+}
@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"crypto/rand"
+	"io"
+	"sync/atomic"
+)
+
+// randSourceHolder wraps reader, so readers of different types can be
+// stored in atomic.Value.
+type randSourceHolder struct {
+	r io.Reader
+}
+
+var randSource atomic.Value //nolint:gochecknoglobals
+
+// SetRandSource sets source of randomness for transaction IDs and secrets
+// of NonceManager, e.g. certified DRBG of FIPS module. Source must be
+// cryptographically secure and safe for concurrent use. Nil r restores
+// default crypto/rand source.
+func SetRandSource(r io.Reader) {
+	if r == nil {
+		r = rand.Reader
+	}
+	randSource.Store(randSourceHolder{r: r})
+}
+
+// randReader returns source that is set by SetRandSource.
+func randReader() io.Reader {
+	if h, ok := randSource.Load().(randSourceHolder); ok {
+		return h.r
+	}
+
+	return rand.Reader
+}
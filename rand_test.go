@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestSetRandSource(t *testing.T) {
+	defer SetRandSource(nil)
+	seq := make([]byte, 128)
+	for i := range seq {
+		seq[i] = byte(i)
+	}
+	SetRandSource(bytes.NewReader(seq))
+	if id := NewTransactionID(); !bytes.Equal(id[:], seq[:TransactionIDSize]) {
+		t.Errorf("unexpected transaction ID: %x", id)
+	}
+	m := New()
+	if err := m.NewTransactionID(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.TransactionID[:], seq[TransactionIDSize:2*TransactionIDSize]) {
+		t.Errorf("unexpected transaction ID: %x", m.TransactionID)
+	}
+	nonces, err := NewNonceManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(nonces.secret, seq[2*TransactionIDSize:2*TransactionIDSize+nonceSecretSize]) {
+		t.Errorf("unexpected secret: %x", nonces.secret)
+	}
+
+	SetRandSource(bytes.NewReader(nil))
+	if err = m.NewTransactionID(); !errors.Is(err, io.EOF) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = NewNonceManager(); !errors.Is(err, io.EOF) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	SetRandSource(nil)
+	if err = m.NewTransactionID(); err != nil {
+		t.Error(err)
+	}
+}
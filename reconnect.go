@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConnectionState is state of client connection, see
+// Client.OnConnectionStateChange.
+type ConnectionState byte
+
+// Possible values for ConnectionState.
+const (
+	ConnectionStateConnected    ConnectionState = iota // connection is usable
+	ConnectionStateDisconnected                        // read failed, redialing
+	ConnectionStateClosed                              // client is closed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionStateConnected:
+		return "connected"
+	case ConnectionStateDisconnected:
+		return "disconnected"
+	case ConnectionStateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// WithReconnect enables re-establishment of client connection: when
+// reading from connection fails, e.g. on ICMP port unreachable error of
+// UDP socket, client calls dial to get new connection, retrying with
+// delays of backoff until success or Close. Transactions in progress are
+// retransmitted over new connection.
+//
+// Failed connection and connections returned by dial are closed by
+// client, regardless of WithNoConnClose.
+func WithReconnect(dial func() (Connection, error), backoff Schedule) ClientOption {
+	return func(c *Client) {
+		c.reconnect.dial = dial
+		c.reconnect.backoff = backoff
+	}
+}
+
+type reconnect struct {
+	dial    func() (Connection, error)
+	backoff Schedule
+
+	mux     sync.Mutex
+	state   ConnectionState
+	handler func(ConnectionState)
+}
+
+// OnConnectionStateChange sets handler that is called when state of
+// client connection changes.
+func (c *Client) OnConnectionStateChange(f func(ConnectionState)) {
+	c.reconnect.mux.Lock()
+	c.reconnect.handler = f
+	c.reconnect.mux.Unlock()
+}
+
+// ConnectionState returns current state of client connection.
+func (c *Client) ConnectionState() ConnectionState {
+	c.reconnect.mux.Lock()
+	defer c.reconnect.mux.Unlock()
+
+	return c.reconnect.state
+}
+
+func (c *Client) setConnectionState(s ConnectionState) {
+	c.reconnect.mux.Lock()
+	changed := c.reconnect.state != s
+	c.reconnect.state = s
+	h := c.reconnect.handler
+	c.reconnect.mux.Unlock()
+	if changed && h != nil {
+		h(s)
+	}
+}
+
+// conn returns current connection of client.
+func (c *Client) conn() Connection {
+	c.connMux.RLock()
+	defer c.connMux.RUnlock()
+
+	return c.c
+}
+
+// redial replaces failed connection with new one, returning nil if
+// client is closed before that.
+func (c *Client) redial(readErr error) Connection {
+	c.log.Debugf("client: failed to read: %v, reconnecting", readErr)
+	c.setConnectionState(ConnectionStateDisconnected)
+	for attempt := 0; ; attempt++ {
+		timer := time.NewTimer(c.reconnect.backoff.Next(attempt))
+		select {
+		case <-c.close:
+			timer.Stop()
+
+			return nil
+		case <-timer.C:
+		}
+		conn, err := c.reconnect.dial()
+		if err != nil {
+			c.log.Debugf("client: failed to reconnect: %v", err)
+
+			continue
+		}
+		if err = c.setDSCP(conn); err != nil {
+			c.log.Debugf("client: failed to set DSCP: %v", err)
+		}
+		if err = c.setFlowLabel(conn); err != nil {
+			c.log.Debugf("client: failed to set flow label: %v", err)
+		}
+		c.connMux.Lock()
+		if c.isClosed() {
+			c.connMux.Unlock()
+			_ = conn.Close()
+
+			return nil
+		}
+		old := c.c
+		c.c, c.closeConn = conn, true
+		c.connMux.Unlock()
+		_ = old.Close()
+		c.logHistory("connection re-established")
+		c.setConnectionState(ConnectionStateConnected)
+
+		return conn
+	}
+}
+
+// isRedialWriteErr reports whether err of writing to connection means
+// that socket is broken and connection should be redialed, e.g. ICMP
+// port unreachable reported to writer. Other errors, like EMSGSIZE for
+// message that does not fit into MTU, are returned to caller.
+func isRedialWriteErr(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestConnectionState_String(t *testing.T) {
+	for s, str := range map[ConnectionState]string{
+		ConnectionStateConnected:    "connected",
+		ConnectionStateDisconnected: "disconnected",
+		ConnectionStateClosed:       "closed",
+		ConnectionState(100):        "unknown",
+	} {
+		if s.String() != str {
+			t.Errorf("%q != %q", s, str)
+		}
+	}
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m := new(Message)
+			if Decode(buf[:n], m) != nil {
+				continue
+			}
+			_, _ = server.WriteTo(MustBuild(m, BindingSuccess).Raw, addr)
+		}
+	}()
+	// Writing to closed port results in ICMP port unreachable, so next
+	// read from connected socket fails.
+	closed := listenLocalUDP(t)
+	closedAddr := closed.LocalAddr().String()
+	if err := closed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("udp4", closedAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dials := 0
+	client, err := NewClient(conn,
+		WithReconnect(func() (Connection, error) {
+			dials++
+
+			return net.Dial("udp4", server.LocalAddr().String())
+		}, Schedule{Initial: time.Millisecond}),
+		WithRTO(time.Millisecond*50),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make(chan ConnectionState, 10)
+	client.OnConnectionStateChange(func(s ConnectionState) {
+		states <- s
+	})
+	var res Event
+	if err = client.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		res = e
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if err = client.Close(); err != nil {
+		t.Error(err)
+	}
+	if dials != 1 {
+		t.Errorf("unexpected dials: %d", dials)
+	}
+	for _, expected := range []ConnectionState{
+		ConnectionStateDisconnected, ConnectionStateConnected, ConnectionStateClosed,
+	} {
+		select {
+		case s := <-states:
+			if s != expected {
+				t.Errorf("unexpected state: %s, expected %s", s, expected)
+			}
+		default:
+			t.Fatalf("no %s state", expected)
+		}
+	}
+	if client.ConnectionState() != ConnectionStateClosed {
+		t.Errorf("unexpected state: %s", client.ConnectionState())
+	}
+}
+
+func TestClient_ReconnectWriteError(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		writeErr error
+		err      error
+		closed   bool
+	}{
+		{
+			name:     "Refused",
+			writeErr: &net.OpError{Op: "write", Net: "udp", Err: syscall.ECONNREFUSED},
+			closed:   true,
+		},
+		{
+			name:     "TooLarge",
+			writeErr: &net.OpError{Op: "write", Net: "udp", Err: syscall.EMSGSIZE},
+			err:      syscall.EMSGSIZE,
+		},
+		{
+			name:     "Other",
+			writeErr: errClientWriteTimedOut,
+			err:      errClientWriteTimedOut,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			closed := make(chan struct{})
+			conn := &testConnection{
+				write: func([]byte) (int, error) {
+					return 0, tc.writeErr
+				},
+				read: func([]byte) (int, error) {
+					<-closed
+
+					return 0, io.EOF
+				},
+				close: func() error {
+					select {
+					case <-closed:
+					default:
+						close(closed)
+					}
+
+					return nil
+				},
+			}
+			client, err := NewClient(conn,
+				WithReconnect(func() (Connection, error) {
+					return nil, errClientWriteTimedOut
+				}, Schedule{Initial: time.Millisecond}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = client.Start(MustBuild(TransactionID, BindingRequest), func(Event) {})
+			switch {
+			case tc.err == nil && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.err != nil && !errors.Is(err, tc.err):
+				t.Errorf("unexpected error: %v, expected %v", err, tc.err)
+			}
+			select {
+			case <-closed:
+				if !tc.closed {
+					t.Error("connection should not be closed")
+				}
+			default:
+				if tc.closed {
+					t.Error("connection should be closed")
+				}
+			}
+			if err = client.Close(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// rttWindowSize is count of last RTT samples used for ClientStats.
+const rttWindowSize = 128
+
+// ClientStats are round-trip time statistics of last client
+// transactions.
+//
+// Following Karn's algorithm, only transactions that were completed
+// without retransmissions are sampled, because response to retransmitted
+// request can't be matched to single transmission.
+type ClientStats struct {
+	Samples int // count of samples, up to 128 last ones are used
+	MinRTT  time.Duration
+	AvgRTT  time.Duration
+	P95RTT  time.Duration
+}
+
+// rttStats is fixed-size ring of last RTT samples.
+type rttStats struct {
+	mux     sync.Mutex
+	samples [rttWindowSize]time.Duration
+	count   int
+}
+
+func (s *rttStats) add(rtt time.Duration) {
+	s.mux.Lock()
+	s.samples[s.count%rttWindowSize] = rtt
+	s.count++
+	s.mux.Unlock()
+}
+
+func (s *rttStats) stats() ClientStats {
+	s.mux.Lock()
+	n := s.count
+	if n > rttWindowSize {
+		n = rttWindowSize
+	}
+	samples := append([]time.Duration(nil), s.samples[:n]...)
+	stats := ClientStats{Samples: s.count}
+	s.mux.Unlock()
+	if n == 0 {
+		return stats
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	var sum time.Duration
+	for _, rtt := range samples {
+		sum += rtt
+	}
+	stats.MinRTT = samples[0]
+	stats.AvgRTT = sum / time.Duration(n)
+	stats.P95RTT = samples[(n*95+99)/100-1]
+
+	return stats
+}
+
+// Stats returns round-trip time statistics of client transactions.
+func (c *Client) Stats() ClientStats {
+	return c.rtt.stats()
+}
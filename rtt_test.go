@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stun
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRTTStats(t *testing.T) {
+	var s rttStats
+	if stats := s.stats(); stats != (ClientStats{}) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	for i := 100; i > 0; i-- {
+		s.add(time.Duration(i) * time.Millisecond)
+	}
+	expected := ClientStats{
+		Samples: 100,
+		MinRTT:  time.Millisecond,
+		AvgRTT:  time.Microsecond * 50500,
+		P95RTT:  time.Millisecond * 95,
+	}
+	if stats := s.stats(); stats != expected {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	// Old samples are dropped from window.
+	for i := 0; i < rttWindowSize; i++ {
+		s.add(time.Second)
+	}
+	if stats := s.stats(); stats.MinRTT != time.Second || stats.Samples != 100+rttWindowSize {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestClient_Stats(t *testing.T) {
+	server := listenLocalUDP(t)
+	defer server.Close() //nolint:errcheck
+	drop := make(chan bool, 1)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			m := new(Message)
+			if Decode(buf[:n], m) != nil {
+				continue
+			}
+			select {
+			case <-drop:
+				continue
+			default:
+			}
+			_, _ = server.WriteTo(MustBuild(m, BindingSuccess).Raw, addr)
+		}
+	}()
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(conn, WithRTO(time.Second), WithTimeoutRate(time.Millisecond*5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	do := func() time.Duration {
+		var rtt time.Duration
+		if err := c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+			if e.Error != nil {
+				t.Error(e.Error)
+			}
+			rtt = e.RTT
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		return rtt
+	}
+	for i := 0; i < 3; i++ {
+		if rtt := do(); rtt <= 0 {
+			t.Errorf("unexpected RTT: %s", rtt)
+		}
+	}
+	// Retransmitted transaction is not sampled.
+	drop <- true
+	if rtt := do(); rtt != 0 {
+		t.Errorf("unexpected RTT of retransmitted transaction: %s", rtt)
+	}
+	stats := c.Stats()
+	if stats.Samples != 3 || stats.MinRTT <= 0 || stats.MinRTT > stats.AvgRTT || stats.AvgRTT > stats.P95RTT {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"strings"
+
+	"golang.org/x/text/secure/precis"
+)
+
+// PrepareOpaqueString prepares s using the PRECIS OpaqueString profile
+// (RFC 8265 Section 4.2), which RFC 8489 requires for passwords, realms
+// and usernames of long-term credentials. Non-ASCII spaces are mapped to
+// ASCII space and the result is in Unicode Normalization Form C.
+func PrepareOpaqueString(s string) (string, error) {
+	return precis.OpaqueString.String(s)
+}
+
+// PrepareUsername prepares s using the PRECIS UsernameCasePreserved
+// profile (RFC 8265 Section 3.4). It is stricter than OpaqueString and
+// rejects spaces, so use it only if application enforces such usernames.
+func PrepareUsername(s string) (string, error) {
+	return precis.UsernameCasePreserved.String(s)
+}
+
+// opaqueString returns s prepared with OpaqueString profile, or s as is
+// if profile rejects it, so key derivation never fails.
+func opaqueString(s string) string {
+	p, err := PrepareOpaqueString(s)
+	if err != nil {
+		return s
+	}
+
+	return p
+}
+
+// longTermKey returns input of long-term key hash, RFC 8489 Section 9.2.2.
+func longTermKey(username, realm, password string) string {
+	return strings.Join([]string{
+		opaqueString(username), opaqueString(realm), opaqueString(password),
+	}, credentialsSep)
+}
@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+func TestPrepareOpaqueString(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+		ok      bool
+	}{
+		{"secret", "secret", true},
+		{"correct horse", "correct horse", true},
+		{"non\u00a0breaking", "non breaking", true}, // non-ASCII space is mapped
+		{"Ame\u0301lie", "Am\u00e9lie", true},       // NFC
+		{"\uff21BC", "\uff21BC", true},              // no width mapping
+		{"", "", false},                             // empty
+		{"bell\u0007", "", false},                   // control character
+	} {
+		out, err := PrepareOpaqueString(tc.in)
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: unexpected error: %v", tc.in, err)
+		}
+		if tc.ok && out != tc.out {
+			t.Errorf("%q: got %q, expected %q", tc.in, out, tc.out)
+		}
+	}
+}
+
+func TestPrepareUsername(t *testing.T) {
+	if out, err := PrepareUsername("\uff21BC"); err != nil || out != "ABC" {
+		t.Errorf("got %q, %v", out, err)
+	}
+	if _, err := PrepareUsername("user name"); err == nil {
+		t.Error("space should be rejected")
+	}
+}
+
+func TestLongTermKeyPrepared(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	// Decomposed and composed forms must produce same key.
+	composed := NewLongTermIntegrity("us\u00e9r", "r\u00e9alm", "pass word")
+	decomposed := NewLongTermIntegrity("use\u0301r", "re\u0301alm", "pass\u00a0word")
+	if !bytes.Equal(composed, decomposed) {
+		t.Error("keys differ")
+	}
+	ascii := NewLongTermIntegrity("user", "realm", "pass")
+	if !bytes.Equal(ascii, NewLongTermIntegrity("user", "realm", "pass")) {
+		t.Error("ascii keys differ")
+	}
+	// Rejected values are used as is.
+	if bytes.Equal(NewLongTermIntegrity("user", "realm", "\u0007"), NewLongTermIntegrity("user", "realm", "")) {
+		t.Error("rejected password should not be dropped")
+	}
+	k1, err := NewLongTermIntegrityAlgorithm("us\u00e9r", "realm", "pass", PasswordAlgorithmSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, _ := NewLongTermIntegrityAlgorithm("use\u0301r", "realm", "pass", PasswordAlgorithmSHA256)
+	if !bytes.Equal(k1, k2) {
+		t.Error("sha256 keys differ")
+	}
+	if c := NewLongTermCredentials("use\u0301r", "realm", "pass"); c.Username() != "us\u00e9r" {
+		t.Errorf("unexpected username %q", c.Username())
+	}
+}
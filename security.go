@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import "strings"
+
+// Protection is set of message protections, see SecurityAudit.
+type Protection uint8
+
+// Protections reported by SecurityAudit.
+const (
+	// ProtectionFingerprint is valid FINGERPRINT attribute.
+	ProtectionFingerprint Protection = 1 << iota
+	// ProtectionIntegrity is MESSAGE-INTEGRITY attribute.
+	ProtectionIntegrity
+	// ProtectionIntegritySHA256 is MESSAGE-INTEGRITY-SHA256 attribute.
+	ProtectionIntegritySHA256
+	// ProtectionNonce is NONCE attribute.
+	ProtectionNonce
+	// ProtectionFreshNonce is NONCE that is accepted by
+	// SecurityReport.CheckNonce.
+	ProtectionFreshNonce
+)
+
+func (p Protection) String() string {
+	if p == 0 {
+		return "none"
+	}
+	names := []string{"fingerprint", "integrity", "integrity-sha256", "nonce", "fresh-nonce"}
+	var parts []string
+	for i, name := range names {
+		if p&(1<<i) != 0 {
+			parts = append(parts, name)
+		}
+	}
+
+	return strings.Join(parts, "|")
+}
+
+// SecurityReport describes protections that message carries.
+type SecurityReport struct {
+	Protections Protection
+	Nonce       []byte // NONCE value, nil if absent
+}
+
+// SecurityAudit reports which protections m carries, so servers can
+// enforce policy, e.g. reject requests without integrity.
+//
+// Integrity attributes are reported if they are present and are not
+// followed by attributes other than FINGERPRINT (and
+// MESSAGE-INTEGRITY-SHA256 for MESSAGE-INTEGRITY), because HMAC can not
+// be verified without key. Callers must still check integrity with
+// credentials. Nonce freshness is reported by CheckNonce.
+func SecurityAudit(m *Message) SecurityReport {
+	var r SecurityReport
+	if m.Contains(AttrFingerprint) && Fingerprint.Check(m) == nil {
+		r.Protections |= ProtectionFingerprint
+	}
+	if integrityLast(m, AttrMessageIntegrity, AttrMessageIntegritySHA256) {
+		r.Protections |= ProtectionIntegrity
+	}
+	if integrityLast(m, AttrMessageIntegritySHA256, AttrFingerprint) {
+		r.Protections |= ProtectionIntegritySHA256
+	}
+	if nonce, err := m.Get(AttrNonce); err == nil {
+		r.Protections |= ProtectionNonce
+		r.Nonce = nonce
+	}
+
+	return r
+}
+
+// integrityLast reports whether m has attribute t that is followed only
+// by FINGERPRINT or allowed attribute.
+func integrityLast(m *Message, t, allowed AttrType) bool {
+	found := false
+	for _, a := range m.Attributes {
+		switch {
+		case a.Type == t:
+			found = true
+		case !found, a.Type == AttrFingerprint, a.Type == allowed:
+		default:
+			return false
+		}
+	}
+
+	return found
+}
+
+// CheckNonce sets ProtectionFreshNonce if message has nonce that is
+// accepted by fresh, e.g. is issued by server and is not expired.
+func (r *SecurityReport) CheckNonce(fresh func(nonce []byte) bool) {
+	if r.Protections&ProtectionNonce != 0 && fresh(r.Nonce) {
+		r.Protections |= ProtectionFreshNonce
+	}
+}
+
+// Has reports whether all of required protections are present.
+func (r SecurityReport) Has(required Protection) bool {
+	return r.Missing(required) == 0
+}
+
+// Missing returns required protections that are absent.
+func (r SecurityReport) Missing(required Protection) Protection {
+	return required &^ r.Protections
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSecurityAudit(t *testing.T) {
+	key := NewShortTermIntegrity("password")
+	for _, tc := range []struct {
+		name     string
+		setters  []Setter
+		expected Protection
+	}{
+		{"None", nil, 0},
+		{"Fingerprint", []Setter{Fingerprint}, ProtectionFingerprint},
+		{"Integrity", []Setter{NewNonce("nonce"), key, Fingerprint}, ProtectionFingerprint | ProtectionIntegrity | ProtectionNonce},
+		{"IntegrityNotLast", []Setter{key, NewSoftware("software")}, 0},
+		{"IntegritySHA256", []Setter{
+			key, RawAttribute{Type: AttrMessageIntegritySHA256, Value: make([]byte, 32)},
+		}, ProtectionIntegrity | ProtectionIntegritySHA256},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m := MustBuild(append([]Setter{TransactionID, BindingRequest}, tc.setters...)...)
+			if r := SecurityAudit(m); r.Protections != tc.expected {
+				t.Errorf("unexpected protections: %s, expected %s", r.Protections, tc.expected)
+			}
+		})
+	}
+	t.Run("BadFingerprint", func(t *testing.T) {
+		m := MustBuild(TransactionID, BindingRequest, Fingerprint)
+		m.Raw[len(m.Raw)-1]++
+		if r := SecurityAudit(m); r.Has(ProtectionFingerprint) {
+			t.Error("invalid fingerprint should not be reported")
+		}
+	})
+	t.Run("CheckNonce", func(t *testing.T) {
+		r := SecurityAudit(MustBuild(TransactionID, BindingRequest, NewNonce("nonce"), key))
+		required := ProtectionIntegrity | ProtectionFreshNonce
+		if missing := r.Missing(required); missing != ProtectionFreshNonce {
+			t.Errorf("unexpected missing protections: %s", missing)
+		}
+		r.CheckNonce(func(nonce []byte) bool {
+			return bytes.Equal(nonce, []byte("nonce"))
+		})
+		if !r.Has(required) {
+			t.Errorf("unexpected protections: %s", r.Protections)
+		}
+	})
+}
+
+func TestProtection_String(t *testing.T) {
+	for p, s := range map[Protection]string{
+		0:                     "none",
+		ProtectionFingerprint: "fingerprint",
+		ProtectionIntegrity | ProtectionFreshNonce:  "integrity|fresh-nonce",
+		ProtectionIntegritySHA256 | ProtectionNonce: "integrity-sha256|nonce",
+	} {
+		if p.String() != s {
+			t.Errorf("%d: %q, expected %q", p, p, s)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"errors"
+	"io"
+)
+
+// maxStreamMessageSize is size of largest message that can be encoded,
+// limited by 16-bit length of header.
+const maxStreamMessageSize = messageHeaderSize + 0xFFFF
+
+var (
+	// ErrMessageTooLarge means that message read from stream exceeds size
+	// limit of StreamDecoder.
+	ErrMessageTooLarge = errors.New("message is too large")
+	// ErrInvalidStream means that data read from stream does not start
+	// with STUN message header, so message boundaries are lost.
+	ErrInvalidStream = errors.New("stream does not start with message header")
+)
+
+// StreamDecoder decodes messages that are sent over stream transport like
+// TCP or TLS without framing (RFC 8489 Section 6.2.2), where boundaries
+// of messages are determined by length in message header. It buffers
+// partial messages and messages that are coalesced in single read, and
+// also splits TURN ChannelData, see ReadPacket.
+//
+// Not goroutine-safe.
+type StreamDecoder struct {
+	r       io.Reader
+	buf     []byte
+	start   int // first buffered byte
+	end     int // end of buffered bytes
+	maxSize int
+	err     error // sticky error that makes stream unusable
+}
+
+// NewStreamDecoder returns StreamDecoder that reads messages from r.
+// Messages longer than maxSize bytes are rejected with ErrMessageTooLarge
+// before they are read, zero maxSize means the 65555 bytes limit of
+// message length.
+func NewStreamDecoder(r io.Reader, maxSize int) *StreamDecoder {
+	if maxSize <= 0 || maxSize > maxStreamMessageSize {
+		maxSize = maxStreamMessageSize
+	}
+
+	return &StreamDecoder{r: r, maxSize: maxSize, buf: make([]byte, 2048)}
+}
+
+// Buffered returns count of bytes that are read from stream but not
+// decoded yet.
+func (d *StreamDecoder) Buffered() int {
+	return d.end - d.start
+}
+
+// Decode reads next message from stream and decodes it into m, reusing
+// m.Raw. Returns io.EOF if stream is closed between messages and
+// io.ErrUnexpectedEOF if it is closed within message.
+//
+// ErrMessageTooLarge and ErrInvalidStream are returned for all following
+// calls, because stream can not be resynchronized. Other errors of
+// message decoding are returned as is, and next message can be decoded.
+// Stream that is shared with TURN ChannelData should be read with
+// ReadPacket.
+func (d *StreamDecoder) Decode(m *Message) error {
+	return d.DecodeAs(m, DecodeDefault)
+}
+
+// DecodeAs is like Decode, but decodes message with compatibility mode,
+// see Message.DecodeAs. Magic cookie is required regardless of mode, as
+// it is needed to find boundaries of messages.
+func (d *StreamDecoder) DecodeAs(m *Message, mode DecodeMode) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	raw, kind, err := d.ReadPacket()
+	if err != nil {
+		return err
+	}
+	if kind != PacketSTUN {
+		d.err = ErrInvalidStream
+
+		return d.err
+	}
+	m.Raw = append(m.Raw[:0], raw...)
+
+	return m.DecodeAs(mode)
+}
+
+// ReadPacket reads next STUN message or TURN ChannelData from stream that
+// is shared by them (RFC 8656 Section 12.5), returning its kind, which
+// is PacketSTUN or PacketTURNChannel. ChannelData includes padding.
+// Packet is valid until next call. Errors are the same as of Decode.
+func (d *StreamDecoder) ReadPacket() ([]byte, PacketKind, error) {
+	if d.err != nil {
+		return nil, PacketUnknown, d.err
+	}
+	if err := d.fill(channelDataHeaderSize); err != nil {
+		return nil, PacketUnknown, err
+	}
+	var (
+		size int
+		kind PacketKind
+	)
+	switch first := d.buf[d.start]; {
+	case first <= 3:
+		if err := d.fill(messageHeaderSize); err != nil {
+			return nil, PacketUnknown, err
+		}
+		header := d.buf[d.start : d.start+messageHeaderSize]
+		size, kind = messageHeaderSize+int(bin.Uint16(header[2:4])), PacketSTUN
+		if bin.Uint32(header[4:8]) != magicCookie || size%padding != 0 {
+			d.err = ErrInvalidStream
+		}
+	case first >= 0x40 && first <= 0x4F:
+		length := int(bin.Uint16(d.buf[d.start+2 : d.start+4]))
+		size, kind = channelDataHeaderSize+nearestPaddedValueLength(length), PacketTURNChannel
+	default:
+		d.err = ErrInvalidStream
+	}
+	if d.err == nil && size > d.maxSize {
+		d.err = ErrMessageTooLarge
+	}
+	if d.err != nil {
+		return nil, PacketUnknown, d.err
+	}
+	if err := d.fill(size); err != nil {
+		return nil, PacketUnknown, err
+	}
+	raw := d.buf[d.start : d.start+size]
+	d.start += size
+	if d.start == d.end {
+		d.start, d.end = 0, 0
+	}
+
+	return raw, kind, nil
+}
+
+// fill reads from stream until at least n bytes are buffered.
+func (d *StreamDecoder) fill(n int) error {
+	if d.end-d.start >= n {
+		return nil
+	}
+	if len(d.buf)-d.start < n {
+		// Moving partial message to start of buffer, growing it if
+		// message does not fit.
+		if len(d.buf) < n {
+			buf := make([]byte, n)
+			copy(buf, d.buf[d.start:d.end])
+			d.buf = buf
+		} else {
+			copy(d.buf, d.buf[d.start:d.end])
+		}
+		d.end -= d.start
+		d.start = 0
+	}
+	for d.end-d.start < n {
+		read, err := d.r.Read(d.buf[d.end:])
+		d.end += read
+		if d.end-d.start >= n {
+			return nil
+		}
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, io.EOF) && d.end > d.start {
+			return io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+
+	return nil
+}
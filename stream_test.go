@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStreamDecoder(t *testing.T) {
+	first := MustBuild(TransactionID, BindingRequest, NewSoftware("first"), Fingerprint)
+	second := MustBuild(TransactionID, BindingSuccess, NewUsername("second"))
+	stream := append(append([]byte(nil), first.Raw...), second.Raw...)
+	decodeAll := func(t *testing.T, r io.Reader) {
+		t.Helper()
+		d := NewStreamDecoder(r, 0)
+		m := new(Message)
+		for _, expected := range []*Message{first, second} {
+			if err := d.Decode(m); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(m.Raw, expected.Raw) || m.TransactionID != expected.TransactionID {
+				t.Fatalf("unexpected message %s", m)
+			}
+		}
+		if err := d.Decode(m); !errors.Is(err, io.EOF) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if d.Buffered() != 0 {
+			t.Errorf("unexpected buffered count: %d", d.Buffered())
+		}
+	}
+	t.Run("Coalesced", func(t *testing.T) {
+		decodeAll(t, bytes.NewReader(stream))
+	})
+	t.Run("Partial", func(t *testing.T) {
+		decodeAll(t, iotest.OneByteReader(bytes.NewReader(stream)))
+	})
+	t.Run("DataErr", func(t *testing.T) {
+		decodeAll(t, iotest.DataErrReader(iotest.HalfReader(bytes.NewReader(stream))))
+	})
+	t.Run("Large", func(t *testing.T) {
+		large := MustBuild(TransactionID, BindingRequest, RawAttribute{Type: 0x8080, Value: make([]byte, 4000)})
+		d := NewStreamDecoder(bytes.NewReader(append(large.Raw, first.Raw...)), 0)
+		m := new(Message)
+		for _, expected := range []*Message{large, first} {
+			if err := d.Decode(m); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(m.Raw, expected.Raw) {
+				t.Fatalf("unexpected message %s", m)
+			}
+		}
+		d = NewStreamDecoder(bytes.NewReader(large.Raw), 1000)
+		for i := 0; i < 2; i++ {
+			if err := d.Decode(m); !errors.Is(err, ErrMessageTooLarge) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+	})
+	t.Run("UnexpectedEOF", func(t *testing.T) {
+		d := NewStreamDecoder(bytes.NewReader(stream[:len(first.Raw)+10]), 0)
+		m := new(Message)
+		if err := d.Decode(m); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Decode(m); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("InvalidStream", func(t *testing.T) {
+		corrupt := func(i int, b byte) []byte {
+			raw := append([]byte(nil), first.Raw...)
+			raw[i] = b
+
+			return raw
+		}
+		for name, raw := range map[string][]byte{
+			"Cookie":  corrupt(4, 0),
+			"Type":    corrupt(0, 0xFF),
+			"Padding": corrupt(3, first.Raw[3]+1),
+		} {
+			t.Run(name, func(t *testing.T) {
+				d := NewStreamDecoder(bytes.NewReader(raw), 0)
+				for i := 0; i < 2; i++ {
+					if err := d.Decode(new(Message)); !errors.Is(err, ErrInvalidStream) {
+						t.Errorf("unexpected error: %v", err)
+					}
+				}
+			})
+		}
+	})
+	t.Run("MalformedMessage", func(t *testing.T) {
+		malformed := MustBuild(TransactionID, BindingRequest, NewSoftware("abc"))
+		malformed.Raw[messageHeaderSize+3] = 0xFF // attribute length
+		d := NewStreamDecoder(bytes.NewReader(append(malformed.Raw, first.Raw...)), 0)
+		m := new(Message)
+		if err := d.Decode(m); err == nil {
+			t.Fatal("should fail")
+		}
+		if err := d.Decode(m); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(m.Raw, first.Raw) {
+			t.Errorf("unexpected message %s", m)
+		}
+	})
+	t.Run("ReadPacket", func(t *testing.T) {
+		channel := &ChannelData{Number: 0x4001, Data: []byte("data")}
+		channel.EncodePadded()
+		odd := &ChannelData{Number: 0x4002, Data: []byte("odd")}
+		odd.EncodePadded()
+		shared := append(append(append([]byte(nil), channel.Raw...), first.Raw...), odd.Raw...)
+		d := NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(shared)), 0)
+		for _, expected := range []struct {
+			raw  []byte
+			kind PacketKind
+		}{
+			{channel.Raw, PacketTURNChannel},
+			{first.Raw, PacketSTUN},
+			{odd.Raw, PacketTURNChannel},
+		} {
+			raw, kind, err := d.ReadPacket()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kind != expected.kind || !bytes.Equal(raw, expected.raw) {
+				t.Errorf("unexpected %s packet %x", kind, raw)
+			}
+		}
+		if _, _, err := d.ReadPacket(); !errors.Is(err, io.EOF) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		d = NewStreamDecoder(bytes.NewReader(shared), 0)
+		if err := d.Decode(new(Message)); !errors.Is(err, ErrInvalidStream) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -54,3 +54,29 @@ func (transactionIDSetter) AddTo(m *Message) error {
 
 // TransactionID is Setter for m.TransactionID.
 var TransactionID Setter = transactionIDSetter{} //nolint:gochecknoglobals
+
+type transactionIDSourceSetter struct {
+	r io.Reader
+}
+
+func (s transactionIDSourceSetter) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(s.r, m.TransactionID[:]); err != nil {
+		return err
+	}
+	m.WriteTransactionID()
+
+	return nil
+}
+
+// TransactionIDFrom returns Setter that sets m.TransactionID to value read
+// from r, or from crypto/rand if r is nil.
+func TransactionIDFrom(r io.Reader) Setter {
+	if r == nil {
+		return TransactionID
+	}
+
+	return transactionIDSourceSetter{r: r}
+}
@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunconformance
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// Requirements checked by CheckClient.
+var (
+	ReqRequestType = Requirement{
+		ID:          "request-type",
+		Reference:   "RFC 8489 Section 6.1",
+		Description: "client sends Binding request",
+	}
+	ReqRequestAuth = Requirement{
+		ID:          "request-auth",
+		Reference:   "RFC 8489 Section 9.2.3",
+		Description: "request retried after 401 error has USERNAME, REALM, NONCE and valid MESSAGE-INTEGRITY",
+	}
+)
+
+var (
+	errNoRequest        = errors.New("no request")
+	errUnexpectedValue  = errors.New("unexpected attribute value")
+	errNoRetry          = errors.New("request is not retried after 401 error")
+	errNonceGeneration  = errors.New("failed to generate nonce")
+	errMissingIntegrity = errors.New("missing MESSAGE-INTEGRITY")
+)
+
+// CheckClient checks STUN client that sends requests to conn for
+// conformance, acting as server. Client must send Binding request within
+// cfg.Timeout. Requests are answered with success responses, so client
+// under test is expected to complete transaction.
+//
+// If cfg.Credentials are set, client is challenged for long-term
+// authentication and is expected to retry with these credentials,
+// otherwise authentication requirements are skipped. Returned error is
+// non-nil only if conn fails.
+func CheckClient(conn net.PacketConn, cfg Config) (*Report, error) {
+	cfg.setDefaults()
+	c := &clientChecker{conn: conn, cfg: cfg, report: new(Report)}
+	if err := c.run(); err != nil {
+		return c.report, err
+	}
+
+	return c.report, nil
+}
+
+type clientChecker struct {
+	conn   net.PacketConn
+	cfg    Config
+	report *Report
+}
+
+func (c *clientChecker) run() error {
+	req, addr, err := c.read(nil)
+	if err != nil {
+		return err
+	}
+	if req == nil {
+		c.report.add(ReqRequestType, errNoRequest)
+		if c.cfg.Credentials == nil {
+			c.report.skip(ReqRequestAuth)
+		} else {
+			c.report.add(ReqRequestAuth, ErrNotChecked)
+		}
+
+		return nil
+	}
+	if req.Type != stun.BindingRequest {
+		c.report.add(ReqRequestType, fmt.Errorf("%w: %s", errUnexpectedType, req.Type))
+	} else {
+		c.report.add(ReqRequestType, nil)
+	}
+	if c.cfg.Credentials == nil {
+		c.report.skip(ReqRequestAuth)
+
+		return c.respond(req, addr, nil)
+	}
+
+	return c.authenticate(req, addr)
+}
+
+// read returns next decoded message, skipping retransmissions of prev,
+// or nil if there is no message before timeout.
+func (c *clientChecker) read(prev *stun.Message) (*stun.Message, net.Addr, error) {
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.cfg.Timeout)); err != nil {
+		return nil, nil, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := c.conn.ReadFrom(buf)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		c.report.merge(CheckMessage(buf[:n]))
+		m := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+		if m.Decode() != nil || (prev != nil && m.TransactionID == prev.TransactionID) {
+			continue
+		}
+
+		return m, addr, nil
+	}
+}
+
+func (c *clientChecker) authenticate(req *stun.Message, addr net.Addr) error {
+	nonces, err := stun.NewNonceManager()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errNonceGeneration, err) //nolint:errorlint
+	}
+	challenge, err := stun.Build(req,
+		stun.NewType(req.Type.Method, stun.ClassErrorResponse),
+		stun.CodeUnauthorized,
+		stun.NewRealm(c.cfg.Realm),
+		nonces,
+		stun.Fingerprint,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err = c.conn.WriteTo(challenge.Raw, addr); err != nil {
+		return err
+	}
+	retry, addr, err := c.read(req)
+	if err != nil {
+		return err
+	}
+	if retry == nil {
+		c.report.add(ReqRequestAuth, errNoRetry)
+
+		return nil
+	}
+	integrity, err := c.checkCredentials(retry, nonces)
+	c.report.add(ReqRequestAuth, err)
+
+	return c.respond(retry, addr, integrity)
+}
+
+// checkCredentials checks long-term credentials of req, returning
+// integrity of response.
+func (c *clientChecker) checkCredentials(req *stun.Message, nonces *stun.NonceManager) (stun.Integrity, error) {
+	if !req.Contains(stun.AttrMessageIntegrity) && !req.Contains(stun.AttrMessageIntegritySHA256) {
+		return nil, errMissingIntegrity
+	}
+	var (
+		username stun.Username
+		realm    stun.Realm
+		reqNonce stun.Nonce
+	)
+	if err := req.Parse(&username, &realm, &reqNonce); err != nil {
+		return nil, fmt.Errorf("%w: %v", errMissingAttribute, err) //nolint:errorlint
+	}
+	switch {
+	case username.String() != c.cfg.Credentials.Username:
+		return nil, fmt.Errorf("%w: USERNAME %q", errUnexpectedValue, username)
+	case realm.String() != c.cfg.Realm:
+		return nil, fmt.Errorf("%w: REALM %q", errUnexpectedValue, realm)
+	case nonces.Validate(reqNonce) != nil:
+		return nil, fmt.Errorf("%w: NONCE %q", errUnexpectedValue, reqNonce)
+	}
+	alg := stun.PasswordAlgorithmMD5
+	if req.Contains(stun.AttrPasswordAlgorithm) {
+		if err := alg.GetFrom(req); err != nil {
+			return nil, err
+		}
+	}
+	key, err := stun.NewLongTermIntegrityAlgorithm(username.String(), c.cfg.Realm, c.cfg.Credentials.Password, alg)
+	if err != nil {
+		return nil, err
+	}
+	integrity := stun.IntegrityFor(req, key)
+
+	return integrity, integrity.Check(req)
+}
+
+// respond sends success response to req, protected with integrity if it
+// is not nil.
+func (c *clientChecker) respond(req *stun.Message, addr net.Addr, integrity stun.Integrity) error {
+	setters := []stun.Setter{req, stun.NewType(req.Type.Method, stun.ClassSuccessResponse)}
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		setters = append(setters, &stun.XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port})
+	}
+	if integrity != nil {
+		setters = append(setters, integrity)
+	}
+	res, err := stun.Build(append(setters, stun.Fingerprint)...)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.WriteTo(res.Raw, addr)
+
+	return err
+}
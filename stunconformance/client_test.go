@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stunconformance
+
+import (
+	"testing"
+
+	"github.com/pion/stun/v3"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+type checkResult struct {
+	report *Report
+	err    error
+}
+
+// checkClient runs CheckClient in background.
+func checkClient(t *testing.T, cfg Config) (*stun.Client, <-chan checkResult) {
+	t.Helper()
+	conn := listenUDP(t)
+	done := make(chan checkResult, 1)
+	go func() {
+		defer conn.Close() //nolint:errcheck
+		report, err := CheckClient(conn, cfg)
+		done <- checkResult{report, err}
+	}()
+	client, err := stun.NewClient(dialUDP(t, conn.LocalAddr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, done
+}
+
+func do(t *testing.T, client *stun.Client, setters ...stun.Setter) *stun.Message {
+	t.Helper()
+	setters = append([]stun.Setter{stun.TransactionID, stun.BindingRequest}, setters...)
+	var res *stun.Message
+	if err := client.Do(stun.MustBuild(append(setters, stun.Fingerprint)...), func(e stun.Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+
+			return
+		}
+		res = new(stun.Message)
+		e.Message.CloneTo(res) //nolint:errcheck,gosec
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestCheckClient(t *testing.T) {
+	client, done := checkClient(t, Config{})
+	defer client.Close() //nolint:errcheck
+	res := do(t, client)
+	var addr stun.XORMappedAddress
+	if err := addr.GetFrom(res); err != nil {
+		t.Error(err)
+	}
+	result := <-done
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	result.report.Assert(t)
+}
+
+func TestCheckClient_LongTermAuth(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	creds := stun.Credentials{Username: "user", Password: "secret"}
+	client, done := checkClient(t, Config{Credentials: &creds})
+	defer client.Close() //nolint:errcheck
+	var (
+		realm stun.Realm
+		nonce stun.Nonce
+	)
+	if err := do(t, client).Parse(&realm, &nonce); err != nil {
+		t.Fatal(err)
+	}
+	res := do(t, client, stun.LongTermAuth(creds, realm.String(), nonce.String(), stun.PasswordAlgorithmSHA256))
+	key, err := stun.NewLongTermIntegrityAlgorithm(creds.Username, realm.String(), creds.Password, stun.PasswordAlgorithmSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = stun.MessageIntegritySHA256(key).Check(res); err != nil {
+		t.Error(err)
+	}
+	result := <-done
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	result.report.Assert(t)
+
+	t.Run("InvalidPassword", func(t *testing.T) {
+		client, done := checkClient(t, Config{Credentials: &creds})
+		defer client.Close() //nolint:errcheck
+		if err := do(t, client).Parse(&realm, &nonce); err != nil {
+			t.Fatal(err)
+		}
+		do(t, client, stun.LongTermAuth(stun.Credentials{Username: creds.Username}, realm.String(), nonce.String(), 0))
+		result := <-done
+		if failed := result.report.Failed(); len(failed) != 1 || failed[0].Requirement != ReqRequestAuth {
+			t.Errorf("unexpected report:\n%s", result.report)
+		}
+	})
+}
+
+func TestCheckClient_Nonconforming(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close() //nolint:errcheck
+	client := dialUDP(t, conn.LocalAddr())
+	defer client.Close() //nolint:errcheck
+	m := stun.MustBuild(stun.TransactionID, stun.NewType(stun.MethodBinding, stun.ClassIndication), stun.NewSoftware("abc"))
+	m.Raw[len(m.Raw)-1] = 1
+	if _, err := client.Write(m.Raw); err != nil {
+		t.Fatal(err)
+	}
+	report, err := CheckClient(conn, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := report.Failed()
+	if len(failed) != 2 || failed[0].Requirement != ReqPadding || failed[1].Requirement != ReqRequestType {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
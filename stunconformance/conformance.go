@@ -0,0 +1,391 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package stunconformance checks STUN implementations for conformance to
+// RFC 8489, acting as client against server under test (CheckServer) or
+// as server for client under test (CheckClient). Single message, e.g.
+// captured from network, can be checked with Conformance.
+//
+// Each check produces Result for Requirement, so implementations can be
+// certified by inspecting Report or asserting it in tests:
+//
+//	report, err := stunconformance.CheckServer(conn, stunconformance.Config{})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	report.Assert(t)
+package stunconformance
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// Requirement is single conformance requirement.
+type Requirement struct {
+	ID          string // short stable identifier, e.g. "header"
+	Reference   string // e.g. "RFC 8489 Section 5"
+	Description string
+}
+
+func (r Requirement) String() string {
+	return fmt.Sprintf("%s (%s)", r.ID, r.Reference)
+}
+
+// Requirements checked for every received message.
+var (
+	ReqHeader = Requirement{
+		ID:          "header",
+		Reference:   "RFC 8489 Section 5",
+		Description: "header starts with two zero bits and magic cookie, length is multiple of 4 and matches message size",
+	}
+	ReqPadding = Requirement{
+		ID:          "padding",
+		Reference:   "RFC 8489 Section 14",
+		Description: "attributes are padded to 4 bytes with zero bytes",
+	}
+	ReqOrdering = Requirement{
+		ID:          "ordering",
+		Reference:   "RFC 8489 Section 14.5",
+		Description: "only MESSAGE-INTEGRITY-SHA256 and FINGERPRINT follow MESSAGE-INTEGRITY and nothing follows FINGERPRINT",
+	}
+	ReqFingerprint = Requirement{
+		ID:          "fingerprint",
+		Reference:   "RFC 8489 Section 14.7",
+		Description: "FINGERPRINT, if present, is valid",
+	}
+)
+
+// Requirements checked by Conformance, in addition to ones checked by
+// CheckMessage and ReqXORMappedAddress.
+var (
+	ReqErrorCode = Requirement{
+		ID:          "error-code",
+		Reference:   "RFC 8489 Section 6.3.4",
+		Description: "error response contains ERROR-CODE",
+	}
+	ReqChallengeAttributes = Requirement{
+		ID:          "challenge-attributes",
+		Reference:   "RFC 8489 Section 9.2.4",
+		Description: "401 and 438 error responses contain REALM and NONCE",
+	}
+	ReqIntegrityUsername = Requirement{
+		ID:          "integrity-username",
+		Reference:   "RFC 8489 Section 9",
+		Description: "request with MESSAGE-INTEGRITY or MESSAGE-INTEGRITY-SHA256 contains USERNAME or USERHASH",
+	}
+)
+
+// ErrNotChecked means that requirement was not checked, e.g. because
+// required message was not received.
+var ErrNotChecked = errors.New("not checked")
+
+// Result is result of requirement check.
+type Result struct {
+	Requirement Requirement
+	Skipped     bool  // requirement is not applicable to configuration
+	Err         error // nil if requirement is met
+}
+
+// Passed reports whether requirement is met.
+func (r Result) Passed() bool {
+	return !r.Skipped && r.Err == nil
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Skipped:
+		return "SKIP " + r.Requirement.String()
+	case r.Err != nil:
+		return fmt.Sprintf("FAIL %s: %v", r.Requirement, r.Err)
+	default:
+		return "PASS " + r.Requirement.String()
+	}
+}
+
+// Report is list of check results, one per requirement.
+type Report struct {
+	Results []Result
+}
+
+// add records result of req check. Failure of requirement that is checked
+// several times is kept.
+func (r *Report) add(req Requirement, err error) {
+	for i := range r.Results {
+		if r.Results[i].Requirement.ID != req.ID {
+			continue
+		}
+		if r.Results[i].Err == nil {
+			r.Results[i].Err = err
+		}
+
+		return
+	}
+	r.Results = append(r.Results, Result{Requirement: req, Err: err})
+}
+
+func (r *Report) skip(req Requirement) {
+	r.Results = append(r.Results, Result{Requirement: req, Skipped: true})
+}
+
+func (r *Report) merge(results []Result) {
+	for _, res := range results {
+		r.add(res.Requirement, res.Err)
+	}
+}
+
+// Passed reports whether no requirement is failed.
+func (r *Report) Passed() bool {
+	return len(r.Failed()) == 0
+}
+
+// Failed returns results of failed requirements.
+func (r *Report) Failed() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if !res.Skipped && res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+
+	return failed
+}
+
+// String returns one line per result.
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, res := range r.Results {
+		b.WriteString(res.String())
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// Assert reports failed requirements as test errors.
+func (r *Report) Assert(t testing.TB) {
+	t.Helper()
+	for _, res := range r.Failed() {
+		t.Error(res.String())
+	}
+}
+
+// Config configures conformance checks.
+type Config struct {
+	// Timeout to wait for each message, 1 second by default.
+	Timeout time.Duration
+	// Credentials enable long-term authentication checks.
+	Credentials *stun.Credentials
+	// Realm is sent by CheckClient in authentication challenge, "pion.ly"
+	// by default.
+	Realm string
+}
+
+const (
+	defaultTimeout = time.Second
+	defaultRealm   = "pion.ly"
+)
+
+func (c *Config) setDefaults() {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.Realm == "" {
+		c.Realm = defaultRealm
+	}
+}
+
+var (
+	errMessageTooShort    = errors.New("message is shorter than header")
+	errLeadingBits        = errors.New("leading bits are not zero")
+	errMagicCookie        = errors.New("invalid magic cookie")
+	errLengthNotPadded    = errors.New("length is not multiple of 4")
+	errLengthMismatch     = errors.New("length does not match message size")
+	errAttributeTruncated = errors.New("attribute is truncated")
+	errPaddingNotZero     = errors.New("padding is not zero")
+	errAfterIntegrity     = errors.New("attribute follows MESSAGE-INTEGRITY")
+	errAfterFingerprint   = errors.New("attribute follows FINGERPRINT")
+)
+
+// magicCookie is fixed value of STUN header.
+const magicCookie = 0x2112A442
+
+// headerSize is STUN message header size.
+const headerSize = 20
+
+// CheckMessage checks raw message for requirements that apply to any
+// message: ReqHeader, ReqPadding, ReqOrdering and ReqFingerprint.
+func CheckMessage(raw []byte) []Result {
+	results := []Result{{Requirement: ReqHeader, Err: checkHeader(raw)}}
+	if results[0].Err != nil {
+		return append(results,
+			Result{Requirement: ReqPadding, Err: ErrNotChecked},
+			Result{Requirement: ReqOrdering, Err: ErrNotChecked},
+			Result{Requirement: ReqFingerprint, Err: ErrNotChecked},
+		)
+	}
+	m := &stun.Message{Raw: append([]byte(nil), raw...)}
+	decodeErr := m.Decode()
+	results = append(results, Result{Requirement: ReqPadding, Err: checkPadding(raw)})
+	if decodeErr != nil {
+		return append(results,
+			Result{Requirement: ReqOrdering, Err: decodeErr},
+			Result{Requirement: ReqFingerprint, Err: decodeErr},
+		)
+	}
+	results = append(results, Result{Requirement: ReqOrdering, Err: checkOrdering(m)})
+	// Misplaced FINGERPRINT is reported by ordering check.
+	var fingerprintErr error
+	if n := len(m.Attributes); n > 0 && m.Attributes[n-1].Type == stun.AttrFingerprint {
+		fingerprintErr = stun.Fingerprint.Check(m)
+	}
+
+	return append(results, Result{Requirement: ReqFingerprint, Err: fingerprintErr})
+}
+
+func checkHeader(raw []byte) error {
+	switch {
+	case len(raw) < headerSize:
+		return errMessageTooShort
+	case raw[0]>>6 != 0:
+		return errLeadingBits
+	case binary.BigEndian.Uint32(raw[4:8]) != magicCookie:
+		return errMagicCookie
+	}
+	length := int(binary.BigEndian.Uint16(raw[2:4]))
+	if length%4 != 0 {
+		return errLengthNotPadded
+	}
+	if headerSize+length != len(raw) {
+		return fmt.Errorf("%w: %d != %d", errLengthMismatch, length, len(raw)-headerSize)
+	}
+
+	return nil
+}
+
+func checkPadding(raw []byte) error {
+	for b := raw[headerSize:]; len(b) > 0; {
+		if len(b) < 4 {
+			return errAttributeTruncated
+		}
+		t := stun.AttrType(binary.BigEndian.Uint16(b[0:2]))
+		length := int(binary.BigEndian.Uint16(b[2:4]))
+		padded := (length + 3) &^ 3
+		if len(b) < 4+padded {
+			return fmt.Errorf("%w: %s", errAttributeTruncated, t)
+		}
+		for _, v := range b[4+length : 4+padded] {
+			if v != 0 {
+				return fmt.Errorf("%w: %s", errPaddingNotZero, t)
+			}
+		}
+		b = b[4+padded:]
+	}
+
+	return nil
+}
+
+func checkOrdering(m *stun.Message) error {
+	var integrity, integritySHA256, fingerprint bool
+	for _, a := range m.Attributes {
+		switch {
+		case fingerprint:
+			return fmt.Errorf("%w: %s", errAfterFingerprint, a.Type)
+		case a.Type == stun.AttrFingerprint:
+			fingerprint = true
+		case integritySHA256, integrity && a.Type != stun.AttrMessageIntegritySHA256:
+			return fmt.Errorf("%w: %s", errAfterIntegrity, a.Type)
+		case a.Type == stun.AttrMessageIntegrity:
+			integrity = true
+		case a.Type == stun.AttrMessageIntegritySHA256:
+			integritySHA256 = true
+		}
+	}
+
+	return nil
+}
+
+// Violation is requirement that message fails to meet.
+type Violation struct {
+	Requirement Requirement
+	Err         error
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %v", v.Requirement, v.Err)
+}
+
+// Conformance checks message against RFC 8489 requirements: ones of
+// CheckMessage, and attributes that are required for message class,
+// like ERROR-CODE of error response and XOR-MAPPED-ADDRESS of Binding
+// success response. Only m.Raw is checked. Returns nil if message
+// conforms.
+func Conformance(m *stun.Message) []Violation {
+	var violations []Violation
+	for _, res := range CheckMessage(m.Raw) {
+		if res.Err != nil && !errors.Is(res.Err, ErrNotChecked) {
+			violations = append(violations, Violation{Requirement: res.Requirement, Err: res.Err})
+		}
+	}
+	decoded := &stun.Message{Raw: append([]byte(nil), m.Raw...)}
+	if decoded.Decode() != nil {
+		// Reported by CheckMessage.
+		return violations
+	}
+	for _, v := range checkRequiredAttributes(decoded) {
+		if v.Err != nil {
+			violations = append(violations, v)
+		}
+	}
+
+	return violations
+}
+
+func missing(m *stun.Message, attrs ...stun.AttrType) error {
+	for _, t := range attrs {
+		if !m.Contains(t) {
+			return fmt.Errorf("%w: %s", errMissingAttribute, t)
+		}
+	}
+
+	return nil
+}
+
+func checkRequiredAttributes(m *stun.Message) []Violation {
+	switch m.Type.Class {
+	case stun.ClassRequest:
+		if !m.Contains(stun.AttrMessageIntegrity) && !m.Contains(stun.AttrMessageIntegritySHA256) {
+			return nil
+		}
+		var err error
+		if !m.Contains(stun.AttrUsername) && !m.Contains(stun.AttrUserhash) {
+			err = fmt.Errorf("%w: %s or %s", errMissingAttribute, stun.AttrUsername, stun.AttrUserhash)
+		}
+
+		return []Violation{{Requirement: ReqIntegrityUsername, Err: err}}
+	case stun.ClassSuccessResponse:
+		if m.Type.Method != stun.MethodBinding {
+			return nil
+		}
+
+		return []Violation{{Requirement: ReqXORMappedAddress, Err: missing(m, stun.AttrXORMappedAddress)}}
+	case stun.ClassErrorResponse:
+		var code stun.ErrorCodeAttribute
+		if err := code.GetFrom(m); err != nil {
+			return []Violation{{Requirement: ReqErrorCode, Err: err}}
+		}
+		if code.Code != stun.CodeUnauthorized && code.Code != stun.CodeStaleNonce {
+			return nil
+		}
+
+		return []Violation{{Requirement: ReqChallengeAttributes, Err: missing(m, stun.AttrRealm, stun.AttrNonce)}}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunconformance
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pion/stun/v3"
+)
+
+func failedIDs(results []Result) []string {
+	var ids []string
+	for _, res := range results {
+		if res.Err != nil {
+			ids = append(ids, res.Requirement.ID)
+		}
+	}
+
+	return ids
+}
+
+func TestCheckMessage(t *testing.T) {
+	valid := func() *stun.Message {
+		return stun.MustBuild(stun.TransactionID, stun.BindingRequest,
+			stun.NewSoftware("abc"),
+			stun.NewShortTermIntegrity("pwd"),
+			stun.Fingerprint,
+		)
+	}
+	for _, tc := range []struct {
+		name   string
+		raw    func() []byte
+		failed string
+	}{
+		{"Valid", func() []byte { return valid().Raw }, ""},
+		{"Short", func() []byte { return valid().Raw[:10] }, "header"},
+		{"LeadingBits", func() []byte {
+			raw := valid().Raw
+			raw[0] |= 0xC0
+
+			return raw
+		}, "header"},
+		{"Cookie", func() []byte {
+			raw := valid().Raw
+			raw[4]++
+
+			return raw
+		}, "header"},
+		{"Length", func() []byte {
+			raw := valid().Raw
+
+			return raw[:len(raw)-4]
+		}, "header"},
+		{"Padding", func() []byte {
+			m := stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.NewSoftware("abc"))
+			m.Raw[len(m.Raw)-1] = 1
+
+			return m.Raw
+		}, "padding"},
+		{"AfterIntegrity", func() []byte {
+			m := stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.NewShortTermIntegrity("pwd"))
+			m.Add(stun.AttrSoftware, []byte("abcd"))
+
+			return m.Raw
+		}, "ordering"},
+		{"AfterFingerprint", func() []byte {
+			m := stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.Fingerprint)
+			m.Add(stun.AttrSoftware, []byte("abcd"))
+
+			return m.Raw
+		}, "ordering"},
+		{"Fingerprint", func() []byte {
+			raw := valid().Raw
+			raw[len(raw)-1]++
+
+			return raw
+		}, "fingerprint"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			results := CheckMessage(tc.raw())
+			if len(results) != 4 {
+				t.Fatalf("unexpected results: %v", results)
+			}
+			failed := failedIDs(results)
+			if tc.failed == "" && len(failed) > 0 || tc.failed != "" && (len(failed) == 0 || failed[0] != tc.failed) {
+				t.Errorf("unexpected failures: %v", results)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	errTest := errors.New("test")
+	r := new(Report)
+	r.add(ReqHeader, nil)
+	r.add(ReqPadding, errTest)
+	r.add(ReqPadding, nil)
+	r.add(ReqHeader, errTest)
+	r.skip(ReqAuthChallenge)
+	if len(r.Results) != 3 {
+		t.Fatalf("unexpected results: %v", r.Results)
+	}
+	if r.Passed() || len(r.Failed()) != 2 {
+		t.Errorf("unexpected failures: %v", r.Failed())
+	}
+	if r.Results[2].Passed() {
+		t.Error("skipped result should not pass")
+	}
+	lines := strings.Split(strings.TrimSpace(r.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "FAIL header") || !strings.HasPrefix(lines[2], "SKIP auth-challenge") {
+		t.Errorf("unexpected report:\n%s", r)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	xorAddr := &stun.XORMappedAddress{IP: []byte{127, 0, 0, 1}, Port: 3478}
+	for _, tc := range []struct {
+		name     string
+		message  func() *stun.Message
+		violated []string
+	}{
+		{"Request", func() *stun.Message {
+			return stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.Fingerprint)
+		}, nil},
+		{"AuthenticatedRequest", func() *stun.Message {
+			return stun.MustBuild(stun.TransactionID, stun.BindingRequest,
+				stun.NewUsername("user"), stun.NewShortTermIntegrity("pwd"),
+			)
+		}, nil},
+		{"IntegrityWithoutUsername", func() *stun.Message {
+			return stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.NewShortTermIntegrity("pwd"))
+		}, []string{"integrity-username"}},
+		{"Success", func() *stun.Message {
+			return stun.MustBuild(stun.TransactionID, stun.BindingSuccess, xorAddr, stun.Fingerprint)
+		}, nil},
+		{"SuccessWithoutAddress", func() *stun.Message {
+			return stun.MustBuild(stun.TransactionID, stun.BindingSuccess)
+		}, []string{"xor-mapped-address"}},
+		{"Error", func() *stun.Message {
+			return stun.MustBuild(stun.TransactionID, stun.BindingError, stun.CodeBadRequest)
+		}, nil},
+		{"ErrorWithoutCode", func() *stun.Message {
+			return stun.MustBuild(stun.TransactionID, stun.BindingError)
+		}, []string{"error-code"}},
+		{"Challenge", func() *stun.Message {
+			return stun.MustBuild(stun.TransactionID, stun.BindingError, stun.CodeUnauthorized,
+				stun.NewRealm("realm"), stun.NewNonce("nonce"),
+			)
+		}, nil},
+		{"ChallengeWithoutNonce", func() *stun.Message {
+			return stun.MustBuild(stun.TransactionID, stun.BindingError, stun.CodeStaleNonce, stun.NewRealm("realm"))
+		}, []string{"challenge-attributes"}},
+		{"Ordering", func() *stun.Message {
+			m := stun.MustBuild(stun.TransactionID, stun.BindingSuccess, stun.Fingerprint)
+			m.Add(stun.AttrXORMappedAddress, make([]byte, 8))
+
+			return m
+		}, []string{"ordering"}},
+		{"Cookie", func() *stun.Message {
+			m := stun.MustBuild(stun.TransactionID, stun.BindingSuccess, xorAddr)
+			m.Raw[4]++
+
+			return m
+		}, []string{"header"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var violated []string
+			for _, v := range Conformance(tc.message()) {
+				if v.Err == nil {
+					t.Errorf("violation without error: %s", v)
+				}
+				violated = append(violated, v.Requirement.ID)
+			}
+			if strings.Join(violated, ",") != strings.Join(tc.violated, ",") {
+				t.Errorf("unexpected violations: %v", violated)
+			}
+		})
+	}
+}
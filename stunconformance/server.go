@@ -0,0 +1,266 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunconformance
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// Requirements checked by CheckServer.
+var (
+	ReqBindingSuccess = Requirement{
+		ID:          "binding-success",
+		Reference:   "RFC 8489 Section 6.3.1",
+		Description: "Binding request gets success response",
+	}
+	ReqXORMappedAddress = Requirement{
+		ID:          "xor-mapped-address",
+		Reference:   "RFC 8489 Section 6.3.1.1",
+		Description: "Binding success response contains XOR-MAPPED-ADDRESS",
+	}
+	ReqUnknownAttributes = Requirement{
+		ID:          "unknown-attributes",
+		Reference:   "RFC 8489 Section 6.3.1",
+		Description: "request with unknown comprehension-required attribute gets 420 error listing it in UNKNOWN-ATTRIBUTES",
+	}
+	ReqAuthChallenge = Requirement{
+		ID:          "auth-challenge",
+		Reference:   "RFC 8489 Section 9.2.4",
+		Description: "request without MESSAGE-INTEGRITY gets 401 error with REALM and NONCE",
+	}
+	ReqAuthResponse = Requirement{
+		ID:          "auth-response",
+		Reference:   "RFC 8489 Section 9.2.4",
+		Description: "response to authenticated request has valid MESSAGE-INTEGRITY",
+	}
+	ReqAuthReject = Requirement{
+		ID:          "auth-reject",
+		Reference:   "RFC 8489 Section 9.2.4",
+		Description: "request with invalid MESSAGE-INTEGRITY gets 401 error",
+	}
+)
+
+// attrUnknown is comprehension-required attribute that is not assigned.
+const attrUnknown stun.AttrType = 0x7FFE
+
+var (
+	errNoResponse         = errors.New("no response")
+	errUnexpectedType     = errors.New("unexpected message type")
+	errUnexpectedCode     = errors.New("unexpected error code")
+	errMissingAttribute   = errors.New("missing attribute")
+	errUnknownNotReported = errors.New("unknown attribute is not reported")
+)
+
+// CheckServer checks STUN server connected via datagram conn, e.g.
+// returned by net.Dial("udp", addr), for conformance.
+//
+// If cfg.Credentials are set, server is expected to require long-term
+// authentication with these credentials, otherwise authentication
+// requirements are skipped. Returned error is non-nil only if conn
+// fails.
+func CheckServer(conn net.Conn, cfg Config) (*Report, error) {
+	cfg.setDefaults()
+	s := &serverChecker{conn: conn, cfg: cfg, report: new(Report), auth: stun.Unauthenticated()}
+	if err := s.run(); err != nil {
+		return s.report, err
+	}
+
+	return s.report, nil
+}
+
+type serverChecker struct {
+	conn   net.Conn
+	cfg    Config
+	report *Report
+	auth   stun.Setter
+	key    stun.MessageIntegrity
+	realm  string
+	nonce  string
+}
+
+func (s *serverChecker) run() error {
+	if s.cfg.Credentials == nil {
+		s.report.skip(ReqAuthChallenge)
+	} else if err := s.checkChallenge(); err != nil {
+		return err
+	}
+	if err := s.checkBinding(); err != nil {
+		return err
+	}
+	if err := s.checkUnknownAttributes(); err != nil {
+		return err
+	}
+	if s.cfg.Credentials == nil {
+		s.report.skip(ReqAuthReject)
+
+		return nil
+	}
+
+	return s.checkReject()
+}
+
+// roundTrip sends request built from setters with FINGERPRINT, returning
+// response or nil if there is no response before timeout.
+func (s *serverChecker) roundTrip(setters ...stun.Setter) (*stun.Message, error) {
+	setters = append([]stun.Setter{stun.TransactionID, stun.BindingRequest}, setters...)
+	req, err := stun.Build(append(setters, stun.Fingerprint)...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = s.conn.Write(req.Raw); err != nil {
+		return nil, err
+	}
+	if err = s.conn.SetReadDeadline(time.Now().Add(s.cfg.Timeout)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, err := s.conn.Read(buf)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, nil //nolint:nilnil
+		}
+		if err != nil {
+			return nil, err
+		}
+		res := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+		if res.Decode() != nil || res.TransactionID != req.TransactionID {
+			continue
+		}
+		s.report.merge(CheckMessage(res.Raw))
+
+		return res, nil
+	}
+}
+
+func (s *serverChecker) checkChallenge() error {
+	res, err := s.roundTrip()
+	if err != nil {
+		return err
+	}
+	if err = checkError(res, stun.CodeUnauthorized); err != nil {
+		s.report.add(ReqAuthChallenge, err)
+
+		return nil
+	}
+	var (
+		realm stun.Realm
+		nonce stun.Nonce
+	)
+	if err = res.Parse(&realm, &nonce); err != nil {
+		s.report.add(ReqAuthChallenge, fmt.Errorf("%w: %v", errMissingAttribute, err)) //nolint:errorlint
+
+		return nil
+	}
+	s.report.add(ReqAuthChallenge, nil)
+	s.realm, s.nonce = realm.String(), nonce.String()
+	s.auth = stun.LongTermAuth(*s.cfg.Credentials, s.realm, s.nonce, 0)
+	s.key = stun.NewLongTermIntegrity(s.cfg.Credentials.Username, s.realm, s.cfg.Credentials.Password)
+
+	return nil
+}
+
+func (s *serverChecker) checkBinding() error {
+	res, err := s.roundTrip(s.auth)
+	if err != nil {
+		return err
+	}
+	switch {
+	case res == nil:
+		s.report.add(ReqBindingSuccess, errNoResponse)
+	case res.Type != stun.BindingSuccess:
+		s.report.add(ReqBindingSuccess, fmt.Errorf("%w: %s", errUnexpectedType, res.Type))
+	default:
+		s.report.add(ReqBindingSuccess, nil)
+		var addr stun.XORMappedAddress
+		s.report.add(ReqXORMappedAddress, addr.GetFrom(res))
+		if s.cfg.Credentials == nil {
+			s.report.skip(ReqAuthResponse)
+		} else if s.key != nil {
+			s.report.add(ReqAuthResponse, s.key.Check(res))
+		} else {
+			s.report.add(ReqAuthResponse, ErrNotChecked)
+		}
+
+		return nil
+	}
+	s.report.add(ReqXORMappedAddress, ErrNotChecked)
+	if s.cfg.Credentials == nil {
+		s.report.skip(ReqAuthResponse)
+	} else {
+		s.report.add(ReqAuthResponse, ErrNotChecked)
+	}
+
+	return nil
+}
+
+func (s *serverChecker) checkUnknownAttributes() error {
+	unknown := stun.RawAttribute{Type: attrUnknown, Value: []byte{1, 2, 3, 4}}
+	res, err := s.roundTrip(&unknown, s.auth)
+	if err != nil {
+		return err
+	}
+	if err = checkError(res, stun.CodeUnknownAttribute); err != nil {
+		s.report.add(ReqUnknownAttributes, err)
+
+		return nil
+	}
+	var attrs stun.UnknownAttributes
+	if err = attrs.GetFrom(res); err != nil {
+		s.report.add(ReqUnknownAttributes, fmt.Errorf("%w: %v", errMissingAttribute, err)) //nolint:errorlint
+
+		return nil
+	}
+	for _, t := range attrs {
+		if t == attrUnknown {
+			s.report.add(ReqUnknownAttributes, nil)
+
+			return nil
+		}
+	}
+	s.report.add(ReqUnknownAttributes, errUnknownNotReported)
+
+	return nil
+}
+
+func (s *serverChecker) checkReject() error {
+	if s.key == nil {
+		s.report.add(ReqAuthReject, ErrNotChecked)
+
+		return nil
+	}
+	creds := *s.cfg.Credentials
+	creds.Password += "-invalid"
+	res, err := s.roundTrip(stun.LongTermAuth(creds, s.realm, s.nonce, 0))
+	if err != nil {
+		return err
+	}
+	s.report.add(ReqAuthReject, checkError(res, stun.CodeUnauthorized))
+
+	return nil
+}
+
+// checkError checks that res is error response with code.
+func checkError(res *stun.Message, code stun.ErrorCode) error {
+	if res == nil {
+		return errNoResponse
+	}
+	if res.Type.Class != stun.ClassErrorResponse {
+		return fmt.Errorf("%w: %s", errUnexpectedType, res.Type)
+	}
+	var attr stun.ErrorCodeAttribute
+	if err := attr.GetFrom(res); err != nil {
+		return fmt.Errorf("%w: %v", errMissingAttribute, err) //nolint:errorlint
+	}
+	if attr.Code != code {
+		return fmt.Errorf("%w: %d", errUnexpectedCode, attr.Code)
+	}
+
+	return nil
+}
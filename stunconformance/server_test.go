@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stunconformance
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+	"github.com/pion/stun/v3/stunserver"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+func listenUDP(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func dialUDP(t *testing.T, addr net.Addr) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("udp4", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func serve(t *testing.T, srv *stunserver.Server) net.Addr {
+	t.Helper()
+	conn := listenUDP(t)
+	go func() {
+		if err := srv.ServePacket(conn); !errors.Is(err, stunserver.ErrServerClosed) {
+			t.Error(err)
+		}
+	}()
+
+	return conn.LocalAddr()
+}
+
+func TestCheckServer(t *testing.T) {
+	srv := stunserver.New(stunserver.WithSoftware("conformance"))
+	defer srv.Close() //nolint:errcheck
+	conn := dialUDP(t, serve(t, srv))
+	defer conn.Close() //nolint:errcheck
+
+	report, err := CheckServer(conn, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.Assert(t)
+	skipped := 0
+	for _, res := range report.Results {
+		if res.Skipped {
+			skipped++
+		}
+	}
+	if skipped != 3 {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
+
+func TestCheckServer_LongTermAuth(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	creds := stun.Credentials{Username: "user", Password: "secret"}
+	const realm = "pion.ly"
+	key := stun.NewLongTermIntegrity(creds.Username, realm, creds.Password)
+	srv := stunserver.New(stunserver.WithLongTermAuth(realm, func(u, r string) ([]byte, bool) {
+		return key, u == creds.Username && r == realm
+	}))
+	defer srv.Close() //nolint:errcheck
+	conn := dialUDP(t, serve(t, srv))
+	defer conn.Close() //nolint:errcheck
+
+	report, err := CheckServer(conn, Config{Credentials: &creds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.Assert(t)
+	for _, res := range report.Results {
+		if res.Skipped {
+			t.Errorf("unexpected skip: %s", res)
+		}
+	}
+}
+
+func TestCheckServer_Nonconforming(t *testing.T) {
+	// Server answers any request with success response without
+	// XOR-MAPPED-ADDRESS and with SOFTWARE after FINGERPRINT.
+	server := listenUDP(t)
+	defer server.Close() //nolint:errcheck
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := new(stun.Message)
+			if stun.Decode(buf[:n], req) != nil {
+				continue
+			}
+			res := stun.MustBuild(req, stun.BindingSuccess, stun.Fingerprint)
+			res.Add(stun.AttrSoftware, []byte("bad!"))
+			_, _ = server.WriteTo(res.Raw, addr)
+		}
+	}()
+	conn := dialUDP(t, server.LocalAddr())
+	defer conn.Close() //nolint:errcheck
+
+	report, err := CheckServer(conn, Config{Timeout: time.Millisecond * 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := make(map[string]bool)
+	for _, res := range report.Failed() {
+		failed[res.Requirement.ID] = true
+	}
+	for _, req := range []Requirement{ReqOrdering, ReqXORMappedAddress, ReqUnknownAttributes} {
+		if !failed[req.ID] {
+			t.Errorf("%s should fail:\n%s", req.ID, report)
+		}
+	}
+	if len(failed) != 3 {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
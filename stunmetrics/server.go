@@ -0,0 +1,232 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunmetrics
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pion/stun/v3"
+)
+
+// DefaultSizeBuckets are upper bounds of message size histogram buckets
+// in bytes.
+var DefaultSizeBuckets = []float64{64, 128, 256, 512, 1024, 1500} //nolint:gochecknoglobals
+
+// ServerMetrics collects stunserver metrics: messages and responses by
+// method, class and error code, malformed packets and histogram of
+// message sizes. It implements stunserver.MetricsCollector and can be
+// shared by several servers:
+//
+//	metrics := stunmetrics.NewServer()
+//	srv := stunserver.New(stunserver.WithMetrics(metrics))
+//	...
+//	http.Handle("/metrics", metrics)
+type ServerMetrics struct {
+	malformed uint64
+
+	mux       sync.RWMutex
+	messages  map[counterKey]*uint64
+	responses map[counterKey]*uint64
+
+	buckets      []float64
+	bucketCounts []uint64 // cumulative counts are computed on export
+	sizeCount    uint64
+	sizeSum      uint64 // bytes
+}
+
+type counterKey struct {
+	method stun.Method
+	class  stun.MessageClass
+	code   stun.ErrorCode
+}
+
+// ServerOption sets ServerMetrics option.
+type ServerOption func(m *ServerMetrics)
+
+// WithSizeBuckets sets upper bounds of message size histogram buckets in
+// bytes, in increasing order.
+func WithSizeBuckets(buckets []float64) ServerOption {
+	return func(m *ServerMetrics) {
+		m.buckets = append([]float64(nil), buckets...)
+	}
+}
+
+// NewServer returns new ServerMetrics.
+func NewServer(options ...ServerOption) *ServerMetrics {
+	m := &ServerMetrics{
+		buckets:   DefaultSizeBuckets,
+		messages:  make(map[counterKey]*uint64),
+		responses: make(map[counterKey]*uint64),
+	}
+	for _, o := range options {
+		o(m)
+	}
+	m.bucketCounts = make([]uint64, len(m.buckets))
+
+	return m
+}
+
+// counter returns counter of key in counters, adding it if needed.
+func (m *ServerMetrics) counter(counters map[counterKey]*uint64, key counterKey) *uint64 {
+	m.mux.RLock()
+	c, ok := counters[key]
+	m.mux.RUnlock()
+	if ok {
+		return c
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if c, ok = counters[key]; !ok {
+		c = new(uint64)
+		counters[key] = c
+	}
+
+	return c
+}
+
+// ObserveMessage implements stunserver.MetricsCollector.
+func (m *ServerMetrics) ObserveMessage(t stun.MessageType, size int) {
+	atomic.AddUint64(m.counter(m.messages, counterKey{method: t.Method, class: t.Class}), 1)
+	for i, bound := range m.buckets {
+		if float64(size) <= bound {
+			atomic.AddUint64(&m.bucketCounts[i], 1)
+
+			break
+		}
+	}
+	atomic.AddUint64(&m.sizeSum, uint64(size)) //nolint:gosec // G115
+	atomic.AddUint64(&m.sizeCount, 1)
+}
+
+// ObserveResponse implements stunserver.MetricsCollector.
+func (m *ServerMetrics) ObserveResponse(t stun.MessageType, code stun.ErrorCode) {
+	atomic.AddUint64(m.counter(m.responses, counterKey{method: t.Method, class: t.Class, code: code}), 1)
+}
+
+// IncMalformed implements stunserver.MetricsCollector.
+func (m *ServerMetrics) IncMalformed() {
+	atomic.AddUint64(&m.malformed, 1)
+}
+
+// Counter is value of counter with labels. Code is zero for messages and
+// success responses.
+type Counter struct {
+	Method stun.Method
+	Class  stun.MessageClass
+	Code   stun.ErrorCode
+	Value  uint64
+}
+
+// ServerSnapshot is point-in-time copy of server metrics.
+type ServerSnapshot struct {
+	Malformed   uint64
+	Messages    []Counter // sorted by method, class and code
+	Responses   []Counter // sorted by method, class and code
+	SizeCount   uint64
+	SizeSum     uint64 // bytes
+	SizeBuckets []Bucket
+}
+
+// Snapshot returns current metrics. Counters are read independently, so
+// snapshot is not atomic.
+func (m *ServerMetrics) Snapshot() ServerSnapshot {
+	s := ServerSnapshot{
+		Malformed:   atomic.LoadUint64(&m.malformed),
+		SizeCount:   atomic.LoadUint64(&m.sizeCount),
+		SizeSum:     atomic.LoadUint64(&m.sizeSum),
+		SizeBuckets: make([]Bucket, len(m.buckets)),
+	}
+	m.mux.RLock()
+	s.Messages = snapshotCounters(m.messages)
+	s.Responses = snapshotCounters(m.responses)
+	m.mux.RUnlock()
+	var cumulative uint64
+	for i, bound := range m.buckets {
+		cumulative += atomic.LoadUint64(&m.bucketCounts[i])
+		s.SizeBuckets[i] = Bucket{UpperBound: bound, Count: cumulative}
+	}
+
+	return s
+}
+
+func snapshotCounters(counters map[counterKey]*uint64) []Counter {
+	s := make([]Counter, 0, len(counters))
+	for k, c := range counters {
+		s = append(s, Counter{Method: k.method, Class: k.class, Code: k.code, Value: atomic.LoadUint64(c)})
+	}
+	sort.Slice(s, func(i, j int) bool {
+		a, b := s[i], s[j]
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+
+		return a.Code < b.Code
+	})
+
+	return s
+}
+
+// WriteTo writes metrics in Prometheus text exposition format. Code label
+// of responses is empty for success responses.
+func (m *ServerMetrics) WriteTo(w io.Writer) (int64, error) {
+	s := m.Snapshot()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	const (
+		messages  = "stun_server_messages_total"
+		responses = "stun_server_responses_total"
+		malformed = "stun_server_malformed_total"
+		size      = "stun_server_message_size_bytes"
+	)
+	fmt.Fprintf(cw, "# HELP %s STUN messages received.\n# TYPE %s counter\n", messages, messages)
+	for _, c := range s.Messages {
+		fmt.Fprintf(cw, "%s{method=%q,class=%q} %d\n", messages, c.Method, c.Class, c.Value)
+	}
+	fmt.Fprintf(cw, "# HELP %s STUN responses sent.\n# TYPE %s counter\n", responses, responses)
+	for _, c := range s.Responses {
+		code := ""
+		if c.Code != 0 {
+			code = strconv.Itoa(int(c.Code))
+		}
+		fmt.Fprintf(cw, "%s{method=%q,class=%q,code=%q} %d\n", responses, c.Method, c.Class, code, c.Value)
+	}
+	fmt.Fprintf(cw, "# HELP %s Packets that failed to decode as STUN.\n# TYPE %s counter\n%s %d\n",
+		malformed, malformed, malformed, s.Malformed)
+	fmt.Fprintf(cw, "# HELP %s Size of received STUN messages.\n# TYPE %s histogram\n", size, size)
+	for _, b := range s.SizeBuckets {
+		fmt.Fprintf(cw, "%s_bucket{le=%q} %d\n", size, strconv.FormatFloat(b.UpperBound, 'g', -1, 64), b.Count)
+	}
+	fmt.Fprintf(cw, "%s_bucket{le=\"+Inf\"} %d\n", size, s.SizeCount)
+	fmt.Fprintf(cw, "%s_sum %d\n", size, s.SizeSum)
+	fmt.Fprintf(cw, "%s_count %d\n", size, s.SizeCount)
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves metrics in Prometheus text exposition format.
+func (m *ServerMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = m.WriteTo(w)
+}
+
+// Publish exports metrics snapshot as expvar variable with name. Like
+// expvar.Publish, it panics if name is already registered.
+func (m *ServerMetrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return m.Snapshot()
+	}))
+}
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunmetrics
+
+import (
+	"expvar"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pion/stun/v3"
+	"github.com/pion/stun/v3/stunserver"
+)
+
+var _ stunserver.MetricsCollector = (*ServerMetrics)(nil)
+
+func TestServerMetrics(t *testing.T) {
+	m := NewServer(WithSizeBuckets([]float64{64, 512}))
+	m.ObserveMessage(stun.BindingRequest, 20)
+	m.ObserveMessage(stun.BindingRequest, 100)
+	m.ObserveMessage(stun.NewType(stun.MethodAllocate, stun.ClassRequest), 1000)
+	m.ObserveResponse(stun.BindingSuccess, 0)
+	m.ObserveResponse(stun.BindingError, stun.CodeUnauthorized)
+	m.ObserveResponse(stun.BindingError, stun.CodeUnauthorized)
+	m.IncMalformed()
+	expected := ServerSnapshot{
+		Malformed: 1,
+		Messages: []Counter{
+			{Method: stun.MethodBinding, Class: stun.ClassRequest, Value: 2},
+			{Method: stun.MethodAllocate, Class: stun.ClassRequest, Value: 1},
+		},
+		Responses: []Counter{
+			{Method: stun.MethodBinding, Class: stun.ClassSuccessResponse, Value: 1},
+			{Method: stun.MethodBinding, Class: stun.ClassErrorResponse, Code: stun.CodeUnauthorized, Value: 2},
+		},
+		SizeCount:   3,
+		SizeSum:     1120,
+		SizeBuckets: []Bucket{{64, 1}, {512, 2}},
+	}
+	if s := m.Snapshot(); !reflect.DeepEqual(s, expected) {
+		t.Errorf("unexpected snapshot: %+v", s)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE stun_server_messages_total counter",
+		`stun_server_messages_total{method="Binding",class="request"} 2`,
+		`stun_server_messages_total{method="Allocate",class="request"} 1`,
+		`stun_server_responses_total{method="Binding",class="success response",code=""} 1`,
+		`stun_server_responses_total{method="Binding",class="error response",code="401"} 2`,
+		"stun_server_malformed_total 1",
+		"# TYPE stun_server_message_size_bytes histogram",
+		`stun_server_message_size_bytes_bucket{le="64"} 1`,
+		`stun_server_message_size_bytes_bucket{le="512"} 2`,
+		`stun_server_message_size_bytes_bucket{le="+Inf"} 3`,
+		"stun_server_message_size_bytes_sum 1120",
+		"stun_server_message_size_bytes_count 3",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("%q not found in:\n%s", line, body)
+		}
+	}
+
+	m.Publish("stunmetrics_server_test")
+	if v := expvar.Get("stunmetrics_server_test"); v == nil || !strings.Contains(v.String(), `"Malformed":1`) {
+		t.Errorf("unexpected expvar: %v", v)
+	}
+}
+
+func TestServerMetrics_Concurrent(t *testing.T) {
+	m := NewServer()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.ObserveMessage(stun.BindingRequest, 20)
+				m.ObserveResponse(stun.BindingSuccess, 0)
+			}
+		}()
+	}
+	wg.Wait()
+	s := m.Snapshot()
+	if len(s.Messages) != 1 || s.Messages[0].Value != 400 || s.Responses[0].Value != 400 {
+		t.Errorf("unexpected snapshot: %+v", s)
+	}
+}
@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package stunmetrics implements stun.MetricsCollector that keeps client
+// metrics in memory and exposes them in Prometheus text format and via
+// expvar, and ServerMetrics that does the same for stunserver:
+//
+//	metrics := stunmetrics.New()
+//	client, err := stun.NewClient(conn, stun.WithMetrics(metrics))
+//	...
+//	http.Handle("/metrics", metrics)
+package stunmetrics
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets are upper bounds of RTT histogram buckets in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5} //nolint:gochecknoglobals
+
+// Metrics collects client metrics. It can be shared by several clients.
+type Metrics struct {
+	sent        uint64
+	received    uint64
+	timeouts    uint64
+	retransmits uint64
+
+	buckets      []float64
+	bucketCounts []uint64 // cumulative counts are computed on export
+	rttCount     uint64
+	rttSum       uint64 // nanoseconds
+}
+
+// Option sets Metrics option.
+type Option func(m *Metrics)
+
+// WithBuckets sets upper bounds of RTT histogram buckets in seconds, in
+// increasing order.
+func WithBuckets(buckets []float64) Option {
+	return func(m *Metrics) {
+		m.buckets = append([]float64(nil), buckets...)
+	}
+}
+
+// New returns new Metrics.
+func New(options ...Option) *Metrics {
+	m := &Metrics{buckets: DefaultBuckets}
+	for _, o := range options {
+		o(m)
+	}
+	m.bucketCounts = make([]uint64, len(m.buckets))
+
+	return m
+}
+
+// IncSent implements stun.MetricsCollector.
+func (m *Metrics) IncSent() {
+	atomic.AddUint64(&m.sent, 1)
+}
+
+// IncReceived implements stun.MetricsCollector.
+func (m *Metrics) IncReceived() {
+	atomic.AddUint64(&m.received, 1)
+}
+
+// IncTimeout implements stun.MetricsCollector.
+func (m *Metrics) IncTimeout() {
+	atomic.AddUint64(&m.timeouts, 1)
+}
+
+// IncRetransmit implements stun.MetricsCollector.
+func (m *Metrics) IncRetransmit() {
+	atomic.AddUint64(&m.retransmits, 1)
+}
+
+// ObserveRTT implements stun.MetricsCollector.
+func (m *Metrics) ObserveRTT(rtt time.Duration) {
+	seconds := rtt.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			atomic.AddUint64(&m.bucketCounts[i], 1)
+
+			break
+		}
+	}
+	atomic.AddUint64(&m.rttSum, uint64(rtt))
+	atomic.AddUint64(&m.rttCount, 1)
+}
+
+// Bucket is histogram bucket.
+type Bucket struct {
+	UpperBound float64 // seconds for RTT, bytes for message size
+	Count      uint64  // cumulative count of observations
+}
+
+// Snapshot is point-in-time copy of metrics.
+type Snapshot struct {
+	Sent        uint64
+	Received    uint64
+	Timeouts    uint64
+	Retransmits uint64
+	RTTCount    uint64
+	RTTSum      time.Duration
+	RTTBuckets  []Bucket
+}
+
+// Snapshot returns current metrics. Counters are read independently, so
+// snapshot is not atomic.
+func (m *Metrics) Snapshot() Snapshot {
+	s := Snapshot{
+		Sent:        atomic.LoadUint64(&m.sent),
+		Received:    atomic.LoadUint64(&m.received),
+		Timeouts:    atomic.LoadUint64(&m.timeouts),
+		Retransmits: atomic.LoadUint64(&m.retransmits),
+		RTTCount:    atomic.LoadUint64(&m.rttCount),
+		RTTSum:      time.Duration(atomic.LoadUint64(&m.rttSum)), //nolint:gosec
+		RTTBuckets:  make([]Bucket, len(m.buckets)),
+	}
+	var cumulative uint64
+	for i, bound := range m.buckets {
+		cumulative += atomic.LoadUint64(&m.bucketCounts[i])
+		s.RTTBuckets[i] = Bucket{UpperBound: bound, Count: cumulative}
+	}
+
+	return s
+}
+
+// WriteTo writes metrics in Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	s := m.Snapshot()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, c := range []struct {
+		name, help string
+		value      uint64
+	}{
+		{"stun_client_messages_sent_total", "STUN messages sent.", s.Sent},
+		{"stun_client_messages_received_total", "STUN messages received.", s.Received},
+		{"stun_client_timeouts_total", "STUN transactions timed out.", s.Timeouts},
+		{"stun_client_retransmits_total", "STUN request retransmissions.", s.Retransmits},
+	} {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.value)
+	}
+	const rtt = "stun_client_rtt_seconds"
+	fmt.Fprintf(cw, "# HELP %s STUN transaction round-trip time.\n# TYPE %s histogram\n", rtt, rtt)
+	for _, b := range s.RTTBuckets {
+		fmt.Fprintf(cw, "%s_bucket{le=%q} %d\n", rtt, strconv.FormatFloat(b.UpperBound, 'g', -1, 64), b.Count)
+	}
+	fmt.Fprintf(cw, "%s_bucket{le=\"+Inf\"} %d\n", rtt, s.RTTCount)
+	fmt.Fprintf(cw, "%s_sum %s\n", rtt, strconv.FormatFloat(s.RTTSum.Seconds(), 'g', -1, 64))
+	fmt.Fprintf(cw, "%s_count %d\n", rtt, s.RTTCount)
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves metrics in Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = m.WriteTo(w)
+}
+
+// Publish exports metrics snapshot as expvar variable with name. Like
+// expvar.Publish, it panics if name is already registered.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return m.Snapshot()
+	}))
+}
+
+// countingWriter counts written bytes and keeps first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	w.err = err
+
+	return n, err
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunmetrics
+
+import (
+	"expvar"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+var _ stun.MetricsCollector = (*Metrics)(nil)
+
+func TestMetrics(t *testing.T) {
+	m := New(WithBuckets([]float64{0.01, 0.1}))
+	m.IncSent()
+	m.IncSent()
+	m.IncReceived()
+	m.IncTimeout()
+	m.IncRetransmit()
+	m.ObserveRTT(time.Millisecond * 5)
+	m.ObserveRTT(time.Millisecond * 50)
+	m.ObserveRTT(time.Second)
+	expected := Snapshot{
+		Sent:        2,
+		Received:    1,
+		Timeouts:    1,
+		Retransmits: 1,
+		RTTCount:    3,
+		RTTSum:      time.Millisecond * 1055,
+		RTTBuckets:  []Bucket{{0.01, 1}, {0.1, 2}},
+	}
+	if s := m.Snapshot(); !reflect.DeepEqual(s, expected) {
+		t.Errorf("unexpected snapshot: %+v", s)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE stun_client_messages_sent_total counter",
+		"stun_client_messages_sent_total 2",
+		"stun_client_messages_received_total 1",
+		"stun_client_timeouts_total 1",
+		"stun_client_retransmits_total 1",
+		"# TYPE stun_client_rtt_seconds histogram",
+		`stun_client_rtt_seconds_bucket{le="0.01"} 1`,
+		`stun_client_rtt_seconds_bucket{le="0.1"} 2`,
+		`stun_client_rtt_seconds_bucket{le="+Inf"} 3`,
+		"stun_client_rtt_seconds_sum 1.055",
+		"stun_client_rtt_seconds_count 3",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("%q not found in:\n%s", line, body)
+		}
+	}
+	var b strings.Builder
+	if n, err := m.WriteTo(&b); err != nil || n != int64(len(body)) {
+		t.Errorf("unexpected WriteTo result: %d, %v", n, err)
+	}
+
+	m.Publish("stunmetrics_test")
+	if v := expvar.Get("stunmetrics_test"); v == nil || !strings.Contains(v.String(), `"Sent":2`) {
+		t.Errorf("unexpected expvar: %v", v)
+	}
+}
@@ -0,0 +1,261 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunpcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// ErrUnsupportedFormat means that capture is not in classic libpcap
+// format, e.g. pcapng.
+var ErrUnsupportedFormat = errors.New("stunpcap: unsupported capture format")
+
+// ErrUnsupportedLinkType means that link layer of capture is not supported.
+var ErrUnsupportedLinkType = errors.New("stunpcap: unsupported link type")
+
+var errTruncated = errors.New("stunpcap: truncated packet")
+
+// Link types, see https://www.tcpdump.org/linktypes.html.
+const (
+	linkTypeNull      = 0
+	linkTypeEthernet  = 1
+	linkTypeRaw       = 101
+	linkTypeLinuxSLL  = 113
+	linkTypeIPv4      = 228
+	linkTypeIPv6      = 229
+	linkTypeLoop      = 108
+	linkTypeLinuxSLL2 = 276
+)
+
+// Magic numbers of microsecond and nanosecond resolution captures.
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+)
+
+const (
+	fileHeaderSize   = 24
+	recordHeaderSize = 16
+	maxSnapLen       = 262144
+)
+
+// Reader is PacketSource that reads UDP and TCP payloads from classic
+// libpcap capture file. Other packets, IP fragments and IPv6 extension
+// headers are skipped.
+//
+// TCP streams are not reassembled, so STUN messages that span several
+// segments are not decoded.
+type Reader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	linkType uint32
+	header   [recordHeaderSize]byte
+	buf      []byte
+}
+
+// NewReader reads capture file header from r and returns Reader.
+func NewReader(r io.Reader) (*Reader, error) {
+	var header [fileHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	reader := &Reader{r: r}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header[0:4]) {
+		case magicMicroseconds:
+			reader.order = order
+		case magicNanoseconds:
+			reader.order, reader.nanos = order, true
+		}
+	}
+	if reader.order == nil {
+		return nil, ErrUnsupportedFormat
+	}
+	reader.linkType = reader.order.Uint32(header[20:24]) & 0x0fffffff
+	switch reader.linkType {
+	case linkTypeNull, linkTypeLoop, linkTypeEthernet, linkTypeRaw,
+		linkTypeLinuxSLL, linkTypeLinuxSLL2, linkTypeIPv4, linkTypeIPv6:
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedLinkType, reader.linkType)
+	}
+
+	return reader, nil
+}
+
+// NextPacket returns next UDP or TCP packet of capture, or io.EOF if
+// there are no more packets. Data of returned packet is valid until next
+// NextPacket call.
+func (r *Reader) NextPacket() (Packet, error) {
+	for {
+		frame, ts, err := r.readRecord()
+		if err != nil {
+			return Packet{}, err
+		}
+		if p, ok := r.parseFrame(frame); ok {
+			p.Timestamp = ts
+
+			return p, nil
+		}
+	}
+}
+
+func (r *Reader) readRecord() ([]byte, time.Time, error) {
+	if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, time.Time{}, errTruncated
+		}
+
+		return nil, time.Time{}, err
+	}
+	var (
+		sec    = int64(r.order.Uint32(r.header[0:4]))
+		frac   = int64(r.order.Uint32(r.header[4:8]))
+		length = r.order.Uint32(r.header[8:12])
+	)
+	if length > maxSnapLen {
+		return nil, time.Time{}, errTruncated
+	}
+	if !r.nanos {
+		frac *= int64(time.Microsecond)
+	}
+	if cap(r.buf) < int(length) {
+		r.buf = make([]byte, length)
+	}
+	r.buf = r.buf[:length]
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		return nil, time.Time{}, errTruncated
+	}
+
+	return r.buf, time.Unix(sec, frac), nil
+}
+
+// parseFrame strips link layer header of frame and parses IP packet.
+func (r *Reader) parseFrame(frame []byte) (Packet, bool) { //nolint:cyclop
+	switch r.linkType {
+	case linkTypeNull, linkTypeLoop:
+		if len(frame) < 4 {
+			return Packet{}, false
+		}
+
+		return parseIP(frame[4:])
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return Packet{}, false
+		}
+		etherType, offset := binary.BigEndian.Uint16(frame[12:14]), 14
+		for etherType == 0x8100 || etherType == 0x88a8 { // VLAN tags
+			if len(frame) < offset+4 {
+				return Packet{}, false
+			}
+			etherType = binary.BigEndian.Uint16(frame[offset+2 : offset+4])
+			offset += 4
+		}
+		if etherType != 0x0800 && etherType != 0x86dd {
+			return Packet{}, false
+		}
+
+		return parseIP(frame[offset:])
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return Packet{}, false
+		}
+
+		return parseIP(frame[16:])
+	case linkTypeLinuxSLL2:
+		if len(frame) < 20 {
+			return Packet{}, false
+		}
+
+		return parseIP(frame[20:])
+	default:
+		return parseIP(frame)
+	}
+}
+
+// IP protocol numbers.
+const (
+	protoTCP = 6
+	protoUDP = 17
+)
+
+// parseIP parses IPv4 or IPv6 packet, returning transport payload.
+func parseIP(b []byte) (Packet, bool) {
+	if len(b) == 0 {
+		return Packet{}, false
+	}
+	var (
+		proto    byte
+		src, dst net.IP
+		payload  []byte
+	)
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < 20 {
+			return Packet{}, false
+		}
+		headerLen := int(b[0]&0x0f) * 4
+		totalLen := int(binary.BigEndian.Uint16(b[2:4]))
+		if headerLen < 20 || totalLen < headerLen || len(b) < totalLen {
+			return Packet{}, false
+		}
+		if binary.BigEndian.Uint16(b[6:8])&0x3fff != 0 {
+			// Fragment.
+			return Packet{}, false
+		}
+		proto, src, dst = b[9], net.IP(b[12:16]), net.IP(b[16:20])
+		payload = b[headerLen:totalLen]
+	case 6:
+		if len(b) < 40 {
+			return Packet{}, false
+		}
+		payloadLen := int(binary.BigEndian.Uint16(b[4:6]))
+		if len(b) < 40+payloadLen {
+			return Packet{}, false
+		}
+		proto, src, dst = b[6], net.IP(b[8:24]), net.IP(b[24:40])
+		payload = b[40 : 40+payloadLen]
+	default:
+		return Packet{}, false
+	}
+
+	// Copying addresses, because frame buffer is reused.
+	return parseTransport(proto, append(net.IP(nil), src...), append(net.IP(nil), dst...), payload)
+}
+
+func parseTransport(proto byte, src, dst net.IP, b []byte) (Packet, bool) {
+	switch proto {
+	case protoUDP:
+		if len(b) < 8 {
+			return Packet{}, false
+		}
+
+		return Packet{
+			Src:  &net.UDPAddr{IP: src, Port: int(binary.BigEndian.Uint16(b[0:2]))},
+			Dst:  &net.UDPAddr{IP: dst, Port: int(binary.BigEndian.Uint16(b[2:4]))},
+			Data: b[8:],
+		}, true
+	case protoTCP:
+		if len(b) < 20 {
+			return Packet{}, false
+		}
+		offset := int(b[12]>>4) * 4
+		if offset < 20 || len(b) < offset {
+			return Packet{}, false
+		}
+
+		return Packet{
+			Src:  &net.TCPAddr{IP: src, Port: int(binary.BigEndian.Uint16(b[0:2]))},
+			Dst:  &net.TCPAddr{IP: dst, Port: int(binary.BigEndian.Uint16(b[2:4]))},
+			Data: b[offset:],
+		}, true
+	default:
+		return Packet{}, false
+	}
+}
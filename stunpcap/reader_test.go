@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunpcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// capture builds little-endian microsecond resolution pcap file.
+type capture struct {
+	bytes.Buffer
+}
+
+func newCapture(linkType uint32) *capture {
+	c := new(capture)
+	header := make([]byte, fileHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], magicMicroseconds)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], maxSnapLen)
+	binary.LittleEndian.PutUint32(header[20:24], linkType)
+	c.Write(header)
+
+	return c
+}
+
+func (c *capture) add(ts time.Time, frame []byte) {
+	header := make([]byte, recordHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(header[4:8], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(header[8:12], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(header[12:16], uint32(len(frame)))
+	c.Write(header)
+	c.Write(frame)
+}
+
+func udpPacket(src, dst *net.UDPAddr, payload []byte) []byte {
+	udp := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(udp[0:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(payload)))
+	udp = append(udp, payload...)
+	if src.IP.To4() == nil {
+		ip := make([]byte, 40)
+		ip[0] = 6 << 4
+		binary.BigEndian.PutUint16(ip[4:6], uint16(len(udp)))
+		ip[6] = protoUDP
+		copy(ip[8:24], src.IP)
+		copy(ip[24:40], dst.IP)
+
+		return append(ip, udp...)
+	}
+	ip := make([]byte, 20)
+	ip[0] = 4<<4 | 5
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(udp)))
+	ip[9] = protoUDP
+	copy(ip[12:16], src.IP.To4())
+	copy(ip[16:20], dst.IP.To4())
+
+	return append(ip, udp...)
+}
+
+func ethernetFrame(ip []byte) []byte {
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+
+	return append(frame, ip...)
+}
+
+func TestReader(t *testing.T) {
+	ts := time.Unix(1700000000, 123456000)
+	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	c := newCapture(linkTypeEthernet)
+	c.add(ts, ethernetFrame(udpPacket(clientAddr, serverAddr, request.Raw)))
+	c.add(ts, append(make([]byte, 12), 0x08, 0x06)) // ARP
+	fragment := udpPacket(clientAddr, serverAddr, request.Raw)
+	fragment[6] = 0x20 // more fragments
+	c.add(ts, ethernetFrame(fragment))
+	r, err := NewReader(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := r.NextPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Timestamp.Equal(ts) {
+		t.Errorf("unexpected timestamp: %s", p.Timestamp)
+	}
+	if p.Src.String() != clientAddr.String() || p.Dst.String() != serverAddr.String() {
+		t.Errorf("unexpected addresses: %s -> %s", p.Src, p.Dst)
+	}
+	if !bytes.Equal(p.Data, request.Raw) {
+		t.Error("unexpected payload")
+	}
+	if _, err = r.NextPacket(); !errors.Is(err, io.EOF) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestReader_IPv6(t *testing.T) {
+	src := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1}
+	dst := &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 2}
+	c := newCapture(linkTypeRaw)
+	c.add(time.Unix(1, 0), udpPacket(src, dst, []byte{1, 2, 3}))
+	r, err := NewReader(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := r.NextPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Src.String() != src.String() || !bytes.Equal(p.Data, []byte{1, 2, 3}) {
+		t.Errorf("unexpected packet: %+v", p)
+	}
+}
+
+func TestNewReader_Errors(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader(make([]byte, fileHeaderSize))); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := NewReader(newCapture(147)); !errors.Is(err, ErrUnsupportedLinkType) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	c := newCapture(linkTypeRaw)
+	c.Write(make([]byte, 4))
+	r, err := NewReader(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.NextPacket(); !errors.Is(err, errTruncated) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
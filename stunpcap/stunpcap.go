@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package stunpcap decodes STUN messages from packet captures, making it
+// possible to inspect and replay captured transactions.
+//
+// Packets can be read from libpcap files with Reader or from any other
+// source, e.g. gopacket, by implementing PacketSource.
+package stunpcap
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// Packet is captured transport payload.
+type Packet struct {
+	Timestamp time.Time
+	Src       net.Addr // *net.UDPAddr or *net.TCPAddr, can be nil
+	Dst       net.Addr // *net.UDPAddr or *net.TCPAddr, can be nil
+	Data      []byte
+}
+
+// PacketSource yields captured packets, returning io.EOF after the last
+// one.
+type PacketSource interface {
+	NextPacket() (Packet, error)
+}
+
+// Message is STUN message decoded from captured packet.
+type Message struct {
+	*stun.Message
+	Timestamp time.Time
+	Src       net.Addr
+	Dst       net.Addr
+}
+
+// Decoder decodes STUN messages from PacketSource, skipping packets that
+// are not STUN.
+//
+// Payloads of TCP packets may contain several STUN messages, each of them
+// is returned separately.
+type Decoder struct {
+	src     PacketSource
+	packet  Packet
+	pending []byte
+	skipped int
+}
+
+// NewDecoder returns Decoder that reads packets from src.
+func NewDecoder(src PacketSource) *Decoder {
+	return &Decoder{src: src}
+}
+
+// Skipped returns count of packets that were skipped as non-STUN.
+func (d *Decoder) Skipped() int {
+	return d.skipped
+}
+
+// Next returns next STUN message, or io.EOF if source is exhausted.
+// Returned message does not share memory with packet source.
+func (d *Decoder) Next() (Message, error) {
+	for {
+		if m, ok := d.nextPending(); ok {
+			return m, nil
+		}
+		packet, err := d.src.NextPacket()
+		if err != nil {
+			return Message{}, err
+		}
+		d.packet = packet
+		d.pending = packet.Data
+		if !stun.IsMessage(d.pending) {
+			d.skipped++
+			d.pending = nil
+		}
+	}
+}
+
+// nextPending decodes next message from pending data of current packet.
+func (d *Decoder) nextPending() (Message, bool) {
+	if len(d.pending) < messageHeaderSize {
+		d.pending = nil
+
+		return Message{}, false
+	}
+	size := messageHeaderSize + int(d.pending[2])<<8 + int(d.pending[3])
+	if _, ok := d.packet.Src.(*net.UDPAddr); ok || d.packet.Src == nil {
+		// Datagram contains single message.
+		size = len(d.pending)
+	}
+	if size > len(d.pending) {
+		d.skipped++
+		d.pending = nil
+
+		return Message{}, false
+	}
+	m := &stun.Message{Raw: append([]byte(nil), d.pending[:size]...)}
+	d.pending = d.pending[size:]
+	if err := m.Decode(); err != nil {
+		d.skipped++
+		d.pending = nil
+
+		return Message{}, false
+	}
+
+	return Message{
+		Message:   m,
+		Timestamp: d.packet.Timestamp,
+		Src:       d.packet.Src,
+		Dst:       d.packet.Dst,
+	}, true
+}
+
+// messageHeaderSize is STUN message header size.
+const messageHeaderSize = 20
+
+// ClientAgent is subset of stun.ClientAgent that is required to replay
+// capture.
+type ClientAgent interface {
+	Process(*stun.Message) error
+	Start(id [stun.TransactionIDSize]byte, deadline time.Time) error
+	Collect(time.Time) error
+}
+
+// Replay passes messages of capture to agent as if they were sent and
+// received in real time: each request starts transaction with timeout
+// relative to capture timestamp, responses are processed and agent
+// collects timed out transactions using capture timestamps.
+//
+// Retransmitted requests are ignored. Returns nil when source is
+// exhausted.
+func Replay(d *Decoder, agent ClientAgent, timeout time.Duration) error {
+	var last time.Time
+	for {
+		m, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			if last.IsZero() {
+				return nil
+			}
+
+			return agent.Collect(last.Add(timeout + time.Nanosecond))
+		}
+		if err != nil {
+			return err
+		}
+		last = m.Timestamp
+		if err = agent.Collect(m.Timestamp); err != nil {
+			return err
+		}
+		switch m.Type.Class {
+		case stun.ClassRequest:
+			err = agent.Start(m.TransactionID, m.Timestamp.Add(timeout))
+			if errors.Is(err, stun.ErrTransactionExists) {
+				err = nil
+			}
+		case stun.ClassSuccessResponse, stun.ClassErrorResponse:
+			err = agent.Process(m.Message)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
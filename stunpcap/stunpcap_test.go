@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunpcap
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+type packetSlice []Packet
+
+func (s *packetSlice) NextPacket() (Packet, error) {
+	if len(*s) == 0 {
+		return Packet{}, io.EOF
+	}
+	p := (*s)[0]
+	*s = (*s)[1:]
+
+	return p, nil
+}
+
+var (
+	clientAddr = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50000}
+	serverAddr = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 3478}
+)
+
+func TestDecoder(t *testing.T) {
+	start := time.Unix(1000, 0)
+	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	response := stun.MustBuild(request, stun.BindingSuccess)
+	tcpAddr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50001}
+	packets := packetSlice{
+		{Timestamp: start, Src: clientAddr, Dst: serverAddr, Data: request.Raw},
+		{Timestamp: start, Src: clientAddr, Dst: serverAddr, Data: []byte("not a STUN message")},
+		{Timestamp: start, Src: tcpAddr, Data: append(append([]byte(nil), request.Raw...), response.Raw...)},
+		{Timestamp: start.Add(time.Second), Src: serverAddr, Dst: clientAddr, Data: response.Raw},
+	}
+	d := NewDecoder(&packets)
+	for i, expected := range []struct {
+		typ stun.MessageType
+		src net.Addr
+	}{
+		{stun.BindingRequest, clientAddr},
+		{stun.BindingRequest, tcpAddr},
+		{stun.BindingSuccess, tcpAddr},
+		{stun.BindingSuccess, serverAddr},
+	} {
+		m, err := d.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != expected.typ || m.Src != expected.src {
+			t.Errorf("%d: unexpected message %s from %s", i, m, m.Src)
+		}
+		if m.TransactionID != request.TransactionID {
+			t.Errorf("%d: unexpected transaction ID", i)
+		}
+	}
+	if _, err := d.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if d.Skipped() != 1 {
+		t.Errorf("unexpected skipped count: %d", d.Skipped())
+	}
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Unix(1000, 0)
+	answered := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	lost := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	packets := packetSlice{
+		{Timestamp: start, Src: clientAddr, Data: answered.Raw},
+		{Timestamp: start, Src: clientAddr, Data: lost.Raw},
+		{Timestamp: start.Add(time.Millisecond * 500), Src: clientAddr, Data: lost.Raw}, // retransmit
+		{Timestamp: start.Add(time.Millisecond * 100), Src: serverAddr, Data: stun.MustBuild(answered, stun.BindingSuccess).Raw},
+	}
+	events := make(map[[stun.TransactionIDSize]byte]error)
+	agent := stun.NewAgent(func(e stun.Event) {
+		events[e.TransactionID] = e.Error
+	})
+	if err := Replay(NewDecoder(&packets), agent, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err, ok := events[answered.TransactionID]; !ok || err != nil {
+		t.Errorf("unexpected answered transaction result: %v", err)
+	}
+	if err := events[lost.TransactionID]; !errors.Is(err, stun.ErrTransactionTimeOut) {
+		t.Errorf("unexpected lost transaction result: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunserver
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// defaultBatchSize is default count of datagrams read or written per
+// system call.
+const defaultBatchSize = 16
+
+// WithBatchSize sets maximum count of datagrams that are read or written
+// per system call on UDP sockets, 16 by default. Batches are implemented
+// via recvmmsg and sendmmsg on Linux, other platforms transparently
+// process single datagram per call. Size of 1 disables batching.
+func WithBatchSize(size int) Option {
+	return func(s *Server) {
+		s.batchSize = size
+	}
+}
+
+// WithUDPOffload enables or disables UDP segmentation and receive
+// offloads (UDP_SEGMENT and UDP_GRO socket options) for batched UDP
+// sockets on Linux. Offloads are enabled by default if supported by
+// kernel.
+//
+// With segmentation offload, consecutive responses to the same client
+// are sent as single buffer that is split by kernel or network device,
+// e.g. when client sends bursts of requests.
+func WithUDPOffload(enabled bool) Option {
+	return func(s *Server) {
+		s.noOffload = !enabled
+	}
+}
+
+// batchConn reads and writes multiple datagrams per call, implemented by
+// ipv4.PacketConn and ipv6.PacketConn.
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// newBatchConn returns batchConn for conn if it is UDP socket.
+func newBatchConn(conn net.PacketConn) (batchConn, *net.UDPConn, bool) {
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		return nil, nil, false
+	}
+	if addr, isUDP := udpConn.LocalAddr().(*net.UDPAddr); isUDP && addr.IP.To4() == nil {
+		return ipv6.NewPacketConn(udpConn), udpConn, true
+	}
+
+	return ipv4.NewPacketConn(udpConn), udpConn, true
+}
+
+// Offload limits.
+const (
+	maxOffloadSize     = 65507 // maximum UDP payload over IPv4
+	maxOffloadSegments = 64    // UDP_MAX_SEGMENTS of Linux kernel
+)
+
+// messageBatch is buffer of datagrams for batchConn.
+//
+// If offload is enabled, each message can hold several segments of equal
+// size, where only the last one can be shorter.
+type messageBatch struct {
+	ms      []ipv4.Message
+	n       int   // count of messages to write
+	size    []int // segment size of messages to write
+	offload bool
+}
+
+func newMessageBatch(size int, offload bool) *messageBatch {
+	b := &messageBatch{
+		ms:      make([]ipv4.Message, size),
+		size:    make([]int, size),
+		offload: offload,
+	}
+	bufferSize := maxMessageSize
+	if offload {
+		bufferSize = maxOffloadSize
+	}
+	for i := range b.ms {
+		b.ms[i].Buffers = [][]byte{make([]byte, bufferSize)}
+		if offload {
+			b.ms[i].OOB = make([]byte, offloadOOBSize)
+		}
+	}
+
+	return b
+}
+
+// segments calls f for each datagram of i-th received message, splitting
+// message coalesced by receive offload.
+func (b *messageBatch) segments(i int, f func(data []byte)) {
+	m := &b.ms[i]
+	data := m.Buffers[0][:m.N]
+	size := len(data)
+	if b.offload {
+		if gro := segmentSize(m.OOB[:m.NN]); gro > 0 {
+			size = gro
+		}
+	}
+	for len(data) > size {
+		f(data[:size])
+		data = data[size:]
+	}
+	f(data)
+}
+
+// add queues datagram for writing, copying p. Returns false if batch is
+// full.
+func (b *messageBatch) add(p []byte, addr net.Addr) bool {
+	if b.n > 0 && b.offload && b.canAppend(b.n-1, p, addr) {
+		m := &b.ms[b.n-1]
+		m.Buffers[0] = append(m.Buffers[0], p...)
+
+		return true
+	}
+	if b.n == len(b.ms) {
+		return false
+	}
+	m := &b.ms[b.n]
+	m.Buffers[0] = append(m.Buffers[0][:0], p...)
+	m.Addr = addr
+	b.size[b.n] = len(p)
+	b.n++
+
+	return true
+}
+
+// canAppend reports whether p can be appended as segment of i-th message.
+func (b *messageBatch) canAppend(i int, p []byte, addr net.Addr) bool {
+	var (
+		m    = &b.ms[i]
+		size = b.size[i]
+		n    = len(m.Buffers[0])
+	)
+
+	return len(p) <= size && n%size == 0 && // last segment is full
+		n/size < maxOffloadSegments && n+len(p) <= maxOffloadSize &&
+		sameAddr(m.Addr, addr)
+}
+
+// prepare sets segment size control messages of queued messages.
+func (b *messageBatch) prepare() {
+	for i := range b.ms[:b.n] {
+		m := &b.ms[i]
+		m.OOB = m.OOB[:cap(m.OOB)]
+		if len(m.Buffers[0]) > b.size[i] {
+			m.OOB = m.OOB[:putSegmentSize(m.OOB, b.size[i])]
+		} else {
+			m.OOB = m.OOB[:0]
+		}
+	}
+}
+
+func sameAddr(a, b net.Addr) bool {
+	x, ok := a.(*net.UDPAddr)
+	y, ok2 := b.(*net.UDPAddr)
+	if !ok || !ok2 {
+		return false
+	}
+
+	return x.Port == y.Port && x.IP.Equal(y.IP) && x.Zone == y.Zone
+}
+
+// serveBatch is servePacket loop that reads and writes datagrams in
+// batches.
+func (s *Server) serveBatch(conn net.PacketConn, bc batchConn, udpConn *net.UDPConn, group *behaviorGroup) error {
+	var gso, gro bool
+	if !s.noOffload {
+		gso, gro = udpOffload(udpConn)
+	}
+	var (
+		in  = newMessageBatch(s.batchSize, gro)
+		out = newMessageBatch(s.batchSize, gso)
+		h   = s.newPacketHandler(conn, group)
+		err error
+	)
+	handle := func(data []byte, from net.Addr) {
+		target, addr, ok := s.handlePacket(h, data, from)
+		if !ok || err != nil {
+			return
+		}
+		if target != conn {
+			// Response is sent from other socket of behavior group.
+			err = s.writeResponse(target, h.res.Raw, addr)
+
+			return
+		}
+		if !out.add(h.res.Raw, addr) {
+			if err = s.writeBatch(conn, bc, out); err == nil {
+				out.add(h.res.Raw, addr)
+			}
+		}
+	}
+	for {
+		n, readErr := bc.ReadBatch(in.ms, 0)
+		if readErr != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+
+			return readErr
+		}
+		for i := 0; i < n; i++ {
+			from := in.ms[i].Addr
+			in.segments(i, func(data []byte) {
+				handle(data, from)
+			})
+		}
+		if err == nil {
+			err = s.writeBatch(conn, bc, out)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeBatch writes queued messages of b, skipping ones that failed.
+func (s *Server) writeBatch(conn net.PacketConn, bc batchConn, b *messageBatch) error {
+	b.prepare()
+	defer func() {
+		b.n = 0
+	}()
+	for i := 0; i < b.n; {
+		n, err := bc.WriteBatch(b.ms[i:b.n], 0)
+		i += n
+		if err == nil {
+			continue
+		}
+		if s.isClosed() {
+			return ErrServerClosed
+		}
+		if i >= b.n {
+			break
+		}
+		if len(b.ms[i].OOB) > 0 && isOffloadError(err) {
+			// Network device does not support segmentation offload.
+			s.log.Debugf("stunserver: disabling UDP segmentation offload: %v", err)
+			b.offload = false
+			if err = s.writeSegments(conn, &b.ms[i], b.size[i]); err != nil {
+				return err
+			}
+			b.ms[i].OOB = b.ms[i].OOB[:0]
+			i++
+
+			continue
+		}
+		s.log.Debugf("stunserver: failed to write response to %s: %v", b.ms[i].Addr, err)
+		i++
+	}
+
+	return nil
+}
+
+// writeSegments writes segments of message separately.
+func (s *Server) writeSegments(conn net.PacketConn, m *ipv4.Message, size int) error {
+	for data := m.Buffers[0]; len(data) > 0; {
+		segment := data
+		if len(segment) > size {
+			segment = segment[:size]
+		}
+		data = data[len(segment):]
+		if err := s.writeResponse(conn, segment, m.Addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
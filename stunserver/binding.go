@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunserver
+
+import (
+	"net"
+
+	"github.com/pion/stun/v3"
+)
+
+// behaviorGroup are sockets for RFC 5780 mode, indexed by address and
+// port, where 0 is primary and 1 is alternate.
+type behaviorGroup [2][2]net.PacketConn
+
+// CHANGE-REQUEST flags, RFC 5780 Section 7.2.
+const (
+	changeIPFlag   = 0x04
+	changePortFlag = 0x02
+)
+
+// prepare returns socket for sending response to r that was received
+// on conn, filling RFC 5780 fields of r.
+func (g *behaviorGroup) prepare(conn net.PacketConn, r *Request) net.PacketConn {
+	var ip, port int
+	for i := range g {
+		for j := range g[i] {
+			if g[i][j] == conn {
+				ip, port = i, j
+			}
+		}
+	}
+	r.OtherAddress = g[ip^1][port^1].LocalAddr()
+	if v, err := r.Message.Get(stun.AttrChangeRequest); err == nil && len(v) == 4 {
+		if v[3]&changeIPFlag != 0 {
+			ip ^= 1
+		}
+		if v[3]&changePortFlag != 0 {
+			port ^= 1
+		}
+	}
+	out := g[ip][port]
+	r.ResponseOrigin = out.LocalAddr()
+
+	return out
+}
+
+// knownAttributes are comprehension-required attributes that server
+// understands in Binding requests.
+//
+//nolint:gochecknoglobals
+var knownAttributes = map[stun.AttrType]bool{
+	stun.AttrUsername:               true,
+	stun.AttrMessageIntegrity:       true,
+	stun.AttrMessageIntegritySHA256: true,
+	stun.AttrRealm:                  true,
+	stun.AttrNonce:                  true,
+	stun.AttrUserhash:               true,
+	stun.AttrPasswordAlgorithm:      true,
+	stun.AttrChangeRequest:          true,
+	stun.AttrPadding:                true,
+	stun.AttrResponsePort:           true,
+	stun.AttrPriority:               true,
+	stun.AttrUseCandidate:           true,
+}
+
+// BindingHandler returns Handler that answers Binding requests with
+// XOR-MAPPED-ADDRESS, adding RESPONSE-ORIGIN and OTHER-ADDRESS in RFC
+// 5780 behavior discovery mode. Requests of other methods and ones with
+// unknown comprehension-required attributes are answered with error,
+// other messages are ignored. RFC 3489 clients, which are served with
+// WithDecodeMode(stun.DecodeLegacy), get MAPPED-ADDRESS instead.
+func BindingHandler() Handler {
+	return HandlerFunc(serveBinding)
+}
+
+func serveBinding(w ResponseWriter, r *Request) {
+	req := r.Message
+	if req.Type.Class != stun.ClassRequest {
+		return
+	}
+	if req.Type.Method != stun.MethodBinding {
+		_ = WriteError(w, r, stun.CodeBadRequest)
+
+		return
+	}
+	var unknown stun.UnknownAttributes
+	for _, a := range req.Attributes {
+		if a.Type.Required() && !knownAttributes[a.Type] {
+			unknown = append(unknown, a.Type)
+		}
+	}
+	if len(unknown) > 0 {
+		r.debugf("stunserver: unknown attributes %s from %s", unknown, r.RemoteAddr)
+		_ = WriteError(w, r, stun.CodeUnknownAttribute, unknown)
+
+		return
+	}
+	// Response is built without setters slice, so it does not allocate.
+	res := acquireMessage()
+	defer releaseMessage(res)
+	res.TransactionID = req.TransactionID
+	res.Type = stun.BindingSuccess
+	res.WriteHeader()
+	ip, port := addrIPPort(r.RemoteAddr)
+	var err error
+	if stun.IsRFC3489(req) {
+		err = (&stun.MappedAddress{IP: ip, Port: port}).AddTo(res)
+	} else {
+		err = stun.XORMappedAddress{IP: ip, Port: port}.AddTo(res)
+	}
+	if err == nil && r.ResponseOrigin != nil {
+		originIP, originPort := addrIPPort(r.ResponseOrigin)
+		otherIP, otherPort := addrIPPort(r.OtherAddress)
+		origin := stun.ResponseOrigin{IP: originIP, Port: originPort}
+		other := stun.OtherAddress{IP: otherIP, Port: otherPort}
+		if err = origin.AddTo(res); err == nil {
+			err = other.AddTo(res)
+		}
+	}
+	if err != nil {
+		_ = WriteError(w, r, stun.CodeServerError)
+
+		return
+	}
+	_ = w.WriteMessage(res)
+}
+
+// responseAddr returns address that response to req received from
+// remote over datagram transport should be sent to, honoring
+// RESPONSE-PORT attribute (RFC 5780 Section 7.5).
+func responseAddr(req *stun.Message, remote net.Addr) net.Addr {
+	var port stun.ResponsePort
+	if port.GetFrom(req) != nil {
+		return remote
+	}
+	ip, _ := addrIPPort(remote)
+
+	return &net.UDPAddr{IP: ip, Port: int(port)}
+}
+
+// addrIPPort returns IP and port of addr.
+func addrIPPort(addr net.Addr) (net.IP, int) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP, a.Port
+	case *net.TCPAddr:
+		return a.IP, a.Port
+	default:
+		udpAddr, err := net.ResolveUDPAddr("udp", addr.String())
+		if err != nil {
+			return nil, 0
+		}
+
+		return udpAddr.IP, udpAddr.Port
+	}
+}
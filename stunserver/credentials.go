@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunserver
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// CredentialStore looks up long-term credential key (see
+// stun.NewLongTermIntegrity) for provided username and realm.
+type CredentialStore interface {
+	Lookup(username, realm string) (key []byte, ok bool)
+}
+
+// AlgorithmCredentialStore is CredentialStore that also looks up keys
+// derived with password algorithms of RFC 8489, see
+// stun.NewLongTermIntegrityAlgorithm and WithPasswordAlgorithms.
+type AlgorithmCredentialStore interface {
+	CredentialStore
+	LookupAlgorithm(username, realm string, alg stun.PasswordAlgorithm) (key []byte, ok bool)
+}
+
+// CredentialsFunc is adapter to use ordinary function as CredentialStore.
+// Keep stun.LongTermCredentials of users to avoid deriving key for each
+// request.
+type CredentialsFunc func(username, realm string) (key []byte, ok bool)
+
+// Lookup returns f(username, realm).
+func (f CredentialsFunc) Lookup(username, realm string) ([]byte, bool) {
+	return f(username, realm)
+}
+
+// StaticCredentials is AlgorithmCredentialStore of fixed users of single
+// realm.
+type StaticCredentials struct {
+	realm string
+	keys  map[string]staticKey
+}
+
+// staticKey is credentials of user with password or stored MD5 key.
+type staticKey struct {
+	creds *stun.LongTermCredentials // nil if only md5 is known
+	md5   []byte
+}
+
+// NewStaticCredentials derives keys of users from passwords by username.
+func NewStaticCredentials(realm string, passwords map[string]string) *StaticCredentials {
+	c := &StaticCredentials{realm: realm, keys: make(map[string]staticKey, len(passwords))}
+	for username, password := range passwords {
+		c.keys[username] = staticKey{creds: stun.NewLongTermCredentials(username, realm, password)}
+	}
+
+	return c
+}
+
+// Lookup returns MD5 key of username if realm matches.
+func (c *StaticCredentials) Lookup(username, realm string) ([]byte, bool) {
+	return c.LookupAlgorithm(username, realm, stun.PasswordAlgorithmMD5)
+}
+
+// LookupAlgorithm returns key of username for alg if realm matches.
+// Users with stored key have only MD5 key.
+func (c *StaticCredentials) LookupAlgorithm(username, realm string, alg stun.PasswordAlgorithm) ([]byte, bool) {
+	if realm != c.realm {
+		return nil, false
+	}
+	k, ok := c.keys[username]
+	switch {
+	case !ok:
+		return nil, false
+	case k.creds != nil:
+		key, err := k.creds.Key(alg)
+
+		return key, err == nil
+	default:
+		return k.md5, alg == stun.PasswordAlgorithmMD5 && stun.LegacyHashesEnabled()
+	}
+}
+
+// Len returns count of users.
+func (c *StaticCredentials) Len() int {
+	return len(c.keys)
+}
+
+var errCredentialsLine = errors.New("expected username:password")
+
+// ParseCredentials reads htpasswd-style username:password lines of realm
+// users from r. Empty lines and lines starting with "#" are skipped.
+// Password can be "0x" followed by hex-encoded 16 byte key instead, like
+// in TURN server user databases, so plain passwords are not stored.
+func ParseCredentials(r io.Reader, realm string) (*StaticCredentials, error) {
+	c := &StaticCredentials{realm: realm, keys: make(map[string]staticKey)}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		username, password, found := strings.Cut(text, ":")
+		if !found {
+			return nil, fmt.Errorf("line %d: %w", line, errCredentialsLine)
+		}
+		if encoded, ok := strings.CutPrefix(password, "0x"); ok && len(encoded) == 2*md5Size {
+			key, err := hex.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			c.keys[username] = staticKey{md5: key}
+
+			continue
+		}
+		c.keys[username] = staticKey{creds: stun.NewLongTermCredentials(username, realm, password)}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// md5Size is size of long-term key.
+const md5Size = 16
+
+// LoadCredentials reads file with ParseCredentials.
+func LoadCredentials(name, realm string) (*StaticCredentials, error) {
+	f, err := os.Open(name) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+	c, err := ParseCredentials(f, realm)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return c, nil
+}
+
+// TURNRESTCredentials is CredentialStore of time-limited credentials of
+// TURN REST API (draft-uberti-behave-turn-rest), that are issued by web
+// service sharing secret with server, see
+// stun.GenerateEphemeralCredentials.
+type TURNRESTCredentials struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewTURNRESTCredentials returns TURNRESTCredentials with shared secret.
+func NewTURNRESTCredentials(secret []byte) *TURNRESTCredentials {
+	return &TURNRESTCredentials{secret: secret, now: time.Now}
+}
+
+// Password returns password of username.
+func (c *TURNRESTCredentials) Password(username string) string {
+	return stun.EphemeralPassword(c.secret, username)
+}
+
+// Issue returns credentials of user that expire after ttl.
+func (c *TURNRESTCredentials) Issue(user string, ttl time.Duration) (username, password string) {
+	creds := stun.NewEphemeralCredentials(c.secret, c.now().Add(ttl), user)
+
+	return creds.Username, creds.Password
+}
+
+// Lookup returns MD5 key of username if it is not expired.
+func (c *TURNRESTCredentials) Lookup(username, realm string) ([]byte, bool) {
+	return c.LookupAlgorithm(username, realm, stun.PasswordAlgorithmMD5)
+}
+
+// LookupAlgorithm returns key of username for alg if it is not expired.
+func (c *TURNRESTCredentials) LookupAlgorithm(username, realm string, alg stun.PasswordAlgorithm) ([]byte, bool) {
+	expires, err := stun.EphemeralExpiration(username)
+	if err != nil || c.now().After(expires) {
+		return nil, false
+	}
+	key, err := stun.NewLongTermIntegrityAlgorithm(username, realm, c.Password(username), alg)
+
+	return key, err == nil
+}
@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunserver
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pion/stun/v3"
+)
+
+// Handler responds to STUN message received by server.
+//
+// Handler is called for each decoded message, including indications,
+// and must not retain w or r after return.
+type Handler interface {
+	ServeSTUN(w ResponseWriter, r *Request)
+}
+
+// HandlerFunc is adapter to use ordinary function as Handler.
+type HandlerFunc func(w ResponseWriter, r *Request)
+
+// ServeSTUN calls f(w, r).
+func (f HandlerFunc) ServeSTUN(w ResponseWriter, r *Request) {
+	f(w, r)
+}
+
+// Middleware wraps Handler, e.g. to reject requests before they reach
+// next handler or to set fields of Request for it.
+type Middleware func(next Handler) Handler
+
+// Chain returns h wrapped with middleware, where the first one is
+// outermost and receives requests first.
+func Chain(h Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+
+	return h
+}
+
+// WithHandler sets handler that serves decoded messages, BindingHandler
+// by default.
+func WithHandler(h Handler) Option {
+	return func(s *Server) {
+		s.handler = h
+	}
+}
+
+// WithMiddleware appends middleware that wraps handler of server.
+//
+// Middleware set by WithRateLimiter, WithLongTermAuth and
+// WithReplayCache options is outermost, so middleware of this option
+// receives authenticated requests.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, middleware...)
+	}
+}
+
+// ErrResponseWritten means that response to request is already written.
+var ErrResponseWritten = errors.New("stunserver: response is already written")
+
+// ResponseWriter sends response to request.
+type ResponseWriter interface {
+	// WriteMessage sends response m. Server serializes m into its own
+	// buffer, adding SOFTWARE attribute, MESSAGE-INTEGRITY of
+	// authenticated request to success response and FINGERPRINT
+	// attribute, so m must not contain them and can be reused after
+	// call. Only one response can be written for request.
+	WriteMessage(m *stun.Message) error
+}
+
+// Request is STUN message received by server.
+type Request struct {
+	Message    *stun.Message
+	RemoteAddr net.Addr
+	LocalAddr  net.Addr
+
+	// ResponseOrigin and OtherAddress are RESPONSE-ORIGIN and
+	// OTHER-ADDRESS of response, set only in RFC 5780 behavior discovery
+	// mode.
+	ResponseOrigin net.Addr
+	OtherAddress   net.Addr
+
+	// Integrity is MESSAGE-INTEGRITY or MESSAGE-INTEGRITY-SHA256 with key
+	// of authenticated request, set by authentication middleware. Server
+	// protects success response with it.
+	Integrity stun.Integrity
+
+	srv *Server
+}
+
+// reset prepares r for serving new message.
+func (r *Request) reset(remote, local net.Addr) {
+	r.RemoteAddr = remote
+	r.LocalAddr = local
+	r.ResponseOrigin = nil
+	r.OtherAddress = nil
+	r.Integrity = nil
+}
+
+// debugf logs debug message with logger of server, if any.
+func (r *Request) debugf(format string, args ...interface{}) {
+	if r.srv != nil {
+		r.srv.log.Debugf(format, args...)
+	}
+}
+
+// WriteError writes error response to r with code and attributes of
+// setters, e.g. REALM and NONCE.
+func WriteError(w ResponseWriter, r *Request, code stun.ErrorCode, setters ...stun.Setter) error {
+	res := acquireMessage()
+	defer releaseMessage(res)
+	all := make([]stun.Setter, 0, len(setters)+3)
+	all = append(all, r.Message, stun.NewType(r.Message.Type.Method, stun.ClassErrorResponse), code)
+	if err := res.Build(append(all, setters...)...); err != nil {
+		return err
+	}
+
+	return w.WriteMessage(res)
+}
+
+//nolint:gochecknoglobals
+var messagePool = sync.Pool{
+	New: func() interface{} {
+		return new(stun.Message)
+	},
+}
+
+func acquireMessage() *stun.Message {
+	return messagePool.Get().(*stun.Message) //nolint:forcetypeassert
+}
+
+func releaseMessage(m *stun.Message) {
+	m.Reset()
+	messagePool.Put(m)
+}
+
+// responseWriter is ResponseWriter of server that serializes response
+// into buffer, which is reused for all requests of socket or connection
+// and is sent by caller after handler returns. Writing response does
+// not allocate.
+type responseWriter struct {
+	srv     *Server
+	req     *Request
+	res     *stun.Message
+	written bool
+	logArgs [2]interface{}
+}
+
+func (w *responseWriter) WriteMessage(m *stun.Message) error {
+	if w.written {
+		return ErrResponseWritten
+	}
+	res := w.res
+	if err := m.CloneTo(res); err != nil {
+		return err
+	}
+	if w.srv.software != nil {
+		err := stun.StampSoftware(res, *w.srv.software)
+		if err != nil && !errors.Is(err, stun.ErrMessageProtected) {
+			return err
+		}
+	}
+	if w.req.Integrity != nil && res.Type.Class == stun.ClassSuccessResponse {
+		if err := w.req.Integrity.AddTo(res); err != nil {
+			return err
+		}
+	}
+	if stun.IsRFC3489(w.req.Message) {
+		// Classic STUN has no FINGERPRINT, and the magic cookie is part
+		// of transaction ID.
+		copy(res.Raw[4:8], w.req.Message.Raw[4:8])
+	} else if err := stun.Fingerprint.AddTo(res); err != nil {
+		return err
+	}
+	w.written = true
+	if res.Type.Class == stun.ClassErrorResponse {
+		atomic.AddUint64(&w.srv.stats.errors, 1)
+	} else {
+		atomic.AddUint64(&w.srv.stats.success, 1)
+	}
+	w.srv.observeResponse(res)
+
+	return nil
+}
+
+// dispatch serves r with handler of server, returning false if there
+// is no response to send.
+func (s *Server) dispatch(w *responseWriter, r *Request) bool {
+	// Arguments are kept in w, so trace does not allocate when disabled.
+	w.logArgs[0], w.logArgs[1] = r.Message, r.RemoteAddr
+	s.log.Tracef("stunserver: %s from %s", w.logArgs[:]...)
+	w.written = false
+	s.handler.ServeSTUN(w, r)
+	if !w.written {
+		atomic.AddUint64(&s.stats.dropped, 1)
+	}
+
+	return w.written
+}
@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunserver
+
+import "github.com/pion/stun/v3"
+
+// MetricsCollector receives server traffic events, see WithMetrics.
+// Methods are called concurrently by goroutines that serve requests, so
+// they should not block.
+type MetricsCollector interface {
+	// ObserveMessage is called for each decoded message with its type
+	// and size in bytes.
+	ObserveMessage(t stun.MessageType, size int)
+	// ObserveResponse is called for each written response with its type
+	// and code of ERROR-CODE attribute, which is zero for success
+	// responses.
+	ObserveResponse(t stun.MessageType, code stun.ErrorCode)
+	// IncMalformed is called for each packet that failed to decode.
+	IncMalformed()
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveMessage(stun.MessageType, int)             {}
+func (noopMetrics) ObserveResponse(stun.MessageType, stun.ErrorCode) {}
+func (noopMetrics) IncMalformed()                                    {}
+
+// WithMetrics sets collector of per-method, per-class and per-error-code
+// counters, e.g. to see bursts of 401 responses or malformed traffic.
+// See stunmetrics.ServerMetrics for implementation that exposes them to
+// Prometheus and expvar.
+func WithMetrics(m MetricsCollector) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
+}
+
+// observeResponse reports written response res to metrics of server.
+func (s *Server) observeResponse(res *stun.Message) {
+	var code stun.ErrorCodeAttribute
+	if res.Type.Class == stun.ClassErrorResponse {
+		_ = code.GetFrom(res)
+	}
+	s.metrics.ObserveResponse(res.Type, code.Code)
+}
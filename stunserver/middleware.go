@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunserver
+
+import (
+	"github.com/pion/logging"
+	"github.com/pion/stun/v3"
+)
+
+// LongTermAuth returns Middleware that authenticates requests with
+// long-term credential mechanism (RFC 5389 Section 10.2), setting
+// Request.Integrity for next handler. Requests with
+// MESSAGE-INTEGRITY-SHA256 of RFC 8489 are checked and answered with it
+// instead of MESSAGE-INTEGRITY, see stun.IntegrityFor. Requests that fail are answered
+// with error that carries realm and nonce issued by nonces. Other
+// messages are passed to next handler unauthenticated.
+//
+// If algorithms are provided, password algorithm is negotiated as in
+// RFC 8489 Section 9.2.4: challenges carry PASSWORD-ALGORITHMS, and
+// requests with algorithm that is not in the list, including requests
+// without PASSWORD-ALGORITHM that use MD5 of RFC 5389, are answered with
+// 400 (Bad Request) with PASSWORD-ALGORITHMS. Nonces should advertise
+// stun.FeaturePasswordAlgorithms, and keys of algorithms other than MD5
+// are looked up only in AlgorithmCredentialStore.
+func LongTermAuth(
+	realm string, credentials CredentialStore, nonces *stun.NonceManager, algorithms ...stun.PasswordAlgorithm,
+) Middleware {
+	challenge := []stun.Setter{stun.NewRealm(realm), nonces}
+	if len(algorithms) > 0 {
+		challenge = append(challenge, stun.PasswordAlgorithms(algorithms))
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			if r.Message.Type.Class != stun.ClassRequest {
+				next.ServeSTUN(w, r)
+
+				return
+			}
+			integrity, code := authenticate(r.Message, credentials, nonces, algorithms)
+			switch code {
+			case 0:
+				r.Integrity = integrity
+				next.ServeSTUN(w, r)
+			case stun.CodeBadRequest:
+				r.debugf("stunserver: authentication of %s from %s failed: %d", r.Message.Type, r.RemoteAddr, code)
+				if len(algorithms) > 0 {
+					_ = WriteError(w, r, code, challenge...)
+				} else {
+					_ = WriteError(w, r, code)
+				}
+			default:
+				r.debugf("stunserver: authentication of %s from %s failed: %d", r.Message.Type, r.RemoteAddr, code)
+				_ = WriteError(w, r, code, challenge...)
+			}
+		})
+	}
+}
+
+// authenticate performs long-term credential check of req, returning
+// integrity for response or error code.
+func authenticate(
+	req *stun.Message, credentials CredentialStore, nonces *stun.NonceManager, algorithms []stun.PasswordAlgorithm,
+) (stun.Integrity, stun.ErrorCode) {
+	if !req.Contains(stun.AttrMessageIntegrity) && !req.Contains(stun.AttrMessageIntegritySHA256) {
+		return nil, stun.CodeUnauthorized
+	}
+	var (
+		username stun.Username
+		realm    stun.Realm
+		nonce    stun.Nonce
+	)
+	if err := req.Parse(&username, &realm, &nonce); err != nil {
+		return nil, stun.CodeBadRequest
+	}
+	alg, ok := negotiatedAlgorithm(req, algorithms)
+	if !ok {
+		return nil, stun.CodeBadRequest
+	}
+	if nonces.Validate(nonce) != nil {
+		return nil, stun.CodeStaleNonce
+	}
+	key, ok := lookupKey(credentials, username.String(), realm.String(), alg)
+	if !ok {
+		return nil, stun.CodeUnauthorized
+	}
+	integrity := stun.IntegrityFor(req, key)
+	if err := integrity.Check(req); err != nil {
+		return nil, stun.CodeUnauthorized
+	}
+
+	return integrity, 0
+}
+
+// negotiatedAlgorithm returns password algorithm of req, reporting
+// whether it is one of algorithms and req echoes them as RFC 8489
+// Section 9.2.4 requires. Request without PASSWORD-ALGORITHM and
+// PASSWORD-ALGORITHMS uses MD5, which is the only algorithm if algorithms
+// are empty.
+func negotiatedAlgorithm(req *stun.Message, algorithms []stun.PasswordAlgorithm) (stun.PasswordAlgorithm, bool) {
+	if len(algorithms) == 0 {
+		return stun.PasswordAlgorithmMD5, true
+	}
+	if !req.Contains(stun.AttrPasswordAlgorithm) && !req.Contains(stun.AttrPasswordAlgorithms) {
+		return stun.PasswordAlgorithmMD5, containsAlgorithm(algorithms, stun.PasswordAlgorithmMD5)
+	}
+	var (
+		alg  stun.PasswordAlgorithm
+		echo stun.PasswordAlgorithms
+	)
+	if req.Parse(&alg, &echo) != nil || len(echo) != len(algorithms) {
+		return 0, false
+	}
+	for i := range echo {
+		if echo[i] != algorithms[i] {
+			return 0, false
+		}
+	}
+
+	return alg, containsAlgorithm(algorithms, alg)
+}
+
+func containsAlgorithm(algorithms []stun.PasswordAlgorithm, alg stun.PasswordAlgorithm) bool {
+	for _, a := range algorithms {
+		if a == alg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookupKey returns key of alg from credentials, using Lookup for MD5 if
+// credentials are not AlgorithmCredentialStore.
+func lookupKey(credentials CredentialStore, username, realm string, alg stun.PasswordAlgorithm) ([]byte, bool) {
+	if store, ok := credentials.(AlgorithmCredentialStore); ok {
+		return store.LookupAlgorithm(username, realm, alg)
+	}
+	if alg != stun.PasswordAlgorithmMD5 {
+		return nil, false
+	}
+
+	return credentials.Lookup(username, realm)
+}
+
+// RequireFingerprint returns Middleware that drops messages without
+// valid FINGERPRINT attribute, e.g. when STUN is multiplexed with other
+// protocols on the same port.
+func RequireFingerprint() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			if err := stun.Fingerprint.Check(r.Message); err != nil {
+				r.debugf("stunserver: dropping %s from %s: %v", r.Message.Type, r.RemoteAddr, err)
+
+				return
+			}
+			next.ServeSTUN(w, r)
+		})
+	}
+}
+
+// Logging returns Middleware that logs each message and type of its
+// response with debug level.
+func Logging(log logging.LeveledLogger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			lw := &loggingWriter{ResponseWriter: w}
+			next.ServeSTUN(lw, r)
+			if !lw.written {
+				log.Debugf("stunserver: %s from %s: no response", r.Message.Type, r.RemoteAddr)
+
+				return
+			}
+			log.Debugf("stunserver: %s from %s: %s", r.Message.Type, r.RemoteAddr, lw.res)
+		})
+	}
+}
+
+// loggingWriter records type of written response.
+type loggingWriter struct {
+	ResponseWriter
+	res     stun.MessageType
+	written bool
+}
+
+func (w *loggingWriter) WriteMessage(m *stun.Message) error {
+	if err := w.ResponseWriter.WriteMessage(m); err != nil {
+		return err
+	}
+	w.res, w.written = m.Type, true
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+// +build linux
+
+package stunserver
+
+import (
+	"errors"
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// offloadOOBSize is size of control message with segment size.
+var offloadOOBSize = unix.CmsgSpace(4) //nolint:gochecknoglobals
+
+// udpOffload enables UDP_GRO on conn and reports whether segmentation
+// (UDP_SEGMENT) and receive (UDP_GRO) offloads are supported.
+func udpOffload(conn *net.UDPConn) (gso, gro bool) {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return false, false
+	}
+	_ = rc.Control(func(fd uintptr) {
+		_, getErr := unix.GetsockoptInt(int(fd), unix.IPPROTO_UDP, unix.UDP_SEGMENT)
+		gso = getErr == nil
+		gro = unix.SetsockoptInt(int(fd), unix.IPPROTO_UDP, unix.UDP_GRO, 1) == nil
+	})
+
+	return gso, gro
+}
+
+// putSegmentSize writes UDP_SEGMENT control message to oob, which must
+// be at least offloadOOBSize long, returning its length.
+func putSegmentSize(oob []byte, size int) int {
+	for i := range oob[:offloadOOBSize] {
+		oob[i] = 0
+	}
+	hdr := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0])) //nolint:gosec
+	hdr.Level = unix.SOL_UDP
+	hdr.Type = unix.UDP_SEGMENT
+	hdr.SetLen(unix.CmsgLen(2))
+	*(*uint16)(unsafe.Pointer(&oob[unix.CmsgLen(0)])) = uint16(size) //nolint:gosec
+
+	return unix.CmsgSpace(2)
+}
+
+// segmentSize returns size of segments coalesced by UDP_GRO from oob, or
+// zero if datagram is not coalesced.
+func segmentSize(oob []byte) int {
+	messages, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, m := range messages {
+		if m.Header.Level == unix.SOL_UDP && m.Header.Type == unix.UDP_GRO && len(m.Data) >= 4 {
+			return int(*(*int32)(unsafe.Pointer(&m.Data[0]))) //nolint:gosec
+		}
+	}
+
+	return 0
+}
+
+// isOffloadError reports whether err means that segmentation offload is
+// not supported by network device.
+func isOffloadError(err error) bool {
+	return errors.Is(err, unix.EIO)
+}
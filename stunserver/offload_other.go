@@ -0,0 +1,27 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+// +build !linux
+
+package stunserver
+
+import "net"
+
+const offloadOOBSize = 0
+
+func udpOffload(*net.UDPConn) (gso, gro bool) {
+	return false, false
+}
+
+func putSegmentSize([]byte, int) int {
+	return 0
+}
+
+func segmentSize([]byte) int {
+	return 0
+}
+
+func isOffloadError(error) bool {
+	return false
+}
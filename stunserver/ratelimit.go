@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunserver
+
+import (
+	"container/list"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithRateLimiter limits rate of messages from each source IP address
+// with l, see RateLimit.
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(s *Server) {
+		s.limiter = l
+	}
+}
+
+// RateLimit returns Middleware that limits rate of messages from each
+// source IP address, messages over limit are dropped without response.
+// Limiting prevents use of public server for reflection and
+// amplification attacks with spoofed source addresses.
+func RateLimit(l *RateLimiter) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			if !l.Allow(r.RemoteAddr) {
+				if r.srv != nil {
+					atomic.AddUint64(&r.srv.stats.limited, 1)
+				}
+
+				return
+			}
+			next.ServeSTUN(w, r)
+		})
+	}
+}
+
+// RateLimiter is token bucket rate limiter of requests per source IP
+// address.
+//
+// Limiter tracks bounded count of addresses, evicting least recently
+// seen one to track new address. Eviction resets bucket of address to
+// full burst, so count of sources should cover active clients.
+//
+// RateLimiter is safe for concurrent use.
+type RateLimiter struct {
+	rate    float64 // tokens per second
+	burst   float64
+	sources int
+	now     func() time.Time
+
+	mux     sync.Mutex
+	buckets map[[net.IPv6len]byte]*list.Element
+	lru     list.List // of *tokenBucket, the most recent first
+}
+
+type tokenBucket struct {
+	ip     [net.IPv6len]byte
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns limiter that allows rate requests per second
+// from each IP address, with bursts of up to burst requests, tracking at
+// most sources addresses.
+func NewRateLimiter(rate float64, burst, sources int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		sources: sources,
+		now:     time.Now,
+		buckets: make(map[[net.IPv6len]byte]*list.Element),
+	}
+}
+
+// Allow reports whether request from addr is allowed, taking token from
+// bucket of its IP address.
+func (l *RateLimiter) Allow(addr net.Addr) bool {
+	var key [net.IPv6len]byte
+	ip, _ := addrIPPort(addr)
+	copy(key[:], ip.To16())
+	now := l.now()
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	e, ok := l.buckets[key]
+	if !ok {
+		for l.lru.Len() >= l.sources && l.lru.Len() > 0 {
+			back := l.lru.Back()
+			delete(l.buckets, back.Value.(*tokenBucket).ip) //nolint:forcetypeassert
+			l.lru.Remove(back)
+		}
+		e = l.lru.PushFront(&tokenBucket{ip: key, tokens: l.burst, last: now})
+		l.buckets[key] = e
+	} else {
+		l.lru.MoveToFront(e)
+	}
+	b := e.Value.(*tokenBucket) //nolint:forcetypeassert
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
+
+// Len returns count of tracked addresses.
+func (l *RateLimiter) Len() int {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	return l.lru.Len()
+}
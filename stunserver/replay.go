@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunserver
+
+import (
+	"container/list"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// WithReplayCache enables replay protection of authenticated requests
+// with cache, see ReplayProtection. Requires WithLongTermAuth.
+func WithReplayCache(c *ReplayCache) Option {
+	return func(s *Server) {
+		s.replays = c
+	}
+}
+
+// ReplayProtection returns Middleware that drops replayed authenticated
+// requests, ones with Request.Integrity set by authentication middleware,
+// see ReplayCache.
+func ReplayProtection(c *ReplayCache) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			if r.Integrity != nil && !c.Accept(r.Message.TransactionID, r.RemoteAddr) {
+				r.debugf("stunserver: dropping replayed %s from %s", r.Message.Type, r.RemoteAddr)
+
+				return
+			}
+			next.ServeSTUN(w, r)
+		})
+	}
+}
+
+// ReplayCache remembers transaction IDs of authenticated requests, so
+// server can drop captured MESSAGE-INTEGRITY protected request that is
+// replayed from other address. Retransmissions of request from the same
+// address are answered, as client can't distinguish them from lost
+// responses.
+//
+// Cache holds at most size of the most recent transactions for ttl, so
+// ttl should cover nonce lifetime: older requests are rejected by nonce
+// validation.
+//
+// ReplayCache is safe for concurrent use.
+type ReplayCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mux     sync.Mutex
+	entries map[[stun.TransactionIDSize]byte]*list.Element
+	lru     list.List // of *replayEntry, the most recent first
+}
+
+type replayEntry struct {
+	id      [stun.TransactionIDSize]byte
+	ip      net.IP
+	port    int
+	expires time.Time
+}
+
+// NewReplayCache returns new ReplayCache of size transactions that are
+// remembered for ttl.
+func NewReplayCache(size int, ttl time.Duration) *ReplayCache {
+	return &ReplayCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[[stun.TransactionIDSize]byte]*list.Element, size),
+	}
+}
+
+// Accept records transaction id of request received from addr,
+// returning false if request is replay: the same transaction was seen
+// from other address within ttl.
+func (c *ReplayCache) Accept(id [stun.TransactionIDSize]byte, addr net.Addr) bool {
+	ip, port := addrIPPort(addr)
+	now := c.now()
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if e, ok := c.entries[id]; ok {
+		entry := e.Value.(*replayEntry) //nolint:forcetypeassert
+		if now.Before(entry.expires) {
+			if entry.port != port || !entry.ip.Equal(ip) {
+				return false
+			}
+			c.lru.MoveToFront(e)
+
+			return true
+		}
+		c.remove(e)
+	}
+	for c.lru.Len() >= c.size && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+	c.entries[id] = c.lru.PushFront(&replayEntry{
+		id:      id,
+		ip:      append(net.IP(nil), ip...),
+		port:    port,
+		expires: now.Add(c.ttl),
+	})
+
+	return true
+}
+
+func (c *ReplayCache) remove(e *list.Element) {
+	delete(c.entries, e.Value.(*replayEntry).id) //nolint:forcetypeassert
+	c.lru.Remove(e)
+}
+
+// Len returns count of remembered transactions, including expired ones
+// that are not evicted yet.
+func (c *ReplayCache) Len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.lru.Len()
+}
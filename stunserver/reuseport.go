@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stunserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+)
+
+var (
+	// ErrReusePortUnsupported means that SO_REUSEPORT is not supported on
+	// current platform.
+	ErrReusePortUnsupported = errors.New("stunserver: SO_REUSEPORT is not supported")
+	// ErrNoConnections means that no connections are passed to serve.
+	ErrNoConnections = errors.New("stunserver: no connections to serve")
+)
+
+// ListenReusePort binds count sockets to the same UDP address with
+// SO_REUSEPORT, so kernel distributes datagrams between them by flow
+// hash. Serve them with Server.ServePackets to scale single port server
+// across cores.
+//
+// If address has zero port, all sockets are bound to port that is
+// selected for the first one.
+func ListenReusePort(network, address string, count int) ([]net.PacketConn, error) {
+	if count <= 1 {
+		conn, err := net.ListenPacket(network, address) //nolint:noctx
+		if err != nil {
+			return nil, err
+		}
+
+		return []net.PacketConn{conn}, nil
+	}
+	if reusePortControl == nil {
+		return nil, ErrReusePortUnsupported
+	}
+	lc := net.ListenConfig{Control: reusePortControl}
+	conns := make([]net.PacketConn, 0, count)
+	for i := 0; i < count; i++ {
+		conn, err := lc.ListenPacket(context.Background(), network, address)
+		if err != nil {
+			for _, c := range conns {
+				_ = c.Close()
+			}
+
+			return nil, err
+		}
+		if i == 0 {
+			// Binding other sockets to port that was actually selected.
+			host, _, splitErr := net.SplitHostPort(address)
+			if splitErr != nil {
+				_ = conn.Close()
+
+				return nil, splitErr
+			}
+			if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+				address = net.JoinHostPort(host, strconv.Itoa(addr.Port))
+			}
+		}
+		conns = append(conns, conn)
+	}
+
+	return conns, nil
+}
+
+// ServePackets serves each of conns in its own goroutine like
+// ServePacket, with separate buffers and messages, until any of them
+// fails or Close is called. All conns are closed on return.
+func (s *Server) ServePackets(conns ...net.PacketConn) error {
+	labeled, err := s.flowLabelConns(conns)
+	if err != nil {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+
+		return err
+	}
+
+	return s.servePackets(labeled, nil)
+}
+
+func (s *Server) servePackets(conns []net.PacketConn, group *behaviorGroup) error {
+	if len(conns) == 0 {
+		return ErrNoConnections
+	}
+	errs := make(chan error, len(conns))
+	for _, conn := range conns {
+		go func(conn net.PacketConn) {
+			errs <- s.servePacket(conn, group)
+		}(conn)
+	}
+	// Stopping all sockets when any of them fails.
+	err := <-errs
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+	for i := 1; i < len(conns); i++ {
+		<-errs
+	}
+
+	return err
+}
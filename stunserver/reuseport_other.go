@@ -0,0 +1,12 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package stunserver
+
+import "syscall"
+
+// reusePortControl is nil as SO_REUSEPORT is not supported.
+var reusePortControl func(network, address string, c syscall.RawConn) error //nolint:gochecknoglobals
@@ -0,0 +1,601 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package stunserver implements STUN server that answers Binding requests
+// over datagram and stream transports, with optional long-term
+// authentication and RFC 5780 NAT behavior discovery support.
+//
+// Messages are served by Handler that can be replaced or wrapped with
+// Middleware, similar to net/http.
+package stunserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/logging"
+	"github.com/pion/stun/v3"
+)
+
+// ErrServerClosed is returned by Serve methods after Close call.
+var ErrServerClosed = errors.New("stunserver: server closed")
+
+// maxMessageSize is the maximum size of STUN message server can receive.
+const maxMessageSize = 1500
+
+// Stats are cumulative server counters.
+type Stats struct {
+	Received  uint64 // messages received, including malformed ones
+	Malformed uint64 // packets that failed to decode as STUN
+	Success   uint64 // success responses sent
+	Errors    uint64 // error responses sent
+	Dropped   uint64 // messages without response, e.g. indications
+	Limited   uint64 // messages dropped by rate limiter, see WithRateLimiter
+}
+
+type stats struct {
+	received  uint64
+	malformed uint64
+	success   uint64
+	errors    uint64
+	dropped   uint64
+	limited   uint64
+}
+
+// Option sets server option.
+type Option func(s *Server)
+
+// WithSoftware sets SOFTWARE attribute of all responses to software,
+// replacing one added by handler, or removes it if software is empty.
+// SOFTWARE is placed before MESSAGE-INTEGRITY and FINGERPRINT.
+func WithSoftware(software string) Option {
+	return func(s *Server) {
+		v := stun.NewSoftware(software)
+		s.software = &v
+	}
+}
+
+// WithLogger sets server logger, that traces requests and malformed
+// packets and logs error responses and I/O errors with debug level.
+// Defaults to pion/logging logger with "stunserver" scope.
+func WithLogger(log logging.LeveledLogger) Option {
+	return func(s *Server) {
+		s.log = log
+	}
+}
+
+// WithLongTermAuth enables long-term credential mechanism (RFC 5389
+// Section 10.2) for all requests, using realm and credentials lookup.
+func WithLongTermAuth(realm string, credentials CredentialsFunc) Option {
+	return func(s *Server) {
+		s.realm = stun.NewRealm(realm)
+		s.credentials = nil
+		if credentials != nil {
+			s.credentials = credentials
+		}
+	}
+}
+
+// WithCredentialStore is like WithLongTermAuth, but looks up keys in
+// store, e.g. StaticCredentials or TURNRESTCredentials.
+func WithCredentialStore(realm string, store CredentialStore) Option {
+	return func(s *Server) {
+		s.realm = stun.NewRealm(realm)
+		s.credentials = store
+	}
+}
+
+// WithPasswordAlgorithms enables negotiation of password algorithm of
+// long-term authentication (RFC 8489 Section 9.2.4) with algorithms in
+// order of preference, see LongTermAuth. Defaults to SHA-256 if
+// stun.LegacyHashesEnabled is false, and to MD5 of RFC 5389 otherwise.
+func WithPasswordAlgorithms(algorithms ...stun.PasswordAlgorithm) Option {
+	return func(s *Server) {
+		s.algorithms = append([]stun.PasswordAlgorithm(nil), algorithms...)
+	}
+}
+
+// WithNonceManager sets manager that issues and validates NONCE values
+// of long-term authentication, e.g. to share nonce secret between
+// servers. Defaults to manager with random secret and
+// stun.DefaultNonceTTL, that advertises stun.FeaturePasswordAlgorithms
+// if password algorithms are negotiated.
+func WithNonceManager(m *stun.NonceManager) Option {
+	return func(s *Server) {
+		s.nonces = m
+	}
+}
+
+// WithDecodeMode sets compatibility mode of decoding requests, see
+// stun.Message.DecodeAs. Requests without magic cookie are dropped by
+// default, and stun.DecodeLegacy makes server answer RFC 3489 clients
+// with MAPPED-ADDRESS and their 128-bit transaction ID, without
+// FINGERPRINT.
+func WithDecodeMode(mode stun.DecodeMode) Option {
+	return func(s *Server) {
+		s.decodeMode = mode
+	}
+}
+
+// WithDSCP sets DSCP of responses, e.g. stun.DSCPExpeditedForwarding to
+// prioritize connectivity checks, see stun.SetDSCP. Serve methods return
+// error if DSCP can not be set on served socket, while failures on
+// accepted stream connections are logged.
+func WithDSCP(dscp int) Option {
+	return func(s *Server) {
+		s.dscp = &dscp
+	}
+}
+
+// defaultIdleTimeout is default time after which idle stream connection
+// is closed.
+const defaultIdleTimeout = 5 * time.Minute
+
+// WithIdleTimeout sets time in which client of stream connection must send
+// next message, 5 minutes by default, otherwise connection is closed.
+// Partially received message does not extend it, so slow clients can not
+// hold connections. Zero disables timeout.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.idleTimeout = d
+	}
+}
+
+// WithFlowLabel sets IPv6 flow label of responses sent by served IPv6
+// UDP sockets, see stun.NewFlowLabelPacketConn. Serve methods of packet
+// sockets return error if flow label can not be set. Responses are not
+// batched when flow label is set.
+func WithFlowLabel(label uint32) Option {
+	return func(s *Server) {
+		s.flowLabel = &label
+	}
+}
+
+// Server answers STUN Binding requests.
+//
+// All methods are safe for concurrent use.
+type Server struct {
+	software    *stun.Software
+	realm       stun.Realm
+	nonces      *stun.NonceManager
+	replays     *ReplayCache
+	limiter     *RateLimiter
+	handler     Handler
+	middleware  []Middleware
+	credentials CredentialStore
+	algorithms  []stun.PasswordAlgorithm
+	stats       stats
+	metrics     MetricsCollector
+	log         logging.LeveledLogger
+	batchSize   int
+	noOffload   bool
+	decodeMode  stun.DecodeMode
+	dscp        *int
+	flowLabel   *uint32
+	idleTimeout time.Duration
+
+	mux       sync.Mutex
+	closed    bool
+	closers   map[io.Closer]struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// New initializes new Server with provided options.
+func New(options ...Option) *Server {
+	srv := &Server{
+		closers:     make(map[io.Closer]struct{}),
+		batchSize:   defaultBatchSize,
+		idleTimeout: defaultIdleTimeout,
+	}
+	for _, o := range options {
+		o(srv)
+	}
+	if srv.metrics == nil {
+		srv.metrics = noopMetrics{}
+	}
+	if srv.log == nil {
+		srv.log = logging.NewDefaultLoggerFactory().NewLogger("stunserver")
+	}
+	if len(srv.algorithms) == 0 && !stun.LegacyHashesEnabled() {
+		srv.algorithms = []stun.PasswordAlgorithm{stun.PasswordAlgorithmSHA256}
+	}
+	if srv.nonces == nil {
+		var nonceOptions []stun.NonceOption
+		if len(srv.algorithms) > 0 {
+			nonceOptions = append(nonceOptions, stun.WithNonceFeatures(stun.FeaturePasswordAlgorithms))
+		}
+		nonces, err := stun.NewNonceManager(nonceOptions...)
+		if err != nil {
+			panic(err) //nolint
+		}
+		srv.nonces = nonces
+	}
+	if srv.handler == nil {
+		srv.handler = BindingHandler()
+	}
+	var middleware []Middleware
+	if srv.limiter != nil {
+		middleware = append(middleware, RateLimit(srv.limiter))
+	}
+	if srv.credentials != nil {
+		middleware = append(middleware, LongTermAuth(srv.realm.String(), srv.credentials, srv.nonces, srv.algorithms...))
+		if srv.replays != nil {
+			middleware = append(middleware, ReplayProtection(srv.replays))
+		}
+	}
+	srv.handler = Chain(srv.handler, append(middleware, srv.middleware...)...)
+
+	return srv
+}
+
+// Stats returns current server counters.
+func (s *Server) Stats() Stats {
+	return Stats{
+		Received:  atomic.LoadUint64(&s.stats.received),
+		Malformed: atomic.LoadUint64(&s.stats.malformed),
+		Success:   atomic.LoadUint64(&s.stats.success),
+		Errors:    atomic.LoadUint64(&s.stats.errors),
+		Dropped:   atomic.LoadUint64(&s.stats.dropped),
+		Limited:   atomic.LoadUint64(&s.stats.limited),
+	}
+}
+
+// track registers c to be closed on Close, returning false if server
+// is already closed. Each successful track call must be followed by
+// untrack, Close waits for that.
+func (s *Server) track(c io.Closer) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		return false
+	}
+	s.closers[c] = struct{}{}
+	s.wg.Add(1)
+
+	return true
+}
+
+func (s *Server) untrack(c io.Closer) {
+	s.mux.Lock()
+	delete(s.closers, c)
+	s.mux.Unlock()
+	s.wg.Done()
+}
+
+func (s *Server) isClosed() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.closed
+}
+
+// Close closes all connections and listeners that are served, waiting
+// until all Serve calls return.
+func (s *Server) Close() error {
+	err := ErrServerClosed
+	s.closeOnce.Do(func() {
+		err = s.closeAll()
+		s.wg.Wait()
+	})
+
+	return err
+}
+
+// closeAll marks server as closed and closes all tracked listeners and
+// connections, returning the first error.
+func (s *Server) closeAll() error {
+	s.mux.Lock()
+	s.closed = true
+	closers := s.closers
+	s.closers = make(map[io.Closer]struct{})
+	s.mux.Unlock()
+	var err error
+	for c := range closers {
+		closeErr := c.Close()
+		if closeErr != nil && !errors.Is(closeErr, net.ErrClosed) && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// Shutdown gracefully stops server: listeners are closed and sockets
+// and connections stop reading new requests, while requests that are
+// being served are answered. Shutdown waits until all Serve methods
+// return, then closes connections. If ctx is done before that,
+// connections are closed without waiting for handlers and ctx error is
+// returned, call Close to wait for them.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+
+		return ErrServerClosed
+	}
+	s.closed = true
+	closers := make([]io.Closer, 0, len(s.closers))
+	for c := range s.closers {
+		closers = append(closers, c)
+	}
+	s.mux.Unlock()
+	for _, c := range closers {
+		switch c := c.(type) {
+		case net.Listener:
+			_ = c.Close()
+		case interface{ SetReadDeadline(t time.Time) error }:
+			// Unblocking pending reads, serving loop stops as server is
+			// closed.
+			_ = c.SetReadDeadline(time.Now())
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return s.Close()
+	case <-ctx.Done():
+		_ = s.closeAll()
+
+		return ctx.Err()
+	}
+}
+
+// ServePacket reads requests from conn and writes responses back until
+// conn fails or Close is called, returning ErrServerClosed in latter case.
+func (s *Server) ServePacket(conn net.PacketConn) error {
+	conns, err := s.flowLabelConns([]net.PacketConn{conn})
+	if err != nil {
+		return err
+	}
+
+	return s.servePacket(conns[0], nil)
+}
+
+// ServeBehaviorDiscovery serves RFC 5780 NAT behavior discovery on four
+// sockets, bound to primary and alternate addresses and ports:
+//
+//	primary      primary address, primary port
+//	changedPort  primary address, alternate port
+//	changedIP    alternate address, primary port
+//	changedBoth  alternate address, alternate port
+//
+// Responses carry OTHER-ADDRESS and RESPONSE-ORIGIN attributes and are
+// sent from the socket selected by CHANGE-REQUEST attribute.
+func (s *Server) ServeBehaviorDiscovery(primary, changedPort, changedIP, changedBoth net.PacketConn) error {
+	conns, err := s.flowLabelConns([]net.PacketConn{primary, changedPort, changedIP, changedBoth})
+	if err != nil {
+		return err
+	}
+	group := &behaviorGroup{
+		{conns[0], conns[1]},
+		{conns[2], conns[3]},
+	}
+
+	return s.servePackets(conns, group)
+}
+
+// flowLabelConns returns conns that send packets with flow label of
+// WithFlowLabel, or conns as is if it is not set.
+func (s *Server) flowLabelConns(conns []net.PacketConn) ([]net.PacketConn, error) {
+	if s.flowLabel == nil {
+		return conns, nil
+	}
+	labeled := make([]net.PacketConn, len(conns))
+	for i, conn := range conns {
+		var err error
+		if labeled[i], err = stun.NewFlowLabelPacketConn(conn, *s.flowLabel); err != nil {
+			return nil, err
+		}
+	}
+
+	return labeled, nil
+}
+
+func (s *Server) servePacket(conn net.PacketConn, group *behaviorGroup) error {
+	if s.dscp != nil {
+		if err := stun.SetPacketDSCP(conn, *s.dscp); err != nil {
+			return err
+		}
+	}
+	if !s.track(conn) {
+		return ErrServerClosed
+	}
+	defer s.untrack(conn)
+	if s.batchSize > 1 {
+		if bc, udpConn, ok := newBatchConn(conn); ok {
+			return s.serveBatch(conn, bc, udpConn, group)
+		}
+	}
+	var (
+		buf = make([]byte, maxMessageSize)
+		h   = s.newPacketHandler(conn, group)
+	)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+
+			return err
+		}
+		target, to, ok := s.handlePacket(h, buf[:n], addr)
+		if !ok {
+			continue
+		}
+		if err = s.writeResponse(target, h.res.Raw, to); err != nil {
+			return err
+		}
+	}
+}
+
+// packetHandler holds state of datagram socket that is served.
+type packetHandler struct {
+	conn  net.PacketConn
+	group *behaviorGroup
+	req   Request
+	w     responseWriter
+	res   *stun.Message
+}
+
+func (s *Server) newPacketHandler(conn net.PacketConn, group *behaviorGroup) *packetHandler {
+	h := &packetHandler{
+		conn:  conn,
+		group: group,
+		req:   Request{Message: new(stun.Message), srv: s},
+		res:   new(stun.Message),
+	}
+	h.w = responseWriter{srv: s, req: &h.req, res: h.res}
+
+	return h
+}
+
+// handlePacket serves datagram received from addr, building response
+// into h.res. Returns socket and address to send response to, or false
+// if there is no response.
+func (s *Server) handlePacket(h *packetHandler, data []byte, addr net.Addr) (net.PacketConn, net.Addr, bool) {
+	if !s.decode(data, h.req.Message) {
+		s.log.Tracef("stunserver: malformed packet from %s", addr)
+
+		return nil, nil, false
+	}
+	out := h.conn
+	h.req.reset(addr, h.conn.LocalAddr())
+	if h.group != nil {
+		out = h.group.prepare(h.conn, &h.req)
+	}
+	if !s.dispatch(&h.w, &h.req) {
+		return nil, nil, false
+	}
+
+	return out, responseAddr(h.req.Message, addr), true
+}
+
+// writeResponse writes response to addr, logging failures. Returns
+// ErrServerClosed if server is closed.
+func (s *Server) writeResponse(conn net.PacketConn, raw []byte, addr net.Addr) error {
+	if _, err := conn.WriteTo(raw, addr); err != nil {
+		if s.isClosed() {
+			return ErrServerClosed
+		}
+		s.log.Debugf("stunserver: failed to write response to %s: %v", addr, err)
+	}
+
+	return nil
+}
+
+// Serve accepts stream connections on l, serving requests on each of
+// them, until l fails or Close is called, returning ErrServerClosed in
+// latter case.
+//
+// Use tls.NewListener to serve STUN over TLS.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l) {
+		return ErrServerClosed
+	}
+	defer s.untrack(l)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+
+			return err
+		}
+		if !s.track(conn) {
+			_ = conn.Close()
+
+			return ErrServerClosed
+		}
+		if s.dscp != nil {
+			if err = stun.SetDSCP(conn, *s.dscp); err != nil {
+				s.log.Debugf("stunserver: failed to set DSCP for %s: %v", conn.RemoteAddr(), err)
+			}
+		}
+		go func() {
+			defer s.untrack(conn)
+			s.serveConn(conn)
+			_ = conn.Close()
+		}()
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	var (
+		dec = stun.NewStreamDecoder(conn, maxMessageSize)
+		req = Request{Message: new(stun.Message), srv: s}
+		w   = responseWriter{srv: s, req: &req, res: acquireMessage()}
+	)
+	defer releaseMessage(w.res)
+	for {
+		if s.idleTimeout > 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+				s.log.Debugf("stunserver: failed to set read deadline for %s: %v", conn.RemoteAddr(), err)
+
+				return
+			}
+		}
+		raw, _, err := dec.ReadPacket()
+		if errors.Is(err, stun.ErrInvalidStream) {
+			atomic.AddUint64(&s.stats.received, 1)
+			s.countMalformed()
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !s.isClosed() {
+				s.log.Debugf("stunserver: failed to read from %s: %v", conn.RemoteAddr(), err)
+			}
+
+			return
+		}
+		if !s.decode(raw, req.Message) {
+			// Stream is de-synchronized, closing connection.
+			s.log.Debugf("stunserver: malformed message from %s, closing connection", conn.RemoteAddr())
+
+			return
+		}
+		req.reset(conn.RemoteAddr(), conn.LocalAddr())
+		if !s.dispatch(&w, &req) {
+			continue
+		}
+		if _, err = conn.Write(w.res.Raw); err != nil {
+			s.log.Debugf("stunserver: failed to write response to %s: %v", conn.RemoteAddr(), err)
+
+			return
+		}
+	}
+}
+
+// countMalformed counts packet that failed to decode.
+func (s *Server) countMalformed() {
+	atomic.AddUint64(&s.stats.malformed, 1)
+	s.metrics.IncMalformed()
+}
+
+func (s *Server) decode(data []byte, req *stun.Message) bool {
+	atomic.AddUint64(&s.stats.received, 1)
+	if !stun.IsMessage(data) && s.decodeMode != stun.DecodeLegacy {
+		s.countMalformed()
+
+		return false
+	}
+	req.Raw = append(req.Raw[:0], data...)
+	if err := req.DecodeAs(s.decodeMode); err != nil {
+		s.countMalformed()
+
+		return false
+	}
+	s.metrics.ObserveMessage(req.Type, len(data))
+
+	return true
+}
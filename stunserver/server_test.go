@@ -0,0 +1,1360 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stunserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/logging"
+	"github.com/pion/stun/v3"
+	"github.com/pion/stun/v3/internal/testutil"
+	"golang.org/x/net/ipv4"
+)
+
+func listenUDP(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+// roundTrip sends request to addr from new socket and returns response
+// and address it was received from.
+func roundTrip(t *testing.T, addr net.Addr, request *stun.Message) (*stun.Message, net.Addr) {
+	t.Helper()
+	conn := listenUDP(t)
+	defer conn.Close() //nolint:errcheck
+	if _, err := conn.WriteTo(request.Raw, addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, from, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := new(stun.Message)
+	if err = stun.Decode(buf[:n], res); err != nil {
+		t.Fatal(err)
+	}
+	if err = stun.Fingerprint.Check(res); err != nil {
+		t.Error(err)
+	}
+
+	return res, from
+}
+
+func serve(t *testing.T, srv *Server) net.Addr {
+	t.Helper()
+	conn := listenUDP(t)
+	go func() {
+		if err := srv.ServePacket(conn); !errors.Is(err, ErrServerClosed) {
+			t.Error(err)
+		}
+	}()
+
+	return conn.LocalAddr()
+}
+
+func errorCode(t *testing.T, m *stun.Message) stun.ErrorCode {
+	t.Helper()
+	var code stun.ErrorCodeAttribute
+	if err := code.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return code.Code
+}
+
+func TestServer_ServePacket(t *testing.T) {
+	srv := New(WithSoftware("stunserver test"))
+	addr := serve(t, srv)
+	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.Fingerprint)
+	res, _ := roundTrip(t, addr, request)
+	if res.Type != stun.BindingSuccess {
+		t.Fatalf("unexpected type: %s", res.Type)
+	}
+	if res.TransactionID != request.TransactionID {
+		t.Error("transaction ID mismatch")
+	}
+	var (
+		xorAddr  stun.XORMappedAddress
+		software stun.Software
+	)
+	if err := res.Parse(&xorAddr, &software); err != nil {
+		t.Fatal(err)
+	}
+	if !xorAddr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("unexpected mapped address: %s", xorAddr)
+	}
+	if software.String() != "stunserver test" {
+		t.Errorf("unexpected software: %s", software)
+	}
+
+	t.Run("UnknownAttribute", func(t *testing.T) {
+		request := stun.MustBuild(stun.TransactionID, stun.BindingRequest,
+			stun.RawAttribute{Type: 0x0042, Value: []byte{1}},
+		)
+		res, _ := roundTrip(t, addr, request)
+		if code := errorCode(t, res); code != stun.CodeUnknownAttribute {
+			t.Fatalf("unexpected code: %d", code)
+		}
+		var unknown stun.UnknownAttributes
+		if err := unknown.GetFrom(res); err != nil {
+			t.Fatal(err)
+		}
+		if len(unknown) != 1 || unknown[0] != 0x0042 {
+			t.Errorf("unexpected unknown attributes: %s", unknown)
+		}
+	})
+	t.Run("BadMethod", func(t *testing.T) {
+		request := stun.MustBuild(stun.TransactionID,
+			stun.NewType(stun.MethodAllocate, stun.ClassRequest),
+		)
+		res, _ := roundTrip(t, addr, request)
+		if code := errorCode(t, res); code != stun.CodeBadRequest {
+			t.Fatalf("unexpected code: %d", code)
+		}
+	})
+
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Close(); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("unexpected second close error: %v", err)
+	}
+	if err := srv.ServePacket(listenUDP(t)); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("unexpected serve error: %v", err)
+	}
+	stats := srv.Stats()
+	if stats.Success != 1 || stats.Errors != 2 || stats.Received != 3 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestServer_LongTermAuth(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	testutil.SkipWithoutMD5(t)
+	const (
+		username = "user"
+		realm    = "pion.ly"
+		password = "secret"
+	)
+	key := stun.NewLongTermIntegrity(username, realm, password)
+	srv := New(WithLongTermAuth(realm, func(u, r string) ([]byte, bool) {
+		if u != username || r != realm {
+			return nil, false
+		}
+
+		return key, true
+	}))
+	defer srv.Close() //nolint:errcheck
+	addr := serve(t, srv)
+
+	res, _ := roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest))
+	if code := errorCode(t, res); code != stun.CodeUnauthorized {
+		t.Fatalf("unexpected code: %d", code)
+	}
+	var nonce stun.Nonce
+	var gotRealm stun.Realm
+	if err := res.Parse(&nonce, &gotRealm); err != nil {
+		t.Fatal(err)
+	}
+	if gotRealm.String() != realm {
+		t.Errorf("unexpected realm: %s", gotRealm)
+	}
+	for _, tc := range []struct {
+		name     string
+		nonce    stun.Nonce
+		key      stun.MessageIntegrity
+		username string
+		code     stun.ErrorCode
+	}{
+		{"Success", nonce, key, username, 0},
+		{"StaleNonce", stun.NewNonce("stale"), key, username, stun.CodeStaleNonce},
+		{"BadKey", nonce, stun.NewLongTermIntegrity(username, realm, "bad"), username, stun.CodeUnauthorized},
+		{"BadUser", nonce, key, "bad", stun.CodeUnauthorized},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			request := stun.MustBuild(stun.TransactionID, stun.BindingRequest,
+				stun.NewUsername(tc.username), stun.NewRealm(realm), tc.nonce, tc.key,
+			)
+			res, _ := roundTrip(t, addr, request)
+			if tc.code != 0 {
+				if code := errorCode(t, res); code != tc.code {
+					t.Fatalf("unexpected code: %d", code)
+				}
+
+				return
+			}
+			if res.Type != stun.BindingSuccess {
+				t.Fatalf("unexpected type: %s", res.Type)
+			}
+			if err := key.Check(res); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	t.Run("BadRequest", func(t *testing.T) {
+		request := stun.MustBuild(stun.TransactionID, stun.BindingRequest, key)
+		res, _ := roundTrip(t, addr, request)
+		if code := errorCode(t, res); code != stun.CodeBadRequest {
+			t.Fatalf("unexpected code: %d", code)
+		}
+	})
+}
+
+func TestServer_PasswordAlgorithms(t *testing.T) {
+	const (
+		username = "user"
+		realm    = "pion.ly"
+		password = "secret"
+	)
+	creds := stun.Credentials{Username: username, Password: password}
+	store := NewStaticCredentials(realm, map[string]string{username: password})
+	challenge := func(t *testing.T, addr net.Addr) stun.Nonce {
+		t.Helper()
+		res, _ := roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest))
+		if code := errorCode(t, res); code != stun.CodeUnauthorized {
+			t.Fatalf("unexpected code: %d", code)
+		}
+		var (
+			nonce      stun.Nonce
+			algorithms stun.PasswordAlgorithms
+		)
+		if err := res.Parse(&nonce, &algorithms); err != nil {
+			t.Fatal(err)
+		}
+		if stun.NonceCookie(nonce).Features()&stun.FeaturePasswordAlgorithms == 0 {
+			t.Errorf("nonce does not advertise password algorithms: %s", nonce)
+		}
+		if len(algorithms) != 1 || algorithms[0] != stun.PasswordAlgorithmSHA256 {
+			t.Errorf("unexpected algorithms: %v", algorithms)
+		}
+
+		return nonce
+	}
+	srv := New(WithCredentialStore(realm, store), WithPasswordAlgorithms(stun.PasswordAlgorithmSHA256))
+	defer srv.Close() //nolint:errcheck
+	addr := serve(t, srv)
+	nonce := challenge(t, addr)
+	sha256 := stun.PasswordAlgorithms{stun.PasswordAlgorithmSHA256}
+	key, err := stun.NewLongTermIntegrityAlgorithm(username, realm, password, stun.PasswordAlgorithmSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// checkSuccess checks that response is protected with
+	// MESSAGE-INTEGRITY-SHA256 like request.
+	checkSuccess := func(t *testing.T, res *stun.Message) {
+		t.Helper()
+		if res.Type != stun.BindingSuccess {
+			t.Fatalf("unexpected response: %s", res)
+		}
+		if res.Contains(stun.AttrMessageIntegrity) {
+			t.Error("response should not have MESSAGE-INTEGRITY")
+		}
+		if err := stun.MessageIntegritySHA256(key).Check(res); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, tc := range []struct {
+		name    string
+		md5     bool
+		setters []stun.Setter
+		code    stun.ErrorCode
+	}{
+		{"SHA256", false, []stun.Setter{sha256, stun.LongTermAuth(creds, realm, nonce.String(), stun.PasswordAlgorithmSHA256)}, 0},
+		{"Legacy", true, []stun.Setter{stun.LongTermAuth(creds, realm, nonce.String(), 0)}, stun.CodeBadRequest},
+		{"MD5", true, []stun.Setter{
+			stun.PasswordAlgorithms{stun.PasswordAlgorithmMD5},
+			stun.LongTermAuth(creds, realm, nonce.String(), stun.PasswordAlgorithmMD5),
+		}, stun.CodeBadRequest},
+		{"NoEcho", false, []stun.Setter{
+			stun.LongTermAuth(creds, realm, nonce.String(), stun.PasswordAlgorithmSHA256),
+		}, stun.CodeBadRequest},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.md5 {
+				testutil.SkipWithoutMD5(t)
+			}
+			request := stun.MustBuild(append([]stun.Setter{stun.TransactionID, stun.BindingRequest}, tc.setters...)...)
+			res, _ := roundTrip(t, addr, request)
+			if tc.code == 0 {
+				checkSuccess(t, res)
+
+				return
+			}
+			if code := errorCode(t, res); code != tc.code {
+				t.Fatalf("unexpected code: %d", code)
+			}
+			var algorithms stun.PasswordAlgorithms
+			if err := algorithms.GetFrom(res); err != nil || len(algorithms) != 1 {
+				t.Errorf("unexpected algorithms: %v, %v", algorithms, err)
+			}
+		})
+	}
+	t.Run("LegacyHashesDisabled", func(t *testing.T) {
+		disableLegacyHashes(t)
+		srv := New(WithCredentialStore(realm, store))
+		defer srv.Close() //nolint:errcheck
+		addr := serve(t, srv)
+		nonce := challenge(t, addr)
+		request := stun.MustBuild(stun.TransactionID, stun.BindingRequest,
+			sha256, stun.LongTermAuth(creds, realm, nonce.String(), stun.PasswordAlgorithmSHA256),
+		)
+		res, _ := roundTrip(t, addr, request)
+		checkSuccess(t, res)
+	})
+}
+
+func TestServer_NonceManager(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	const (
+		username = "user"
+		realm    = "pion.ly"
+	)
+	key := stun.NewLongTermIntegrity(username, realm, "secret")
+	nonces, err := stun.NewNonceManager(stun.WithNonceSecret([]byte("nonce secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(WithNonceManager(nonces), WithLongTermAuth(realm, func(string, string) ([]byte, bool) {
+		return key, true
+	}))
+	defer srv.Close() //nolint:errcheck
+	addr := serve(t, srv)
+
+	// Nonce issued by other server that shares the secret is accepted.
+	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest,
+		stun.NewUsername(username), stun.NewRealm(realm), nonces, key,
+	)
+	res, _ := roundTrip(t, addr, request)
+	if res.Type != stun.BindingSuccess {
+		t.Fatalf("unexpected type: %s", res.Type)
+	}
+}
+
+func TestStaticCredentials(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	testutil.SkipWithoutMD5(t)
+	store := NewStaticCredentials("pion.ly", map[string]string{"user": "secret"})
+	key, ok := store.Lookup("user", "pion.ly")
+	if !ok || !bytes.Equal(key, stun.NewLongTermIntegrity("user", "pion.ly", "secret")) {
+		t.Error("unexpected key")
+	}
+	if _, ok = store.Lookup("user", "other"); ok {
+		t.Error("other realm should not match")
+	}
+	if _, ok = store.Lookup("other", "pion.ly"); ok {
+		t.Error("unknown user should not match")
+	}
+}
+
+func TestParseCredentials(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	key := stun.NewLongTermIntegrity("bob", "pion.ly", "hunter2")
+	store, err := ParseCredentials(strings.NewReader(
+		"# users\n\nalice:secret:with:colons\n"+fmt.Sprintf("bob:0x%x\n", []byte(key)),
+	), "pion.ly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Len() != 2 {
+		t.Fatalf("unexpected count: %d", store.Len())
+	}
+	if got, _ := store.Lookup("alice", "pion.ly"); !bytes.Equal(got, stun.NewLongTermIntegrity("alice", "pion.ly", "secret:with:colons")) {
+		t.Error("unexpected key of alice")
+	}
+	if got, _ := store.Lookup("bob", "pion.ly"); !bytes.Equal(got, key) {
+		t.Error("unexpected key of bob")
+	}
+	sha256Key, _ := stun.NewLongTermIntegrityAlgorithm(
+		"alice", "pion.ly", "secret:with:colons", stun.PasswordAlgorithmSHA256,
+	)
+	if got, _ := store.LookupAlgorithm("alice", "pion.ly", stun.PasswordAlgorithmSHA256); !bytes.Equal(got, sha256Key) {
+		t.Error("unexpected SHA-256 key of alice")
+	}
+	if _, ok := store.LookupAlgorithm("bob", "pion.ly", stun.PasswordAlgorithmSHA256); ok {
+		t.Error("stored key of bob should be MD5 only")
+	}
+	disableLegacyHashes(t)
+	if _, ok := store.Lookup("bob", "pion.ly"); ok {
+		t.Error("MD5 key should not be used with legacy hashes disabled")
+	}
+	if _, err = ParseCredentials(strings.NewReader("alice"), "pion.ly"); !errors.Is(err, errCredentialsLine) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = ParseCredentials(strings.NewReader("bob:0x"+strings.Repeat("zz", 16)), "pion.ly"); err == nil {
+		t.Error("bad hex key should fail")
+	}
+	if _, err = LoadCredentials("testdata/missing", "pion.ly"); err == nil {
+		t.Error("missing file should fail")
+	}
+}
+
+func TestTURNRESTCredentials(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	const realm = "pion.ly"
+	store := NewTURNRESTCredentials([]byte("shared secret"))
+	store.now = func() time.Time { return time.Unix(1700000000, 0) }
+	username, password := store.Issue("alice", time.Hour)
+	if username != "1700003600:alice" {
+		t.Errorf("unexpected username %q", username)
+	}
+	// base64(HMAC-SHA1("shared secret", username))
+	if password != "+7mDo33NUOm4N9aqBNvHVWSJGXY=" {
+		t.Errorf("unexpected password %q", password)
+	}
+	key, ok := store.Lookup(username, realm)
+	if !ok || !bytes.Equal(key, stun.NewLongTermIntegrity(username, realm, password)) {
+		t.Error("unexpected key")
+	}
+	if expired, _ := store.Issue("", -time.Second); expired != "1699999999" {
+		t.Errorf("unexpected username %q", expired)
+	} else if _, ok = store.Lookup(expired, realm); ok {
+		t.Error("expired username should not match")
+	}
+	if _, ok = store.Lookup("alice", realm); ok {
+		t.Error("username without timestamp should not match")
+	}
+
+	// Server authenticates client with issued credentials.
+	srv := New(WithCredentialStore(realm, store))
+	defer srv.Close() //nolint:errcheck
+	addr := serve(t, srv)
+	res, _ := roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest))
+	var nonce stun.Nonce
+	if err := nonce.GetFrom(res); err != nil {
+		t.Fatal(err)
+	}
+	res, _ = roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest,
+		stun.NewUsername(username), stun.NewRealm(realm), nonce, stun.MessageIntegrity(key),
+	))
+	if res.Type != stun.BindingSuccess {
+		t.Fatalf("unexpected type: %s", res.Type)
+	}
+}
+
+func TestServer_Serve(t *testing.T) {
+	srv := New()
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(l)
+	}()
+	conn, err := net.Dial("tcp4", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := stun.NewClient(conn, stun.WithNoRetransmit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = client.Do(stun.MustBuild(stun.TransactionID, stun.BindingRequest), func(e stun.Event) {
+			if e.Error != nil {
+				t.Error(e.Error)
+
+				return
+			}
+			var xorAddr stun.XORMappedAddress
+			if getErr := xorAddr.GetFrom(e.Message); getErr != nil {
+				t.Error(getErr)
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = client.Close(); err != nil {
+		t.Error(err)
+	}
+	if err = srv.Close(); err != nil {
+		t.Error(err)
+	}
+	if err = <-done; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("unexpected serve error: %v", err)
+	}
+}
+
+func TestServer_ServeStream(t *testing.T) {
+	serve := func(t *testing.T, options ...Option) (*Server, net.Conn) {
+		t.Helper()
+		srv := New(options...)
+		l, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() { _ = srv.Serve(l) }()
+		t.Cleanup(func() { _ = srv.Close() })
+		conn, err := net.Dial("tcp4", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		if err = conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+			t.Fatal(err)
+		}
+
+		return srv, conn
+	}
+	t.Run("Coalesced", func(t *testing.T) {
+		_, conn := serve(t)
+		first := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+		second := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+		if _, err := conn.Write(append(append([]byte(nil), first.Raw...), second.Raw...)); err != nil {
+			t.Fatal(err)
+		}
+		dec := stun.NewStreamDecoder(conn, 0)
+		for _, req := range []*stun.Message{first, second} {
+			res := new(stun.Message)
+			if err := dec.Decode(res); err != nil {
+				t.Fatal(err)
+			}
+			if res.TransactionID != req.TransactionID {
+				t.Error("unexpected transaction ID")
+			}
+		}
+	})
+	t.Run("IdleTimeout", func(t *testing.T) {
+		_, conn := serve(t, WithIdleTimeout(time.Millisecond*50))
+		// Partial message does not extend timeout.
+		if _, err := conn.Write(stun.MustBuild(stun.TransactionID, stun.BindingRequest).Raw[:10]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+			t.Errorf("connection should be closed, got %v", err)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		srv, conn := serve(t)
+		if _, err := conn.Write(make([]byte, 20)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+			t.Errorf("connection should be closed, got %v", err)
+		}
+		if stats := srv.Stats(); stats.Malformed != 1 {
+			t.Errorf("unexpected malformed count: %d", stats.Malformed)
+		}
+	})
+}
+
+func TestServer_ServeBehaviorDiscovery(t *testing.T) {
+	srv := New()
+	var conns [4]net.PacketConn
+	for i := range conns {
+		conns[i] = listenUDP(t)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ServeBehaviorDiscovery(conns[0], conns[1], conns[2], conns[3])
+	}()
+	for _, tc := range []struct {
+		name   string
+		change byte
+		from   net.PacketConn
+	}{
+		{"NoChange", 0, conns[0]},
+		{"ChangePort", changePortFlag, conns[1]},
+		{"ChangeIP", changeIPFlag, conns[2]},
+		{"ChangeBoth", changeIPFlag | changePortFlag, conns[3]},
+	} {
+		request := stun.MustBuild(stun.TransactionID, stun.BindingRequest,
+			stun.RawAttribute{Type: stun.AttrChangeRequest, Value: []byte{0, 0, 0, tc.change}},
+		)
+		res, from := roundTrip(t, conns[0].LocalAddr(), request)
+		if from.String() != tc.from.LocalAddr().String() {
+			t.Errorf("%s: response from %s, expected %s", tc.name, from, tc.from.LocalAddr())
+		}
+		var (
+			origin stun.ResponseOrigin
+			other  stun.OtherAddress
+		)
+		if err := res.Parse(&origin, &other); err != nil {
+			t.Fatal(err)
+		}
+		if origin.String() != from.String() {
+			t.Errorf("%s: unexpected RESPONSE-ORIGIN %s", tc.name, origin)
+		}
+		if other.String() != conns[3].LocalAddr().String() {
+			t.Errorf("%s: unexpected OTHER-ADDRESS %s", tc.name, other)
+		}
+	}
+	if err := srv.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := <-done; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("unexpected serve error: %v", err)
+	}
+}
+
+func TestServer_ResponsePort(t *testing.T) {
+	srv := New()
+	defer srv.Close() //nolint:errcheck
+	addr := serve(t, srv)
+	sender, receiver := listenUDP(t), listenUDP(t)
+	defer sender.Close()                             //nolint:errcheck
+	defer receiver.Close()                           //nolint:errcheck
+	port := receiver.LocalAddr().(*net.UDPAddr).Port //nolint:forcetypeassert
+	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.ResponsePort(port))
+	if _, err := sender.WriteTo(request.Raw, addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := receiver.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, _, err := receiver.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := new(stun.Message)
+	if err = stun.Decode(buf[:n], res); err != nil {
+		t.Fatal(err)
+	}
+	if res.TransactionID != request.TransactionID {
+		t.Error("transaction ID mismatch")
+	}
+}
+
+// logBuffer is goroutine-safe log output.
+type logBuffer struct {
+	mux sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return b.buf.String()
+}
+
+func TestServer_Logger(t *testing.T) {
+	out := new(logBuffer)
+	srv := New(
+		WithLogger(logging.NewDefaultLeveledLoggerForScope("test", logging.LogLevelTrace, out)),
+		WithLongTermAuth("pion.ly", func(string, string) ([]byte, bool) {
+			return nil, false
+		}),
+	)
+	defer srv.Close() //nolint:errcheck
+	addr := serve(t, srv)
+	res, _ := roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest))
+	if code := errorCode(t, res); code != stun.CodeUnauthorized {
+		t.Fatalf("unexpected code: %d", code)
+	}
+	for _, s := range []string{"stunserver: Binding request", "authentication of Binding request"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("%q not found in log:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestServer_ServePacketBatch(t *testing.T) {
+	for _, tc := range []struct {
+		size    int
+		offload bool
+	}{
+		{1, false},
+		{4, false},
+		{4, true},
+		{defaultBatchSize, true},
+	} {
+		tc := tc
+		t.Run(fmt.Sprintf("Size%dOffload%t", tc.size, tc.offload), func(t *testing.T) {
+			srv := New(WithBatchSize(tc.size), WithUDPOffload(tc.offload))
+			defer srv.Close() //nolint:errcheck
+			addr := serve(t, srv)
+			conn := listenUDP(t)
+			defer conn.Close() //nolint:errcheck
+			const count = 50
+			pending := make(map[[stun.TransactionIDSize]byte]bool, count)
+			for i := 0; i < count; i++ {
+				request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+				pending[request.TransactionID] = true
+				if _, err := conn.WriteTo(request.Raw, addr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, 1500)
+			for len(pending) > 0 {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					t.Fatalf("%d responses are not received: %v", len(pending), err)
+				}
+				res := new(stun.Message)
+				if err = stun.Decode(buf[:n], res); err != nil {
+					t.Fatal(err)
+				}
+				if res.Type != stun.BindingSuccess || !pending[res.TransactionID] {
+					t.Fatalf("unexpected response: %s", res)
+				}
+				delete(pending, res.TransactionID)
+			}
+		})
+	}
+}
+
+func TestMessageBatch(t *testing.T) {
+	var (
+		a     = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+		b     = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2}
+		batch = newMessageBatch(3, true)
+	)
+	for _, d := range []struct {
+		size int
+		addr net.Addr
+	}{
+		{10, a}, {10, a}, {5, a}, // last segment is shorter
+		{10, a}, // previous segment is not full
+		{10, b}, // other address
+	} {
+		if !batch.add(make([]byte, d.size), d.addr) {
+			t.Fatal("batch is full")
+		}
+	}
+	if batch.add(make([]byte, 20), b) {
+		t.Error("longer segment should not fit")
+	}
+	for i, expected := range []struct {
+		length int
+		addr   net.Addr
+	}{
+		{25, a}, {10, a}, {10, b},
+	} {
+		if m := batch.ms[i]; len(m.Buffers[0]) != expected.length || m.Addr != expected.addr || batch.size[i] != 10 {
+			t.Errorf("unexpected message %d: %d bytes to %s", i, len(m.Buffers[0]), m.Addr)
+		}
+	}
+}
+
+func TestServer_ServePackets(t *testing.T) {
+	conns, err := ListenReusePort("udp4", "127.0.0.1:0", 4)
+	if errors.Is(err, ErrReusePortUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conns[0].LocalAddr()
+	for _, conn := range conns {
+		if conn.LocalAddr().String() != addr.String() {
+			t.Fatalf("unexpected address %s, expected %s", conn.LocalAddr(), addr)
+		}
+	}
+	srv := New()
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ServePackets(conns...)
+	}()
+	for i := 0; i < 10; i++ {
+		request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+		if res, _ := roundTrip(t, addr, request); res.Type != stun.BindingSuccess {
+			t.Fatalf("unexpected type: %s", res.Type)
+		}
+	}
+	if err = srv.Close(); err != nil {
+		t.Error(err)
+	}
+	if err = <-done; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("unexpected serve error: %v", err)
+	}
+	if err = New().ServePackets(); !errors.Is(err, ErrNoConnections) {
+		t.Errorf("unexpected serve error: %v", err)
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := NewReplayCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+	var (
+		a  = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1000}
+		b  = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2000}
+		id = [stun.TransactionIDSize]byte{1}
+	)
+	if !c.Accept(id, a) {
+		t.Fatal("new transaction should be accepted")
+	}
+	if !c.Accept(id, a) {
+		t.Error("retransmission should be accepted")
+	}
+	if c.Accept(id, b) {
+		t.Error("replay should be rejected")
+	}
+	now = now.Add(time.Minute)
+	if !c.Accept(id, b) {
+		t.Error("expired transaction should be accepted")
+	}
+	c.Accept([stun.TransactionIDSize]byte{2}, a)
+	c.Accept([stun.TransactionIDSize]byte{3}, a)
+	if c.Len() != 2 {
+		t.Errorf("unexpected length: %d", c.Len())
+	}
+	if !c.Accept(id, a) {
+		t.Error("evicted transaction should be accepted")
+	}
+}
+
+func TestServer_ReplayCache(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	const (
+		username = "user"
+		realm    = "pion.ly"
+	)
+	key := stun.NewLongTermIntegrity(username, realm, "secret")
+	nonces, err := stun.NewNonceManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(WithNonceManager(nonces), WithReplayCache(NewReplayCache(16, time.Minute)),
+		WithLongTermAuth(realm, func(string, string) ([]byte, bool) {
+			return key, true
+		}),
+	)
+	defer srv.Close() //nolint:errcheck
+	addr := serve(t, srv)
+	newRequest := func() *stun.Message {
+		return stun.MustBuild(stun.TransactionID, stun.BindingRequest,
+			stun.NewUsername(username), stun.NewRealm(realm), nonces, key,
+		)
+	}
+	request := newRequest()
+	if res, _ := roundTrip(t, addr, request); res.Type != stun.BindingSuccess {
+		t.Fatalf("unexpected type: %s", res.Type)
+	}
+	// Replay from other address is dropped. Requests are served in order,
+	// so it is processed before the next one is answered.
+	conn := listenUDP(t)
+	defer conn.Close() //nolint:errcheck
+	if _, err = conn.WriteTo(request.Raw, addr); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := roundTrip(t, addr, newRequest()); res.Type != stun.BindingSuccess {
+		t.Fatalf("unexpected type: %s", res.Type)
+	}
+	if dropped := srv.Stats().Dropped; dropped != 1 {
+		t.Errorf("unexpected dropped count: %d", dropped)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewRateLimiter(2, 3, 2)
+	l.now = func() time.Time { return now }
+	var (
+		a = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1000}
+		b = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2000}
+		c = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 1000}
+		d = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 3), Port: 1000}
+	)
+	for i := 0; i < 3; i++ {
+		if !l.Allow(a) {
+			t.Fatalf("request %d should be allowed by burst", i)
+		}
+	}
+	if l.Allow(b) {
+		t.Error("request from the same IP should be limited")
+	}
+	now = now.Add(time.Millisecond * 500)
+	if !l.Allow(a) || l.Allow(a) {
+		t.Error("single token should be refilled")
+	}
+	if !l.Allow(c) {
+		t.Error("request from other IP should be allowed")
+	}
+	// Address a is the least recently seen one.
+	if !l.Allow(d) || l.Len() != 2 {
+		t.Errorf("unexpected tracked count: %d", l.Len())
+	}
+	if !l.Allow(a) {
+		t.Error("evicted address should have full burst")
+	}
+}
+
+func TestServer_RateLimiter(t *testing.T) {
+	srv := New(WithRateLimiter(NewRateLimiter(0, 1, 16)))
+	defer srv.Close() //nolint:errcheck
+	addr := serve(t, srv)
+	if res, _ := roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest)); res.Type != stun.BindingSuccess {
+		t.Fatalf("unexpected type: %s", res.Type)
+	}
+	conn := listenUDP(t)
+	defer conn.Close() //nolint:errcheck
+	if _, err := conn.WriteTo(stun.MustBuild(stun.TransactionID, stun.BindingRequest).Raw, addr); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second * 5); srv.Stats().Limited == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("request should be limited")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestServer_Handler(t *testing.T) {
+	var (
+		mux   sync.Mutex
+		order []string
+	)
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(w ResponseWriter, r *Request) {
+				mux.Lock()
+				order = append(order, name)
+				mux.Unlock()
+				next.ServeSTUN(w, r)
+			})
+		}
+	}
+	out := new(logBuffer)
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Message.Type.Method != stun.MethodAllocate {
+			BindingHandler().ServeSTUN(w, r)
+
+			return
+		}
+		if err := WriteError(w, r, stun.CodeAllocQuotaReached); err != nil {
+			t.Error(err)
+		}
+		if err := WriteError(w, r, stun.CodeServerError); !errors.Is(err, ErrResponseWritten) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	srv := New(
+		WithSoftware("stunserver test"),
+		WithHandler(handler),
+		WithMiddleware(
+			trace("first"),
+			Logging(logging.NewDefaultLeveledLoggerForScope("test", logging.LogLevelDebug, out)),
+			RequireFingerprint(),
+			trace("second"),
+		),
+	)
+	defer srv.Close() //nolint:errcheck
+	addr := serve(t, srv)
+
+	request := stun.MustBuild(stun.TransactionID, stun.NewType(stun.MethodAllocate, stun.ClassRequest), stun.Fingerprint)
+	res, _ := roundTrip(t, addr, request)
+	if code := errorCode(t, res); code != stun.CodeAllocQuotaReached {
+		t.Fatalf("unexpected code: %d", code)
+	}
+	var software stun.Software
+	if err := software.GetFrom(res); err != nil {
+		t.Error(err)
+	}
+	res, _ = roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.Fingerprint))
+	if res.Type != stun.BindingSuccess {
+		t.Fatalf("unexpected type: %s", res.Type)
+	}
+	// Message without fingerprint is dropped before the second middleware.
+	conn := listenUDP(t)
+	defer conn.Close() //nolint:errcheck
+	if _, err := conn.WriteTo(stun.MustBuild(stun.TransactionID, stun.BindingRequest).Raw, addr); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ = roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.Fingerprint)); res.Type != stun.BindingSuccess {
+		t.Fatalf("unexpected type: %s", res.Type)
+	}
+	mux.Lock()
+	got := strings.Join(order, " ")
+	mux.Unlock()
+	if expected := "first second first second first first second"; got != expected {
+		t.Errorf("unexpected order: %s", got)
+	}
+	for _, s := range []string{"Allocate request from 127.0.0.1", "Allocate error response", "no response"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("%q not found in log:\n%s", s, out.String())
+		}
+	}
+	if stats := srv.Stats(); stats.Dropped != 1 || stats.Errors != 1 || stats.Success != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestServer_Software(t *testing.T) {
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		res := stun.MustBuild(r.Message, stun.BindingSuccess, stun.NewSoftware("handler"))
+		if err := w.WriteMessage(res); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, tc := range []struct {
+		name, software, expected string
+	}{
+		{"Replace", "server", "server"},
+		{"Strip", "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := New(WithSoftware(tc.software), WithHandler(handler))
+			defer srv.Close() //nolint:errcheck
+			res, _ := roundTrip(t, serve(t, srv), stun.MustBuild(stun.TransactionID, stun.BindingRequest))
+			var softwares []string
+			for _, a := range res.Attributes {
+				if a.Type == stun.AttrSoftware {
+					softwares = append(softwares, string(a.Value))
+				}
+			}
+			if got := strings.Join(softwares, ","); got != tc.expected {
+				t.Errorf("unexpected software: %q", got)
+			}
+			if err := stun.Fingerprint.Check(res); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestServer_HandlePacketAllocations(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close() //nolint:errcheck
+	srv := New(WithSoftware("stunserver test"))
+	defer srv.Close() //nolint:errcheck
+	var (
+		h    = srv.newPacketHandler(conn, nil)
+		data = stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.Fingerprint).Raw
+		addr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}
+	)
+	// Warming up buffers.
+	if _, _, ok := srv.handlePacket(h, data, addr); !ok {
+		t.Fatal("no response")
+	}
+	testutil.ShouldNotAllocate(t, func() {
+		srv.handlePacket(h, data, addr)
+	})
+	var xorAddr stun.XORMappedAddress
+	if err := xorAddr.GetFrom(h.res); err != nil {
+		t.Fatal(err)
+	}
+	if err := stun.Fingerprint.Check(h.res); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkServer_HandlePacket(b *testing.B) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close() //nolint:errcheck
+	srv := New(WithSoftware("stunserver benchmark"))
+	defer srv.Close() //nolint:errcheck
+	var (
+		h    = srv.newPacketHandler(conn, nil)
+		data = stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.Fingerprint).Raw
+		addr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}
+	)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		srv.handlePacket(h, data, addr)
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	newServer := func(release chan struct{}) (*Server, net.Addr, chan error) {
+		srv := New(WithHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+			entered <- struct{}{}
+			<-release
+			BindingHandler().ServeSTUN(w, r)
+		})))
+		conn := listenUDP(t)
+		l, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error, 2)
+		go func() { done <- srv.ServePacket(conn) }()
+		go func() { done <- srv.Serve(l) }()
+
+		return srv, conn.LocalAddr(), done
+	}
+
+	t.Run("Drain", func(t *testing.T) {
+		release := make(chan struct{})
+		srv, addr, done := newServer(release)
+		responses := make(chan *stun.Message, 1)
+		go func() {
+			res, _ := roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest))
+			responses <- res
+		}()
+		<-entered
+		shutdown := make(chan error, 1)
+		go func() {
+			shutdown <- srv.Shutdown(context.Background())
+		}()
+		for !srv.isClosed() {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		if res := <-responses; res.Type != stun.BindingSuccess {
+			t.Errorf("unexpected type: %s", res.Type)
+		}
+		if err := <-shutdown; err != nil {
+			t.Error(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := <-done; !errors.Is(err, ErrServerClosed) {
+				t.Errorf("unexpected serve error: %v", err)
+			}
+		}
+		if err := srv.Shutdown(context.Background()); !errors.Is(err, ErrServerClosed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Deadline", func(t *testing.T) {
+		release := make(chan struct{})
+		srv, addr, done := newServer(release)
+		conn := listenUDP(t)
+		defer conn.Close() //nolint:errcheck
+		if _, err := conn.WriteTo(stun.MustBuild(stun.TransactionID, stun.BindingRequest).Raw, addr); err != nil {
+			t.Fatal(err)
+		}
+		<-entered
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		shutdown := make(chan error, 1)
+		go func() {
+			shutdown <- srv.Shutdown(ctx)
+		}()
+		// Handler is still blocked when deadline is exceeded.
+		if err := <-shutdown; !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		close(release)
+		for i := 0; i < 2; i++ {
+			if err := <-done; !errors.Is(err, ErrServerClosed) {
+				t.Errorf("unexpected serve error: %v", err)
+			}
+		}
+		if err := srv.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestServer_DecodeMode(t *testing.T) {
+	legacy := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	copy(legacy.Raw[4:8], []byte{0xDE, 0xAD, 0xBE, 0xEF}) // no magic cookie
+	t.Run("Default", func(t *testing.T) {
+		srv := New()
+		defer srv.Close() //nolint:errcheck
+		addr := serve(t, srv)
+		conn := listenUDP(t)
+		defer conn.Close() //nolint:errcheck
+		if _, err := conn.WriteTo(legacy.Raw, addr); err != nil {
+			t.Fatal(err)
+		}
+		// Requests are served in order, so legacy one is dropped before
+		// response to the second one is sent.
+		roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest))
+		if malformed := srv.Stats().Malformed; malformed != 1 {
+			t.Errorf("unexpected malformed count: %d", malformed)
+		}
+	})
+	t.Run("Legacy", func(t *testing.T) {
+		srv := New(WithDecodeMode(stun.DecodeLegacy))
+		defer srv.Close() //nolint:errcheck
+		addr := serve(t, srv)
+		conn := listenUDP(t)
+		defer conn.Close() //nolint:errcheck
+		if _, err := conn.WriteTo(legacy.Raw, addr); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 1500)
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := &stun.Message{Raw: buf[:n]}
+		if err = res.DecodeRFC3489(); err != nil {
+			t.Fatal(err)
+		}
+		if !stun.IsRFC3489(res) || !bytes.Equal(res.Raw[4:20], legacy.Raw[4:20]) {
+			t.Errorf("transaction ID is not echoed: %x", res.Raw[4:20])
+		}
+		var mapped stun.MappedAddress
+		if err = mapped.GetFrom(res); err != nil {
+			t.Error(err)
+		} else if local := conn.LocalAddr().(*net.UDPAddr); mapped.Port != local.Port { //nolint:forcetypeassert
+			t.Errorf("unexpected address %s", mapped)
+		}
+		if res.Contains(stun.AttrXORMappedAddress) || res.Contains(stun.AttrFingerprint) {
+			t.Error("response should not have attributes of RFC 5389")
+		}
+		// Clients of RFC 5389 are still served as usual.
+		roundTrip(t, addr, stun.MustBuild(stun.TransactionID, stun.BindingRequest))
+	})
+}
+
+type recordingMetrics struct {
+	mux       sync.Mutex
+	messages  []stun.MessageType
+	sizes     []int
+	responses []stun.ErrorCode
+	malformed int
+}
+
+func (m *recordingMetrics) ObserveMessage(t stun.MessageType, size int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.messages = append(m.messages, t)
+	m.sizes = append(m.sizes, size)
+}
+
+func (m *recordingMetrics) ObserveResponse(_ stun.MessageType, code stun.ErrorCode) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.responses = append(m.responses, code)
+}
+
+func (m *recordingMetrics) IncMalformed() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.malformed++
+}
+
+func TestServer_Metrics(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close() //nolint:errcheck
+	metrics := &recordingMetrics{}
+	srv := New(WithMetrics(metrics))
+	defer srv.Close() //nolint:errcheck
+	var (
+		h        = srv.newPacketHandler(conn, nil)
+		addr     = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}
+		binding  = stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+		allocate = stun.MustBuild(stun.TransactionID, stun.NewType(stun.MethodAllocate, stun.ClassRequest))
+	)
+	srv.handlePacket(h, binding.Raw, addr)
+	srv.handlePacket(h, allocate.Raw, addr)
+	srv.handlePacket(h, []byte("not a STUN message"), addr)
+	if len(metrics.messages) != 2 || metrics.messages[0] != stun.BindingRequest ||
+		metrics.messages[1].Method != stun.MethodAllocate || metrics.sizes[0] != len(binding.Raw) {
+		t.Errorf("unexpected messages: %v %v", metrics.messages, metrics.sizes)
+	}
+	if len(metrics.responses) != 2 || metrics.responses[0] != 0 || metrics.responses[1] != stun.CodeBadRequest {
+		t.Errorf("unexpected responses: %v", metrics.responses)
+	}
+	if metrics.malformed != 1 {
+		t.Errorf("unexpected malformed count: %d", metrics.malformed)
+	}
+}
+
+func TestServer_DSCP(t *testing.T) {
+	srv := New(WithDSCP(stun.DSCPExpeditedForwarding))
+	defer srv.Close() //nolint:errcheck
+	conn := listenUDP(t)
+	go func() {
+		if err := srv.ServePacket(conn); !errors.Is(err, ErrServerClosed) {
+			t.Error(err)
+		}
+	}()
+	roundTrip(t, conn.LocalAddr(), stun.MustBuild(stun.TransactionID, stun.BindingRequest))
+	if tos, err := ipv4.NewPacketConn(conn).TOS(); err != nil || tos != stun.DSCPExpeditedForwarding<<2 {
+		t.Errorf("unexpected TOS: %d, %v", tos, err)
+	}
+
+	invalid := New(WithDSCP(64))
+	defer invalid.Close() //nolint:errcheck
+	if err := invalid.ServePacket(listenUDP(t)); !errors.Is(err, stun.ErrInvalidDSCP) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestServer_FlowLabel(t *testing.T) {
+	invalid := New(WithFlowLabel(1 << 20))
+	defer invalid.Close() //nolint:errcheck
+	if err := invalid.ServePacket(listenUDP(t)); !errors.Is(err, stun.ErrInvalidFlowLabel) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	srv := New(WithFlowLabel(0x12345))
+	defer srv.Close() //nolint:errcheck
+	if err := srv.ServePacket(listenUDP(t)); !errors.Is(err, stun.ErrFlowLabelNotSupported) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if runtime.GOOS != "linux" {
+		return
+	}
+	conn, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not available:", err)
+	}
+	go func() {
+		if err := srv.ServePacket(conn); !errors.Is(err, ErrServerClosed) {
+			t.Error(err)
+		}
+	}()
+	client, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close() //nolint:errcheck
+	request := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	if _, err = client.WriteTo(request.Raw, conn.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, _, err := client.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := new(stun.Message)
+	if err = stun.Decode(buf[:n], res); err != nil {
+		t.Fatal(err)
+	}
+	if res.TransactionID != request.TransactionID {
+		t.Error("unexpected transaction ID")
+	}
+}
+
+// disableLegacyHashes disables legacy hashes until t and its subtests
+// complete, restoring previous setting.
+func disableLegacyHashes(t *testing.T) {
+	t.Helper()
+	prev := stun.LegacyHashesEnabled()
+	stun.SetLegacyHashes(false)
+	t.Cleanup(func() { stun.SetLegacyHashes(prev) })
+}
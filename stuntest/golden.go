@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/pion/stun/v3"
+)
+
+// LoadGolden reads and decodes message from golden file at path, which
+// contains raw message as is, like files saved by SaveGolden.
+func LoadGolden(path string) (*stun.Message, error) {
+	raw, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	m := new(stun.Message)
+	if err := stun.Decode(raw, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// SaveGolden writes raw message m to golden file at path.
+func SaveGolden(path string, m *stun.Message) error {
+	return os.WriteFile(path, m.Raw, 0o644) //nolint:gosec
+}
+
+// AssertGolden reports test error if raw message m is not equal to one
+// in golden file at path, byte by byte.
+func AssertGolden(t testing.TB, path string, m *stun.Message) {
+	t.Helper()
+	golden, err := LoadGolden(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(golden.Raw, m.Raw) {
+		return
+	}
+	offset := 0
+	for offset < len(golden.Raw) && offset < len(m.Raw) && golden.Raw[offset] == m.Raw[offset] {
+		offset++
+	}
+	t.Errorf("%s: message differs at byte %d:\n%s (got)\n%s (golden)", path, offset, m, golden)
+}
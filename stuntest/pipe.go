@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// PipeOption configures pipe created by NewPipe.
+type PipeOption func(c *pipeConfig)
+
+type pipeConfig struct {
+	latency time.Duration
+	drop    float64
+	reorder float64
+	seed    int64
+}
+
+// WithLatency delays delivery of each packet by d.
+func WithLatency(d time.Duration) PipeOption {
+	return func(c *pipeConfig) {
+		c.latency = d
+	}
+}
+
+// WithDropRate drops packets with probability rate, from 0 to 1.
+func WithDropRate(rate float64) PipeOption {
+	return func(c *pipeConfig) {
+		c.drop = rate
+	}
+}
+
+// WithReorderRate holds packets with probability rate, from 0 to 1, and
+// delivers held packet after the next one in the same direction.
+func WithReorderRate(rate float64) PipeOption {
+	return func(c *pipeConfig) {
+		c.reorder = rate
+	}
+}
+
+// WithSeed sets seed of pseudo-random drops and reorders, 1 by default,
+// so runs with the same writes are reproducible.
+func WithSeed(seed int64) PipeOption {
+	return func(c *pipeConfig) {
+		c.seed = seed
+	}
+}
+
+// PipeAddr is address of PipeConn.
+type PipeAddr string
+
+// Network returns "pipe".
+func (PipeAddr) Network() string { return "pipe" }
+
+func (a PipeAddr) String() string { return string(a) }
+
+// PipeConn is end of in-memory datagram pipe, see NewPipe. Each Write
+// is read by peer as single packet by one Read, like with UDP socket.
+type PipeConn struct {
+	local, remote PipeAddr
+	peer          *PipeConn
+
+	mux     sync.Mutex // guards fields below
+	cond    *sync.Cond
+	inbox   [][]byte
+	closed  bool
+	held    []byte // packet written by this end that is reordered
+	rand    *rand.Rand
+	cfg     pipeConfig
+	dropped int
+}
+
+// NewPipe returns two linked ends of in-memory datagram pipe that can be
+// passed to stun.NewClient, emulating network with latency, drops and
+// reorders set by options. Peer closing does not affect other end.
+func NewPipe(opts ...PipeOption) (*PipeConn, *PipeConn) {
+	cfg := pipeConfig{seed: 1}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	a := newPipeConn("pipe-a", "pipe-b", cfg, cfg.seed)
+	b := newPipeConn("pipe-b", "pipe-a", cfg, cfg.seed+1)
+	a.peer, b.peer = b, a
+
+	return a, b
+}
+
+func newPipeConn(local, remote PipeAddr, cfg pipeConfig, seed int64) *PipeConn {
+	c := &PipeConn{
+		local:  local,
+		remote: remote,
+		cfg:    cfg,
+		rand:   rand.New(rand.NewSource(seed)), //nolint:gosec
+	}
+	c.cond = sync.NewCond(&c.mux)
+
+	return c
+}
+
+// Read reads single packet written by peer to b, blocking until packet is
+// available or c is closed. Packet that does not fit b is truncated.
+func (c *PipeConn) Read(b []byte) (int, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for len(c.inbox) == 0 && !c.closed {
+		c.cond.Wait()
+	}
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	p := c.inbox[0]
+	c.inbox[0] = nil
+	c.inbox = c.inbox[1:]
+
+	return copy(b, p), nil
+}
+
+// Write sends copy of b to peer as single packet. Packets to closed peer
+// are discarded without error.
+func (c *PipeConn) Write(b []byte) (int, error) {
+	p := append([]byte(nil), b...)
+	c.mux.Lock()
+	if c.closed {
+		c.mux.Unlock()
+
+		return 0, net.ErrClosed
+	}
+	var packets [][]byte
+	switch {
+	case c.cfg.drop > 0 && c.rand.Float64() < c.cfg.drop:
+		c.dropped++
+	case c.held == nil && c.cfg.reorder > 0 && c.rand.Float64() < c.cfg.reorder:
+		c.held = p
+	default:
+		packets = append(packets, p)
+		if c.held != nil {
+			packets = append(packets, c.held)
+			c.held = nil
+		}
+	}
+	c.mux.Unlock()
+	if len(packets) == 0 {
+		return len(b), nil
+	}
+	if c.cfg.latency > 0 {
+		time.AfterFunc(c.cfg.latency, func() { c.peer.deliver(packets) })
+	} else {
+		c.peer.deliver(packets)
+	}
+
+	return len(b), nil
+}
+
+func (c *PipeConn) deliver(packets [][]byte) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return
+	}
+	c.inbox = append(c.inbox, packets...)
+	c.cond.Broadcast()
+}
+
+// Dropped returns count of packets written by c that are dropped.
+func (c *PipeConn) Dropped() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.dropped
+}
+
+// Close closes c, unblocking Read. Subsequent calls return net.ErrClosed.
+func (c *PipeConn) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.closed = true
+	c.inbox = nil
+	c.cond.Broadcast()
+
+	return nil
+}
+
+// LocalAddr returns address of c.
+func (c *PipeConn) LocalAddr() net.Addr { return c.local }
+
+// RemoteAddr returns address of peer.
+func (c *PipeConn) RemoteAddr() net.Addr { return c.remote }
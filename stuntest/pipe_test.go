@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// servePipe responds to Binding requests read from conn until it is
+// closed.
+func servePipe(conn *PipeConn) {
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		req := new(stun.Message)
+		if stun.Decode(buf[:n], req) != nil {
+			continue
+		}
+		res := stun.MustBuild(req, stun.BindingSuccess,
+			&stun.XORMappedAddress{IP: net.IPv4(192, 0, 2, 1), Port: 3478},
+		)
+		_, _ = conn.Write(res.Raw)
+	}
+}
+
+func TestPipe_Client(t *testing.T) {
+	clientConn, serverConn := NewPipe(WithLatency(5 * time.Millisecond))
+	defer serverConn.Close() //nolint:errcheck
+	go servePipe(serverConn)
+	client, err := stun.NewClient(clientConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close() //nolint:errcheck
+	if err = client.Do(stun.MustBuild(stun.TransactionID, stun.BindingRequest), func(e stun.Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+
+			return
+		}
+		if e.RTT < 10*time.Millisecond {
+			t.Errorf("unexpected RTT %s", e.RTT)
+		}
+		if e.Remote.String() != "pipe-b" {
+			t.Errorf("unexpected remote %s", e.Remote)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPipe_Drop(t *testing.T) {
+	clientConn, serverConn := NewPipe(WithDropRate(1))
+	defer serverConn.Close() //nolint:errcheck
+	go servePipe(serverConn)
+	client, err := stun.NewClient(clientConn, stun.WithRTO(time.Millisecond), stun.WithTimeoutRate(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close() //nolint:errcheck
+	if err = client.Do(stun.MustBuild(stun.TransactionID, stun.BindingRequest), func(e stun.Event) {
+		if !errors.Is(e.Error, stun.ErrTransactionTimeOut) {
+			t.Errorf("unexpected error: %v", e.Error)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if clientConn.Dropped() == 0 {
+		t.Error("requests should be dropped")
+	}
+}
+
+func TestPipe_Reorder(t *testing.T) {
+	a, b := NewPipe(WithReorderRate(1))
+	defer a.Close() //nolint:errcheck
+	defer b.Close() //nolint:errcheck
+	for _, p := range []string{"1", "2", "3", "4"} {
+		if _, err := a.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf := make([]byte, 10)
+	var got string
+	for i := 0; i < 4; i++ {
+		n, err := b.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got += string(buf[:n])
+	}
+	if got != "2143" {
+		t.Errorf("unexpected order %q", got)
+	}
+}
+
+func TestPipe_Close(t *testing.T) {
+	a, b := NewPipe()
+	read := make(chan error)
+	go func() {
+		_, err := a.Read(make([]byte, 10))
+		read <- err
+	}()
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-read; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := a.Close(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := a.Write([]byte{1}); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// Writing to closed peer is not an error, like with UDP.
+	if _, err := b.Write([]byte{1}); err != nil {
+		t.Error(err)
+	}
+	if a.LocalAddr().String() != "pipe-a" || a.RemoteAddr().Network() != "pipe" {
+		t.Error("unexpected addresses")
+	}
+}
@@ -0,0 +1,259 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// Direction of recorded packet.
+type Direction string
+
+// Directions of recorded packets.
+const (
+	Sent     Direction = "sent"
+	Received Direction = "received"
+)
+
+// Record is packet sent or received by recorded connection. Records are
+// stored as JSON lines, with Raw encoded as base64.
+type Record struct {
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"dir"`
+	Raw       []byte    `json:"raw"`
+}
+
+// Recorder is connection wrapper that writes each packet sent or
+// received by connection as Record to writer, so captured failures can
+// be replayed by Replayer.
+type Recorder struct {
+	stun.Connection
+
+	mux sync.Mutex // guards enc and err
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder returns Recorder of conn that writes records to w. It can be
+// passed to stun.NewClient instead of conn.
+func NewRecorder(conn stun.Connection, w io.Writer) *Recorder {
+	return &Recorder{Connection: conn, enc: json.NewEncoder(w)}
+}
+
+func (r *Recorder) record(d Direction, p []byte) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(Record{Time: time.Now(), Direction: d, Raw: p})
+}
+
+// Read reads from connection, recording packet.
+func (r *Recorder) Read(b []byte) (int, error) {
+	n, err := r.Connection.Read(b)
+	if n > 0 {
+		r.record(Received, b[:n])
+	}
+
+	return n, err
+}
+
+// Write writes to connection, recording packet.
+func (r *Recorder) Write(b []byte) (int, error) {
+	n, err := r.Connection.Write(b)
+	if err == nil {
+		r.record(Sent, b)
+	}
+
+	return n, err
+}
+
+// Err returns first error of writing records. Recording stops on error,
+// while connection keeps working.
+func (r *Recorder) Err() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.err
+}
+
+// ReadRecords reads all records written by Recorder from r.
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+	dec := json.NewDecoder(r)
+	for {
+		var rec Record
+		if err := dec.Decode(&rec); errors.Is(err, io.EOF) {
+			return records, nil
+		} else if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// Replayer is connection that replays records to stun.Client: each
+// received packet is returned by Read after all packets sent before it
+// are written by client. Transaction IDs of received messages are
+// rewritten to ones of written messages, pairing n-th write with n-th
+// sent record, and FINGERPRINT is computed again. MESSAGE-INTEGRITY is
+// not, so it is invalid if transaction IDs differ; use
+// stun.WithTransactionIDSource and stun.TransactionIDFrom to reproduce
+// original transaction IDs.
+type Replayer struct {
+	mux      sync.Mutex
+	cond     *sync.Cond
+	records  []Record
+	next     int // index of next record
+	sentSeen int // sent records before next
+	written  [][]byte
+	ids      map[[stun.TransactionIDSize]byte][stun.TransactionIDSize]byte
+	closed   bool
+}
+
+// NewReplayer returns Replayer of records.
+func NewReplayer(records []Record) *Replayer {
+	r := &Replayer{
+		records: records,
+		ids:     make(map[[stun.TransactionIDSize]byte][stun.TransactionIDSize]byte),
+	}
+	r.cond = sync.NewCond(&r.mux)
+
+	return r
+}
+
+// Read returns next received record, blocking until client writes
+// packets that precede it, or until Replayer is closed if there are no
+// records left.
+func (r *Replayer) Read(b []byte) (int, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for {
+		if r.closed {
+			return 0, net.ErrClosed
+		}
+		if r.next == len(r.records) {
+			r.cond.Wait()
+
+			continue
+		}
+		rec := r.records[r.next]
+		if rec.Direction != Received {
+			if r.sentSeen < len(r.written) {
+				r.sentSeen++
+				r.next++
+			} else {
+				r.cond.Wait()
+			}
+
+			continue
+		}
+		r.next++
+
+		return copy(b, r.rewrite(rec.Raw)), nil
+	}
+}
+
+// rewrite replaces transaction ID of recorded message with written one.
+func (r *Replayer) rewrite(raw []byte) []byte {
+	m := new(stun.Message)
+	if err := stun.Decode(raw, m); err != nil {
+		return raw
+	}
+	id, ok := r.ids[m.TransactionID]
+	if !ok || id == m.TransactionID {
+		return raw
+	}
+	m.TransactionID = id
+	m.WriteTransactionID()
+	if m.Contains(stun.AttrFingerprint) {
+		_ = m.Delete(stun.AttrFingerprint)
+		_ = stun.Fingerprint.AddTo(m)
+	}
+
+	return m.Raw
+}
+
+// Write records b as written by client.
+func (r *Replayer) Write(b []byte) (int, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.closed {
+		return 0, net.ErrClosed
+	}
+	if sent := r.sent(len(r.written)); sent != nil {
+		recorded, written := new(stun.Message), new(stun.Message)
+		if stun.Decode(sent.Raw, recorded) == nil && stun.Decode(b, written) == nil {
+			r.ids[recorded.TransactionID] = written.TransactionID
+		}
+	}
+	r.written = append(r.written, append([]byte(nil), b...))
+	r.cond.Broadcast()
+
+	return len(b), nil
+}
+
+// sent returns n-th sent record or nil.
+func (r *Replayer) sent(n int) *Record {
+	for i := range r.records {
+		if r.records[i].Direction != Sent {
+			continue
+		}
+		if n == 0 {
+			return &r.records[i]
+		}
+		n--
+	}
+
+	return nil
+}
+
+// Written returns packets written by client.
+func (r *Replayer) Written() [][]byte {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return append([][]byte(nil), r.written...)
+}
+
+// Close closes Replayer, unblocking Read.
+func (r *Replayer) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.closed {
+		return net.ErrClosed
+	}
+	r.closed = true
+	r.cond.Broadcast()
+
+	return nil
+}
+
+// Replay passes received messages of records to agent in order, with
+// receive time of record, e.g. to reproduce agent handler failures.
+// Packets that are not STUN messages are skipped.
+func Replay(agent *stun.Agent, records []Record) error {
+	for _, rec := range records {
+		if rec.Direction != Received {
+			continue
+		}
+		m := new(stun.Message)
+		if stun.Decode(rec.Raw, m) != nil {
+			continue
+		}
+		if err := agent.ProcessFrom(m, nil, nil, rec.Time); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
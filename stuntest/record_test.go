@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+func doBinding(t *testing.T, client *stun.Client) {
+	t.Helper()
+	if err := client.Do(stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.Fingerprint), func(e stun.Event) {
+		if e.Error != nil {
+			t.Error(e.Error)
+
+			return
+		}
+		if err := stun.Fingerprint.Check(e.Message); err != nil {
+			t.Error(err)
+		}
+		var addr stun.XORMappedAddress
+		if err := addr.GetFrom(e.Message); err != nil || addr.Port != 3478 {
+			t.Errorf("unexpected address %s: %v", addr, err)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	clientConn, serverConn := NewPipe()
+	defer serverConn.Close() //nolint:errcheck
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, err := serverConn.Read(buf)
+			if err != nil {
+				return
+			}
+			req := new(stun.Message)
+			if stun.Decode(buf[:n], req) != nil {
+				continue
+			}
+			res := stun.MustBuild(req, stun.BindingSuccess,
+				&stun.XORMappedAddress{IP: net.IPv4(192, 0, 2, 1), Port: 3478}, stun.Fingerprint,
+			)
+			_, _ = serverConn.Write(res.Raw)
+		}
+	}()
+	var log bytes.Buffer
+	recorder := NewRecorder(clientConn, &log)
+	client, err := stun.NewClient(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doBinding(t, client)
+	doBinding(t, client)
+	if err = client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadRecords(&log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[0].Direction != Sent || records[1].Direction != Received {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	t.Run("Client", func(t *testing.T) {
+		replayer := NewReplayer(records)
+		client, err := stun.NewClient(replayer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doBinding(t, client)
+		doBinding(t, client)
+		if err = client.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if len(replayer.Written()) != 2 {
+			t.Errorf("unexpected writes: %d", len(replayer.Written()))
+		}
+		if _, err = replayer.Write(nil); !errors.Is(err, net.ErrClosed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("Agent", func(t *testing.T) {
+		var received []time.Time
+		agent := stun.NewAgent(func(e stun.Event) {
+			received = append(received, e.Timestamp)
+		})
+		if err := Replay(agent, records); err != nil {
+			t.Fatal(err)
+		}
+		if len(received) != 2 || !received[0].Equal(records[1].Time) {
+			t.Errorf("unexpected events: %v", received)
+		}
+		if err := agent.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := Replay(agent, records); !errors.Is(err, stun.ErrAgentClosed) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestReadRecords(t *testing.T) {
+	if _, err := ReadRecords(bytes.NewBufferString(`{"time":"2023-01-01T00:00:00Z","dir":"sent","raw":"AAE="}` + "\n{")); err == nil {
+		t.Error("truncated log should fail")
+	}
+}
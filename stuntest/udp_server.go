@@ -1,7 +1,8 @@
 // SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
 // SPDX-License-Identifier: MIT
 
-// Package stuntest contains helpers for testing STUN clients
+// Package stuntest contains helpers for testing STUN clients, RFC 5769
+// test vectors and golden message files.
 package stuntest
 
 import (
@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pion/stun/v3"
+)
+
+// Test vectors of RFC 5769 as raw messages.
+//
+//nolint:lll
+const (
+	// RFC5769Request is sample request of RFC 5769 Section 2.1.
+	RFC5769Request = "\x00\x01\x00\x58\x21\x12\xa4\x42\xb7\xe7\xa7\x01\xbc\x34\xd6\x86\xfa\x87\xdf\xae" +
+		"\x80\x22\x00\x10STUN test client" +
+		"\x00\x24\x00\x04\x6e\x00\x01\xff" +
+		"\x80\x29\x00\x08\x93\x2f\xf9\xb1\x51\x26\x3b\x36" +
+		"\x00\x06\x00\x09\x65\x76\x74\x6a\x3a\x68\x36\x76\x59\x20\x20\x20" +
+		"\x00\x08\x00\x14\x9a\xea\xa7\x0c\xbf\xd8\xcb\x56\x78\x1e\xf2\xb5\xb2\xd3\xf2\x49\xc1\xb5\x71\xa2" +
+		"\x80\x28\x00\x04\xe5\x7a\x3b\xcf"
+	// RFC5769ResponseIPv4 is sample IPv4 response of RFC 5769 Section 2.2.
+	RFC5769ResponseIPv4 = "\x01\x01\x00\x3c\x21\x12\xa4\x42\xb7\xe7\xa7\x01\xbc\x34\xd6\x86\xfa\x87\xdf\xae" +
+		"\x80\x22\x00\x0b\x74\x65\x73\x74\x20\x76\x65\x63\x74\x6f\x72\x20" +
+		"\x00\x20\x00\x08\x00\x01\xa1\x47\xe1\x12\xa6\x43" +
+		"\x00\x08\x00\x14\x2b\x91\xf5\x99\xfd\x9e\x90\xc3\x8c\x74\x89\xf9\x2a\xf9\xba\x53\xf0\x6b\xe7\xd7" +
+		"\x80\x28\x00\x04\xc0\x7d\x4c\x96"
+	// RFC5769ResponseIPv6 is sample IPv6 response of RFC 5769 Section 2.3.
+	RFC5769ResponseIPv6 = "\x01\x01\x00\x48\x21\x12\xa4\x42\xb7\xe7\xa7\x01\xbc\x34\xd6\x86\xfa\x87\xdf\xae" +
+		"\x80\x22\x00\x0b\x74\x65\x73\x74\x20\x76\x65\x63\x74\x6f\x72\x20" +
+		"\x00\x20\x00\x14\x00\x02\xa1\x47\x01\x13\xa9\xfa\xa5\xd3\xf1\x79\xbc\x25\xf4\xb5\xbe\xd2\xb9\xd9" +
+		"\x00\x08\x00\x14\xa3\x82\x95\x4e\x4b\xe6\x7b\xf1\x17\x84\xc9\x7c\x82\x92\xc2\x75\xbf\xe3\xed\x41" +
+		"\x80\x28\x00\x04\xc8\xfb\x0b\x4c"
+	// RFC5769RequestLongTerm is sample request with long-term authentication
+	// of RFC 5769 Section 2.4.
+	RFC5769RequestLongTerm = "\x00\x01\x00\x60\x21\x12\xa4\x42\x78\xad\x34\x33\xc6\xad\x72\xc0\x29\xda\x41\x2e" +
+		"\x00\x06\x00\x12\xe3\x83\x9e\xe3\x83\x88\xe3\x83\xaa\xe3\x83\x83\xe3\x82\xaf\xe3\x82\xb9\x00\x00" +
+		"\x00\x15\x00\x1c\x66\x2f\x2f\x34\x39\x39\x6b\x39\x35\x34\x64\x36\x4f\x4c\x33\x34\x6f\x4c\x39\x46\x53\x54\x76\x79\x36\x34\x73\x41" +
+		"\x00\x14\x00\x0b\x65\x78\x61\x6d\x70\x6c\x65\x2e\x6f\x72\x67\x00" +
+		"\x00\x08\x00\x14\xf6\x70\x24\x65\x6d\xd6\x4a\x3e\x02\xb8\xe0\x71\x2e\x85\xc9\xa2\x8c\xa8\x96\x66"
+)
+
+// Credentials of RFC 5769 test vectors.
+const (
+	// RFC5769Username is short-term username of RFC5769Request.
+	RFC5769Username = "evtj:h6vY"
+	// RFC5769Password is short-term password of RFC5769Request and
+	// responses.
+	RFC5769Password = "VOkJxbRl1RmTxUk/WvJxBt"
+	// RFC5769LongTermUsername is username of RFC5769RequestLongTerm.
+	RFC5769LongTermUsername = "\u30DE\u30C8\u30EA\u30C3\u30AF\u30B9"
+	// RFC5769LongTermPassword is password of RFC5769RequestLongTerm.
+	RFC5769LongTermPassword = "TheMatrIX"
+	// RFC5769Realm is realm of RFC5769RequestLongTerm.
+	RFC5769Realm = "example.org"
+	// RFC5769Nonce is nonce of RFC5769RequestLongTerm.
+	RFC5769Nonce = "f//499k954d6OL34oL9FSTvy64sA"
+)
+
+// Vector is test vector: raw message and key of its MESSAGE-INTEGRITY.
+type Vector struct {
+	Name        string
+	Raw         []byte
+	Integrity   stun.MessageIntegrity
+	Fingerprint bool // message has FINGERPRINT
+}
+
+// RFC5769 returns test vectors of RFC 5769. Raw messages are copies, so
+// they can be modified.
+func RFC5769() []Vector {
+	shortTerm := stun.NewShortTermIntegrity(RFC5769Password)
+
+	return []Vector{
+		{Name: "Request", Raw: []byte(RFC5769Request), Integrity: shortTerm, Fingerprint: true},
+		{Name: "ResponseIPv4", Raw: []byte(RFC5769ResponseIPv4), Integrity: shortTerm, Fingerprint: true},
+		{Name: "ResponseIPv6", Raw: []byte(RFC5769ResponseIPv6), Integrity: shortTerm, Fingerprint: true},
+		{
+			Name: "RequestLongTerm", Raw: []byte(RFC5769RequestLongTerm),
+			Integrity: stun.NewLongTermIntegrity(RFC5769LongTermUsername, RFC5769Realm, RFC5769LongTermPassword),
+		},
+	}
+}
+
+var errNoFingerprint = errors.New("no FINGERPRINT")
+
+// Check decodes raw message of vector and checks its MESSAGE-INTEGRITY
+// and FINGERPRINT.
+func (v Vector) Check() (*stun.Message, error) {
+	m := new(stun.Message)
+	if err := stun.Decode(v.Raw, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", v.Name, err)
+	}
+	if err := v.Integrity.Check(m); err != nil {
+		return nil, fmt.Errorf("%s: %w", v.Name, err)
+	}
+	if !v.Fingerprint {
+		return m, nil
+	}
+	if !m.Contains(stun.AttrFingerprint) {
+		return nil, fmt.Errorf("%s: %w", v.Name, errNoFingerprint)
+	}
+	if err := stun.Fingerprint.Check(m); err != nil {
+		return nil, fmt.Errorf("%s: %w", v.Name, err)
+	}
+
+	return m, nil
+}
+
+// AssertRoundTrip decodes raw message, encodes its attributes again and
+// reports test error if result is not equal to decoded message. Padding
+// is not compared, because senders can pad attributes with any bytes,
+// e.g. RFC 5769 vectors use spaces. Encoded message is returned.
+func AssertRoundTrip(t testing.TB, raw []byte) *stun.Message {
+	t.Helper()
+	decoded := new(stun.Message)
+	if err := stun.Decode(raw, decoded); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	encoded := stun.New()
+	encoded.Type = decoded.Type
+	encoded.TransactionID = decoded.TransactionID
+	for _, a := range decoded.Attributes {
+		encoded.Add(a.Type, a.Value)
+	}
+	encoded.WriteHeader()
+	again := new(stun.Message)
+	if err := stun.Decode(encoded.Raw, again); err != nil {
+		t.Fatalf("failed to decode encoded message: %v", err)
+	}
+	if len(again.Raw) != len(raw) || !again.EqualCanonical(decoded, stun.EqualOptions{IgnorePadding: true}) {
+		t.Errorf("round trip mismatch:\n%s\n%s", decoded, again)
+	}
+
+	return encoded
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stuntest
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pion/stun/v3"
+
+	"github.com/pion/stun/v3/internal/testutil"
+)
+
+func TestRFC5769(t *testing.T) {
+	testutil.SkipWithoutMD5(t)
+	testutil.SkipWithoutMD5(t)
+	vectors := RFC5769()
+	if len(vectors) != 4 {
+		t.Fatalf("unexpected vectors: %d", len(vectors))
+	}
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			m, err := v.Check()
+			if err != nil {
+				t.Fatal(err)
+			}
+			AssertRoundTrip(t, v.Raw)
+			v.Raw[len(v.Raw)-1]++
+			if _, err := v.Check(); err == nil {
+				t.Error("modified vector should fail")
+			}
+			if m.Type.Class == stun.ClassSuccessResponse {
+				var addr stun.XORMappedAddress
+				if err := addr.GetFrom(m); err != nil || addr.Port != 32853 {
+					t.Errorf("unexpected address %s: %v", addr, err)
+				}
+			}
+		})
+	}
+	if RFC5769()[0].Raw[len(RFC5769Request)-1] != RFC5769Request[len(RFC5769Request)-1] {
+		t.Error("vectors should be copies")
+	}
+	longTerm := RFC5769()[3]
+	m, err := longTerm.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var username stun.Username
+	if err := username.GetFrom(m); err != nil || username.String() != RFC5769LongTermUsername {
+		t.Errorf("unexpected username %q: %v", username, err)
+	}
+	longTerm.Fingerprint = true
+	if _, err := longTerm.Check(); !errors.Is(err, errNoFingerprint) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "binding.stun")
+	m := stun.MustBuild(stun.TransactionID, stun.BindingSuccess,
+		&stun.XORMappedAddress{IP: net.IPv4(192, 0, 2, 1), Port: 3478},
+		stun.Fingerprint,
+	)
+	if err := SaveGolden(path, m); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGolden(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(m) {
+		t.Errorf("%s (loaded) != %s", loaded, m)
+	}
+	AssertGolden(t, path, m)
+	if err := os.WriteFile(path, []byte{1, 2, 3}, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadGolden(path); err == nil {
+		t.Error("invalid golden file should fail")
+	}
+	if _, err := LoadGolden(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := LoadGolden("../testdata/ex1_chrome.stun"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+// Package stuntrace implements stun.TransactionTracer that records client
+// transactions as tracing spans:
+//
+//	client, err := stun.NewClient(conn, stuntrace.WithTracerProvider(tp))
+//
+// Interfaces of package follow OpenTelemetry tracing API, so stun does
+// not depend on it, and OpenTelemetry TracerProvider can be used via
+// thin adapter.
+package stuntrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// TracerName is the name of Tracer requested from TracerProvider.
+const TracerName = "github.com/pion/stun/v3/stuntrace"
+
+// Attribute keys of transaction span.
+const (
+	AttrServerAddress = "server.address"      // string
+	AttrServerPort    = "server.port"         // int
+	AttrMethod        = "stun.method"         // string, e.g. "Binding"
+	AttrTransactionID = "stun.transaction_id" // string, hex encoded
+	AttrResponseClass = "stun.response.class" // string, e.g. "success response"
+	AttrErrorCode     = "stun.error_code"     // int, for error responses
+	AttrRTT           = "stun.rtt_ms"         // float64, if RTT is sampled
+)
+
+// KeyValue is span attribute. Value is string, int or float64.
+type KeyValue struct {
+	Key   string
+	Value any
+}
+
+// TracerProvider provides Tracer by name.
+type TracerProvider interface {
+	Tracer(name string) Tracer
+}
+
+// Tracer starts spans.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is single traced operation.
+type Span interface {
+	SetAttributes(kv ...KeyValue)
+	RecordError(err error)
+	End()
+}
+
+// Option sets tracer option.
+type Option func(t *tracer)
+
+// WithContext sets function that returns parent context for transaction
+// spans, context.Background by default.
+func WithContext(f func() context.Context) Option {
+	return func(t *tracer) {
+		t.ctx = f
+	}
+}
+
+// WithTracerProvider returns client option that records each transaction
+// as span of Tracer from tp, with attributes of server address, method,
+// response code and RTT.
+func WithTracerProvider(tp TracerProvider, options ...Option) stun.ClientOption {
+	return stun.WithTracer(New(tp, options...))
+}
+
+// New returns stun.TransactionTracer that uses Tracer from tp.
+func New(tp TracerProvider, options ...Option) stun.TransactionTracer {
+	t := &tracer{
+		tracer: tp.Tracer(TracerName),
+		ctx:    context.Background,
+	}
+	for _, o := range options {
+		o(t)
+	}
+
+	return t
+}
+
+type tracer struct {
+	tracer Tracer
+	ctx    func() context.Context
+}
+
+func (t *tracer) StartTransaction(m *stun.Message, server net.Addr) func(e stun.Event) {
+	_, span := t.tracer.Start(t.ctx(), "STUN "+m.Type.Method.String())
+	attrs := []KeyValue{
+		{Key: AttrMethod, Value: m.Type.Method.String()},
+		{Key: AttrTransactionID, Value: fmt.Sprintf("%x", m.TransactionID)},
+	}
+	if host, port, ok := splitAddr(server); ok {
+		attrs = append(attrs,
+			KeyValue{Key: AttrServerAddress, Value: host},
+			KeyValue{Key: AttrServerPort, Value: port},
+		)
+	}
+	span.SetAttributes(attrs...)
+
+	return func(e stun.Event) {
+		endSpan(span, e)
+	}
+}
+
+// errErrorResponse is recorded for error responses.
+var errErrorResponse = errors.New("error response")
+
+func endSpan(span Span, e stun.Event) {
+	defer span.End()
+	if e.RTT > 0 {
+		span.SetAttributes(KeyValue{Key: AttrRTT, Value: float64(e.RTT) / float64(time.Millisecond)})
+	}
+	if e.Error != nil {
+		span.RecordError(e.Error)
+
+		return
+	}
+	if e.Message == nil {
+		return
+	}
+	span.SetAttributes(KeyValue{Key: AttrResponseClass, Value: e.Message.Type.Class.String()})
+	if e.Message.Type.Class != stun.ClassErrorResponse {
+		return
+	}
+	var code stun.ErrorCodeAttribute
+	if err := code.GetFrom(e.Message); err != nil {
+		span.RecordError(fmt.Errorf("%w: %v", errErrorResponse, err)) //nolint:errorlint
+
+		return
+	}
+	span.SetAttributes(KeyValue{Key: AttrErrorCode, Value: int(code.Code)})
+	span.RecordError(fmt.Errorf("%w: %s", errErrorResponse, code))
+}
+
+// splitAddr returns host and port of addr.
+func splitAddr(addr net.Addr) (string, int, bool) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.String(), a.Port, true
+	case *net.TCPAddr:
+		return a.IP.String(), a.Port, true
+	default:
+		return "", 0, false
+	}
+}
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+//go:build !js
+// +build !js
+
+package stuntrace
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/pion/stun/v3"
+	"github.com/pion/stun/v3/stunserver"
+)
+
+type testSpan struct {
+	name  string
+	attrs map[string]any
+	errs  []error
+	ended bool
+}
+
+func (s *testSpan) SetAttributes(kv ...KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *testSpan) RecordError(err error) { s.errs = append(s.errs, err) }
+
+func (s *testSpan) End() { s.ended = true }
+
+type testProvider struct {
+	mux   sync.Mutex
+	name  string
+	spans []*testSpan
+}
+
+func (p *testProvider) Tracer(name string) Tracer {
+	p.name = name
+
+	return p
+}
+
+func (p *testProvider) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	s := &testSpan{name: spanName, attrs: make(map[string]any)}
+	p.spans = append(p.spans, s)
+
+	return ctx, s
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	srv := stunserver.New()
+	defer srv.Close() //nolint:errcheck
+	serverConn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServePacket(serverConn) //nolint:errcheck
+	conn, err := net.Dial("udp4", serverConn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := new(testProvider)
+	client, err := stun.NewClient(conn, WithTracerProvider(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close() //nolint:errcheck
+	for _, m := range []*stun.Message{
+		stun.MustBuild(stun.TransactionID, stun.BindingRequest),
+		stun.MustBuild(stun.TransactionID, stun.NewType(stun.MethodAllocate, stun.ClassRequest)),
+	} {
+		if err = client.Do(m, func(stun.Event) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	provider.mux.Lock()
+	defer provider.mux.Unlock()
+	if provider.name != TracerName {
+		t.Errorf("unexpected tracer name: %s", provider.name)
+	}
+	if len(provider.spans) != 2 {
+		t.Fatalf("unexpected spans count: %d", len(provider.spans))
+	}
+	port := serverConn.LocalAddr().(*net.UDPAddr).Port //nolint:forcetypeassert
+	success, failure := provider.spans[0], provider.spans[1]
+	if success.name != "STUN Binding" || !success.ended || len(success.errs) != 0 {
+		t.Errorf("unexpected success span: %+v", success)
+	}
+	for key, value := range map[string]any{
+		AttrMethod:        "Binding",
+		AttrServerAddress: "127.0.0.1",
+		AttrServerPort:    port,
+		AttrResponseClass: "success response",
+	} {
+		if success.attrs[key] != value {
+			t.Errorf("unexpected %s: %v", key, success.attrs[key])
+		}
+	}
+	if _, ok := success.attrs[AttrRTT].(float64); !ok {
+		t.Errorf("unexpected %s: %v", AttrRTT, success.attrs[AttrRTT])
+	}
+	if !failure.ended || failure.attrs[AttrErrorCode] != int(stun.CodeBadRequest) {
+		t.Errorf("unexpected failure span: %+v", failure)
+	}
+	if len(failure.errs) != 1 || !errors.Is(failure.errs[0], errErrorResponse) {
+		t.Errorf("unexpected failure span errors: %v", failure.errs)
+	}
+}
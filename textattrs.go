@@ -3,6 +3,12 @@
 
 package stun
 
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
 // NewUsername returns Username with provided value.
 func NewUsername(username string) Username {
 	return Username(username)
@@ -77,6 +83,41 @@ func (s Software) AddTo(m *Message) error {
 	return TextAttribute(s).AddToAs(m, AttrSoftware, softwareRawMaxB)
 }
 
+// ErrMessageProtected means that message can't be modified, because it
+// is protected by MESSAGE-INTEGRITY or MESSAGE-INTEGRITY-SHA256.
+var ErrMessageProtected = errors.New("message is protected by integrity")
+
+// StampSoftware sets SOFTWARE of m to software, replacing existing
+// attribute, or removes SOFTWARE if software is empty. FINGERPRINT is
+// added again after SOFTWARE if present. Returns ErrMessageProtected if
+// m has integrity attribute, because it can't be recomputed without key.
+func StampSoftware(m *Message, software Software) error {
+	if m.Contains(AttrMessageIntegrity) || m.Contains(AttrMessageIntegritySHA256) {
+		return ErrMessageProtected
+	}
+	if err := CheckOverflow(AttrSoftware, len(software), softwareRawMaxB); err != nil {
+		return err
+	}
+	fingerprint := m.Contains(AttrFingerprint)
+	if fingerprint {
+		_ = m.Delete(AttrFingerprint)
+	}
+	var err error
+	switch {
+	case len(software) == 0:
+		_ = m.Delete(AttrSoftware)
+	case m.Contains(AttrSoftware):
+		err = m.Replace(AttrSoftware, software)
+	default:
+		err = software.AddTo(m)
+	}
+	if err == nil && fingerprint {
+		err = Fingerprint.AddTo(m)
+	}
+
+	return err
+}
+
 // GetFrom decodes Software from m.
 func (s *Software) GetFrom(m *Message) error {
 	return (*TextAttribute)(s).GetFromAs(m, AttrSoftware)
@@ -114,6 +155,9 @@ type TextAttribute []byte
 // AddToAs adds attribute with type t to m, checking maximum length. If maxLen
 // is less than 0, no check is performed.
 func (v TextAttribute) AddToAs(m *Message, t AttrType, maxLen int) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
 	if err := CheckOverflow(t, len(v), maxLen); err != nil {
 		return err
 	}
@@ -132,3 +176,100 @@ func (v *TextAttribute) GetFromAs(m *Message, t AttrType) error {
 
 	return nil
 }
+
+// Limits of text attributes in RFC 8489 Section 14.
+const (
+	maxUsernameStrictB = 508 // fewer than 509 bytes
+	maxTextStrictB     = 763 // fewer than 128 characters
+)
+
+// ErrInvalidUTF8 means that text attribute is not valid UTF-8.
+var ErrInvalidUTF8 = errors.New("invalid UTF-8")
+
+// ErrInvalidQuotedText means that REALM or NONCE value is not a sequence
+// of qdtext or quoted-pair (RFC 3261).
+var ErrInvalidQuotedText = errors.New("invalid qdtext character")
+
+// ValidateText checks value v of text attribute t against RFC 8489
+// limits: USERNAME is fewer than 509 bytes, REALM, NONCE and SOFTWARE
+// are at most 763 bytes, all are valid UTF-8, and REALM and NONCE consist
+// of qdtext or quoted-pair. Other attribute types are not checked.
+func ValidateText(t AttrType, v []byte) error {
+	maxLen := maxTextStrictB
+	switch t {
+	case AttrUsername:
+		maxLen = maxUsernameStrictB
+	case AttrRealm, AttrNonce, AttrSoftware:
+	default:
+		return nil
+	}
+	if err := CheckOverflow(t, len(v), maxLen); err != nil {
+		return err
+	}
+	if !utf8.Valid(v) {
+		return fmt.Errorf("%w: %s", ErrInvalidUTF8, t)
+	}
+	if t != AttrRealm && t != AttrNonce {
+		return nil
+	}
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '\\':
+			// quoted-pair = "\\" (%x00-09 / %x0B-0C / %x0E-7F)
+			i++
+			if i == len(v) || v[i] == '\n' || v[i] == '\r' || v[i] > 0x7F {
+				return fmt.Errorf("%w: %s has bad quoted-pair at %d", ErrInvalidQuotedText, t, i-1)
+			}
+		case c == '"', c < 0x20 && c != '\t' && c != '\r' && c != '\n', c == 0x7F:
+			return fmt.Errorf("%w: %s has 0x%02x at %d", ErrInvalidQuotedText, t, c, i)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks u with ValidateText.
+func (u Username) Validate() error {
+	return ValidateText(AttrUsername, u)
+}
+
+// Validate checks n with ValidateText.
+func (n Realm) Validate() error {
+	return ValidateText(AttrRealm, n)
+}
+
+// Validate checks n with ValidateText.
+func (n Nonce) Validate() error {
+	return ValidateText(AttrNonce, n)
+}
+
+// Validate checks s with ValidateText.
+func (s Software) Validate() error {
+	return ValidateText(AttrSoftware, s)
+}
+
+type textValidator struct{}
+
+// ValidText checks all text attributes of message with ValidateText. It
+// is Checker for received messages, and Setter that fails Build if
+// passed after other setters:
+//
+//	m, err := Build(TransactionID, BindingRequest, username, ValidText)
+//	err = m.Check(ValidText)
+var ValidText textValidator //nolint:gochecknoglobals
+
+// Check validates text attributes of m.
+func (textValidator) Check(m *Message) error {
+	for _, a := range m.Attributes {
+		if err := ValidateText(a.Type, a.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddTo validates text attributes that are already added to m.
+func (v textValidator) AddTo(m *Message) error {
+	return v.Check(m)
+}
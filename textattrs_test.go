@@ -7,9 +7,12 @@
 package stun
 
 import (
+	"bytes"
 	"errors"
 	"strings"
 	"testing"
+
+	"github.com/pion/stun/v3/internal/testutil"
 )
 
 func TestSoftware_GetFrom(t *testing.T) {
@@ -124,14 +127,12 @@ func TestUsername(t *testing.T) {
 		m := new(Message)
 		m.WriteHeader()
 		u := NewUsername("username")
-		if allocs := testing.AllocsPerRun(10, func() {
+		testutil.ShouldNotAllocate(t, func() {
 			if err := u.AddTo(m); err != nil {
 				t.Error(err)
 			}
 			m.Reset()
-		}); allocs > 0 {
-			t.Errorf("got %f allocations, zero expected", allocs)
-		}
+		})
 	})
 }
 
@@ -268,3 +269,116 @@ func BenchmarkNonce_GetFrom(b *testing.B) {
 		n.GetFrom(m) //nolint:errcheck,gosec
 	}
 }
+
+func TestValidateText(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		t     AttrType
+		value string
+		err   error
+	}{
+		{"Username", AttrUsername, "user:name", nil},
+		{"UsernameQuote", AttrUsername, "a\"b", nil},
+		{"UsernameMax", AttrUsername, strings.Repeat("a", 508), nil},
+		{"UsernameTooLong", AttrUsername, strings.Repeat("a", 509), ErrAttributeSizeOverflow},
+		{"RealmMax", AttrRealm, strings.Repeat("a", 763), nil},
+		{"RealmTooLong", AttrRealm, strings.Repeat("a", 764), ErrAttributeSizeOverflow},
+		{"SoftwareUTF8", AttrSoftware, "клиент v1", nil},
+		{"SoftwareBadUTF8", AttrSoftware, "a\xffb", ErrInvalidUTF8},
+		{"NonceQuotedPair", AttrNonce, `a\"b`, nil},
+		{"NonceLWS", AttrNonce, "a b\tc", nil},
+		{"NonceQuote", AttrNonce, `a"b`, ErrInvalidQuotedText},
+		{"NonceControl", AttrNonce, "a\x01b", ErrInvalidQuotedText},
+		{"NonceTrailingBackslash", AttrNonce, `ab\`, ErrInvalidQuotedText},
+		{"RealmUTF8", AttrRealm, "пример.рф", nil},
+		{"Other", AttrData, "\xff\"", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateText(tc.t, []byte(tc.value))
+			switch {
+			case tc.err == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.err == ErrAttributeSizeOverflow:
+				if !IsAttrSizeOverflow(err) {
+					t.Fatalf("expected overflow, got %v", err)
+				}
+			case tc.err != nil && !errors.Is(err, tc.err):
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+	if err := Nonce("a\"b").Validate(); !errors.Is(err, ErrInvalidQuotedText) {
+		t.Errorf("unexpected nonce error: %v", err)
+	}
+	if err := NewUsername("user").Validate(); err != nil {
+		t.Errorf("unexpected username error: %v", err)
+	}
+}
+
+func TestValidText(t *testing.T) {
+	if _, err := Build(BindingRequest, NewRealm("realm"), NewSoftware("software"), ValidText); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Build(BindingRequest, NewNonce(`bad"nonce`), ValidText); !errors.Is(err, ErrInvalidQuotedText) {
+		t.Errorf("unexpected build error: %v", err)
+	}
+	msg := MustBuild(BindingRequest, NewUsername("a\xffb"))
+	if err := msg.Check(ValidText); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("unexpected check error: %v", err)
+	}
+	msg = MustBuild(BindingRequest, NewUsername("user"))
+	if err := msg.Check(ValidText); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStampSoftware(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		setters  []Setter
+		software string
+		expected string
+	}{
+		{"Add", nil, "app", "app"},
+		{"Replace", []Setter{NewSoftware("old version")}, "app", "app"},
+		{"Strip", []Setter{NewSoftware("old")}, "", ""},
+		{"Fingerprint", []Setter{NewUsername("user"), Fingerprint}, "app", "app"},
+		{"ReplaceFingerprint", []Setter{NewSoftware("old"), Fingerprint}, "application", "application"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := MustBuild(append([]Setter{TransactionID, BindingRequest}, tc.setters...)...)
+			fingerprint := msg.Contains(AttrFingerprint)
+			if err := StampSoftware(msg, NewSoftware(tc.software)); err != nil {
+				t.Fatal(err)
+			}
+			decoded := new(Message)
+			if err := Decode(msg.Raw, decoded); err != nil {
+				t.Fatal(err)
+			}
+			var software Software
+			if err := software.GetFrom(decoded); tc.expected == "" && !errors.Is(err, ErrAttributeNotFound) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if software.String() != tc.expected {
+				t.Errorf("unexpected software %q", software)
+			}
+			if !fingerprint {
+				return
+			}
+			if err := Fingerprint.Check(decoded); err != nil {
+				t.Error(err)
+			}
+			if last := decoded.Attributes[len(decoded.Attributes)-1].Type; last != AttrFingerprint {
+				t.Errorf("unexpected last attribute %s", last)
+			}
+		})
+	}
+	msg := MustBuild(TransactionID, BindingRequest, NewShortTermIntegrity("pwd"))
+	raw := append([]byte(nil), msg.Raw...)
+	if err := StampSoftware(msg, NewSoftware("app")); !errors.Is(err, ErrMessageProtected) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(raw, msg.Raw) {
+		t.Error("protected message should not be modified")
+	}
+}
@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"io"
+	"sync"
+)
+
+// Message transports.
+//
+// Client accepts any Connection, where each Read returns single message
+// and each Write sends single message, e.g. WebSocket connection to UDP
+// relay. TransportConn adapts transports that deliver messages with
+// callbacks, like WebSocket or data channel of js/wasm, so codec, agent
+// and client can be used without net sockets.
+
+// defaultTransportQueue is count of delivered messages that are buffered
+// by TransportConn until read.
+const defaultTransportQueue = 64
+
+// TransportConn is Connection of message-oriented transport, which sends
+// messages with function and receives messages passed to Deliver.
+// Like UDP socket, it drops messages that are delivered when its queue
+// is full, relying on retransmissions.
+//
+// Safe for concurrent use.
+type TransportConn struct {
+	send func(b []byte) error
+
+	mux     sync.Mutex
+	cond    *sync.Cond
+	queue   [][]byte
+	limit   int
+	dropped int
+	closed  bool
+}
+
+// NewTransportConn returns TransportConn that sends each written message
+// with send, e.g. wrapping WebSocket.send of js/wasm. Buffer passed to
+// send must not be retained.
+func NewTransportConn(send func(b []byte) error) *TransportConn {
+	c := &TransportConn{send: send, limit: defaultTransportQueue}
+	c.cond = sync.NewCond(&c.mux)
+
+	return c
+}
+
+// Deliver passes received message b to reader, e.g. from onmessage
+// callback of WebSocket. Message b is copied, so buffer can be reused.
+// Returns io.ErrClosedPipe if c is closed.
+func (c *TransportConn) Deliver(b []byte) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return io.ErrClosedPipe
+	}
+	if len(c.queue) >= c.limit {
+		c.dropped++
+
+		return nil
+	}
+	c.queue = append(c.queue, append([]byte(nil), b...))
+	c.cond.Signal()
+
+	return nil
+}
+
+// Dropped returns count of delivered messages that were dropped because
+// queue was full.
+func (c *TransportConn) Dropped() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.dropped
+}
+
+// Read reads single delivered message into b, blocking until message is
+// delivered or c is closed. Returns io.ErrShortBuffer if message does not
+// fit b, and io.EOF if c is closed.
+func (c *TransportConn) Read(b []byte) (int, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for len(c.queue) == 0 && !c.closed {
+		c.cond.Wait()
+	}
+	if len(c.queue) == 0 {
+		return 0, io.EOF
+	}
+	msg := c.queue[0]
+	c.queue[0] = nil
+	c.queue = c.queue[1:]
+	if len(msg) > len(b) {
+		return 0, io.ErrShortBuffer
+	}
+
+	return copy(b, msg), nil
+}
+
+// Write sends b as single message.
+func (c *TransportConn) Write(b []byte) (int, error) {
+	c.mux.Lock()
+	closed := c.closed
+	c.mux.Unlock()
+	if closed {
+		return 0, io.ErrClosedPipe
+	}
+	if err := c.send(b); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// Close closes c, unblocking Read. Underlying transport is not closed.
+func (c *TransportConn) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return io.ErrClosedPipe
+	}
+	c.closed = true
+	c.queue = nil
+	c.cond.Broadcast()
+
+	return nil
+}
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2023 The Pion community <https://pion.ly>
+// SPDX-License-Identifier: MIT
+
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestTransportConn(t *testing.T) {
+	var sent [][]byte
+	c := NewTransportConn(func(b []byte) error {
+		sent = append(sent, append([]byte(nil), b...))
+
+		return nil
+	})
+	if n, err := c.Write([]byte("request")); err != nil || n != 7 {
+		t.Fatalf("unexpected write: %d, %v", n, err)
+	}
+	if len(sent) != 1 || string(sent[0]) != "request" {
+		t.Errorf("unexpected sent messages: %q", sent)
+	}
+	buf := []byte("first")
+	if err := c.Deliver(buf); err != nil {
+		t.Fatal(err)
+	}
+	copy(buf, "reuse")
+	if err := c.Deliver([]byte("second message")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 10)
+	if n, err := c.Read(b); err != nil || string(b[:n]) != "first" {
+		t.Errorf("unexpected read: %q, %v", b[:n], err)
+	}
+	if _, err := c.Read(b); !errors.Is(err, io.ErrShortBuffer) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for i := 0; i < defaultTransportQueue+1; i++ {
+		if err := c.Deliver([]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Dropped() != 1 {
+		t.Errorf("unexpected dropped count: %d", c.Dropped())
+	}
+	for i := 0; i < defaultTransportQueue; i++ {
+		if n, err := c.Read(b); err != nil || n != 1 || b[0] != byte(i) {
+			t.Fatalf("unexpected read: %v, %v", b[:n], err)
+		}
+	}
+	done := make(chan error)
+	go func() {
+		_, err := c.Read(b)
+		done <- err
+	}()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; !errors.Is(err, io.EOF) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := c.Close(); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := c.Deliver(buf); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := c.Write(buf); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_TransportConn(t *testing.T) {
+	mapped := &XORMappedAddress{IP: net.IPv4(192, 0, 2, 1), Port: 3478}
+	var conn *TransportConn
+	conn = NewTransportConn(func(b []byte) error {
+		req := new(Message)
+		if err := Decode(b, req); err != nil {
+			return err
+		}
+
+		return conn.Deliver(MustBuild(req, BindingSuccess, mapped).Raw)
+	})
+	c, err := NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() //nolint:errcheck
+	if err = c.Do(MustBuild(TransactionID, BindingRequest), func(e Event) {
+		var addr XORMappedAddress
+		if e.Error != nil {
+			t.Error(e.Error)
+		} else if err := addr.GetFrom(e.Message); err != nil {
+			t.Error(err)
+		} else if !bytes.Equal(addr.IP, mapped.IP.To4()) || addr.Port != mapped.Port {
+			t.Errorf("unexpected address: %s", addr)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
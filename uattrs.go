@@ -31,6 +31,9 @@ const attrTypeSize = 4
 
 // AddTo adds UNKNOWN-ATTRIBUTES attribute to message.
 func (a UnknownAttributes) AddTo(m *Message) error {
+	if err := m.checkMutable(); err != nil {
+		return err
+	}
 	v := make([]byte, 0, attrTypeSize*20) // 20 should be enough
 	// If len(a.Types) > 20, there will be allocations.
 	for i, t := range a {
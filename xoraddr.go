@@ -50,8 +50,61 @@ func isZeros(p net.IP) bool {
 // ErrBadIPLength means that len(IP) is not net.{IPv6len,IPv4len}.
 var ErrBadIPLength = errors.New("invalid length of IP value")
 
+// ErrBadAddressFamily means that address attribute has unknown family.
+var ErrBadAddressFamily = errors.New("invalid address family")
+
+// addressLength validates family and length of address attribute value
+// of type attr, returning length of its IP.
+func addressLength(attr AttrType, value []byte) (int, error) {
+	if len(value) <= 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var ipLen int
+	switch family := bin.Uint16(value[0:2]); family {
+	case familyIPv4:
+		ipLen = net.IPv4len
+	case familyIPv6:
+		ipLen = net.IPv6len
+	default:
+		return 0, fmt.Errorf("%w %d of %s", ErrBadAddressFamily, family, attr)
+	}
+	if err := CheckOverflow(attr, len(value[4:]), ipLen); err != nil {
+		return 0, err
+	}
+	if err := CheckSize(attr, len(value[4:]), ipLen); err != nil {
+		return 0, err
+	}
+
+	return ipLen, nil
+}
+
+// resizeIP returns ip of length n, reusing its buffer if possible.
+func resizeIP(ip net.IP, n int) net.IP {
+	if cap(ip) < n {
+		return make(net.IP, n)
+	}
+
+	return ip[:n]
+}
+
+// normalizeIP returns IPv4-mapped IPv6 address ip as IPv4 address of
+// net.IPv4len, so IPv4 addresses are decoded the same way regardless of
+// family of attribute.
+func normalizeIP(ip net.IP) net.IP {
+	if len(ip) == net.IPv6len && isIPv4(ip) {
+		copy(ip, ip[12:16])
+
+		return ip[:net.IPv4len]
+	}
+
+	return ip
+}
+
 // AddToAs adds XOR-MAPPED-ADDRESS value to msg as attr attribute.
 func (a XORMappedAddress) AddToAs(msg *Message, attr AttrType) error {
+	if err := msg.checkMutable(); err != nil {
+		return err
+	}
 	var (
 		family = familyIPv4
 		ip     = a.IP
@@ -91,38 +144,17 @@ func (a *XORMappedAddress) GetFromAs(msg *Message, attr AttrType) error {
 	if err != nil {
 		return err
 	}
-	family := bin.Uint16(value[0:2])
-	if family != familyIPv6 && family != familyIPv4 {
-		return newDecodeErr("xor-mapped address", "family",
-			fmt.Sprintf("bad value %d", family),
-		)
-	}
-	ipLen := net.IPv4len
-	if family == familyIPv6 {
-		ipLen = net.IPv6len
-	}
-	// Ensuring len(a.IP) == ipLen and reusing a.IP.
-	if len(a.IP) < ipLen {
-		a.IP = a.IP[:cap(a.IP)]
-		for len(a.IP) < ipLen {
-			a.IP = append(a.IP, 0)
-		}
-	}
-	a.IP = a.IP[:ipLen]
-	for i := range a.IP {
-		a.IP[i] = 0
-	}
-	if len(value) <= 4 {
-		return io.ErrUnexpectedEOF
-	}
-	if err := CheckOverflow(attr, len(value[4:]), len(a.IP)); err != nil {
+	ipLen, err := addressLength(attr, value)
+	if err != nil {
 		return err
 	}
+	a.IP = resizeIP(a.IP, ipLen)
 	a.Port = int(bin.Uint16(value[2:4])) ^ (magicCookie >> 16)
 	xorValue := make([]byte, 4+TransactionIDSize)
 	bin.PutUint32(xorValue[0:4], magicCookie)
 	copy(xorValue[4:], msg.TransactionID[:])
 	xor.XorBytes(a.IP, value[4:], xorValue)
+	a.IP = normalizeIP(a.IP)
 
 	return nil
 }
@@ -228,3 +228,52 @@ func TestXORMappedAddress_String(t *testing.T) {
 		}
 	}
 }
+
+func TestXORMappedAddress_GetFrom_Family(t *testing.T) {
+	m := MustBuild(TransactionID, BindingSuccess)
+	xorValue := func(family uint16, ip net.IP) []byte {
+		value := make([]byte, 4+len(ip))
+		bin.PutUint16(value[0:2], family)
+		bin.PutUint16(value[2:4], 1234^(magicCookie>>16))
+		key := make([]byte, 4+TransactionIDSize)
+		bin.PutUint32(key[0:4], magicCookie)
+		copy(key[4:], m.TransactionID[:])
+		for i := range ip {
+			value[4+i] = ip[i] ^ key[i]
+		}
+
+		return value
+	}
+	for _, tc := range []struct {
+		name  string
+		value []byte
+		check func(error) bool
+	}{
+		{"BadFamily", xorValue(3, net.IPv4(1, 2, 3, 4).To4()), func(err error) bool {
+			return errors.Is(err, ErrBadAddressFamily)
+		}},
+		{"IPv6Short", xorValue(familyIPv6, net.IPv4(1, 2, 3, 4).To4()), IsAttrSizeInvalid},
+		{"IPv4Long", xorValue(familyIPv4, net.IPv6loopback), IsAttrSizeOverflow},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			msg := MustBuild(m)
+			msg.Add(AttrXORMappedAddress, tc.value)
+			var addr XORMappedAddress
+			if err := addr.GetFrom(msg); !tc.check(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+	t.Run("IPv4Mapped", func(t *testing.T) {
+		msg := MustBuild(m)
+		msg.Add(AttrXORMappedAddress, xorValue(familyIPv6, net.IPv4(192, 0, 2, 1)))
+		addr := XORMappedAddress{IP: make(net.IP, net.IPv6len)}
+		if err := addr.GetFrom(msg); err != nil {
+			t.Fatal(err)
+		}
+		if len(addr.IP) != net.IPv4len || !addr.IP.Equal(net.IPv4(192, 0, 2, 1)) || addr.Port != 1234 {
+			t.Errorf("unexpected address: %s (%d bytes)", addr, len(addr.IP))
+		}
+	})
+}